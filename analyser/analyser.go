@@ -1,22 +1,41 @@
 package analyser
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/orktes/orlang/ast"
 	"github.com/orktes/orlang/scanner"
 	"github.com/orktes/orlang/types"
 )
 
 type Analyser struct {
-	main                     *ast.File
+	files                    []*ast.File
+	imports                  map[*ast.Import]*ast.File
+	current                  *ast.File
 	scope                    *Scope
 	Error                    func(node ast.Node, msg string, fatal bool)
 	AutoCompleteInfoCallback func([]AutoCompleteInfo)
 }
 
 func New(file *ast.File) (analyser *Analyser, err error) {
+	return NewProgram([]*ast.File{file}, nil)
+}
+
+// NewProgram returns an analyser for a program consisting of several files.
+// Files are analysed in order so imported files must come before the files
+// importing them. The last file is the main file. Imports maps import
+// declarations to the files they refer to
+func NewProgram(files []*ast.File, imports map[*ast.Import]*ast.File) (analyser *Analyser, err error) {
+	if len(files) == 0 {
+		return nil, errors.New("no files to analyse")
+	}
+
 	analyser = &Analyser{
-		main:  file,
-		scope: NewScope(file),
+		files:   files,
+		imports: imports,
+		// Externals are shared by all files
+		scope: NewScope(files[len(files)-1]),
 	}
 	return
 }
@@ -34,27 +53,135 @@ func (analyser *Analyser) AddExternalFunc(name string, typ types.Type) {
 }
 
 func (analyser *Analyser) Analyse() (info *Info, err error) {
-	fileInfo := NewFileInfo()
-
 	info = &Info{
 		FileInfo: map[*ast.File]*FileInfo{},
 	}
 
-	info.FileInfo[analyser.main] = fileInfo
+	scopes := map[*ast.File]*Scope{}
+	prog := newProgram(analyser, analyser.emitError)
+
+	for _, file := range analyser.files {
+		prog.enterFile(file)
+		fileInfo := NewFileInfo()
+		info.FileInfo[file] = fileInfo
 
-	visitor := &visitor{
-		scope:          analyser.scope,
-		node:           analyser.main,
-		info:           fileInfo,
-		autocompleteCb: analyser.AutoCompleteInfoCallback,
-		errorCb: func(node ast.Node, err string, fatal bool) {
-			if analyser.Error != nil {
-				analyser.Error(node, err, fatal)
+		scope := analyser.scope.SubScope(file)
+		scopes[file] = scope
+
+		for _, importDecl := range file.Imports {
+			dep, ok := analyser.imports[importDecl]
+			if !ok || info.FileInfo[dep] == nil {
+				analyser.emitError(importDecl, fmt.Sprintf("could not resolve import %q", importDecl.Path), true)
+				continue
 			}
-		},
+			analyser.importFile(importDecl, scope, fileInfo, scopes[dep], info.FileInfo[dep], dep)
+		}
+
+		prog.addFile(file, scope, fileInfo)
+
+		visitor := &visitor{
+			scope:          scope,
+			node:           file,
+			info:           fileInfo,
+			autocompleteCb: analyser.AutoCompleteInfoCallback,
+			errorCb:        prog.emitError,
+			program:        prog,
+		}
+
+		ast.Walk(visitor, file)
+		analyseEscapes(file, fileInfo)
+	}
+
+	// Instances are created while analysing the files using them
+	for _, file := range analyser.files {
+		fileInfo := info.FileInfo[file]
+		for _, instance := range fileInfo.Instances {
+			analyseEscapes(instance, fileInfo)
+		}
 	}
 
-	ast.Walk(visitor, analyser.main)
+	return
+}
+
+// CurrentFile returns the file being analysed. Error callbacks can use it to
+// find the file of the reported node
+func (analyser *Analyser) CurrentFile() *ast.File {
+	return analyser.current
+}
+
+func (analyser *Analyser) emitError(node ast.Node, err string, fatal bool) {
+	if analyser.Error != nil {
+		analyser.Error(node, err, fatal)
+	}
+}
+
+// importFile makes the exported declarations of dep visible in scope
+func (analyser *Analyser) importFile(importDecl *ast.Import, scope *Scope, fileInfo *FileInfo, depScope *Scope, depInfo *FileInfo, dep *ast.File) {
+	for _, node := range dep.Exports {
+		switch n := node.(type) {
+		case *ast.Struct, *ast.Interface, *ast.Enum:
+			if name := ExportedNames(n); len(name) > 0 {
+				fileInfo.Types[name[0]] = n
+				fileInfo.NodeInfo[n] = depInfo.nodeInfo(n)
+			}
+			continue
+		}
+
+		for _, name := range ExportedNames(node) {
+			details := depScope.GetDetails(name, false)
+			if details == nil {
+				continue
+			}
+
+			if existing := scope.GetDetails(name, false); existing != nil && existing.ScopeItem != details.ScopeItem {
+				analyser.emitError(importDecl, fmt.Sprintf("%s redeclared by import %q", name, importDecl.Path), true)
+				continue
+			}
+
+			scope.SetWithName(name, details.DefineIdentifier, details.ScopeItem)
+			fileInfo.NodeInfo[details.ScopeItem] = depInfo.nodeInfo(details.ScopeItem)
+
+			// Unused imports are not reported
+			scope.MarkUsage(details.ScopeItem, &ast.Identifier{Token: scanner.Token{Text: name}})
+		}
+	}
+}
+
+// ExportedNames returns the names a top level declaration defines
+func ExportedNames(node ast.Node) (names []string) {
+	switch n := node.(type) {
+	case *ast.FunctionDeclaration:
+		if n.Signature.Identifier != nil {
+			names = append(names, n.Signature.Identifier.Text)
+		}
+	case *ast.VariableDeclaration:
+		names = append(names, n.Name.Text)
+	case *ast.TupleDeclaration:
+		var collect func(pattern *ast.TuplePattern)
+		collect = func(pattern *ast.TuplePattern) {
+			for _, pat := range pattern.Patterns {
+				switch p := pat.(type) {
+				case *ast.Identifier:
+					names = append(names, p.Text)
+				case *ast.TuplePattern:
+					collect(p)
+				}
+			}
+		}
+		collect(n.Pattern)
+	case *ast.Struct:
+		if n.Name != nil {
+			names = append(names, n.Name.Text)
+		}
+	case *ast.Interface:
+		if n.Name != nil {
+			names = append(names, n.Name.Text)
+		}
+	case *ast.Enum:
+		if n.Name != nil {
+			names = append(names, n.Name.Text)
+		}
+	}
 	return
 }
 
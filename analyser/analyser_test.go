@@ -1,11 +1,13 @@
 package analyser
 
 import (
+	"sort"
 	"strings"
 	"testing"
 
 	"github.com/orktes/orlang/ast"
 	"github.com/orktes/orlang/parser"
+	"github.com/orktes/orlang/types"
 )
 
 func TestClosureAnalysis(t *testing.T) {
@@ -69,3 +71,455 @@ func TestClosureAnalysis(t *testing.T) {
 	check(closures[1], []string{"b", "c"})
 	check(closures[2], []string{"c"})
 }
+
+func TestImports(t *testing.T) {
+	parse := func(src string) *ast.File {
+		file, err := parser.Parse(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	lib := parse(`
+	export struct Point {
+		var x : int32
+		var y : int32
+	}
+
+	export fn add(a : int32, b : int32) => int32 {
+		return a + b
+	}
+
+	export var origin = Point{x: 0, y: 0}
+
+	fn hidden() => int32 {
+		return 1
+	}
+
+	enum Shape {
+		Circle(int32),
+		Empty
+	}
+
+	export fn area(s : Shape) => int32 {
+		return match s {
+			Circle(r) => r * r,
+			Empty => 0
+		}
+	}
+
+	export fn circle(r : int32) => Shape {
+		return Shape.Circle(r)
+	}
+	`)
+
+	tests := []struct {
+		src    string
+		errors []string
+	}{
+		{`
+		import "lib"
+		fn main() {
+			var a = area(circle(1))
+		}
+		`, nil},
+		{`
+		import "lib"
+		enum Shape {
+			Square(string),
+			None
+		}
+		fn main() {
+			var a = area(Shape.Square("a"))
+		}
+		`, []string{`cannot use Shape.Square("a") (type enum Shape) as type enum Shape in function call`}},
+		{`
+		import "lib"
+		fn main() {
+			var p = Point{x: add(1, 2), y: origin.y}
+			p.x = 1
+		}
+		`, nil},
+		{`
+		import "lib"
+		fn main() {
+			hidden()
+		}
+		`, []string{"hidden (type unknown (undefined)) is not a function", "undefined: hidden"}},
+		{`
+		import "lib"
+		fn add(a : int32, b : int32) => int32 {
+			return a - b
+		}
+		`, []string{"add already declared"}},
+		{`
+		import "missing"
+		fn main() {}
+		`, []string{`could not resolve import "missing"`}},
+	}
+
+	for _, test := range tests {
+		main := parse(test.src)
+		imports := map[*ast.Import]*ast.File{}
+		for _, importDecl := range main.Imports {
+			if importDecl.Path == "lib" {
+				imports[importDecl] = lib
+			}
+		}
+
+		analyser, err := NewProgram([]*ast.File{lib, main}, imports)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		errors := []string{}
+		analyser.Error = func(node ast.Node, msg string, fatal bool) {
+			if fatal {
+				errors = append(errors, msg)
+			}
+		}
+
+		info, err := analyser.Analyse()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Join(errors, "\n") != strings.Join(test.errors, "\n") {
+			t.Errorf("Wrong errors for %s: expected %q got %q", test.src, test.errors, errors)
+		}
+
+		if len(test.errors) == 0 && info.FileInfo[main].Types["Point"] == nil {
+			t.Error("Exported struct Point not visible in importing file")
+		}
+	}
+}
+
+func TestEscapeAnalysis(t *testing.T) {
+	tests := []struct {
+		src     string
+		escapes []string
+	}{
+		{`
+		fn main() {
+			var a = 0
+			var f = fn() {
+				a++
+			}
+			f()
+		}
+		`, nil},
+		{`
+		fn main() {
+			var a = 0
+			fn() {
+				a++
+			}()
+		}
+		`, nil},
+		{`
+		fn main() {
+			var f = fn() {
+				if true {
+					var a = 0
+					if true {
+						a++
+					}
+				}
+			}
+			f()
+		}
+		`, nil},
+		{`
+		fn counter() => (int32) => int32 {
+			var a = 0
+			var b = 0
+			b++
+			return fn(step : int32) => int32 {
+				a = a + step
+				return a
+			}
+		}
+		`, []string{"a"}},
+		{`
+		fn counter() => (int32) => int32 {
+			var a = 0
+			fn next(step : int32) => int32 {
+				a = a + step
+				return a
+			}
+			var f = next
+			return f
+		}
+		`, []string{"a"}},
+		{`
+		var global = 0
+		fn main() {
+			var a = 0
+			var b = 0
+			var p = &a
+			*p = 1
+			var q = &b
+			print(num_to_str(*q))
+			global = *p
+		}
+		`, nil},
+		{`
+		fn foo() => *int32 {
+			var a = 0
+			var b = 1
+			var p = &a
+			var q = p
+			var r = &b
+			*r = 2
+			return q
+		}
+		`, []string{"a"}},
+		{`
+		var global : *int32
+		fn main() {
+			var a = 0
+			var b = 0
+			var c = 0
+			global = &a
+			var (x, y) = (&b, 1)
+			global = x
+			print(num_to_str(y))
+			var arr = []*int32{&c}
+			print(num_to_str(*arr[0]))
+		}
+		`, []string{"a", "b", "c"}},
+		{`
+		fn main() {
+			var a = 0
+			var p = &a
+			var f = fn() {
+				var b = 0
+				p = &b
+			}
+			f()
+			print(num_to_str(*p))
+		}
+		`, []string{"b"}},
+		{`
+		fn main() {
+			var a = 0
+			var p = &a
+			var f = fn() => *int32 {
+				return p
+			}
+			f()
+		}
+		`, []string{"a"}},
+	}
+
+	for _, test := range tests {
+		file, err := parser.Parse(strings.NewReader(test.src))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		analyser, _ := New(file)
+		analyser.AddExternalFunc("print", &types.SignatureType{ArgumentTypes: []types.Type{types.StringType}, ReturnType: types.VoidType})
+		analyser.AddExternalFunc("num_to_str", &types.SignatureType{ArgumentTypes: []types.Type{types.Int32Type}, ReturnType: types.StringType})
+		analyser.Error = func(node ast.Node, msg string, fatal bool) {
+			if fatal {
+				t.Errorf("%s: %s", test.src, msg)
+			}
+		}
+
+		info, err := analyser.Analyse()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		escapes := []string{}
+		for node, nodeInfo := range info.FileInfo[file].NodeInfo {
+			if ident, ok := node.(*ast.Identifier); ok && nodeInfo.Escapes {
+				escapes = append(escapes, ident.Text)
+			}
+		}
+		sort.Strings(escapes)
+
+		if strings.Join(escapes, ",") != strings.Join(test.escapes, ",") {
+			t.Errorf("Wrong escaping variables for %s: expected %q got %q", test.src, test.escapes, escapes)
+		}
+	}
+}
+
+func TestGenerics(t *testing.T) {
+	parse := func(src string) *ast.File {
+		file, err := parser.Parse(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	lib := parse(`
+	export interface Sized {
+		fn size() => int32
+	}
+
+	export struct Box<T> {
+		var value : T
+		fn get() => T {
+			return this.value
+		}
+	}
+
+	export fn max<T>(a : T, b : T, less : (T, T) => bool) => T {
+		if less(a, b) {
+			return b
+		}
+		return a
+	}
+
+	export fn total<T : Sized>(values : []T) => int32 {
+		var sum = 0
+		for _, v in values {
+			sum = sum + v.size()
+		}
+		return sum
+	}
+	`)
+
+	tests := []struct {
+		src    string
+		errors []string
+	}{
+		{`
+		import "lib"
+		fn main() {
+			var b = Box{max(1, 2, fn (a : int32, b : int32) => bool { return a < b })}
+			var s : Box<string> = Box<string>{"s"}
+			var i : int32 = b.get()
+			s.get()
+			i++
+		}
+		`, nil},
+		{`
+		import "lib"
+		fn zero<T>() => int32 {
+			return 0
+		}
+		fn main() {
+			zero()
+		}
+		`, []string{"cannot infer type parameter T of zero"}},
+		{`
+		import "lib"
+		fn zero<T>() => []T {
+			return []T{}
+		}
+		fn main() {
+			var s : []string = zero<string>()
+			var m = max<int32>(1, 2, fn (a : int32, b : int32) => bool { return a < b })
+			if m < len(s) {
+				m++
+			}
+		}
+		`, nil},
+		{`
+		import "lib"
+		fn one() => int32 {
+			return 1
+		}
+		fn less(a : int32, b : int32) => bool {
+			return a < b
+		}
+		fn main() {
+			max<string>(1, 2, less)
+			one<int32>()
+		}
+		`, []string{
+			"cannot use 1 (type int32) as type string in function call",
+			"cannot use 2 (type int32) as type string in function call",
+			"cannot use less (type (int32, int32) -> bool) as type (string, string) -> bool in function call",
+			"one is not a generic function",
+		}},
+		{`
+		import "lib"
+		fn square<T>(v : T) => T {
+			return v * v
+		}
+		fn main() {
+			square(2)
+			square("a")
+		}
+		`, []string{"invalid operation: v * v (operator * not defined on string)"}},
+		{`
+		import "lib"
+		fn main() {
+			total([]int32{1})
+		}
+		`, []string{"int32 does not satisfy Sized (type argument for T of total)"}},
+		{`
+		import "lib"
+		fn main() {
+			var s = Box{"s"}
+			var b : Box<int32> = s
+		}
+		`, []string{"cannot use s (type struct Box<string> { value: string }) as type struct Box<int32> { value: int32 } in assigment"}},
+		{`
+		import "lib"
+		fn main() {
+			var f = max
+		}
+		`, []string{"cannot use generic function max without instantiation"}},
+		{`
+		import "lib"
+		fn main() {
+			fn inner<T>(v : T) {}
+		}
+		`, []string{"generic function inner must be declared at the top level"}},
+		{`
+		import "lib"
+		fn broken<T>(v : T) => int32 {
+			return v.size()
+		}
+		fn main() {
+			broken(1)
+			broken("s")
+		}
+		`, []string{
+			"v.size undefined: (type T has no field or method size)",
+			"cannot use v.size() (type unknown (undefined)) as type int32 in return statement",
+			"v.size (type unknown (undefined)) is not a function",
+			"v.size undefined: (type T has no field or method size)",
+		}},
+	}
+
+	for _, test := range tests {
+		main := parse(test.src)
+		imports := map[*ast.Import]*ast.File{}
+		for _, importDecl := range main.Imports {
+			imports[importDecl] = lib
+		}
+
+		analyser, err := NewProgram([]*ast.File{lib, main}, imports)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		errors := []string{}
+		analyser.Error = func(node ast.Node, msg string, fatal bool) {
+			if fatal {
+				errors = append(errors, msg)
+			}
+		}
+
+		info, err := analyser.Analyse()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Join(errors, "\n") != strings.Join(test.errors, "\n") {
+			t.Errorf("Wrong errors for %s: expected %q got %q", test.src, test.errors, errors)
+		}
+
+		if len(test.errors) == 0 && len(info.FileInfo[lib].Instances) == 0 {
+			t.Error("Instances of generic declarations not recorded in the declaring file")
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package analyser
+
+import (
+	"fmt"
+
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/types"
+)
+
+// Names of the built-in functions
+const (
+	LenBuiltin    = "len"
+	DeleteBuiltin = "delete"
+)
+
+// builtinCall returns the name of the built-in function called by call. Built-in
+// functions can be shadowed by declarations with the same name
+func (v *visitor) builtinCall(call *ast.FunctionCall) (string, bool) {
+	ident, ok := call.Callee.(*ast.Identifier)
+	if !ok || (ident.Text != LenBuiltin && ident.Text != DeleteBuiltin) {
+		return "", false
+	}
+
+	if v.scope.Lookup(ident, true) != nil {
+		return "", false
+	}
+
+	return ident.Text, true
+}
+
+// builtinType returns the return type of a built-in function
+func builtinType(name string) types.Type {
+	if name == LenBuiltin {
+		return types.Int32Type
+	}
+	return types.VoidType
+}
+
+// builtinArguments checks the number of arguments of a built-in function
+// call. Built-in functions have no argument names
+func (v *visitor) builtinArguments(call *ast.FunctionCall, name string, count int) bool {
+	if len(call.Arguments) != count {
+		v.emitError(call, fmt.Sprintf("wrong number of arguments to %s: expected %d, found %d", name, count, len(call.Arguments)), true)
+		return false
+	}
+
+	for _, arg := range call.Arguments {
+		if arg.Name != nil {
+			v.emitError(arg, fmt.Sprintf("%s does not take named arguments", name), true)
+			return false
+		}
+	}
+
+	return true
+}
+
+func (v *visitor) validateBuiltinCall(call *ast.FunctionCall, name string) {
+	switch name {
+	case LenBuiltin:
+		if !v.builtinArguments(call, name, 1) {
+			return
+		}
+
+		arg := call.Arguments[0].Expression
+		argType := types.LazyResolve(v.getTypeForNode(arg))
+		switch argType.(type) {
+		case *types.ArrayType, *types.MapType:
+		default:
+			v.emitError(arg, fmt.Sprintf("invalid argument %s (type %s) for %s", arg, argType.GetName(), name), true)
+		}
+	case DeleteBuiltin:
+		if !v.builtinArguments(call, name, 2) {
+			return
+		}
+
+		target, key := call.Arguments[0].Expression, call.Arguments[1].Expression
+		targetType := types.LazyResolve(v.getTypeForNode(target))
+		mapType, ok := targetType.(*types.MapType)
+		if !ok {
+			v.emitError(target, fmt.Sprintf("invalid argument %s (type %s) for %s", target, targetType.GetName(), name), true)
+			return
+		}
+
+		keyType := v.getTypeForNode(key)
+		if !types.IsAssignable(mapType.Key, keyType) {
+			v.emitError(key, fmt.Sprintf(
+				"cannot use %s (type %s) as type %s in argument to %s",
+				key,
+				keyType.GetName(),
+				mapType.Key.GetName(),
+				name,
+			), true)
+		}
+	}
+}
@@ -5,4 +5,6 @@ import "github.com/orktes/orlang/ast"
 type Closure struct {
 	FunctionDeclaration *ast.FunctionDeclaration
 	Env                 []ScopeItem
+	// Captures holds the identifiers declaring the items of Env
+	Captures []*ast.Identifier
 }
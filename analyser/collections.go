@@ -0,0 +1,205 @@
+package analyser
+
+import (
+	"fmt"
+
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/scanner"
+	"github.com/orktes/orlang/types"
+)
+
+// constantIndex returns the value of an integer literal used as an index
+func constantIndex(expr ast.Expression) (int64, bool) {
+	switch n := expr.(type) {
+	case *ast.ValueExpression:
+		if n.Token.Type == scanner.TokenTypeNumber {
+			return n.Token.Value.(int64), true
+		}
+	case *ast.ParenExpression:
+		return constantIndex(n.Expression)
+	case *ast.UnaryExpression:
+		if n.Operator.Type == scanner.TokenTypeSUB {
+			if value, ok := constantIndex(n.Expression); ok {
+				return -value, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// checkIndex checks that index is an integer and that constant indexes are
+// within the bounds of arrays with a fixed length. Max is the largest valid index
+func (v *visitor) checkIndex(index ast.Expression, arrType *types.ArrayType, max int64) (int64, bool) {
+	indexType := v.getTypeForNode(index)
+	if !types.IsInteger(indexType) {
+		v.emitError(index, fmt.Sprintf("invalid index %s (type %s must be integer)", index, indexType.GetName()), true)
+		return 0, false
+	}
+
+	value, ok := constantIndex(index)
+	if !ok {
+		return 0, false
+	}
+
+	if value < 0 {
+		v.emitError(index, fmt.Sprintf("invalid index %d (index must be non-negative)", value), true)
+		return 0, false
+	}
+
+	if arrType.Length > -1 && value > max {
+		v.emitError(index, fmt.Sprintf("invalid index %d (out of bounds for %d-element array)", value, arrType.Length), true)
+		return 0, false
+	}
+
+	return value, true
+}
+
+func (v *visitor) checkIndexExpression(n *ast.IndexExpression) {
+	switch targetType := types.LazyResolve(v.getTypeForNode(n.Target)).(type) {
+	case *types.ArrayType:
+		v.checkIndex(n.Index, targetType, targetType.Length-1)
+	case *types.MapType:
+		keyType := v.getTypeForNode(n.Index)
+		if !types.IsAssignable(targetType.Key, keyType) {
+			v.emitError(n.Index, fmt.Sprintf(
+				"cannot use %s (type %s) as type %s in map index",
+				n.Index,
+				keyType.GetName(),
+				targetType.Key.GetName(),
+			), true)
+		}
+	default:
+		v.emitError(n.Target, fmt.Sprintf("cannot index %s (type %s)", n.Target, targetType.GetName()), true)
+	}
+}
+
+func (v *visitor) checkSliceExpression(n *ast.SliceExpression) {
+	targetType := types.LazyResolve(v.getTypeForNode(n.Target))
+	arrType, ok := targetType.(*types.ArrayType)
+	if !ok {
+		v.emitError(n.Target, fmt.Sprintf("cannot slice %s (type %s)", n.Target, targetType.GetName()), true)
+		return
+	}
+
+	var low, high int64
+	var lowOk, highOk bool
+	if n.Low != nil {
+		low, lowOk = v.checkIndex(n.Low, arrType, arrType.Length)
+	}
+	if n.High != nil {
+		high, highOk = v.checkIndex(n.High, arrType, arrType.Length)
+	}
+
+	if lowOk && highOk && low > high {
+		v.emitError(n, fmt.Sprintf("invalid slice indices: %d > %d", low, high), true)
+	}
+}
+
+func (v *visitor) checkArrayExpression(n *ast.ArrayExpression) {
+	arrType := v.getTypeForNode(n).(*types.ArrayType)
+
+	if arrType.Length > -1 && int64(len(n.Expressions)) > arrType.Length {
+		v.emitError(n, fmt.Sprintf("too many elements in %s (array length %d)", n, arrType.Length), true)
+	}
+
+	for _, expr := range n.Expressions {
+		exprType := v.getTypeForNode(expr)
+		if !types.IsAssignable(arrType.Type, exprType) {
+			v.emitError(expr, fmt.Sprintf(
+				"cannot use %s (type %s) as type %s in array literal",
+				expr,
+				exprType.GetName(),
+				arrType.Type.GetName(),
+			), true)
+		}
+	}
+}
+
+func (v *visitor) checkMapType(n *ast.MapType) {
+	keyType := v.getTypeForNode(n.Key)
+	if !types.IsComparable(keyType) {
+		v.emitError(n.Key, fmt.Sprintf("invalid map key type %s", keyType.GetName()), true)
+	}
+}
+
+func (v *visitor) checkMapExpression(n *ast.MapExpression) {
+	mapType := v.getTypeForNode(n).(*types.MapType)
+	keys := map[interface{}]bool{}
+
+	for _, entry := range n.Entries {
+		for _, expr := range []struct {
+			node ast.Expression
+			typ  types.Type
+			kind string
+		}{{entry.Key, mapType.Key, "key"}, {entry.Value, mapType.Value, "value"}} {
+			exprType := v.getTypeForNode(expr.node)
+			if !types.IsAssignable(expr.typ, exprType) {
+				v.emitError(expr.node, fmt.Sprintf(
+					"cannot use %s (type %s) as type %s in map %s",
+					expr.node,
+					exprType.GetName(),
+					expr.typ.GetName(),
+					expr.kind,
+				), true)
+			}
+		}
+
+		if value, ok := entry.Key.(*ast.ValueExpression); ok {
+			if keys[value.Token.Value] {
+				v.emitError(entry.Key, fmt.Sprintf("duplicate key %s in map literal", value), true)
+			}
+			keys[value.Token.Value] = true
+		}
+	}
+}
+
+// rangeTypes returns the types of the key and the value when iterating over
+// a value of type typ
+func rangeTypes(typ types.Type) (key types.Type, value types.Type, ok bool) {
+	switch t := types.LazyResolve(typ).(type) {
+	case *types.ArrayType:
+		return types.Int32Type, t.Type, true
+	case *types.MapType:
+		return t.Key, t.Value, true
+	}
+	return nil, nil, false
+}
+
+// visitForInLoop declares the loop variables in a scope of their own. The
+// collection is visited before so that it can not refer to them
+func (v *visitor) visitForInLoop(n *ast.ForInLoop) {
+	ast.Walk(v.subVisitor(n, v.scope), n.Collection)
+
+	collectionType := v.getTypeForNode(n.Collection)
+	keyType, valueType, ok := rangeTypes(collectionType)
+	if !ok {
+		v.emitError(n.Collection, fmt.Sprintf("cannot range over %s (type %s)", n.Collection, collectionType.GetName()), true)
+		return
+	}
+
+	scope := v.scope.SubScope(n)
+	loopVisitor := v.subVisitor(n, scope)
+
+	for _, variable := range []struct {
+		ident *ast.Identifier
+		typ   types.Type
+	}{{n.Key, keyType}, {n.Value, valueType}} {
+		if variable.ident == nil {
+			continue
+		}
+
+		if scope.Get(bindingName(variable.ident), false) != nil {
+			v.emitError(variable.ident, fmt.Sprintf("%s already declared", variable.ident), true)
+			continue
+		}
+
+		scope.Set(variable.ident, &CustomTypeResolvingScopeItem{
+			Node:         variable.ident,
+			ResolvedType: variable.typ,
+		})
+		ast.Walk(loopVisitor, variable.ident)
+		v.getNodeInfo(variable.ident).Type = variable.typ
+	}
+
+	ast.Walk(loopVisitor, n.Block)
+}
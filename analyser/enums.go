@@ -0,0 +1,288 @@
+package analyser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/types"
+)
+
+// wildcard is the pattern matching any value
+const wildcard = "_"
+
+func (v *visitor) resolveEnumType(n *ast.Enum) types.Type {
+	enums := v.getProgram().enums
+	if typ, ok := enums[n]; ok {
+		return typ
+	}
+
+	typ := &types.EnumType{}
+	if n.Name != nil {
+		typ.Name = n.Name.Text
+	}
+	enums[n] = typ
+
+	// Variants can refer to the enum itself
+	v.getNodeInfo(n).Type = typ
+
+	for _, variant := range n.Variants {
+		typ.Variants = append(typ.Variants, types.EnumVariant{
+			Name:  variant.Name.Text,
+			Types: v.getTypesForNodeList(convertTypesToNodes(variant.Types...)...),
+		})
+	}
+
+	return typ
+}
+
+func (v *visitor) checkEnum(n *ast.Enum) {
+	declared := map[string]bool{}
+	for _, variant := range n.Variants {
+		if declared[variant.Name.Text] {
+			v.emitError(variant, fmt.Sprintf("variant %s already declared", variant.Name), true)
+		}
+		declared[variant.Name.Text] = true
+	}
+}
+
+// enumReference returns the enum expr refers to when it names an enum
+// instead of a value
+func (v *visitor) enumReference(expr ast.Expression) *ast.Enum {
+	ident, ok := expr.(*ast.Identifier)
+	if !ok || v.scope.Lookup(ident, true) != nil {
+		return nil
+	}
+
+	enum, _ := v.info.Types[ident.Text].(*ast.Enum)
+	return enum
+}
+
+// variant returns the variant a member expression on an enum refers to
+func (v *visitor) variant(enum *ast.Enum, n *ast.MemberExpression) *ast.EnumVariant {
+	for _, variant := range enum.Variants {
+		if variant.Name.Text == n.Property.Text {
+			return variant
+		}
+	}
+	return nil
+}
+
+// resolveVariantType returns the type of a variant. Variants without values
+// are enum values and the others are functions constructing one
+func (v *visitor) resolveVariantType(enum *ast.Enum, n *ast.MemberExpression) types.Type {
+	enumType := v.getTypeForNode(enum).(*types.EnumType)
+
+	variant := enumType.Variant(n.Property.Text)
+	if variant == nil {
+		v.emitError(n, fmt.Sprintf(
+			"%s undefined: (%s has no variant %s)",
+			n,
+			enumType.GetName(),
+			n.Property.Text,
+		), true)
+		return types.UnknownType("undefined")
+	}
+
+	if len(variant.Types) == 0 {
+		return enumType
+	}
+
+	argumentNames := make([]string, len(variant.Types))
+	for i := range argumentNames {
+		argumentNames[i] = strconv.Itoa(i)
+	}
+
+	return &types.SignatureType{
+		ArgumentTypes: variant.Types,
+		ArgumentNames: argumentNames,
+		ReturnType:    enumType,
+	}
+}
+
+// matchArmScope returns the scope of a match arm declaring the variables
+// bound by its pattern. The scope is created on first use as the type of the
+// match can be needed before the match itself is visited
+func (v *visitor) matchArmScope(n *ast.MatchExpression, arm *ast.MatchArm) *Scope {
+	armInfo := v.getNodeInfo(arm)
+	if armInfo.Scope != nil {
+		return armInfo.Scope
+	}
+
+	armInfo.Scope = v.scope.SubScope(arm)
+	armInfo.Node = arm
+	armInfo.Parent = v.getNodeInfo(n)
+
+	enumType, ok := types.LazyResolve(v.getTypeForNode(n.Subject)).(*types.EnumType)
+	if !ok {
+		return armInfo.Scope
+	}
+
+	if pattern, ok := arm.Pattern.(*ast.VariantPattern); ok {
+		variant := enumType.Variant(pattern.Name.Text)
+		if variant != nil && len(variant.Types) == len(pattern.Payload.Patterns) {
+			v.declarePattern(arm, pattern.Payload, &types.TupleType{Types: variant.Types})
+		}
+	}
+
+	return armInfo.Scope
+}
+
+// declarePattern declares the variables bound by a pattern destructuring a
+// value of type typ in the scope of a match arm
+func (v *visitor) declarePattern(arm *ast.MatchArm, pattern *ast.TuplePattern, typ types.Type) {
+	armInfo := v.getNodeInfo(arm)
+
+	tupleType, ok := types.LazyResolve(typ).(*types.TupleType)
+	if !ok || len(tupleType.Types) != len(pattern.Patterns) {
+		v.emitError(pattern, fmt.Sprintf("cannot destructure %s with %s", typ.GetName(), pattern), true)
+		return
+	}
+
+	for i, pat := range pattern.Patterns {
+		switch p := pat.(type) {
+		case *ast.Identifier:
+			if p.Text == wildcard {
+				continue
+			}
+
+			if armInfo.Scope.Get(bindingName(p), false) != nil {
+				v.emitError(p, fmt.Sprintf("%s already declared", p), true)
+				continue
+			}
+
+			armInfo.Scope.Set(p, &CustomTypeResolvingScopeItem{
+				Node:         p,
+				ResolvedType: tupleType.Types[i],
+			})
+
+			nodeInfo := v.getNodeInfo(p)
+			nodeInfo.Scope = armInfo.Scope
+			nodeInfo.Node = p
+			nodeInfo.Parent = armInfo
+			nodeInfo.Type = tupleType.Types[i]
+		case *ast.TuplePattern:
+			v.declarePattern(arm, p, tupleType.Types[i])
+		}
+	}
+}
+
+// resolveMatchType returns the type of the arms of a match. Matches with code
+// blocks as arms have no value
+func (v *visitor) resolveMatchType(n *ast.MatchExpression) types.Type {
+	if len(n.Arms) == 0 {
+		return types.VoidType
+	}
+
+	for _, arm := range n.Arms {
+		if _, isBlock := arm.Body.(*ast.Block); isBlock {
+			return types.VoidType
+		}
+	}
+
+	arm := n.Arms[0]
+	return v.subVisitor(arm, v.matchArmScope(n, arm)).getTypeForNode(arm.Body)
+}
+
+// visitMatchExpression checks the patterns of a match against the enum it
+// matches on and that every variant is handled
+func (v *visitor) visitMatchExpression(n *ast.MatchExpression) {
+	ast.Walk(v.subVisitor(n, v.scope), n.Subject)
+
+	subjectType := v.getTypeForNode(n.Subject)
+	enumType, ok := types.LazyResolve(subjectType).(*types.EnumType)
+	if !ok {
+		v.emitError(n.Subject, fmt.Sprintf("cannot match on %s (type %s)", n.Subject, subjectType.GetName()), true)
+		return
+	}
+
+	matchType := v.getTypeForNode(n)
+	// The arms of a match used as a statement can have different types
+	_, isStatement := v.node.(*ast.Block)
+
+	matched := map[string]bool{}
+	for _, arm := range n.Arms {
+		if name := v.checkVariantPattern(enumType, arm.Pattern); name != "" {
+			if matched[wildcard] || matched[name] {
+				v.emitError(arm.Pattern, fmt.Sprintf("unreachable pattern %s", arm.Pattern), true)
+			}
+			matched[name] = true
+		}
+
+		armVisitor := v.subVisitor(arm, v.matchArmScope(n, arm))
+		ast.Walk(armVisitor, arm.Body)
+
+		if _, isBlock := arm.Body.(*ast.Block); !isBlock {
+			// Blocks report unused variables when they are left
+			armVisitor.processUnusedVariables()
+
+			if !isStatement {
+				if armType := v.getTypeForNode(arm.Body); !matchType.IsEqual(armType) {
+					v.emitError(arm.Body, fmt.Sprintf(
+						"cannot use %s (type %s) as type %s in match arm",
+						arm.Body,
+						armType.GetName(),
+						matchType.GetName(),
+					), true)
+				}
+			}
+		}
+	}
+
+	if matched[wildcard] {
+		return
+	}
+
+	missing := []string{}
+	for _, variant := range enumType.Variants {
+		if !matched[variant.Name] {
+			missing = append(missing, variant.Name)
+		}
+	}
+
+	if len(missing) > 0 {
+		v.emitError(n, fmt.Sprintf(
+			"non-exhaustive match on %s (missing %s)",
+			enumType.GetName(),
+			strings.Join(missing, ", "),
+		), true)
+	}
+}
+
+// checkVariantPattern returns the name of the variant a pattern matches
+func (v *visitor) checkVariantPattern(enumType *types.EnumType, pattern ast.Pattern) string {
+	var name *ast.Identifier
+	values := 0
+
+	switch p := pattern.(type) {
+	case *ast.Identifier:
+		if p.Text == wildcard {
+			return wildcard
+		}
+		name = p
+	case *ast.VariantPattern:
+		name = p.Name
+		values = len(p.Payload.Patterns)
+	default:
+		v.emitError(pattern, fmt.Sprintf("%s is not a variant of %s", pattern, enumType.GetName()), true)
+		return ""
+	}
+
+	variant := enumType.Variant(name.Text)
+	if variant == nil {
+		v.emitError(pattern, fmt.Sprintf("%s is not a variant of %s", name, enumType.GetName()), true)
+		return ""
+	}
+
+	if len(variant.Types) != values {
+		v.emitError(pattern, fmt.Sprintf(
+			"wrong number of values in pattern %s (variant %s has %d)",
+			pattern,
+			name,
+			len(variant.Types),
+		), true)
+	}
+
+	return name.Text
+}
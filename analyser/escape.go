@@ -0,0 +1,309 @@
+package analyser
+
+import (
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/scanner"
+	"github.com/orktes/orlang/types"
+)
+
+// Escape analysis decides which local variables outlive the call of the
+// function declaring them. A variable escapes if a closure capturing it
+// escapes or if its address escapes. Values escape when they are returned,
+// passed to a function, stored in a global, a field, an element or behind a
+// pointer, or stored in a variable of an enclosing function. Values stored in
+// local variables escape when the value of the variable escapes. Closures are
+// values too: a closure that is only called escapes nothing
+
+// escapeSource is a value flowing into another location. It is either the
+// address of a variable or the value of a variable or a closure
+type escapeSource struct {
+	// node is the identifier declaring a variable or a closure
+	node    ast.Node
+	address bool
+}
+
+type escapeAnalysis struct {
+	info *FileInfo
+	// flows maps local variables to the values stored in them
+	flows map[ast.Node][]escapeSource
+	// sinks are the values escaping directly
+	sinks []escapeSource
+	// captures maps closures to the identifiers declaring the variables they
+	// capture
+	captures map[ast.Node][]*ast.Identifier
+
+	values    map[ast.Node]bool
+	variables map[ast.Node]bool
+}
+
+// analyseEscapes marks the identifiers declaring escaping variables and the
+// escaping closures of a file or an instance of a generic declaration with
+// NodeInfo.Escapes
+func analyseEscapes(node ast.Node, info *FileInfo) {
+	ea := &escapeAnalysis{
+		info:      info,
+		flows:     map[ast.Node][]escapeSource{},
+		captures:  map[ast.Node][]*ast.Identifier{},
+		values:    map[ast.Node]bool{},
+		variables: map[ast.Node]bool{},
+	}
+
+	for _, closure := range info.Closures {
+		ea.captures[closure.FunctionDeclaration] = closure.Captures
+	}
+
+	ast.Walk(ast.VisitorFunc(ea.visit), node)
+
+	for _, source := range ea.sinks {
+		ea.escape(source)
+	}
+}
+
+func (ea *escapeAnalysis) visit(node ast.Node) ast.Visitor {
+	switch n := node.(type) {
+	case *ast.VariableDeclaration:
+		if n.DefaultValue != nil {
+			ea.flow(n.Name, n.DefaultValue)
+		}
+	case *ast.TupleDeclaration:
+		if n.DefaultValue != nil {
+			ea.flowPattern(n.Pattern, n.DefaultValue)
+		}
+	case *ast.Assigment:
+		if ident, ok := n.Left.(*ast.Identifier); ok {
+			if details := ea.declaration(ident); details != nil {
+				ea.flow(details.DefineIdentifier, n.Right)
+				break
+			}
+		}
+		// Fields, elements and values behind pointers can be read by anyone
+		ea.flow(nil, n.Right)
+	case *ast.MatchExpression:
+		for _, arm := range n.Arms {
+			if pattern, ok := arm.Pattern.(*ast.VariantPattern); ok {
+				ea.flowPattern(pattern.Payload, n.Subject)
+			}
+		}
+	case *ast.TypeSwitch:
+		if n.Binding != nil {
+			ea.flow(n.Binding, n.Subject)
+		}
+	case *ast.ReturnStatement:
+		if n.Expression != nil {
+			ea.flow(nil, n.Expression)
+		}
+	case *ast.CallArgument:
+		ea.flow(nil, n.Expression)
+	case *ast.ArrayExpression:
+		for _, expr := range n.Expressions {
+			ea.flow(nil, expr)
+		}
+	case *ast.MapExpression:
+		for _, entry := range n.Entries {
+			ea.flow(nil, entry.Key)
+			ea.flow(nil, entry.Value)
+		}
+	case *ast.BinaryExpression:
+		if ea.info.nodeInfo(n).OverloadedOperation != nil {
+			// Overloaded operators are function calls
+			ea.flow(nil, n.Left)
+			ea.flow(nil, n.Right)
+		}
+	}
+
+	return ast.VisitorFunc(ea.visit)
+}
+
+func (ea *escapeAnalysis) flowPattern(pattern *ast.TuplePattern, expr ast.Expression) {
+	for _, pat := range pattern.Patterns {
+		switch p := pat.(type) {
+		case *ast.Identifier:
+			if _, ok := ea.info.NodeInfo[p]; !ok {
+				// Wildcards declare nothing
+				continue
+			}
+			ea.flow(p, expr)
+		case *ast.TuplePattern:
+			ea.flowPattern(p, expr)
+		}
+	}
+}
+
+// flow records that the value of expr is stored in the variable declared by
+// dest. A nil dest means that the value escapes
+func (ea *escapeAnalysis) flow(dest *ast.Identifier, expr ast.Expression) {
+	var destFunc *ast.FunctionDeclaration
+	if dest != nil {
+		destFunc = ea.function(dest)
+	}
+
+	for _, source := range ea.sources(expr) {
+		if destFunc == nil || !ea.encloses(ea.function(source.node), destFunc) {
+			// Stored in a global or in a variable that can outlive the source
+			ea.sinks = append(ea.sinks, source)
+			continue
+		}
+		ea.flows[dest] = append(ea.flows[dest], source)
+	}
+}
+
+// sources returns the variables and closures whose value or address expr
+// can evaluate to
+func (ea *escapeAnalysis) sources(expr ast.Expression) (sources []escapeSource) {
+	if nodeInfo, ok := ea.info.NodeInfo[expr]; ok {
+		if _, ok := types.LazyResolve(nodeInfo.Type).(types.PrimitiveType); ok {
+			// Numbers, strings and booleans hold no references
+			return nil
+		}
+	}
+
+	switch n := expr.(type) {
+	case *ast.Identifier:
+		details := ea.declaration(n)
+		if details == nil {
+			return nil
+		}
+		if fun, ok := details.ScopeItem.(*ast.FunctionDeclaration); ok {
+			return []escapeSource{{node: fun}}
+		}
+		return []escapeSource{{node: details.DefineIdentifier}}
+	case *ast.FunctionDeclaration:
+		return []escapeSource{{node: n}}
+	case *ast.UnaryExpression:
+		switch n.Operator.Type {
+		case scanner.TokenTypeAMPERSAND:
+			if ident := rootIdentifier(n.Expression); ident != nil {
+				if details := ea.declaration(ident); details != nil {
+					return []escapeSource{{node: details.DefineIdentifier, address: true}}
+				}
+			}
+		case scanner.TokenTypeASTERISK:
+			return ea.sources(n.Expression)
+		}
+	case *ast.ParenExpression:
+		return ea.sources(n.Expression)
+	case *ast.MemberExpression:
+		return ea.sources(n.Target)
+	case *ast.TypeAssertion:
+		return ea.sources(n.Expression)
+	case *ast.IndexExpression:
+		return ea.sources(n.Target)
+	case *ast.SliceExpression:
+		return ea.sources(n.Target)
+	case *ast.Assigment:
+		return ea.sources(n.Right)
+	case *ast.TupleExpression:
+		for _, expr := range n.Expressions {
+			sources = append(sources, ea.sources(expr)...)
+		}
+	case *ast.MatchExpression:
+		for _, arm := range n.Arms {
+			if body, ok := arm.Body.(ast.Expression); ok {
+				sources = append(sources, ea.sources(body)...)
+			}
+		}
+	}
+	return
+}
+
+// rootIdentifier returns the variable whose storage holds the location expr
+// refers to
+func rootIdentifier(expr ast.Expression) *ast.Identifier {
+	switch n := expr.(type) {
+	case *ast.Identifier:
+		return n
+	case *ast.MemberExpression:
+		return rootIdentifier(n.Target)
+	case *ast.IndexExpression:
+		return rootIdentifier(n.Target)
+	case *ast.ParenExpression:
+		return rootIdentifier(n.Expression)
+	}
+	return nil
+}
+
+func (ea *escapeAnalysis) declaration(ident *ast.Identifier) *ScopeItemDetails {
+	nodeInfo, ok := ea.info.NodeInfo[ident]
+	if !ok || nodeInfo.Scope == nil {
+		return nil
+	}
+	return nodeInfo.Scope.LookupDetails(ident, true)
+}
+
+// function returns the function declaring a variable or a closure. Globals
+// and declarations in other files return nil
+func (ea *escapeAnalysis) function(node ast.Node) *ast.FunctionDeclaration {
+	if node == nil {
+		return nil
+	}
+
+	nodeInfo, ok := ea.info.NodeInfo[node]
+	if !ok {
+		return nil
+	}
+
+	for parent := nodeInfo.Parent; parent != nil; parent = parent.Parent {
+		switch n := parent.Node.(type) {
+		case *ast.FunctionDeclaration:
+			return n
+		case *ast.File:
+			// The file is its own parent
+			return nil
+		}
+	}
+	return nil
+}
+
+// encloses returns true if inner is outer or declared inside it
+func (ea *escapeAnalysis) encloses(outer, inner *ast.FunctionDeclaration) bool {
+	if outer == nil {
+		// Globals live as long as the program
+		return true
+	}
+	for fun := inner; fun != nil; fun = ea.function(fun) {
+		if fun == outer {
+			return true
+		}
+	}
+	return false
+}
+
+func (ea *escapeAnalysis) escape(source escapeSource) {
+	if source.address {
+		ea.escapeVariable(source.node)
+		return
+	}
+	ea.escapeValue(source.node)
+}
+
+// escapeVariable marks a variable whose storage outlives the function
+// declaring it. Anything stored in it can be read later
+func (ea *escapeAnalysis) escapeVariable(node ast.Node) {
+	if ea.variables[node] {
+		return
+	}
+	ea.variables[node] = true
+
+	if ea.function(node) != nil {
+		ea.info.nodeInfo(node).Escapes = true
+	}
+	ea.escapeValue(node)
+}
+
+func (ea *escapeAnalysis) escapeValue(node ast.Node) {
+	if ea.values[node] {
+		return
+	}
+	ea.values[node] = true
+
+	for _, source := range ea.flows[node] {
+		ea.escape(source)
+	}
+
+	if captures, ok := ea.captures[node]; ok {
+		ea.info.nodeInfo(node).Escapes = true
+		for _, ident := range captures {
+			ea.escapeVariable(ident)
+		}
+	}
+}
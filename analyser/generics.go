@@ -0,0 +1,627 @@
+package analyser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/scanner"
+	"github.com/orktes/orlang/types"
+)
+
+// Generic functions and structs are monomorphised: every distinct list of
+// type arguments creates a copy of the generic declaration, an instance,
+// which is analysed in the file declaring the generic declaration with the
+// type parameters bound to the type arguments. Instances are stored in
+// FileInfo.Instances and code generators emit them instead of the generic
+// declarations. Instances with type parameters as type arguments are only
+// used for type checking the bodies of other generic declarations
+
+// maxInstanceDepth limits the nesting of instances created while analysing
+// other instances so that recursive generic code creating ever larger types
+// is reported instead of analysed forever
+const maxInstanceDepth = 32
+
+// program is the state shared by the visitors of all files of a program
+type program struct {
+	analyser  *Analyser
+	files     map[ast.Node]*ast.File
+	scopes    map[*ast.File]*Scope
+	infos     map[*ast.File]*FileInfo
+	instances map[ast.Node]map[string]ast.Node
+	reported  map[string]bool
+	// embedding holds the interfaces whose embedded interfaces are being
+	// resolved
+	embedding map[*ast.Interface]bool
+	// enums holds the type of each enum declaration so that every file
+	// refers to an enum with the same type
+	enums   map[*ast.Enum]*types.EnumType
+	errorCb func(node ast.Node, msg string, fatal bool)
+	depth   int
+	// current is the file whose nodes are being visited
+	current *ast.File
+}
+
+func newProgram(analyser *Analyser, errorCb func(node ast.Node, msg string, fatal bool)) *program {
+	return &program{
+		analyser:  analyser,
+		files:     map[ast.Node]*ast.File{},
+		scopes:    map[*ast.File]*Scope{},
+		infos:     map[*ast.File]*FileInfo{},
+		instances: map[ast.Node]map[string]ast.Node{},
+		reported:  map[string]bool{},
+		embedding: map[*ast.Interface]bool{},
+		enums:     map[*ast.Enum]*types.EnumType{},
+		errorCb:   errorCb,
+	}
+}
+
+func (p *program) addFile(file *ast.File, scope *Scope, info *FileInfo) {
+	p.scopes[file] = scope
+	p.infos[file] = info
+	for _, node := range file.Body {
+		p.files[node] = file
+	}
+}
+
+func (p *program) emitError(node ast.Node, msg string, fatal bool) {
+	p.reported[p.errorKey(node)] = true
+	if p.errorCb != nil {
+		p.errorCb(node, msg, fatal)
+	}
+}
+
+// emitInstanceError reports an error found in an instance unless an error was
+// already reported for the same node of the generic declaration
+func (p *program) emitInstanceError(node ast.Node, msg string, fatal bool) {
+	if p.reported[p.errorKey(node)] {
+		return
+	}
+	p.emitError(node, msg, fatal)
+}
+
+func (p *program) errorKey(node ast.Node) string {
+	return fmt.Sprintf("%p:%v", p.current, node.StartPos())
+}
+
+// enterFile makes file the current file and returns the previous one
+func (p *program) enterFile(file *ast.File) *ast.File {
+	current := p.current
+	p.current = file
+	if p.analyser != nil {
+		p.analyser.current = file
+	}
+	return current
+}
+
+// getProgram returns the program shared by the visitors. Visitors created
+// without one share a program consisting of the file they visit
+func (v *visitor) getProgram() *program {
+	root := v
+	for root.parent != nil {
+		root = root.parent
+	}
+
+	if root.program == nil {
+		root.program = newProgram(nil, root.errorCb)
+		if file, ok := root.node.(*ast.File); ok {
+			root.program.addFile(file, root.scope, root.info)
+			root.program.current = file
+		}
+	}
+
+	return root.program
+}
+
+// checkGenericValue reports generic functions used as values as only calls
+// can instantiate them
+func (v *visitor) checkGenericValue(expr ast.Expression) {
+	ident, ok := expr.(*ast.Identifier)
+	if !ok {
+		return
+	}
+
+	if signType, ok := v.getTypeForNode(ident).(*types.SignatureType); ok && len(signType.TypeParameters) > 0 {
+		v.emitError(ident, fmt.Sprintf("cannot use generic function %s without instantiation", ident), true)
+	}
+}
+
+// typeParameter returns the type bound to a type parameter name
+func (v *visitor) typeParameter(name string) types.Type {
+	for visitor := v; visitor != nil; visitor = visitor.parent {
+		if typ, ok := visitor.typeParams[name]; ok {
+			return typ
+		}
+	}
+	return nil
+}
+
+// typeParameterVisitor returns a sub visitor resolving the names of params
+// to their type parameter types
+func (v *visitor) typeParameterVisitor(node ast.Node, scope *Scope, params []*ast.TypeParameter) *visitor {
+	sub := v.subVisitor(node, scope)
+	if len(params) > 0 {
+		sub.typeParams = map[string]types.Type{}
+		for _, param := range params {
+			sub.typeParams[param.Name.Text] = v.getTypeForNode(param)
+		}
+	}
+	return sub
+}
+
+func (v *visitor) typeParameterTypes(params []*ast.TypeParameter) (typs []*types.TypeParameterType) {
+	for _, param := range params {
+		if typ, ok := v.getTypeForNode(param).(*types.TypeParameterType); ok {
+			typs = append(typs, typ)
+		}
+	}
+	return
+}
+
+func (v *visitor) resolveTypeParameterType(n *ast.TypeParameter) types.Type {
+	typ := &types.TypeParameterType{Name: n.Name.Text}
+	if n.Constraint == nil {
+		return typ
+	}
+
+	constraintType := types.LazyResolve(v.getTypeForNode(n.Constraint))
+	if constraint, ok := constraintType.(*types.InterfaceType); ok {
+		typ.Constraint = constraint
+	} else {
+		v.emitError(n.Constraint, fmt.Sprintf(
+			"cannot use %s (type %s) as constraint for %s",
+			n.Constraint,
+			constraintType.GetName(),
+			n.Name,
+		), true)
+	}
+
+	return typ
+}
+
+// genericStruct returns the generic struct a type name refers to
+func (v *visitor) genericStruct(name string) *ast.Struct {
+	if v.typeParameter(name) != nil {
+		return nil
+	}
+
+	if structDecl, ok := v.info.Types[name].(*ast.Struct); ok && len(structDecl.TypeParameters) > 0 {
+		return structDecl
+	}
+	return nil
+}
+
+// resolveGenericTypeReference returns the type of a reference to a generic
+// struct with type arguments
+func (v *visitor) resolveGenericTypeReference(n *ast.TypeReference) types.Type {
+	structDecl := v.genericStruct(n.Name.Text)
+	if structDecl == nil {
+		v.emitError(n, fmt.Sprintf("%s is not a generic type", n.Name), true)
+		return v.getTypeForTypeName(n.Name.Text)
+	}
+
+	args := v.getTypesForNodeList(convertTypesToNodes(n.TypeArguments...)...)
+	instance := v.instantiate(structDecl, structDecl.TypeParameters, structDecl.Name.Text, args, n)
+	if instance == nil {
+		return types.UnknownType(n.Name.Text)
+	}
+
+	return v.getTypeForNode(instance)
+}
+
+// resolveGenericStructExpression returns the type of a struct expression
+// creating a value of a generic struct. Type arguments not given explicitly
+// are inferred from the values of the properties
+func (v *visitor) resolveGenericStructExpression(n *ast.StructExpression, structDecl *ast.Struct) types.Type {
+	var args []types.Type
+
+	if len(n.TypeArguments) > 0 {
+		args = v.getTypesForNodeList(convertTypesToNodes(n.TypeArguments...)...)
+	} else {
+		structType, ok := types.LazyResolve(v.getTypeForNode(structDecl)).(*types.StructType)
+		if !ok {
+			return types.UnknownType(n.Identifier.Text)
+		}
+
+		declVisitor := v.declarationVisitor(structDecl)
+		if declVisitor == nil {
+			declVisitor = v
+		}
+
+		bindings := newBindings(declVisitor.typeParameterTypes(structDecl.TypeParameters))
+		for i, callArg := range n.Arguments {
+			if callArg.Name != nil {
+				i = -1
+				for x, vr := range structType.Variables {
+					if vr.Name == callArg.Name.Text {
+						i = x
+					}
+				}
+			}
+
+			if i < 0 || i >= len(structType.Variables) {
+				continue
+			}
+
+			inferTypeArguments(structType.Variables[i].Type, v.getTypeForNode(callArg.Expression), bindings)
+		}
+
+		if args = v.boundTypeArguments(n, declVisitor.typeParameterTypes(structDecl.TypeParameters), n.Identifier.Text, bindings); args == nil {
+			return types.UnknownType(n.Identifier.Text)
+		}
+	}
+
+	instance := v.instantiate(structDecl, structDecl.TypeParameters, structDecl.Name.Text, args, n)
+	if instance == nil {
+		return types.UnknownType(n.Identifier.Text)
+	}
+
+	v.getNodeInfo(n).Instance = instance
+	return v.getTypeForNode(instance)
+}
+
+// resolveGenericCallType infers the type arguments of a call to a generic
+// function from the call arguments unless they are given explicitly and
+// returns the return type of the instance called
+func (v *visitor) resolveGenericCallType(n *ast.FunctionCall, signature *types.SignatureType) types.Type {
+	if len(signature.TypeParameters) == 0 {
+		v.emitError(n.Callee, fmt.Sprintf("%s is not a generic function", n.Callee), true)
+		return signature.ReturnType
+	}
+
+	var decl *ast.FunctionDeclaration
+	if ident, ok := n.Callee.(*ast.Identifier); ok {
+		decl, _ = v.scope.Lookup(ident, true).(*ast.FunctionDeclaration)
+	}
+
+	if decl == nil || len(decl.Signature.TypeParameters) == 0 {
+		v.emitError(n.Callee, fmt.Sprintf("cannot call %s without instantiating it", n.Callee), true)
+		return types.UnknownType("undefined")
+	}
+
+	name := decl.Signature.Identifier.Text
+	args := v.callTypeArguments(n, signature, name)
+	if args == nil {
+		return types.UnknownType("undefined")
+	}
+
+	instance := v.instantiate(decl, decl.Signature.TypeParameters, name, args, n)
+	if instance == nil {
+		return types.UnknownType("undefined")
+	}
+
+	instanceType := v.getTypeForNode(instance).(*types.SignatureType)
+
+	calleeInfo := v.getNodeInfo(n.Callee)
+	calleeInfo.Instance = instance
+	calleeInfo.Type = instanceType
+
+	return instanceType.ReturnType
+}
+
+// callTypeArguments returns the type arguments given to a call or inferred
+// from the call arguments
+func (v *visitor) callTypeArguments(n *ast.FunctionCall, signature *types.SignatureType, name string) []types.Type {
+	if len(n.TypeArguments) > 0 {
+		return v.getTypesForNodeList(convertTypesToNodes(n.TypeArguments...)...)
+	}
+
+	bindings := newBindings(signature.TypeParameters)
+	for i, callArg := range n.Arguments {
+		if callArg.Name != nil {
+			i = -1
+			for x, argName := range signature.ArgumentNames {
+				if argName == callArg.Name.Text {
+					i = x
+				}
+			}
+		}
+
+		if i < 0 || i >= len(signature.ArgumentTypes) {
+			continue
+		}
+
+		inferTypeArguments(signature.ArgumentTypes[i], v.getTypeForNode(callArg.Expression), bindings)
+	}
+
+	return v.boundTypeArguments(n, signature.TypeParameters, name, bindings)
+}
+
+func newBindings(params []*types.TypeParameterType) map[*types.TypeParameterType]types.Type {
+	bindings := map[*types.TypeParameterType]types.Type{}
+	for _, param := range params {
+		bindings[param] = nil
+	}
+	return bindings
+}
+
+// boundTypeArguments returns the types bound to params in order or reports
+// the first type parameter that could not be inferred
+func (v *visitor) boundTypeArguments(n ast.Node, params []*types.TypeParameterType, name string, bindings map[*types.TypeParameterType]types.Type) []types.Type {
+	args := []types.Type{}
+	for _, param := range params {
+		arg := bindings[param]
+		if arg == nil {
+			v.emitError(n, fmt.Sprintf("cannot infer type parameter %s of %s", param.Name, name), true)
+			return nil
+		}
+		args = append(args, arg)
+	}
+	return args
+}
+
+// inferTypeArguments binds the type parameters in param to the parts of arg
+// in the same place. Type parameters already bound keep their first binding
+func inferTypeArguments(param types.Type, arg types.Type, bindings map[*types.TypeParameterType]types.Type) {
+	inferTypes(param, arg, bindings, map[types.Type]bool{})
+}
+
+func inferTypes(param types.Type, arg types.Type, bindings map[*types.TypeParameterType]types.Type, seen map[types.Type]bool) {
+	param, arg = types.LazyResolve(param), types.LazyResolve(arg)
+
+	switch p := param.(type) {
+	case *types.TypeParameterType:
+		if bound, ok := bindings[p]; ok && bound == nil {
+			bindings[p] = arg
+		}
+	case *types.ArrayType:
+		if a, ok := arg.(*types.ArrayType); ok {
+			inferTypes(p.Type, a.Type, bindings, seen)
+		}
+	case *types.MapType:
+		if a, ok := arg.(*types.MapType); ok {
+			inferTypes(p.Key, a.Key, bindings, seen)
+			inferTypes(p.Value, a.Value, bindings, seen)
+		}
+	case *types.PointerType:
+		if a, ok := arg.(*types.PointerType); ok {
+			inferTypes(p.Elem, a.Elem, bindings, seen)
+		}
+	case *types.TupleType:
+		if a, ok := arg.(*types.TupleType); ok && len(a.Types) == len(p.Types) {
+			for i := range p.Types {
+				inferTypes(p.Types[i], a.Types[i], bindings, seen)
+			}
+		}
+	case *types.SignatureType:
+		if a, ok := arg.(*types.SignatureType); ok && len(a.ArgumentTypes) == len(p.ArgumentTypes) {
+			for i := range p.ArgumentTypes {
+				inferTypes(p.ArgumentTypes[i], a.ArgumentTypes[i], bindings, seen)
+			}
+			if p.ReturnType != nil && a.ReturnType != nil {
+				inferTypes(p.ReturnType, a.ReturnType, bindings, seen)
+			}
+		}
+	case *types.StructType:
+		a, ok := arg.(*types.StructType)
+		if !ok || seen[p] || len(a.Variables) != len(p.Variables) {
+			return
+		}
+		seen[p] = true
+		for i := range p.Variables {
+			inferTypes(p.Variables[i].Type, a.Variables[i].Type, bindings, seen)
+		}
+	}
+}
+
+// containsTypeParameter returns true if typ refers to a type parameter
+func containsTypeParameter(typ types.Type, seen map[types.Type]bool) bool {
+	switch t := types.LazyResolve(typ).(type) {
+	case *types.TypeParameterType:
+		return true
+	case *types.ArrayType:
+		return containsTypeParameter(t.Type, seen)
+	case *types.MapType:
+		return containsTypeParameter(t.Key, seen) || containsTypeParameter(t.Value, seen)
+	case *types.PointerType:
+		return containsTypeParameter(t.Elem, seen)
+	case *types.TupleType:
+		for _, typ := range t.Types {
+			if containsTypeParameter(typ, seen) {
+				return true
+			}
+		}
+	case *types.SignatureType:
+		for _, typ := range t.ArgumentTypes {
+			if containsTypeParameter(typ, seen) {
+				return true
+			}
+		}
+		return t.ReturnType != nil && containsTypeParameter(t.ReturnType, seen)
+	case *types.StructType:
+		if seen[t] {
+			return false
+		}
+		seen[t] = true
+		for _, v := range t.Variables {
+			if containsTypeParameter(v.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// typeArgumentName returns the name of a type argument used in the names of
+// instances. Type parameters are qualified with their identity when the name
+// is used as a key as different declarations can use the same names
+func typeArgumentName(typ types.Type, qualify bool) string {
+	switch t := types.LazyResolve(typ).(type) {
+	case *types.TypeParameterType:
+		if qualify {
+			return fmt.Sprintf("%s@%p", t.Name, t)
+		}
+		return t.Name
+	case *types.StructType:
+		return t.Name
+	case *types.EnumType:
+		return t.Name
+	case *types.InterfaceType:
+		if t.Name != "" {
+			return t.Name
+		}
+	case *types.ArrayType:
+		if t.Length > -1 {
+			return fmt.Sprintf("[%d]%s", t.Length, typeArgumentName(t.Type, qualify))
+		}
+		return "[]" + typeArgumentName(t.Type, qualify)
+	case *types.MapType:
+		return fmt.Sprintf("map[%s]%s", typeArgumentName(t.Key, qualify), typeArgumentName(t.Value, qualify))
+	case *types.PointerType:
+		return "*" + typeArgumentName(t.Elem, qualify)
+	case *types.TupleType:
+		return "(" + typeArgumentNames(t.Types, qualify) + ")"
+	case *types.SignatureType:
+		name := "(" + typeArgumentNames(t.ArgumentTypes, qualify) + ")"
+		if t.ReturnType != nil {
+			name += " => " + typeArgumentName(t.ReturnType, qualify)
+		}
+		return name
+	}
+	return typ.GetName()
+}
+
+func typeArgumentNames(typs []types.Type, qualify bool) string {
+	names := make([]string, len(typs))
+	for i, typ := range typs {
+		names[i] = typeArgumentName(typ, qualify)
+	}
+	return strings.Join(names, ", ")
+}
+
+// instantiate returns the instance of a generic function or struct for a
+// list of type arguments. Instances are created once per list of type
+// arguments and analysed in the file declaring decl
+func (v *visitor) instantiate(decl ast.Node, params []*ast.TypeParameter, name string, args []types.Type, at ast.Node) ast.Node {
+	if len(args) != len(params) {
+		v.emitError(at, fmt.Sprintf(
+			"wrong number of type arguments for %s (expected %d got %d)",
+			name,
+			len(params),
+			len(args),
+		), true)
+		return nil
+	}
+
+	prog := v.getProgram()
+	declVisitor := v.declarationVisitor(decl)
+	if declVisitor == nil {
+		v.emitError(at, fmt.Sprintf("cannot instantiate %s", name), true)
+		return nil
+	}
+	file, declInfo := declVisitor.node.(*ast.File), declVisitor.info
+
+	abstract := false
+	for i, param := range declVisitor.typeParameterTypes(params) {
+		if containsTypeParameter(args[i], map[types.Type]bool{}) {
+			abstract = true
+		}
+
+		if param.Constraint != nil && !param.Constraint.IsEqual(args[i]) {
+			v.emitError(at, fmt.Sprintf(
+				"%s does not satisfy %s (type argument for %s of %s)",
+				typeArgumentName(args[i], false),
+				typeArgumentName(param.Constraint, false),
+				params[i].Name,
+				name,
+			), true)
+			return nil
+		}
+	}
+
+	key := typeArgumentNames(args, true)
+	if instance, ok := prog.instances[decl][key]; ok {
+		v.shareInstance(instance, declInfo)
+		return instance
+	}
+
+	instanceName := fmt.Sprintf("%s<%s>", name, typeArgumentNames(args, false))
+	instance := ast.Copy(decl)
+	switch n := instance.(type) {
+	case *ast.FunctionDeclaration:
+		n.Signature.TypeParameters = nil
+		n.Signature.Identifier = renamedIdentifier(n.Signature.Identifier, instanceName)
+	case *ast.Struct:
+		n.TypeParameters = nil
+		n.Name = renamedIdentifier(n.Name, instanceName)
+		declInfo.Types[instanceName] = n
+	}
+
+	if prog.instances[decl] == nil {
+		prog.instances[decl] = map[string]ast.Node{}
+	}
+	prog.instances[decl][key] = instance
+	v.shareInstance(instance, declInfo)
+
+	declVisitor.typeParams = map[string]types.Type{}
+	for i, param := range params {
+		declVisitor.typeParams[param.Name.Text] = args[i]
+	}
+
+	declVisitor.getTypeForNode(instance)
+
+	if abstract {
+		// Instances with type parameters only provide types for checking
+		// generic code
+		return instance
+	}
+
+	declInfo.Instances = append(declInfo.Instances, instance)
+
+	if prog.depth >= maxInstanceDepth {
+		v.emitError(at, fmt.Sprintf("instantiation of %s exceeds maximum depth", instanceName), true)
+		return instance
+	}
+
+	prog.depth++
+	current := prog.enterFile(file)
+	ast.Walk(declVisitor, instance)
+	prog.enterFile(current)
+	prog.depth--
+
+	if fn, ok := instance.(*ast.FunctionDeclaration); ok {
+		// Instances are referred to through the generic function
+		declVisitor.scope.MarkUsage(fn, &ast.Identifier{Token: scanner.Token{Text: instanceName}})
+	}
+
+	return instance
+}
+
+// declarationVisitor returns a visitor for the file declaring decl. Types of
+// nodes inside generic declarations are resolved with it so that every file
+// sees the same type parameter types
+func (v *visitor) declarationVisitor(decl ast.Node) *visitor {
+	prog := v.getProgram()
+	file := prog.files[decl]
+	declInfo := prog.infos[file]
+	if file == nil || declInfo == nil {
+		return nil
+	}
+
+	return &visitor{
+		node:    file,
+		scope:   prog.scopes[file],
+		info:    declInfo,
+		program: prog,
+		errorCb: prog.emitInstanceError,
+	}
+}
+
+// shareInstance makes an instance declared in another file known to the file
+// being analysed
+func (v *visitor) shareInstance(instance ast.Node, declInfo *FileInfo) {
+	if declInfo == v.info {
+		return
+	}
+
+	v.info.NodeInfo[instance] = declInfo.nodeInfo(instance)
+	if structDecl, ok := instance.(*ast.Struct); ok {
+		v.info.Types[structDecl.Name.Text] = structDecl
+	}
+}
+
+func renamedIdentifier(ident *ast.Identifier, name string) *ast.Identifier {
+	renamed := *ident
+	renamed.Text = name
+	return &renamed
+}
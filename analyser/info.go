@@ -7,12 +7,26 @@ import (
 )
 
 type NodeInfo struct {
-	Type                types.Type
-	Node                ast.Node
-	Parent              *NodeInfo
-	Children            []*NodeInfo
-	Scope               *Scope
-	TypeCast            bool
+	Type     types.Type
+	Node     ast.Node
+	Parent   *NodeInfo
+	Children []*NodeInfo
+	Scope    *Scope
+	TypeCast bool
+	// Builtin is the name of the built-in function called by a function call
+	Builtin string
+	// AddressTaken is set on the identifier declaring a variable whose address
+	// is taken with the & operator
+	AddressTaken bool
+	// Escapes is set on the identifier declaring a local variable that
+	// outlives the call of the function declaring it and on closures that do
+	Escapes bool
+	// Variant is set on member expressions referring to an enum variant
+	Variant *ast.EnumVariant
+	// Instance is set on identifiers calling a generic function and on struct
+	// expressions creating a value of a generic struct. It is the instance of
+	// the generic declaration used
+	Instance            ast.Node
 	OverloadedOperation *ast.FunctionDeclaration
 	Closures            []*Closure
 }
@@ -21,6 +35,9 @@ type FileInfo struct {
 	NodeInfo map[ast.Node]*NodeInfo
 	Types    map[string]ast.Node
 	Closures []*Closure
+	// Instances are the instances of the generic functions and structs
+	// declared in the file
+	Instances []ast.Node
 }
 
 func NewFileInfo() *FileInfo {
@@ -31,6 +48,16 @@ func NewFileInfo() *FileInfo {
 	}
 }
 
+func (fi *FileInfo) nodeInfo(node ast.Node) *NodeInfo {
+	if nodeInfo, ok := fi.NodeInfo[node]; ok {
+		return nodeInfo
+	}
+
+	nodeInfo := &NodeInfo{}
+	fi.NodeInfo[node] = nodeInfo
+	return nodeInfo
+}
+
 type Info struct {
 	FileInfo map[*ast.File]*FileInfo
 }
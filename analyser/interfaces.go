@@ -0,0 +1,98 @@
+package analyser
+
+import (
+	"fmt"
+
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/types"
+)
+
+// Interfaces embedding other interfaces have the methods of the embedded
+// interfaces. The method sets are flattened into the InterfaceType so that
+// comparing types only needs the methods of the interface itself
+
+func (v *visitor) resolveInterfaceType(n *ast.Interface) types.Type {
+	typ := &types.InterfaceType{}
+	if n.Name != nil {
+		typ.Name = n.Name.Text
+	}
+
+	// Signatures can refer to the interface itself
+	v.getNodeInfo(n).Type = typ
+
+	prog := v.getProgram()
+	prog.embedding[n] = true
+	defer delete(prog.embedding, n)
+
+	for _, embedded := range n.Embedded {
+		embeddedType := v.embeddedInterface(embedded)
+		if embeddedType == nil {
+			continue
+		}
+
+		for _, fn := range embeddedType.Functions {
+			v.addInterfaceFunction(typ, embedded, fn.Name, fn.Type)
+		}
+	}
+
+	declared := map[string]bool{}
+	for _, signature := range n.Functions {
+		var name string
+		if signature.Identifier != nil {
+			name = signature.Identifier.Text
+		} else if signature.Operator != nil {
+			name = signature.Operator.Text
+		}
+
+		if declared[name] {
+			v.emitError(signature, fmt.Sprintf("duplicate method %s in interface %s", name, typ.Name), true)
+			continue
+		}
+		declared[name] = true
+
+		v.addInterfaceFunction(typ, signature, name, v.getTypeForNode(signature).(*types.SignatureType))
+	}
+
+	return typ
+}
+
+// embeddedInterface returns the type of an interface embedded in another
+func (v *visitor) embeddedInterface(embedded *ast.TypeReference) *types.InterfaceType {
+	if decl, ok := v.info.Types[embedded.Name.Text].(*ast.Interface); ok && v.getProgram().embedding[decl] {
+		v.emitError(embedded, fmt.Sprintf("invalid recursive embedding of interface %s", embedded), true)
+		return nil
+	}
+
+	embeddedType := types.LazyResolve(v.getTypeForNode(embedded))
+	interfaceType, ok := embeddedType.(*types.InterfaceType)
+	if !ok {
+		v.emitError(embedded, fmt.Sprintf(
+			"cannot embed %s (type %s is not an interface)",
+			embedded,
+			typeArgumentName(embeddedType, false),
+		), true)
+	}
+	return interfaceType
+}
+
+// addInterfaceFunction adds a method to the method set of an interface.
+// Methods with the same name must have identical signatures
+func (v *visitor) addInterfaceFunction(typ *types.InterfaceType, at ast.Node, name string, signature *types.SignatureType) {
+	if ok, existing := typ.HasFunction(name); ok {
+		if !existing.IsEqual(signature) {
+			v.emitError(at, fmt.Sprintf(
+				"duplicate method %s in interface %s with different signatures (%s and %s)",
+				name,
+				typ.Name,
+				existing.GetName(),
+				signature.GetName(),
+			), true)
+		}
+		return
+	}
+
+	typ.Functions = append(typ.Functions, struct {
+		Name string
+		Type *types.SignatureType
+	}{name, signature})
+}
@@ -0,0 +1,166 @@
+package analyser
+
+import (
+	"fmt"
+
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/scanner"
+	"github.com/orktes/orlang/types"
+)
+
+// Pointers are created with &x and dereferenced with *p. Variables, struct
+// fields, array elements and dereferenced pointers are addressable. Fields
+// and methods of a struct can be accessed directly through a pointer to it
+
+// resolveUnaryType returns the type of a unary expression
+func (v *visitor) resolveUnaryType(n *ast.UnaryExpression) types.Type {
+	typ := v.getTypeForNode(n.Expression)
+
+	switch n.Operator.Type {
+	case scanner.TokenTypeAMPERSAND:
+		return &types.PointerType{Elem: typ}
+	case scanner.TokenTypeASTERISK:
+		if pointerType, ok := types.LazyResolve(typ).(*types.PointerType); ok {
+			return pointerType.Elem
+		}
+		return types.UnknownType("undefined")
+	}
+
+	return typ
+}
+
+// memberTargetType returns the type whose members are accessed by a member
+// expression on target. Pointers to structs are dereferenced automatically
+func (v *visitor) memberTargetType(target ast.Expression) types.Type {
+	typ := v.getTypeForNode(target)
+	if pointerType, ok := types.LazyResolve(typ).(*types.PointerType); ok {
+		return types.LazyResolve(pointerType.Elem)
+	}
+	return typ
+}
+
+// declaration returns the scope item an identifier refers to and the
+// identifier it was declared with
+func (v *visitor) declaration(ident *ast.Identifier) *ScopeItemDetails {
+	if ident.Text == "this" && v.getParentStructDecl() != nil {
+		return nil
+	}
+	return v.scope.LookupDetails(ident, true)
+}
+
+// isConstant returns true if expr refers to a variable declared with const or
+// to a field or an element of one
+func (v *visitor) isConstant(expr ast.Expression) bool {
+	switch n := expr.(type) {
+	case *ast.Identifier:
+		details := v.declaration(n)
+		if details == nil {
+			return false
+		}
+
+		switch item := details.ScopeItem.(type) {
+		case *ast.VariableDeclaration:
+			return item.Constant
+		case *CustomTypeResolvingScopeItem:
+			if tupleDecl, ok := item.Node.(*ast.TupleDeclaration); ok {
+				return tupleDecl.Constant
+			}
+		}
+	case *ast.MemberExpression:
+		if _, ok := types.LazyResolve(v.getTypeForNode(n.Target)).(*types.PointerType); ok {
+			return false
+		}
+		return v.isConstant(n.Target)
+	case *ast.IndexExpression:
+		return v.isConstant(n.Target)
+	case *ast.ParenExpression:
+		return v.isConstant(n.Expression)
+	}
+	return false
+}
+
+// isAddressable returns true if the address of expr can be taken
+func (v *visitor) isAddressable(expr ast.Expression) bool {
+	switch n := expr.(type) {
+	case *ast.Identifier:
+		details := v.declaration(n)
+		if details == nil {
+			return false
+		}
+
+		switch item := details.ScopeItem.(type) {
+		case *ast.VariableDeclaration, *ast.Argument:
+			return true
+		case *CustomTypeResolvingScopeItem:
+			// Externals have no declaring node
+			return item.Node != nil
+		}
+	case *ast.MemberExpression:
+		if typeWithMethods, ok := v.memberTargetType(n.Target).(types.TypeWithMethods); ok {
+			if isMethod, _ := typeWithMethods.HasFunction(n.Property.Text); isMethod {
+				return false
+			}
+		}
+		return true
+	case *ast.IndexExpression:
+		_, isArray := types.LazyResolve(v.getTypeForNode(n.Target)).(*types.ArrayType)
+		return isArray
+	case *ast.UnaryExpression:
+		return n.Operator.Type == scanner.TokenTypeASTERISK
+	case *ast.ParenExpression:
+		return v.isAddressable(n.Expression)
+	}
+	return false
+}
+
+// markAddressTaken records that the address of a variable is taken so that
+// code generators can store it in memory that outlives a single value
+func (v *visitor) markAddressTaken(expr ast.Expression) {
+	switch n := expr.(type) {
+	case *ast.Identifier:
+		if details := v.declaration(n); details != nil {
+			v.getNodeInfo(details.DefineIdentifier).AddressTaken = true
+		}
+	case *ast.ParenExpression:
+		v.markAddressTaken(n.Expression)
+	}
+}
+
+func (v *visitor) checkUnaryExpression(n *ast.UnaryExpression) {
+	switch n.Operator.Type {
+	case scanner.TokenTypeAMPERSAND:
+		if _, ok := n.Expression.(*ast.StructExpression); ok {
+			// &Foo{} allocates a new struct
+			break
+		}
+
+		if v.isConstant(n.Expression) {
+			v.emitError(n, fmt.Sprintf("cannot take the address of constant %s", n.Expression), true)
+			break
+		}
+
+		if !v.isAddressable(n.Expression) {
+			v.emitError(n, fmt.Sprintf("cannot take the address of %s", n.Expression), true)
+			break
+		}
+
+		v.markAddressTaken(n.Expression)
+	case scanner.TokenTypeASTERISK:
+		typ := v.getTypeForNode(n.Expression)
+		if _, ok := types.LazyResolve(typ).(*types.PointerType); !ok {
+			v.emitError(n, fmt.Sprintf("invalid indirect of %s (type %s)", n.Expression, typ.GetName()), true)
+		}
+	case scanner.TokenTypeIncrement, scanner.TokenTypeDecrement:
+		v.checkMutable(n.Expression)
+	}
+}
+
+// checkMutable checks that the value of expr can be changed. Values behind a
+// pointer are always mutable even if the pointer was declared with const
+func (v *visitor) checkMutable(expr ast.Expression) bool {
+	if v.isConstant(expr) {
+		v.emitError(expr, fmt.Sprintf("cannot assign to constant %s", expr), true)
+		return false
+	}
+	return true
+}
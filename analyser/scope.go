@@ -1,6 +1,8 @@
 package analyser
 
 import (
+	"fmt"
+
 	"github.com/orktes/orlang/types"
 
 	"github.com/orktes/orlang/ast"
@@ -50,7 +52,8 @@ func (s *Scope) SubScope(node ast.Node) *Scope {
 }
 
 func (s *Scope) MarkUsage(si ScopeItem, ident *ast.Identifier) {
-	scope := s.GetDefiningScope(ident.Text)
+	name := s.Name(ident)
+	scope := s.GetDefiningScope(name)
 	if scope == nil {
 		return
 	}
@@ -62,7 +65,7 @@ func (s *Scope) MarkUsage(si ScopeItem, ident *ast.Identifier) {
 		s.references[si] = references
 	}
 
-	if scope.items[ident.Text].DefineIdentifier != ident {
+	if scope.items[name].DefineIdentifier != ident {
 		usages := scope.usage[si]
 		usages = append(usages, ident)
 		scope.usage[si] = usages
@@ -131,6 +134,16 @@ func (s *Scope) Traverse(parent bool, visitor func(key string, info *ScopeItemDe
 	s.traverseScope(parent, nil, visitor)
 }
 
+// encloses returns true if scope is s or one of its sub scopes
+func (s *Scope) encloses(scope *Scope) bool {
+	for ; scope != nil; scope = scope.parent {
+		if scope == s {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Scope) GetDefiningScope(indentifier string) *Scope {
 	if _, ok := s.items[indentifier]; ok {
 		return s
@@ -161,8 +174,38 @@ func (s *Scope) GetDetails(indentifier string, parent bool) *ScopeItemDetails {
 	return nil
 }
 
+// Lookup returns the scope item an identifier refers to
+func (s *Scope) Lookup(ident *ast.Identifier, parent bool) ast.Node {
+	return s.Get(s.Name(ident), parent)
+}
+
+// LookupDetails returns the details of the scope item an identifier refers to
+func (s *Scope) LookupDetails(ident *ast.Identifier, parent bool) *ScopeItemDetails {
+	return s.GetDetails(s.Name(ident), parent)
+}
+
+// Name returns the name an identifier is looked up with. Identifiers
+// introduced by a macro expansion refer to the bindings made by the same
+// expansion and otherwise to the bindings visible at the call site
+func (s *Scope) Name(ident *ast.Identifier) string {
+	if name := bindingName(ident); name != ident.Text && s.GetDefiningScope(name) != nil {
+		return name
+	}
+	return ident.Text
+}
+
+// bindingName returns the name a declared identifier is bound to. Bindings
+// introduced by a macro expansion are renamed with the mark of the expansion
+// so that they can't capture or shadow the identifiers of the caller
+func bindingName(ident *ast.Identifier) string {
+	if ident.Mark == 0 {
+		return ident.Text
+	}
+	return fmt.Sprintf("%s#%d", ident.Text, ident.Mark)
+}
+
 func (s *Scope) Set(identifier *ast.Identifier, node ast.Node) {
-	s.SetWithName(identifier.Text, identifier, node)
+	s.SetWithName(bindingName(identifier), identifier, node)
 }
 
 func (s *Scope) SetWithName(name string, identifier *ast.Identifier, node ast.Node) {
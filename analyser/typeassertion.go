@@ -0,0 +1,146 @@
+package analyser
+
+import (
+	"fmt"
+
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/types"
+)
+
+// Type assertions and type switches recover the struct held by an interface
+// value. Only struct and interface types can be asserted as code generators
+// identify the value held by an interface by its struct
+
+func (v *visitor) resolveTypeAssertionType(n *ast.TypeAssertion) types.Type {
+	typ := v.getTypeForNode(n.Type)
+	if n.Ok {
+		return &types.TupleType{Types: []types.Type{typ, types.BoolType}}
+	}
+	return typ
+}
+
+// subjectInterface returns the interface type of the value asserted by expr
+func (v *visitor) subjectInterface(expr ast.Expression, at ast.Node) *types.InterfaceType {
+	subjectType := types.LazyResolve(v.getTypeForNode(expr))
+	interfaceType, ok := subjectType.(*types.InterfaceType)
+	if !ok {
+		v.emitError(at, fmt.Sprintf(
+			"invalid type assertion: %s (non-interface type %s on left)",
+			at,
+			subjectType.GetName(),
+		), true)
+	}
+	return interfaceType
+}
+
+// checkAssertedType checks that a value of interface type can hold a value of
+// the type typ refers to. kind names the construct in error messages
+func (v *visitor) checkAssertedType(interfaceType *types.InterfaceType, typ ast.Type, at ast.Node, kind string) bool {
+	assertedType := types.LazyResolve(v.getTypeForNode(typ))
+
+	switch t := assertedType.(type) {
+	case *types.InterfaceType:
+		return true
+	case *types.StructType:
+		if interfaceType.IsEqual(t) {
+			return true
+		}
+
+		v.emitError(at, fmt.Sprintf(
+			"impossible %s: %s (%s does not implement %s)",
+			kind,
+			at,
+			typeArgumentName(t, false),
+			typeArgumentName(interfaceType, false),
+		), true)
+	default:
+		v.emitError(at, fmt.Sprintf(
+			"invalid %s: %s (%s is not a struct or interface type)",
+			kind,
+			at,
+			typeArgumentName(assertedType, false),
+		), true)
+	}
+
+	return false
+}
+
+func (v *visitor) checkTypeAssertion(n *ast.TypeAssertion) {
+	if interfaceType := v.subjectInterface(n.Expression, n); interfaceType != nil {
+		v.checkAssertedType(interfaceType, n.Type, n, "type assertion")
+	}
+}
+
+// visitTypeSwitch checks the cases of a type switch and declares the binding
+// of the switch in the scope of every case
+func (v *visitor) visitTypeSwitch(n *ast.TypeSwitch) {
+	switchVisitor := v.subVisitor(n, v.scope)
+	ast.Walk(switchVisitor, n.Subject)
+
+	subjectType := v.getTypeForNode(n.Subject)
+	interfaceType := v.subjectInterface(n.Subject, n.Subject)
+
+	seen := map[types.Type]bool{}
+	defaults := 0
+	bindingUsed := false
+
+	for _, c := range n.Cases {
+		if c.Types == nil {
+			if defaults++; defaults > 1 {
+				v.emitError(c, "multiple defaults in type switch", true)
+			}
+		}
+
+		for _, typ := range c.Types {
+			ast.Walk(switchVisitor, typ)
+
+			caseType := types.LazyResolve(v.getTypeForNode(typ))
+			if seen[caseType] {
+				v.emitError(typ, fmt.Sprintf("duplicate case %s in type switch", typ), true)
+			}
+			seen[caseType] = true
+
+			if interfaceType != nil {
+				v.checkAssertedType(interfaceType, typ, typ, "type switch case")
+			}
+		}
+
+		scope := v.scope.SubScope(c)
+		caseInfo := v.getNodeInfo(c)
+		caseInfo.Scope = scope
+		caseInfo.Node = c
+		caseInfo.Parent = v.getNodeInfo(n)
+
+		if n.Binding != nil {
+			bindingType := subjectType
+			if len(c.Types) == 1 {
+				bindingType = v.getTypeForNode(c.Types[0])
+			}
+
+			scope.Set(n.Binding, &CustomTypeResolvingScopeItem{
+				Node:         n.Binding,
+				ResolvedType: bindingType,
+			})
+		}
+
+		ast.Walk(v.subVisitor(c, scope), c.Block)
+
+		if n.Binding != nil && len(scope.UnusedScopeItems()) == 0 {
+			bindingUsed = true
+		}
+	}
+
+	if n.Binding != nil {
+		bindingInfo := v.getNodeInfo(n.Binding)
+		bindingInfo.Node = n.Binding
+		bindingInfo.Parent = v.getNodeInfo(n)
+		bindingInfo.Type = subjectType
+		if len(n.Cases) > 0 {
+			bindingInfo.Scope = v.getNodeInfo(n.Cases[len(n.Cases)-1]).Scope
+		}
+
+		if !bindingUsed && n.Binding.Text != wildcard {
+			v.emitError(n.Binding, fmt.Sprintf("%s declared but not used", n.Binding.Text), false)
+		}
+	}
+}
@@ -18,6 +18,10 @@ type visitor struct {
 	parent         *visitor
 	errorCb        func(node ast.Node, msg string, fatal bool)
 	autocompleteCb func([]AutoCompleteInfo)
+	// typeParams binds the names of type parameters to types while visiting
+	// generic declarations and their instances
+	typeParams map[string]types.Type
+	program    *program
 }
 
 func (v *visitor) subVisitor(node ast.Node, scope *Scope) *visitor {
@@ -38,7 +42,7 @@ func (v *visitor) emitError(node ast.Node, err string, fatal bool) {
 }
 
 func (v *visitor) scopeMustGet(identifier *ast.Identifier, cb func(ScopeItem)) {
-	if node := v.scope.Get(identifier.Text, true); node != nil {
+	if node := v.scope.Lookup(identifier, true); node != nil {
 		cb(node)
 	}
 }
@@ -54,6 +58,10 @@ func (v *visitor) getTypesForNodeList(nodes ...ast.Node) []types.Type {
 }
 
 func (v *visitor) getTypeForTypeName(typName string) types.Type {
+	if typ := v.typeParameter(typName); typ != nil {
+		return typ
+	}
+
 	if typ := types.Types[typName]; typ != nil {
 		return typ
 	}
@@ -82,6 +90,8 @@ func (v *visitor) resolveTypeForNode(node ast.Node) types.Type {
 			}
 
 			arrLength = valExpr.Token.Value.(int64)
+		} else if n.Length != nil {
+			v.emitError(n.Length, "array length must be an integer constant", true)
 		}
 
 		return &types.ArrayType{
@@ -89,9 +99,24 @@ func (v *visitor) resolveTypeForNode(node ast.Node) types.Type {
 			Length: arrLength,
 		}
 	case *ast.ArrayExpression:
-		return &types.ArrayType{
-			Type:   v.getTypeForNode(n.Type.Type),
-			Length: int64(len(n.Expressions)),
+		return v.getTypeForNode(n.Type)
+	case *ast.MapType:
+		return &types.MapType{
+			Key:   v.getTypeForNode(n.Key),
+			Value: v.getTypeForNode(n.Value),
+		}
+	case *ast.MapExpression:
+		return v.getTypeForNode(n.Type)
+	case *ast.IndexExpression:
+		switch targetType := types.LazyResolve(v.getTypeForNode(n.Target)).(type) {
+		case *types.ArrayType:
+			return targetType.Type
+		case *types.MapType:
+			return targetType.Value
+		}
+	case *ast.SliceExpression:
+		if arrType, ok := types.LazyResolve(v.getTypeForNode(n.Target)).(*types.ArrayType); ok {
+			return &types.ArrayType{Type: arrType.Type, Length: -1}
 		}
 	case *ast.VariableDeclaration:
 		if n.Type != nil {
@@ -100,8 +125,15 @@ func (v *visitor) resolveTypeForNode(node ast.Node) types.Type {
 		return v.getTypeForNode(n.DefaultValue)
 	case *ast.ComparisonExpression:
 		return types.BoolType
+	case *ast.Assigment:
+		return v.getTypeForNode(n.Right)
 	case *ast.TypeReference:
+		if len(n.TypeArguments) > 0 {
+			return v.resolveGenericTypeReference(n)
+		}
 		return v.getTypeForTypeName(n.Name.Text)
+	case *ast.TypeParameter:
+		return v.resolveTypeParameterType(n)
 	case *ast.ValueExpression:
 		switch n.Token.Type {
 		case scanner.TokenTypeNumber:
@@ -130,12 +162,24 @@ func (v *visitor) resolveTypeForNode(node ast.Node) types.Type {
 			}
 		}
 
+		if name, ok := v.builtinCall(n); ok {
+			return builtinType(name)
+		}
+
 		typ := v.getTypeForNode(n.Callee)
 		if fnDeclType, ok := typ.(*types.SignatureType); ok {
+			if len(fnDeclType.TypeParameters) > 0 || len(n.TypeArguments) > 0 {
+				return v.resolveGenericCallType(n, fnDeclType)
+			}
 			return fnDeclType.ReturnType
 		}
 	case *ast.UnaryExpression:
-		return v.getTypeForNode(n.Expression)
+		return v.resolveUnaryType(n)
+	case *ast.PointerType:
+		if ref, ok := n.Elem.(*ast.TypeReference); ok && n.Const && ref.Name.Text == types.CStringTypeName {
+			return types.StringType
+		}
+		return &types.PointerType{Elem: v.getTypeForNode(n.Elem)}
 	case *ast.BinaryExpression:
 		leftType := v.getTypeForNode(n.Left)
 		rightType := v.getTypeForNode(n.Right)
@@ -171,26 +215,43 @@ func (v *visitor) resolveTypeForNode(node ast.Node) types.Type {
 
 		return leftType
 	case *ast.FunctionSignature:
+		if len(n.TypeParameters) > 0 {
+			v = v.typeParameterVisitor(v.node, v.scope, n.TypeParameters)
+		}
+
 		returnType := types.VoidType
 		if n.ReturnType != nil {
 			returnType = v.getTypeForNode(n.ReturnType)
 		}
 
-		argumentsVariables := make([]string, len(n.Arguments))
-		for i, arg := range n.Arguments {
+		// A variadic argument ends the argument list
+		arguments := n.Arguments
+		variadic := len(arguments) > 0 && arguments[len(arguments)-1].Variadic
+		if variadic {
+			arguments = arguments[:len(arguments)-1]
+		}
+
+		argumentsVariables := make([]string, len(arguments))
+		for i, arg := range arguments {
 			if arg.Name != nil {
 				argumentsVariables[i] = arg.Name.Text
 			}
 		}
 
 		return &types.SignatureType{
-			ReturnType:    returnType,
-			ArgumentTypes: v.getTypesForNodeList(convertArgumentsToNodes(n.Arguments...)...),
-			ArgumentNames: argumentsVariables,
+			ReturnType:     returnType,
+			ArgumentTypes:  v.getTypesForNodeList(convertArgumentsToNodes(arguments...)...),
+			ArgumentNames:  argumentsVariables,
+			TypeParameters: v.typeParameterTypes(n.TypeParameters),
+			Variadic:       variadic,
 		}
 	case *ast.FunctionDeclaration:
 		return v.getTypeForNode(n.Signature)
 	case *ast.Argument:
+		if n.Variadic {
+			// Variadic arguments accept values of any type
+			return types.AnyType
+		}
 		return v.getTypeForNode(n.Type)
 	case *ast.ParenExpression:
 		return v.getTypeForNode(n.Expression)
@@ -221,13 +282,23 @@ func (v *visitor) resolveTypeForNode(node ast.Node) types.Type {
 		})
 		return tp
 	case *ast.StructExpression:
+		if structDecl := v.genericStruct(n.Identifier.Text); structDecl != nil {
+			return v.resolveGenericStructExpression(n, structDecl)
+		}
 		return v.getTypeForTypeName(n.Identifier.Text)
 	case *ast.Struct:
+		if len(n.TypeParameters) > 0 {
+			v = v.typeParameterVisitor(v.node, v.scope, n.TypeParameters)
+		}
+
 		typ := &types.StructType{}
 		if n.Name != nil {
 			typ.Name = n.Name.Text
 		}
 
+		// Properties can refer to the struct itself
+		v.getNodeInfo(n).Type = typ
+
 		for _, varDecl := range n.Variables {
 			typ.Variables = append(typ.Variables, struct {
 				Name string
@@ -250,27 +321,13 @@ func (v *visitor) resolveTypeForNode(node ast.Node) types.Type {
 
 		return typ
 	case *ast.Interface:
-		typ := &types.InterfaceType{}
-		if n.Name != nil {
-			typ.Name = n.Name.Text
-		}
-
-		for _, signature := range n.Functions {
-			var name string
-			if signature.Identifier != nil {
-				name = signature.Identifier.Text
-			} else if signature.Operator != nil {
-				name = signature.Operator.Text
-			}
-			typ.Functions = append(typ.Functions, struct {
-				Name string
-				Type *types.SignatureType
-			}{name, v.getTypeForNode(signature).(*types.SignatureType)})
+		return v.resolveInterfaceType(n)
+	case *ast.MemberExpression:
+		if enum := v.enumReference(n.Target); enum != nil {
+			return v.resolveVariantType(enum, n)
 		}
 
-		return typ
-	case *ast.MemberExpression:
-		targetType := v.getTypeForNode(n.Target)
+		targetType := v.memberTargetType(n.Target)
 		if typeWithMembersType, ok := targetType.(types.TypeWithMembers); ok {
 			if ok, typ := typeWithMembersType.HasMember(n.Property.Text); ok {
 				return typ
@@ -283,6 +340,12 @@ func (v *visitor) resolveTypeForNode(node ast.Node) types.Type {
 			targetType.GetName(),
 			n.Property.Text,
 		), true)
+	case *ast.Enum:
+		return v.resolveEnumType(n)
+	case *ast.MatchExpression:
+		return v.resolveMatchType(n)
+	case *ast.TypeAssertion:
+		return v.resolveTypeAssertionType(n)
 	case *CustomTypeResolvingScopeItem:
 		return n.ResolvedType
 	default:
@@ -293,13 +356,7 @@ func (v *visitor) resolveTypeForNode(node ast.Node) types.Type {
 }
 
 func (v *visitor) getNodeInfo(node ast.Node) *NodeInfo {
-	if nodeInfo, ok := v.info.NodeInfo[node]; !ok {
-		nodeInfo = &NodeInfo{}
-		v.info.NodeInfo[node] = nodeInfo
-		return nodeInfo
-	} else {
-		return nodeInfo
-	}
+	return v.info.nodeInfo(node)
 }
 
 func (v *visitor) getTypeForNode(node ast.Node) types.Type {
@@ -353,6 +410,41 @@ func (v *visitor) isEqualType(a ast.Node, b ast.Node) (bool, types.Type, types.T
 	return aType.IsEqual(bType), aType, bType
 }
 
+// isAssignableType returns true if the value b can be assigned to a
+func (v *visitor) isAssignableType(a ast.Node, b ast.Node) (bool, types.Type, types.Type) {
+	aType := v.getTypeForNode(a)
+	bType := v.getTypeForNode(b)
+	if aType == nil || bType == nil {
+		return false, aType, bType
+	}
+
+	if isIntegerLiteral(b) && types.IsInteger(aType) {
+		// Integer literals take the integer type they are assigned to
+		v.getNodeInfo(b).Type = aType
+		return true, aType, aType
+	}
+
+	return types.IsAssignable(aType, bType), aType, bType
+}
+
+func isIntegerLiteral(node ast.Node) bool {
+	value, ok := node.(*ast.ValueExpression)
+	return ok && value.Token.Type == scanner.TokenTypeNumber
+}
+
+// definesArithmetic returns true if operator can be applied to operands of
+// typ. Strings can only be concatenated. Operations on type parameters are
+// checked in each instance of the generic declaration with the type arguments
+func definesArithmetic(typ types.Type, operator scanner.Token) bool {
+	switch typ := types.LazyResolve(typ).(type) {
+	case *types.TypeParameterType, types.UnknownType:
+		return true
+	case types.PrimitiveType:
+		return types.IsNumeric(typ) || (typ == types.StringType && operator.Type == scanner.TokenTypeADD)
+	}
+	return false
+}
+
 func (v *visitor) validateTypeConversion(call *ast.FunctionCall) bool {
 	if ident, ok := call.Callee.(*ast.Identifier); ok {
 		typ := v.getType(ident.Text)
@@ -381,16 +473,7 @@ func (v *visitor) validateTypeConversion(call *ast.FunctionCall) bool {
 				return true
 			}
 
-			conversionOk := false
-			switch exprType {
-			case types.Float32Type, types.Float64Type, types.Int32Type, types.Int64Type:
-				switch typ {
-				case types.Float32Type, types.Float64Type, types.Int32Type, types.Int64Type:
-					conversionOk = true
-				}
-			}
-
-			if !conversionOk {
+			if !types.IsNumeric(exprType) || !types.IsNumeric(typ) {
 				// cannot convert "" (type string) to type int
 				v.emitError(
 					call,
@@ -426,7 +509,7 @@ func (v *visitor) checkAutoComplete(ident *ast.Identifier) {
 
 	switch parent := v.node.(type) {
 	case *ast.MemberExpression:
-		targetType := v.getTypeForNode(parent.Target)
+		targetType := v.memberTargetType(parent.Target)
 		if targeType, targeTypeOk := targetType.(types.TypeWithMembers); targeTypeOk {
 			members := targeType.GetMembers()
 			for _, mem := range members {
@@ -515,6 +598,17 @@ func (v *visitor) Visit(node ast.Node) ast.Visitor {
 	nodeInfo.Parent = v.getNodeInfo(v.node)
 	nodeInfo.Parent.Children = append(nodeInfo.Parent.Children, nodeInfo)
 
+	switch node.(type) {
+	case *ast.ValueExpression, *ast.BinaryExpression, *ast.ComparisonExpression, *ast.UnaryExpression,
+		*ast.ParenExpression, *ast.TupleExpression, *ast.StructExpression, *ast.FunctionCall,
+		*ast.VariableDeclaration, *ast.TupleDeclaration, *ast.Argument,
+		*ast.ArrayExpression, *ast.MapExpression, *ast.IndexExpression, *ast.SliceExpression,
+		*ast.MatchExpression, *ast.TypeAssertion:
+		// Resolve types eagerly so that code generators can rely on NodeInfo.Type
+		// even for values that are never referenced
+		v.getTypeForNode(node)
+	}
+
 typeCheck:
 	switch n := node.(type) {
 	case *ast.Identifier:
@@ -539,6 +633,15 @@ typeCheck:
 			if n.Property == node {
 				break typeCheck
 			}
+			// Enum variants are referred to through the enum
+			if n.Target == node && v.enumReference(n.Target) != nil {
+				break typeCheck
+			}
+		case *ast.ForInLoop:
+			// Loop variables are declared by the loop
+			if n.Key == node || n.Value == node {
+				break typeCheck
+			}
 		case *ast.FunctionCall:
 			// check if call is a typecast
 			if ident, ok := n.Callee.(*ast.Identifier); ok {
@@ -547,7 +650,12 @@ typeCheck:
 					break typeCheck
 				}
 			}
-		case ast.Declaration, *ast.StructExpression, *ast.Struct, *ast.Interface, *ast.TypeReference:
+
+			if _, ok := v.builtinCall(n); ok && n.Callee == node {
+				break typeCheck
+			}
+		case ast.Declaration, *ast.StructExpression, *ast.Struct, *ast.Interface, *ast.TypeReference,
+			*ast.Enum, *ast.EnumVariant, *ast.TypeParameter:
 			break typeCheck
 		}
 
@@ -558,13 +666,19 @@ typeCheck:
 			}
 		}
 
-		scopeItem := v.scope.Get(n.Text, true)
+		scopeItem := v.scope.Lookup(n, true)
 		if scopeItem == nil {
 			v.emitError(n, fmt.Sprintf("undefined: %s", n), true)
 			break
 		}
 
 		v.scope.MarkUsage(scopeItem, n)
+		v.getTypeForNode(n)
+
+		// Calls instantiate generic functions
+		if call, ok := v.node.(*ast.FunctionCall); !ok || call.Callee != n {
+			v.checkGenericValue(n)
+		}
 	case *ast.FunctionCall:
 		// Check if function call is a typecast
 		if ident, ok := n.Callee.(*ast.Identifier); ok {
@@ -576,6 +690,12 @@ typeCheck:
 			}
 		}
 
+		if name, ok := v.builtinCall(n); ok {
+			nodeInfo.Builtin = name
+			v.validateBuiltinCall(n, name)
+			break
+		}
+
 		funcType := v.getTypeForNode(n.Callee)
 		if signType, ok := funcType.(*types.SignatureType); !ok {
 			v.emitError(
@@ -583,6 +703,10 @@ typeCheck:
 				fmt.Sprintf("%s (type %s) is not a function", n.Callee, funcType.GetName()),
 				true)
 			break
+		} else if len(signType.TypeParameters) > 0 {
+			// Generic functions which could not be instantiated have already
+			// been reported
+			break
 		} else {
 			usedArgs := map[string]bool{}
 			namedArgs := false
@@ -614,7 +738,7 @@ typeCheck:
 
 				if len(signType.ArgumentNames) > i {
 					argName := signType.ArgumentNames[i]
-					if _, ok := usedArgs[argName]; ok {
+					if _, ok := usedArgs[argName]; ok && argName != "" {
 						v.emitError(
 							callArg,
 							fmt.Sprintf("argument %s already defined", argName),
@@ -625,7 +749,7 @@ typeCheck:
 
 					fnArgType := signType.ArgumentTypes[i]
 					exprType := v.getTypeForNode(callArg.Expression)
-					equal := fnArgType.IsEqual(exprType)
+					equal := types.IsAssignable(fnArgType, exprType)
 
 					if !equal {
 						v.emitError(callArg.Expression, fmt.Sprintf(
@@ -644,7 +768,7 @@ typeCheck:
 						"too few arguments in call to %s",
 						n.Callee,
 					), true)
-				} else if len(n.Arguments) > len(signType.ArgumentTypes) {
+				} else if len(n.Arguments) > len(signType.ArgumentTypes) && !signType.Variadic {
 					v.emitError(n, fmt.Sprintf(
 						"too many arguments in call to %s",
 						n.Callee,
@@ -654,7 +778,7 @@ typeCheck:
 		}
 
 	case *ast.StructExpression:
-		identType := types.LazyResolve(v.getTypeForTypeName(n.Identifier.Text))
+		identType := types.LazyResolve(v.getTypeForNode(n))
 		if structType, structTypeOk := identType.(*types.StructType); !structTypeOk {
 			v.emitError(
 				n,
@@ -703,7 +827,7 @@ typeCheck:
 
 					structArgType := structType.Variables[i].Type
 					exprType := v.getTypeForNode(callArg.Expression)
-					equal := structArgType.IsEqual(exprType)
+					equal := types.IsAssignable(structArgType, exprType)
 
 					if !equal {
 						v.emitError(callArg.Expression, fmt.Sprintf(
@@ -748,7 +872,7 @@ typeCheck:
 		}
 
 		returnType := v.getTypeForNode(n.Expression)
-		equal := funcDeclType.ReturnType.IsEqual(returnType)
+		equal := types.IsAssignable(funcDeclType.ReturnType, returnType)
 
 		if !equal {
 			v.emitError(n.Expression, fmt.Sprintf(
@@ -800,6 +924,16 @@ typeCheck:
 				aType.GetName(),
 				bType.GetName(),
 			), true)
+			break
+		}
+
+		if !definesArithmetic(aType, n.Operator) {
+			v.emitError(n, fmt.Sprintf(
+				"invalid operation: %s (operator %s not defined on %s)",
+				n,
+				n.Operator.Text,
+				aType.GetName(),
+			), true)
 		}
 
 	case *ast.ComparisonExpression:
@@ -817,7 +951,7 @@ typeCheck:
 	case *ast.Argument:
 		if n.DefaultValue != nil {
 			if n.Type != nil {
-				equal, aType, bType := v.isEqualType(n, n.DefaultValue)
+				equal, aType, bType := v.isAssignableType(n, n.DefaultValue)
 
 				if !equal {
 					v.emitError(n.DefaultValue, fmt.Sprintf(
@@ -834,7 +968,7 @@ typeCheck:
 			// TODO figure out why we arrive here
 			break
 		}
-		scopeItem := v.scope.Get(n.Name.Text, false)
+		scopeItem := v.scope.Get(bindingName(n.Name), false)
 		if scopeItem != nil {
 			v.emitError(n, fmt.Sprintf("%s already declared", n.Name), true)
 			break
@@ -851,19 +985,23 @@ typeCheck:
 		// Struct member function dont need to be added to scope
 		structParen, structParentOk := v.node.(*ast.Struct)
 
+		if _, fileOk := v.node.(*ast.File); len(n.Signature.TypeParameters) > 0 && !fileOk {
+			v.emitError(n, fmt.Sprintf("generic function %s must be declared at the top level", n.Signature.Identifier), true)
+			return nil
+		}
+
 		if n.Signature.Identifier != nil {
-			scopeItem := v.scope.Get(n.Signature.Identifier.Text, false)
+			scopeItem := v.scope.Get(bindingName(n.Signature.Identifier), false)
 			if scopeItem != nil {
 				v.emitError(n, fmt.Sprintf("%s already declared", n.Signature.Identifier), true)
 				break
 			}
 
-			if structParentOk {
-				break
+			// Member functions still get their own scope for their arguments
+			if !structParentOk {
+				v.scope.Set(n.Signature.Identifier, n)
 			}
 
-			v.scope.Set(n.Signature.Identifier, n)
-
 		} else if n.Signature.Operator != nil {
 			argCount := len(n.Signature.Arguments)
 			if argCount != 2 {
@@ -890,11 +1028,12 @@ typeCheck:
 			}
 		}
 
-		return v.subVisitor(node, v.scope.SubScope(node))
+		v.getTypeForNode(n)
+		return v.typeParameterVisitor(node, v.scope.SubScope(node), n.Signature.TypeParameters)
 	case *ast.TupleDeclaration:
 		if n.DefaultValue != nil {
 			if n.Type != nil {
-				equal, aType, bType := v.isEqualType(n, n.DefaultValue)
+				equal, aType, bType := v.isAssignableType(n, n.DefaultValue)
 
 				if !equal {
 					v.emitError(n.DefaultValue, fmt.Sprintf(
@@ -935,7 +1074,7 @@ typeCheck:
 	case *ast.VariableDeclaration:
 		if n.DefaultValue != nil {
 			if n.Type != nil {
-				equal, aType, bType := v.isEqualType(n, n.DefaultValue)
+				equal, aType, bType := v.isAssignableType(n, n.DefaultValue)
 
 				if !equal {
 					v.emitError(n.DefaultValue, fmt.Sprintf(
@@ -949,12 +1088,16 @@ typeCheck:
 			}
 		}
 
+		if n.DefaultValue != nil {
+			v.checkGenericValue(n.DefaultValue)
+		}
+
 		// Struct properties dont need to be added to scope
 		if _, structParentOk := v.node.(*ast.Struct); structParentOk {
 			break
 		}
 
-		scopeItem := v.scope.Get(n.Name.Text, false)
+		scopeItem := v.scope.Get(bindingName(n.Name), false)
 		if scopeItem != nil {
 			v.emitError(n, fmt.Sprintf("%s already declared", n.Name), true)
 			break
@@ -962,7 +1105,16 @@ typeCheck:
 
 		v.scope.Set(n.Name, n)
 	case *ast.Assigment:
-		equal, leftType, rightType := v.isEqualType(n.Left, n.Right)
+		if _, ok := n.Left.(*ast.SliceExpression); ok {
+			v.emitError(n.Left, fmt.Sprintf("cannot assign to %s", n.Left), true)
+			break
+		}
+
+		if !v.checkMutable(n.Left) {
+			break
+		}
+
+		equal, leftType, rightType := v.isAssignableType(n.Left, n.Right)
 		if !equal {
 			v.emitError(n.Right, fmt.Sprintf(
 				"cannot use %s (type %s) as type %s in assigment expression",
@@ -978,16 +1130,72 @@ typeCheck:
 		if n.Name != nil {
 			v.info.Types[n.Name.Text] = n
 		}
+
+		if len(n.TypeParameters) > 0 {
+			return v.typeParameterVisitor(node, v.scope, n.TypeParameters)
+		}
 	case *ast.Interface:
 		// TODO check that it is not redeclared
-		// TODO check that no property or function is double declared
 		nodeInfo.Type = v.getTypeForNode(node)
 		if n.Name != nil {
 			v.info.Types[n.Name.Text] = n
 		}
+	case *ast.File:
+		// Enum variants, generic structs and embedded interfaces can be
+		// referred to before they are declared
+		for _, node := range n.Body {
+			switch decl := node.(type) {
+			case *ast.Enum:
+				if decl.Name != nil {
+					v.info.Types[decl.Name.Text] = decl
+				}
+			case *ast.Interface:
+				if decl.Name != nil {
+					v.info.Types[decl.Name.Text] = decl
+				}
+			case *ast.Struct:
+				if decl.Name != nil && len(decl.TypeParameters) > 0 {
+					v.info.Types[decl.Name.Text] = decl
+				}
+			}
+		}
+	case *ast.Enum:
+		nodeInfo.Type = v.getTypeForNode(node)
+		v.checkEnum(n)
+		if n.Name != nil {
+			v.info.Types[n.Name.Text] = n
+		}
+	case *ast.MatchExpression:
+		v.visitMatchExpression(n)
+		return nil
+	case *ast.TypeAssertion:
+		v.checkTypeAssertion(n)
+	case *ast.TypeSwitch:
+		v.visitTypeSwitch(n)
+		return nil
+	case *ast.ArrayExpression:
+		v.checkArrayExpression(n)
+	case *ast.MapType:
+		v.checkMapType(n)
+	case *ast.MapExpression:
+		v.checkMapExpression(n)
+	case *ast.ForInLoop:
+		v.visitForInLoop(n)
+		return nil
+	case *ast.UnaryExpression:
+		v.checkUnaryExpression(n)
+	case *ast.IndexExpression:
+		v.checkIndexExpression(n)
+	case *ast.SliceExpression:
+		v.checkSliceExpression(n)
 	case *ast.MemberExpression:
 		nodeInfo.Type = v.getTypeForNode(node)
-		targetType := v.getTypeForNode(n.Target)
+		if enum := v.enumReference(n.Target); enum != nil {
+			nodeInfo.Variant = v.variant(enum, n)
+			break
+		}
+
+		targetType := v.memberTargetType(n.Target)
 		if typeWithMembersType, ok := targetType.(types.TypeWithMembers); ok {
 			if ok, _ := typeWithMembersType.HasMember(n.Property.Text); ok {
 				break
@@ -1030,6 +1238,10 @@ func (v *visitor) processUnusedVariables() {
 			break
 		}
 
+		if scopeItemInfo.DefineIdentifier.Text == wildcard {
+			continue
+		}
+
 		v.emitError(scopeItemInfo.DefineIdentifier,
 			fmt.Sprintf("%s declared but not used", scopeItemInfo.DefineIdentifier.Text),
 			false)
@@ -1048,11 +1260,20 @@ func (v *visitor) Leave(node ast.Node) {
 
 				for scopeItem, refs := range v.scope.GetReferencedItems() {
 					ref := refs[0]
-					definingScope := v.scope.GetDefiningScope(ref.Text)
-					if _, ok := definingScope.node.(*ast.File); !ok {
-						// Not defined in root scope so reference needed
-						closure.Env = append(closure.Env, scopeItem)
+					refScope := v.getNodeInfo(ref).Scope
+					name := refScope.Name(ref)
+					definingScope := refScope.GetDefiningScope(name)
+					if definingScope == nil || v.scope.encloses(definingScope) {
+						// Declared inside the function
+						continue
 					}
+					if _, ok := definingScope.node.(*ast.File); ok {
+						// Defined in root scope so no reference needed
+						continue
+					}
+
+					closure.Env = append(closure.Env, scopeItem)
+					closure.Captures = append(closure.Captures, definingScope.items[name].DefineIdentifier)
 
 					nodeInfo := v.getNodeInfo(scopeItem)
 					nodeInfo.Closures = append([]*Closure{closure}, nodeInfo.Closures...)
@@ -44,11 +44,15 @@ func TestVisitor(t *testing.T) {
 
 			var arrVar : []int32
 			var anotherArrVar : []int32 = arrVar
-			var anotherArrVarWithLength : [2]int32 = arrVar // TODO Will this be PITA in the runtime ?
-			anotherArrVarWithLength = []int32{1, 2}
+			var anotherArrVarWithLength : [2]int32 = [2]int32{1, 2}
+			anotherArrVarWithLength = [2]int32{3}
+			arrVar = anotherArrVarWithLength
 			var initArrVar = []int32{1, 2}
 			arrVar = initArrVar
-			//var value : int32 = initArrVar[0]
+			var value : int32 = initArrVar[0]
+			initArrVar[value] = anotherArrVarWithLength[1]
+			arrVar = anotherArrVarWithLength[1:]
+			arrVar = initArrVar[:len(initArrVar)]
 
 			var boolValue : bool = true
 			boolValue = false
@@ -70,8 +74,41 @@ func TestVisitor(t *testing.T) {
 				strVal = a.toString()
 			}
 
+			var ptr : *int32 = &bar
+			*ptr = *ptr + 1
+			*ptr++
+			var ptrPtr = &ptr
+			**ptrPtr = 2
+			const constPtr = ptr
+			*constPtr = 3
+			var elemPtr = &initArrVar[0]
+			*elemPtr = bar
+
+			var shape = Shape.Circle(1.0)
+			shape = Shape.Empty
+			var area : float32 = match shape {
+				Circle(r) => r * r,
+				Rect(w, (h, _)) => w * h,
+				Empty => 0.0
+			}
+			area = area + match shape {
+				Circle(r) => r,
+				_ => 0.0
+			}
+			match Shape.Rect(1.0, (2.0, 3)) {
+				Circle(_) => { area = 1.0 }
+				Rect(_, _) => area = 2.0,
+				Empty => {}
+			}
+
 			return
 		}
+
+		enum Shape {
+			Circle(float32)
+			Rect(float32, (float32, int32))
+			Empty
+		}
   `))
 	if err != nil {
 		t.Error(err)
@@ -127,6 +164,16 @@ func TestVisitorErrors(t *testing.T) {
 				1 + 0.5
 			}
 		`, "3:5 invalid operation: 1 + 0.5 (mismatched types int32 and float32)"},
+		{`
+			fn foo() {
+				"a" - "b"
+			}
+		`, "3:5 invalid operation: \"a\" - \"b\" (operator - not defined on string)"},
+		{`
+			fn foo() {
+				true + false
+			}
+		`, "3:5 invalid operation: true + false (operator + not defined on bool)"},
 		{`
 			fn foo(x : int32 = 0.5) {
 			}
@@ -518,6 +565,479 @@ func TestVisitorErrors(t *testing.T) {
 				str.toString()
 			}
 		`, ""},
+		{`
+			fn main() {
+				var arr = [2]int32{1, 2, 3}
+				arr
+			}
+		`, "3:15 too many elements in [2]int32{1, 2, 3} (array length 2)"},
+		{`
+			fn main() {
+				var arr = []int32{1, 0.5}
+				arr
+			}
+		`, "3:26 cannot use 0.5 (type float32) as type int32 in array literal"},
+		{`
+			fn main() {
+				var arr = [2]int32{1, 2}
+				arr[2] = 1
+			}
+		`, "4:9 invalid index 2 (out of bounds for 2-element array)"},
+		{`
+			fn main() {
+				var arr = []int32{1, 2}
+				arr[-1] = 1
+			}
+		`, "4:9 invalid index -1 (index must be non-negative)"},
+		{`
+			fn main() {
+				var arr = []int32{1, 2}
+				var value = arr[0.5]
+				value
+			}
+		`, "4:21 invalid index 0.5 (type float32 must be integer)"},
+		{`
+			fn main() {
+				var notArr = 1
+				var value = notArr[0]
+				value
+			}
+		`, "4:17 cannot index notArr (type int32)"},
+		{`
+			fn main() {
+				var arr = [2]int32{1, 2}
+				var slice = arr[1:3]
+				slice
+			}
+		`, "4:23 invalid index 3 (out of bounds for 2-element array)"},
+		{`
+			fn main() {
+				var arr = []int32{1, 2}
+				var slice = arr[2:1]
+				slice
+			}
+		`, "4:17 invalid slice indices: 2 > 1"},
+		{`
+			fn main() {
+				var arr = []int32{1, 2}
+				var fixed : [2]int32 = arr[0:2]
+				fixed
+			}
+		`, "4:28 cannot use arr[0:2] (type []int32) as type [2]int32 in assigment"},
+		{`
+			fn main() {
+				var arr = []int32{1, 2}
+				arr[0:1] = arr
+			}
+		`, "4:5 cannot assign to arr[0:1]"},
+		{`
+			fn main() {
+				var length = len(1)
+				length
+			}
+		`, "3:22 invalid argument 1 (type int32) for len"},
+		{`
+			fn main() {
+				var arr = []int32{1, 2}
+				var length : int32 = len(arr, arr)
+				length
+			}
+		`, "4:26 wrong number of arguments to len: expected 1, found 2"},
+		{`
+			fn len(x : int32) => string {
+				return x.toString()
+			}
+
+			fn main() {
+				var length : string = len(1)
+				length
+			}
+		`, ""},
+		{`
+			fn main() {
+				var m = map[string]int32{"a": 1}
+				var value : int32 = m["a"]
+				m["b"] = value
+				delete(m, "a")
+				var size : int32 = len(m)
+				size
+				for key, value in m {
+					m[key] = value + 1
+				}
+			}
+		`, ""},
+		{`
+			fn main() {
+				var m = map[[]int32]int32{}
+				m
+			}
+		`, "3:17 invalid map key type []int32"},
+		{`
+			fn main() {
+				var m = map[string]int32{"a": 1, "a": 2}
+				m
+			}
+		`, "3:38 duplicate key \"a\" in map literal"},
+		{`
+			fn main() {
+				var m = map[string]int32{1: 1}
+				m
+			}
+		`, "3:30 cannot use 1 (type int32) as type string in map key"},
+		{`
+			fn main() {
+				var m = map[string]int32{"a": "b"}
+				m
+			}
+		`, "3:35 cannot use \"b\" (type string) as type int32 in map value"},
+		{`
+			fn main() {
+				var m = map[string]int32{}
+				m[1] = 1
+			}
+		`, "4:7 cannot use 1 (type int32) as type string in map index"},
+		{`
+			fn main() {
+				var m = map[string]int32{}
+				var s = m[0:1]
+				s
+			}
+		`, "4:13 cannot slice m (type map[string]int32)"},
+		{`
+			fn main() {
+				var m = map[string]int32{}
+				delete(m, 1)
+			}
+		`, "4:15 cannot use 1 (type int32) as type string in argument to delete"},
+		{`
+			fn main() {
+				var notMap = 1
+				for key in notMap {
+				}
+			}
+		`, "4:16 cannot range over notMap (type int32)"},
+		{`
+			fn main() {
+				var arr = []string{"a"}
+				for i, value in arr {
+					var str : string = i
+					str
+					value
+				}
+			}
+		`, "5:25 cannot use i (type int32) as type string in assigment"},
+		{`
+			fn main() {
+				var x = 1
+				var p = &x
+				var f : float32 = *p
+				f
+			}
+		`, "5:23 cannot use *p (type int32) as type float32 in assigment"},
+		{`
+			fn main() {
+				var x = 1
+				var y = *x
+				y
+			}
+		`, "4:13 invalid indirect of x (type int32)"},
+		{`
+			fn main() {
+				const c = 1
+				var p = &c
+				p
+			}
+		`, "4:13 cannot take the address of constant c"},
+		{`
+			fn main() {
+				var p = &1
+				p
+			}
+		`, "3:13 cannot take the address of 1"},
+		{`
+			fn main() {
+				var m = map[string]int32{}
+				var p = &m["a"]
+				p
+			}
+		`, "4:13 cannot take the address of m[\"a\"]"},
+		{`
+			fn main() {
+				const c = 1
+				c = 2
+			}
+		`, "4:5 cannot assign to constant c"},
+		{`
+			fn main() {
+				const c = 1
+				c++
+			}
+		`, "4:5 cannot assign to constant c"},
+		{`
+			struct Point {
+				var x : int32
+			}
+			fn main() {
+				const p = Point{}
+				p.x = 1
+			}
+		`, "7:5 cannot assign to constant p.x"},
+		{`
+			fn main() {
+				var x = 1
+				var p : *float32 = &x
+				p
+			}
+		`, "4:24 cannot use &x (type *int32) as type *float32 in assigment"},
+		{`
+			struct Point {
+				var x : int32
+			}
+			fn main() {
+				var p = &Point{}
+				p.y = 1
+			}
+		`, "7:5 p.y undefined: (type struct Point { x: int32 } has no field or method y)"},
+		{`
+			enum Shape {
+				Circle(float32)
+				Empty
+			}
+			fn main() {
+				var s = Shape.Square
+				s
+			}
+		`, "7:13 Shape.Square undefined: (enum Shape has no variant Square)"},
+		{`
+			enum Shape {
+				Circle(float32)
+				Empty
+			}
+			fn main() {
+				var s = Shape.Circle(1)
+				s
+			}
+		`, "7:26 cannot use 1 (type int32) as type float32 in function call"},
+		{`
+			enum Shape {
+				Circle(float32)
+				Rect(float64, float64)
+				Empty
+			}
+			fn main() {
+				var s = Shape.Empty
+				var r = match s {
+					Circle(r) => r
+				}
+				r
+			}
+		`, "9:13 non-exhaustive match on enum Shape (missing Rect, Empty)"},
+		{`
+			enum Shape {
+				Circle(float32)
+				Empty
+			}
+			fn main() {
+				var s = Shape.Empty
+				var r = match s {
+					Circle(r, h) => r,
+					_ => 0.0
+				}
+				r
+			}
+		`, "9:6 wrong number of values in pattern Circle(r, h) (variant Circle has 1)"},
+		{`
+			enum Shape {
+				Circle(float32)
+				Empty
+			}
+			fn main() {
+				var s = Shape.Empty
+				var r = match s {
+					Square => 1.0,
+					_ => 0.0
+				}
+				r
+			}
+		`, "9:6 Square is not a variant of enum Shape"},
+		{`
+			enum Shape {
+				Circle(float32)
+				Empty
+			}
+			fn main() {
+				var s = Shape.Empty
+				var r = match s {
+					_ => 0.0,
+					Empty => 1.0
+				}
+				r
+			}
+		`, "10:6 unreachable pattern Empty"},
+		{`
+			enum Shape {
+				Circle(float32)
+				Empty
+			}
+			fn main() {
+				var s = Shape.Empty
+				var r = match s {
+					Circle(r) => r,
+					Empty => 1
+				}
+				r
+			}
+		`, "10:15 cannot use 1 (type int32) as type float32 in match arm"},
+		{`
+			fn main() {
+				var r = match 1 {
+					_ => 0.0
+				}
+				r
+			}
+		`, "3:19 cannot match on 1 (type int32)"},
+		{`
+			enum Shape {
+				Circle(float32)
+				Circle
+			}
+		`, "4:5 variant Circle already declared"},
+		{`
+			interface Shape {
+				fn area() => int32
+			}
+			struct Point {
+				var x : int32
+			}
+			fn foo(s : Shape) {
+				s.(Point)
+			}
+		`, "9:5 impossible type assertion: s.(Point) (Point does not implement Shape)"},
+		{`
+			struct Point {
+				var x : int32
+			}
+			fn foo(p : Point) {
+				p.(Point)
+			}
+		`, "6:5 invalid type assertion: p.(Point) (non-interface type struct Point { x: int32 } on left)"},
+		{`
+			fn foo(v : anything) {
+				v.(int32)
+			}
+		`, "3:5 invalid type assertion: v.(int32) (int32 is not a struct or interface type)"},
+		{`
+			interface Shape {
+				fn area() => int32
+			}
+			struct Point {
+				var x : int32
+			}
+			fn foo(s : Shape) {
+				switch s.(type) {
+				case Point {
+				}
+				}
+			}
+		`, "10:10 impossible type switch case: Point (Point does not implement Shape)"},
+		{`
+			struct Point {
+				var x : int32
+			}
+			fn foo(v : anything) {
+				switch v.(type) {
+				case Point {
+				}
+				case anything, Point {
+				}
+				}
+			}
+		`, "9:20 duplicate case Point in type switch"},
+		{`
+			fn foo(v : anything) {
+				switch v.(type) {
+				default {
+				}
+				default {
+				}
+				}
+			}
+		`, "6:5 multiple defaults in type switch"},
+		{`
+			fn foo(v : anything) {
+				switch var x = v.(type) {
+				default {
+				}
+				}
+			}
+		`, "3:16 x declared but not used"},
+		{`
+			interface Reader {
+				fn read() => string
+			}
+			interface Source {
+				fn read() => int32
+			}
+			interface ReadSource {
+				Reader
+				Source
+			}
+		`, "10:5 duplicate method read in interface ReadSource with different signatures (() -> string and () -> int32)"},
+		{`
+			interface Reader {
+				fn read() => string
+			}
+			interface ReadCloser {
+				Reader
+				fn read() => string
+				fn read() => string
+			}
+		`, "8:5 duplicate method read in interface ReadCloser"},
+		{`
+			interface A {
+				B
+			}
+			interface B {
+				A
+			}
+		`, "6:5 invalid recursive embedding of interface A"},
+		{`
+			struct Point {
+			}
+			interface Shape {
+				Point
+			}
+		`, "5:5 cannot embed Point (type Point is not an interface)"},
+		{`
+			interface Reader {
+				fn read() => string
+			}
+			interface Closer {
+				fn close()
+			}
+			interface ReadCloser {
+				Reader
+				Closer
+			}
+			struct File {
+				fn read() => string {
+					return ""
+				}
+			}
+			fn foo() {
+				var f = File{}
+				var rc : ReadCloser = f
+			}
+		`, "19:27 cannot use f (type struct File {  }) as type interace ReadCloser { read: () -> string, close: () -> void } in assigment"},
+		{`
+			macro declareSecret {
+				() : (var secret = 1 secret++)
+			}
+			fn foo() {
+				declareSecret!()
+				secret
+			}
+		`, "7:5 undefined: secret"},
 	}
 
 	for _, test := range tests {
@@ -21,5 +21,5 @@ func (a *Argument) EndPos() Position {
 		return a.Type.EndPos()
 	}
 
-	return a.EndPos()
+	return a.Name.EndPos()
 }
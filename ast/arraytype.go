@@ -24,5 +24,8 @@ func (at *ArrayType) EndPos() Position {
 }
 
 func (at *ArrayType) String() string {
+	if at.Length != nil {
+		return fmt.Sprintf("[%s]%s", at.Length, at.Type)
+	}
 	return fmt.Sprintf("[]%s", at.Type)
 }
@@ -0,0 +1,56 @@
+package ast
+
+import "reflect"
+
+// Copy returns a deep copy of node. Nodes referred to multiple times in the
+// tree are copied once so that the copy keeps the same shape
+func Copy(node Node) Node {
+	if node == nil {
+		return nil
+	}
+	copied := map[interface{}]reflect.Value{}
+	return deepCopy(reflect.ValueOf(node), copied).Interface().(Node)
+}
+
+func deepCopy(value reflect.Value, copied map[interface{}]reflect.Value) reflect.Value {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return value
+		}
+		if c, ok := copied[value.Interface()]; ok {
+			return c
+		}
+		c := reflect.New(value.Elem().Type())
+		copied[value.Interface()] = c
+		c.Elem().Set(deepCopy(value.Elem(), copied))
+		return c
+	case reflect.Interface:
+		if value.IsNil() {
+			return value
+		}
+		c := reflect.New(value.Type()).Elem()
+		c.Set(deepCopy(value.Elem(), copied))
+		return c
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+		c := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			c.Index(i).Set(deepCopy(value.Index(i), copied))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(value.Type()).Elem()
+		c.Set(value)
+		for i := 0; i < value.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(deepCopy(value.Field(i), copied))
+			}
+		}
+		return c
+	default:
+		return value
+	}
+}
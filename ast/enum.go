@@ -0,0 +1,36 @@
+package ast
+
+// Enum declares a sum type. Each variant can carry a list of values
+type Enum struct {
+	Start    Position
+	Name     *Identifier
+	Variants []*EnumVariant
+	End      Position
+}
+
+func (e *Enum) StartPos() Position {
+	return e.Start
+}
+
+func (e *Enum) EndPos() Position {
+	return e.End
+}
+
+// EnumVariant is a single variant of an enum. Types is empty for variants
+// without a payload
+type EnumVariant struct {
+	Name  *Identifier
+	Types []Type
+	End   Position
+}
+
+func (ev *EnumVariant) StartPos() Position {
+	return ev.Name.StartPos()
+}
+
+func (ev *EnumVariant) EndPos() Position {
+	if len(ev.Types) == 0 {
+		return ev.Name.EndPos()
+	}
+	return ev.End
+}
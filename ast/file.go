@@ -2,7 +2,9 @@ package ast
 
 type File struct {
 	Filename     string
+	Imports      []*Import
 	Body         []Node
+	Exports      []Node
 	NodeComments map[Node][]Comment
 	Comments     []Comment
 	Macros       map[string]*Macro
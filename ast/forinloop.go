@@ -0,0 +1,24 @@
+package ast
+
+// ForInLoop iterates over the elements of an array or the entries of a map.
+// Key is the index or the map key and Value is nil when omitted
+type ForInLoop struct {
+	Start      Position
+	Key        *Identifier
+	Value      *Identifier
+	Collection Expression
+	Block      *Block
+}
+
+func (forloop *ForInLoop) StartPos() Position {
+	return forloop.Start
+}
+
+func (forloop *ForInLoop) EndPos() Position {
+	if forloop.Block == nil {
+		return forloop.Start
+	}
+	return forloop.Block.End
+}
+
+func (_ *ForInLoop) stmtNode() {}
@@ -3,13 +3,14 @@ package ast
 import "github.com/orktes/orlang/scanner"
 
 type FunctionSignature struct {
-	Start      Position
-	End        Position
-	Identifier *Identifier
-	Operator   *scanner.Token
-	Arguments  []*Argument
-	ReturnType Type
-	Extern     bool
+	Start          Position
+	End            Position
+	Identifier     *Identifier
+	TypeParameters []*TypeParameter
+	Operator       *scanner.Token
+	Arguments      []*Argument
+	ReturnType     Type
+	Extern         bool
 }
 
 func (FunctionSignature) declarationNode() {}
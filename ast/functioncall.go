@@ -5,10 +5,13 @@ import (
 	"strings"
 )
 
+// FunctionCall calls Callee. TypeArguments are set when a generic function
+// is called with explicit type arguments: two<int32>(1, 2)
 type FunctionCall struct {
-	Callee    Expression
-	End       Position
-	Arguments []*CallArgument
+	Callee        Expression
+	TypeArguments []Type
+	End           Position
+	Arguments     []*CallArgument
 }
 
 func (fc *FunctionCall) StartPos() Position {
@@ -29,7 +32,17 @@ func (fc *FunctionCall) String() string {
 			names = append(names, fmt.Sprintf("%s", arg.Expression))
 		}
 	}
-	return fmt.Sprintf("%s(%s)", fc.Callee, strings.Join(names, ", "))
+
+	typeArgs := ""
+	if len(fc.TypeArguments) > 0 {
+		args := make([]string, len(fc.TypeArguments))
+		for i, arg := range fc.TypeArguments {
+			args[i] = fmt.Sprintf("%s", arg)
+		}
+		typeArgs = "<" + strings.Join(args, ", ") + ">"
+	}
+
+	return fmt.Sprintf("%s%s(%s)", fc.Callee, typeArgs, strings.Join(names, ", "))
 }
 
 func (_ FunctionCall) exprNode() {
@@ -0,0 +1,17 @@
+package ast
+
+// Import is an import "path" declaration. Exported declarations of the
+// imported file become visible in the importing file
+type Import struct {
+	Start Position
+	End   Position
+	Path  string
+}
+
+func (i *Import) StartPos() Position {
+	return i.Start
+}
+
+func (i *Import) EndPos() Position {
+	return i.End
+}
@@ -0,0 +1,59 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/orktes/orlang/scanner"
+)
+
+type IndexExpression struct {
+	Target       Expression
+	Index        Expression
+	LeftBracket  scanner.Token
+	RightBracket scanner.Token
+}
+
+func (IndexExpression) exprNode() {}
+
+func (ie *IndexExpression) StartPos() Position {
+	return ie.Target.StartPos()
+}
+
+func (ie *IndexExpression) EndPos() Position {
+	return EndPositionFromToken(ie.RightBracket)
+}
+
+func (ie *IndexExpression) String() string {
+	return fmt.Sprintf("%s[%s]", ie.Target, ie.Index)
+}
+
+// SliceExpression is an expression of the form target[low:high]. Low and High
+// are nil when omitted
+type SliceExpression struct {
+	Target       Expression
+	Low          Expression
+	High         Expression
+	LeftBracket  scanner.Token
+	RightBracket scanner.Token
+}
+
+func (SliceExpression) exprNode() {}
+
+func (se *SliceExpression) StartPos() Position {
+	return se.Target.StartPos()
+}
+
+func (se *SliceExpression) EndPos() Position {
+	return EndPositionFromToken(se.RightBracket)
+}
+
+func (se *SliceExpression) String() string {
+	low, high := "", ""
+	if se.Low != nil {
+		low = fmt.Sprintf("%s", se.Low)
+	}
+	if se.High != nil {
+		high = fmt.Sprintf("%s", se.High)
+	}
+	return fmt.Sprintf("%s[%s:%s]", se.Target, low, high)
+}
@@ -4,7 +4,9 @@ type Interface struct {
 	Start     Position
 	Name      *Identifier
 	Functions []*FunctionSignature
-	End       Position
+	// Embedded are the interfaces whose methods are part of the interface
+	Embedded []*TypeReference
+	End      Position
 }
 
 func (i *Interface) StartPos() Position {
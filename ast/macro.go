@@ -7,6 +7,8 @@ type Macro struct {
 	End      Position
 	Name     scanner.Token
 	Patterns []*MacroPattern
+	// Filename is the file the macro is declared in
+	Filename string
 }
 
 func (mcr *Macro) StartPos() Position {
@@ -0,0 +1,52 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/orktes/orlang/scanner"
+)
+
+type MapEntry struct {
+	Key   Expression
+	Value Expression
+}
+
+func (me *MapEntry) StartPos() Position {
+	return me.Key.StartPos()
+}
+
+func (me *MapEntry) EndPos() Position {
+	return me.Value.EndPos()
+}
+
+func (me *MapEntry) String() string {
+	return fmt.Sprintf("%s: %s", me.Key, me.Value)
+}
+
+type MapExpression struct {
+	Type       *MapType
+	Entries    []*MapEntry
+	LeftBrace  scanner.Token
+	RightBrace scanner.Token
+}
+
+func (MapExpression) exprNode() {}
+
+func (me *MapExpression) StartPos() Position {
+	return me.Type.StartPos()
+}
+
+func (me *MapExpression) EndPos() Position {
+	return EndPositionFromToken(me.RightBrace)
+}
+
+func (me *MapExpression) String() string {
+	entries := []string{}
+
+	for _, entry := range me.Entries {
+		entries = append(entries, entry.String())
+	}
+
+	return fmt.Sprintf("%s{%s}", me.Type, strings.Join(entries, ", "))
+}
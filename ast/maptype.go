@@ -0,0 +1,29 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/orktes/orlang/scanner"
+)
+
+type MapType struct {
+	Map          scanner.Token
+	LeftBracket  scanner.Token
+	RightBracket scanner.Token
+	Key          Type
+	Value        Type
+}
+
+func (MapType) typeNode() {}
+
+func (mt *MapType) StartPos() Position {
+	return StartPositionFromToken(mt.Map)
+}
+
+func (mt *MapType) EndPos() Position {
+	return mt.Value.EndPos()
+}
+
+func (mt *MapType) String() string {
+	return fmt.Sprintf("map[%s]%s", mt.Key, mt.Value)
+}
@@ -0,0 +1,36 @@
+package ast
+
+// MatchExpression selects the first arm whose pattern matches the enum value
+// of Subject
+type MatchExpression struct {
+	Start   Position
+	Subject Expression
+	Arms    []*MatchArm
+	End     Position
+}
+
+func (MatchExpression) exprNode() {}
+
+func (me *MatchExpression) StartPos() Position {
+	return me.Start
+}
+
+func (me *MatchExpression) EndPos() Position {
+	return me.End
+}
+
+// MatchArm is a single arm of a match expression. Pattern is either a
+// VariantPattern or an identifier naming a variant without a payload or the
+// wildcard _. Body is an expression or a block
+type MatchArm struct {
+	Pattern Pattern
+	Body    Node
+}
+
+func (ma *MatchArm) StartPos() Position {
+	return ma.Pattern.StartPos()
+}
+
+func (ma *MatchArm) EndPos() Position {
+	return ma.Body.EndPos()
+}
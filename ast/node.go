@@ -5,6 +5,17 @@ import "github.com/orktes/orlang/scanner"
 type Position struct {
 	Line   int
 	Column int
+	// Expansion is the macro expansion of the token the position is from
+	Expansion *scanner.Expansion `json:"-"`
+}
+
+// Expansions returns the macro expansions the position is in starting from
+// the innermost one
+func (p Position) Expansions() (expansions []*scanner.Expansion) {
+	for expansion := p.Expansion; expansion != nil; expansion = expansion.Call.Expansion {
+		expansions = append(expansions, expansion)
+	}
+	return
 }
 
 type Node interface {
@@ -13,9 +24,9 @@ type Node interface {
 }
 
 func StartPositionFromToken(token scanner.Token) Position {
-	return Position{Line: token.StartLine, Column: token.StartColumn}
+	return Position{Line: token.StartLine, Column: token.StartColumn, Expansion: token.Expansion}
 }
 
 func EndPositionFromToken(token scanner.Token) Position {
-	return Position{Line: token.EndLine, Column: token.EndColumn}
+	return Position{Line: token.EndLine, Column: token.EndColumn, Expansion: token.Expansion}
 }
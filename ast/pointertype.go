@@ -0,0 +1,30 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/orktes/orlang/scanner"
+)
+
+type PointerType struct {
+	Star  scanner.Token
+	Const bool
+	Elem  Type
+}
+
+func (PointerType) typeNode() {}
+
+func (pt *PointerType) StartPos() Position {
+	return StartPositionFromToken(pt.Star)
+}
+
+func (pt *PointerType) EndPos() Position {
+	return pt.Elem.EndPos()
+}
+
+func (pt *PointerType) String() string {
+	if pt.Const {
+		return fmt.Sprintf("*const %s", pt.Elem)
+	}
+	return fmt.Sprintf("*%s", pt.Elem)
+}
@@ -1,11 +1,12 @@
 package ast
 
 type Struct struct {
-	Start     Position
-	Name      *Identifier
-	Variables []*VariableDeclaration
-	Functions []*FunctionDeclaration
-	End       Position
+	Start          Position
+	Name           *Identifier
+	TypeParameters []*TypeParameter
+	Variables      []*VariableDeclaration
+	Functions      []*FunctionDeclaration
+	End            Position
 }
 
 func (sd *Struct) StartPos() Position {
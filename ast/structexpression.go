@@ -1,9 +1,10 @@
 package ast
 
 type StructExpression struct {
-	Identifier *Identifier
-	End        Position
-	Arguments  []*CallArgument
+	Identifier    *Identifier
+	TypeArguments []Type
+	End           Position
+	Arguments     []*CallArgument
 }
 
 func (StructExpression) exprNode() {}
@@ -0,0 +1,27 @@
+package ast
+
+import "fmt"
+
+// TypeAssertion asserts that the interface value of Expression holds a value
+// of Type. Assertions with Ok set evaluate to a tuple of the value and
+// whether the assertion succeeded instead of failing
+type TypeAssertion struct {
+	Expression Expression
+	Type       Type
+	Ok         bool
+	End        Position
+}
+
+func (TypeAssertion) exprNode() {}
+
+func (ta *TypeAssertion) StartPos() Position {
+	return ta.Expression.StartPos()
+}
+
+func (ta *TypeAssertion) EndPos() Position {
+	return ta.End
+}
+
+func (ta *TypeAssertion) String() string {
+	return fmt.Sprintf("%s.(%s)", ta.Expression, ta.Type)
+}
@@ -0,0 +1,29 @@
+package ast
+
+import "fmt"
+
+// TypeParameter declares a type parameter of a generic function or struct.
+// Constraint is the interface the type arguments have to implement and nil
+// when any type is accepted
+type TypeParameter struct {
+	Name       *Identifier
+	Constraint Type
+}
+
+func (tp *TypeParameter) StartPos() Position {
+	return tp.Name.StartPos()
+}
+
+func (tp *TypeParameter) EndPos() Position {
+	if tp.Constraint != nil {
+		return tp.Constraint.EndPos()
+	}
+	return tp.Name.EndPos()
+}
+
+func (tp *TypeParameter) String() string {
+	if tp.Constraint != nil {
+		return fmt.Sprintf("%s : %s", tp.Name, tp.Constraint)
+	}
+	return tp.Name.Text
+}
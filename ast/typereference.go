@@ -1,7 +1,16 @@
 package ast
 
+import (
+	"fmt"
+	"strings"
+)
+
+// TypeReference refers to a named type. TypeArguments are set when a generic
+// struct is instantiated with explicit type arguments as in Box<int32>
 type TypeReference struct {
-	Name *Identifier
+	Name          *Identifier
+	TypeArguments []Type
+	End           Position
 }
 
 func (pt *TypeReference) StartPos() Position {
@@ -9,11 +18,23 @@ func (pt *TypeReference) StartPos() Position {
 }
 
 func (pt *TypeReference) EndPos() Position {
+	if len(pt.TypeArguments) > 0 {
+		return pt.End
+	}
 	return pt.Name.EndPos()
 }
 
 func (pt *TypeReference) String() string {
-	return pt.Name.Text
+	if len(pt.TypeArguments) == 0 {
+		return pt.Name.Text
+	}
+
+	args := make([]string, len(pt.TypeArguments))
+	for i, arg := range pt.TypeArguments {
+		args[i] = fmt.Sprintf("%s", arg)
+	}
+
+	return fmt.Sprintf("%s<%s>", pt.Name.Text, strings.Join(args, ", "))
 }
 
 func (_ *TypeReference) typeNode() {}
@@ -0,0 +1,39 @@
+package ast
+
+// TypeSwitch runs the block of the first case listing the type of the value
+// held by the interface value of Subject. Binding is declared in every case
+// with the type of the case if the case lists a single type and with the type
+// of Subject otherwise
+type TypeSwitch struct {
+	Start   Position
+	Binding *Identifier
+	Subject Expression
+	Cases   []*TypeSwitchCase
+	End     Position
+}
+
+func (ts *TypeSwitch) StartPos() Position {
+	return ts.Start
+}
+
+func (ts *TypeSwitch) EndPos() Position {
+	return ts.End
+}
+
+func (_ *TypeSwitch) stmtNode() {}
+
+// TypeSwitchCase is a single case of a type switch. Cases without types are
+// the default case
+type TypeSwitchCase struct {
+	Start Position
+	Types []Type
+	Block *Block
+}
+
+func (tc *TypeSwitchCase) StartPos() Position {
+	return tc.Start
+}
+
+func (tc *TypeSwitchCase) EndPos() Position {
+	return tc.Block.End
+}
@@ -1,6 +1,10 @@
 package ast
 
-import "github.com/orktes/orlang/scanner"
+import (
+	"fmt"
+
+	"github.com/orktes/orlang/scanner"
+)
 
 type UnaryExpression struct {
 	Expression
@@ -22,4 +26,11 @@ func (u *UnaryExpression) EndPos() Position {
 	return u.Expression.EndPos()
 }
 
+func (u *UnaryExpression) String() string {
+	if u.Postfix {
+		return fmt.Sprintf("%s%s", u.Expression, u.Operator.Text)
+	}
+	return fmt.Sprintf("%s%s", u.Operator.Text, u.Expression)
+}
+
 func (_ *UnaryExpression) exprNode() {}
@@ -0,0 +1,23 @@
+package ast
+
+import "fmt"
+
+// VariantPattern matches an enum variant and destructures its payload
+type VariantPattern struct {
+	Name    *Identifier
+	Payload *TuplePattern
+}
+
+func (VariantPattern) patternNode() {}
+
+func (vp *VariantPattern) StartPos() Position {
+	return vp.Name.StartPos()
+}
+
+func (vp *VariantPattern) EndPos() Position {
+	return vp.Payload.EndPos()
+}
+
+func (vp *VariantPattern) String() string {
+	return fmt.Sprintf("%s%s", vp.Name, vp.Payload)
+}
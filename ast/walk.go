@@ -62,13 +62,26 @@ func Walk(v Visitor, node Node) {
 		Walk(v, n.Condition)
 		Walk(v, n.After)
 		Walk(v, n.Block)
+	case *ForInLoop:
+		Walk(v, n.Key)
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+		Walk(v, n.Collection)
+		Walk(v, n.Block)
 	case *FunctionCall:
 		Walk(v, n.Callee)
+		for _, t := range n.TypeArguments {
+			Walk(v, t)
+		}
 		for _, nb := range n.Arguments {
 			Walk(v, nb)
 		}
 	case *FunctionSignature:
 		Walk(v, n.Identifier)
+		for _, tp := range n.TypeParameters {
+			Walk(v, tp)
+		}
 		for _, nb := range n.Arguments {
 			Walk(v, nb)
 		}
@@ -97,6 +110,14 @@ func Walk(v Visitor, node Node) {
 		Walk(v, n.Property)
 	case *TypeReference:
 		Walk(v, n.Name)
+		for _, t := range n.TypeArguments {
+			Walk(v, t)
+		}
+	case *TypeParameter:
+		Walk(v, n.Name)
+		if n.Constraint != nil {
+			Walk(v, n.Constraint)
+		}
 	case *UnaryExpression:
 		Walk(v, n.Expression)
 	case *VariableDeclaration:
@@ -135,9 +156,36 @@ func Walk(v Visitor, node Node) {
 			Walk(v, n.Length)
 		}
 		Walk(v, n.Type)
+	case *MapType:
+		Walk(v, n.Key)
+		Walk(v, n.Value)
+	case *PointerType:
+		Walk(v, n.Elem)
+	case *MapExpression:
+		Walk(v, n.Type)
+		for _, e := range n.Entries {
+			Walk(v, e)
+		}
+	case *MapEntry:
+		Walk(v, n.Key)
+		Walk(v, n.Value)
+	case *IndexExpression:
+		Walk(v, n.Target)
+		Walk(v, n.Index)
+	case *SliceExpression:
+		Walk(v, n.Target)
+		if n.Low != nil {
+			Walk(v, n.Low)
+		}
+		if n.High != nil {
+			Walk(v, n.High)
+		}
 
 	case *Struct:
 		Walk(v, n.Name)
+		for _, tp := range n.TypeParameters {
+			Walk(v, tp)
+		}
 		for _, vr := range n.Variables {
 			Walk(v, vr)
 		}
@@ -146,14 +194,57 @@ func Walk(v Visitor, node Node) {
 		}
 	case *StructExpression:
 		Walk(v, n.Identifier)
+		for _, t := range n.TypeArguments {
+			Walk(v, t)
+		}
 		for _, nb := range n.Arguments {
 			Walk(v, nb)
 		}
 	case *Interface:
 		Walk(v, n.Name)
+		for _, embedded := range n.Embedded {
+			Walk(v, embedded)
+		}
 		for _, fn := range n.Functions {
 			Walk(v, fn)
 		}
+	case *Enum:
+		Walk(v, n.Name)
+		for _, variant := range n.Variants {
+			Walk(v, variant)
+		}
+	case *EnumVariant:
+		Walk(v, n.Name)
+		for _, t := range n.Types {
+			Walk(v, t)
+		}
+	case *MatchExpression:
+		Walk(v, n.Subject)
+		for _, arm := range n.Arms {
+			Walk(v, arm)
+		}
+	case *MatchArm:
+		Walk(v, n.Pattern)
+		Walk(v, n.Body)
+	case *VariantPattern:
+		Walk(v, n.Name)
+		Walk(v, n.Payload)
+	case *TypeAssertion:
+		Walk(v, n.Expression)
+		Walk(v, n.Type)
+	case *TypeSwitch:
+		if n.Binding != nil {
+			Walk(v, n.Binding)
+		}
+		Walk(v, n.Subject)
+		for _, c := range n.Cases {
+			Walk(v, c)
+		}
+	case *TypeSwitchCase:
+		for _, t := range n.Types {
+			Walk(v, t)
+		}
+		Walk(v, n.Block)
 	default:
 		panic(fmt.Errorf("Unknown node type: %s", reflect.TypeOf(n)))
 	}
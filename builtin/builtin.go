@@ -0,0 +1,80 @@
+// Package builtin contains the externs every orlang program can call
+// without declaring them
+package builtin
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/orktes/orlang/analyser"
+	"github.com/orktes/orlang/ir/interpreter"
+	"github.com/orktes/orlang/types"
+)
+
+var (
+	// Print writes a string followed by a line break to the output
+	Print = &types.SignatureType{
+		ArgumentNames: []string{"str"},
+		ArgumentTypes: []types.Type{types.StringType},
+		ReturnType:    types.VoidType,
+		Extern:        true,
+	}
+
+	// IntToStr formats an integer in base 10
+	IntToStr = &types.SignatureType{
+		ArgumentNames: []string{"i"},
+		ArgumentTypes: []types.Type{types.Int64Type},
+		ReturnType:    types.StringType,
+		Extern:        true,
+	}
+
+	// ArgCount returns the number of command line arguments given to the program
+	ArgCount = &types.SignatureType{
+		ArgumentNames: []string{},
+		ArgumentTypes: []types.Type{},
+		ReturnType:    types.Int32Type,
+		Extern:        true,
+	}
+
+	// Arg returns a command line argument by index
+	Arg = &types.SignatureType{
+		ArgumentNames: []string{"index"},
+		ArgumentTypes: []types.Type{types.Int32Type},
+		ReturnType:    types.StringType,
+		Extern:        true,
+	}
+)
+
+// Configure registers the build-in externs to an analyser
+func Configure(analyser *analyser.Analyser) {
+	analyser.AddExternalFunc("print", Print)
+	analyser.AddExternalFunc("int_to_str", IntToStr)
+	analyser.AddExternalFunc("arg_count", ArgCount)
+	analyser.AddExternalFunc("arg", Arg)
+}
+
+// Register provides implementations of the build-in externs to an interpreter.
+// Print writes to out and arguments are read from args
+func Register(in *interpreter.Interpreter, out io.Writer, args []string) {
+	in.RegisterExtern("print", func(values []interpreter.Value) (interpreter.Value, error) {
+		_, err := fmt.Fprintln(out, values[0])
+		return nil, err
+	})
+
+	in.RegisterExtern("int_to_str", func(values []interpreter.Value) (interpreter.Value, error) {
+		return strconv.FormatInt(values[0].(int64), 10), nil
+	})
+
+	in.RegisterExtern("arg_count", func(values []interpreter.Value) (interpreter.Value, error) {
+		return int32(len(args)), nil
+	})
+
+	in.RegisterExtern("arg", func(values []interpreter.Value) (interpreter.Value, error) {
+		index := values[0].(int32)
+		if index < 0 || int(index) >= len(args) {
+			return nil, fmt.Errorf("argument index %d out of range", index)
+		}
+		return args[index], nil
+	})
+}
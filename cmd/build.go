@@ -15,13 +15,14 @@
 package cmd
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
-	"path"
+	"path/filepath"
 
-	"github.com/orktes/orlang/analyser"
+	"github.com/orktes/orlang/codegen/c"
 	"github.com/orktes/orlang/codegen/js"
-	"github.com/orktes/orlang/parser"
+	"github.com/orktes/orlang/project"
 	"github.com/spf13/cobra"
 )
 
@@ -29,51 +30,170 @@ import (
 var buildCmd = &cobra.Command{
 	Use:   "build",
 	Short: "Build Orlang application",
-	Long:  `Build Orlang application`,
+	Long: `Build Orlang application.
+Output is written next to the source file unless --output or --out-dir is given.
+Without --target an output file without the .js extension is built into a
+native executable. Without files the main file of the project manifest is built`,
 	Run: func(cmd *cobra.Command, args []string) {
-		files := args
+		target := cmd.Flag("target").Value.String()
+		output := cmd.Flag("output").Value.String()
+		outDir := cmd.Flag("out-dir").Value.String()
+		sourceMap, _ := cmd.Flags().GetBool("source-map")
+		debugLeaks, _ := cmd.Flags().GetBool("debug-leaks")
 
-		for _, filePath := range files {
-			file, err := os.Open(filePath)
-			if err != nil {
-				panic(err)
-			}
+		if !cmd.Flag("target").Changed && output != "" && filepath.Ext(output) != ".js" {
+			target = "c"
+		}
+
+		if len(args) == 0 {
+			mainFile, err := manifestMain()
+			exitOnError(err)
+			args = []string{mainFile}
+		}
+
+		if output != "" && len(args) > 1 {
+			exitOnError(fmt.Errorf("--output can only be used with a single file, use --out-dir instead"))
+		}
 
-			fileNode, err := parser.Parse(file)
-			if err != nil {
-				panic(err)
+		failed := false
+		for _, filePath := range args {
+			outfile := output
+			if outfile == "" {
+				outfile = outputPath(filePath, outDir, target)
 			}
 
-			an, err := analyser.New(fileNode)
-			if err != nil {
-				panic(err)
+			if err := buildFile(filePath, outfile, target, sourceMap, debugLeaks); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				failed = true
 			}
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// manifestMain returns the main file of the project manifest
+func manifestMain() (string, error) {
+	manifest, err := readManifest()
+	if err != nil {
+		return "", err
+	}
+
+	if manifest == nil {
+		return "", fmt.Errorf("no files given and no %s found", project.ManifestName)
+	}
+
+	if manifest.MainFile() == "" {
+		return "", fmt.Errorf("no files given and %s has no main file", project.ManifestName)
+	}
+
+	return manifest.MainFile(), nil
+}
+
+// outputPath returns the default output file for a source file
+func outputPath(filePath string, outDir string, target string) string {
+	ext := filepath.Ext(filePath)
+	outfile := filePath[0 : len(filePath)-len(ext)]
+	if outDir != "" {
+		outfile = filepath.Join(outDir, filepath.Base(outfile))
+	}
+
+	if target == "js" {
+		outfile += ".js"
+	}
+	return outfile
+}
 
-			fileInfo, err := an.Analyse()
-			if err != nil {
-				panic(err)
+func buildFile(filePath string, outfile string, target string, sourceMap bool, debugLeaks bool) error {
+	if target != "js" && target != "c" {
+		return fmt.Errorf("unknown target %s", target)
+	}
+
+	source, err := loadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(outfile); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	switch target {
+	case "c":
+		module, err := source.lower()
+		if err != nil {
+			return err
+		}
+		return c.CompileWithOptions(module, outfile, c.Options{DebugLeaks: debugLeaks})
+	default:
+		codegen, code, err := generateJS(source)
+		if err != nil {
+			return err
+		}
+
+		if sourceMap {
+			mapFile := outfile + ".map"
+			if err := writeSourceMap(source, codegen, outfile, mapFile); err != nil {
+				return err
 			}
+			code = append(code, fmt.Sprintf("\n//# sourceMappingURL=%s\n", filepath.Base(mapFile))...)
+		}
+
+		return ioutil.WriteFile(outfile, code, 0644)
+	}
+}
 
-			target := cmd.Flag("target").Value.String()
-			switch target {
-			case "js":
-				jscg := js.New(fileInfo)
-				code := jscg.Generate(fileNode)
-				ext := path.Ext(filePath)
-				outfile := filePath[0:len(filePath)-len(ext)] + ".js"
-				err := ioutil.WriteFile(outfile, code, 0644)
-				if err != nil {
-					panic(err)
-				}
+// generateJS runs the JS code generator which panics on unsupported nodes
+func generateJS(source *sourceFile) (codegen *js.JSCodeGen, code []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s: %v", source.path, r)
+		}
+	}()
+
+	codegen = js.New(source.info)
+	return codegen, codegen.Generate(source.program.Files...), nil
+}
+
+// writeSourceMap writes the source map of the generated outfile. Sources are
+// relative to the source map and their content is embedded in the map
+func writeSourceMap(source *sourceFile, codegen *js.JSCodeGen, outfile string, mapFile string) error {
+	sourceMap := codegen.SourceMap(filepath.Base(outfile))
+
+	mapDir, err := filepath.Abs(filepath.Dir(mapFile))
+	if err != nil {
+		return err
+	}
+
+	for i, file := range source.program.Files {
+		if path, err := filepath.Abs(file.Filename); err == nil {
+			if rel, err := filepath.Rel(mapDir, path); err == nil {
+				sourceMap.Sources[i] = filepath.ToSlash(rel)
 			}
 		}
-	},
+		sourceMap.SourcesContent = append(sourceMap.SourcesContent, string(source.program.Sources[file]))
+	}
+
+	data, err := sourceMap.JSON()
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(mapFile, data, 0644)
 }
 
 func init() {
 	RootCmd.AddCommand(buildCmd)
 
-	buildCmd.PersistentFlags().String("target", "js", "Target platform")
+	buildCmd.PersistentFlags().String("target", "js", "Target platform [js|c]")
+	buildCmd.PersistentFlags().StringP("output", "o", "", "Output file (only with a single source file)")
+	buildCmd.PersistentFlags().String("out-dir", "", "Directory for output files")
+	buildCmd.PersistentFlags().Bool("source-map", true, "Write a source map next to JavaScript output")
+	buildCmd.PersistentFlags().Bool("debug-leaks", false, "Report objects that are never released when the program exits (c target only)")
 	// Here you will define your flags and configuration settings.
 
 	// Cobra supports Persistent Flags which will work for this command
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/orktes/orlang/analyser"
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/builtin"
+	"github.com/orktes/orlang/ir"
+	"github.com/orktes/orlang/ir/arc"
+	"github.com/orktes/orlang/ir/lowering"
+	"github.com/orktes/orlang/loader"
+	"github.com/orktes/orlang/project"
+)
+
+// diagnostics is a list of formatted errors for a source file
+type diagnostics []string
+
+func (d diagnostics) Error() string {
+	return strings.Join(d, "\n")
+}
+
+// sourceFile is a parsed and analysed program. The main file is loaded
+// together with the files it imports
+type sourceFile struct {
+	path    string
+	program *loader.Program
+	info    *analyser.Info
+}
+
+func (s *sourceFile) line(file *ast.File, pos ast.Position) string {
+	lines := strings.Split(string(s.program.Sources[file]), "\n")
+	if pos.Line < 0 || pos.Line >= len(lines) {
+		return ""
+	}
+	return lines[pos.Line]
+}
+
+func (s *sourceFile) diagnostic(file *ast.File, pos ast.Position, endPos ast.Position, msg string) string {
+	return formatParseError(file.Filename, pos, endPos, s.line(file, pos), msg)
+}
+
+// file returns the loaded file with the given name
+func (s *sourceFile) file(filename string) *ast.File {
+	for _, file := range s.program.Files {
+		if file.Filename == filename {
+			return file
+		}
+	}
+	return s.program.Main
+}
+
+// loadProgram loads a file and its imports
+func loadProgram(filePath string) (*loader.Program, error) {
+	ldr, err := newLoader()
+	if err != nil {
+		return nil, err
+	}
+	return ldr.Load(filePath)
+}
+
+// newLoader returns a loader resolving imports with the project manifest if
+// there is one
+func newLoader() (*loader.Loader, error) {
+	manifest, err := readManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	if manifest == nil {
+		return &loader.Loader{}, nil
+	}
+
+	resolver, err := project.NewResolver(manifest)
+	if err != nil {
+		return nil, err
+	}
+	return &loader.Loader{Resolve: resolver.Resolve}, nil
+}
+
+// loadError returns load errors in files as diagnostics
+func loadError(err error) error {
+	loadErr, ok := err.(*loader.Error)
+	if !ok {
+		return err
+	}
+
+	line := ""
+	if src, err := ioutil.ReadFile(loadErr.Filename); err == nil {
+		if lines := strings.Split(string(src), "\n"); loadErr.Line < len(lines) {
+			line = lines[loadErr.Line]
+		}
+	}
+	return diagnostics{formatParseError(loadErr.Filename, loadErr.Position, loadErr.Position, line, loadErr.Message)}
+}
+
+// loadFile loads and analyses a file and its imports with the build-in externs.
+// Parse and fatal analyser errors are returned as diagnostics
+func loadFile(filePath string) (*sourceFile, error) {
+	program, err := loadProgram(filePath)
+	if err != nil {
+		return nil, loadError(err)
+	}
+
+	source := &sourceFile{
+		path:    filePath,
+		program: program,
+	}
+
+	an, err := analyser.NewProgram(program.Files, program.Imports)
+	if err != nil {
+		return nil, err
+	}
+
+	builtin.Configure(an)
+
+	var errs diagnostics
+	an.Error = func(node ast.Node, msg string, fatal bool) {
+		if fatal {
+			errs = append(errs, source.diagnostic(an.CurrentFile(), node.StartPos(), node.EndPos(), msg))
+		}
+	}
+
+	source.info, err = an.Analyse()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return source, nil
+}
+
+// lower lowers an analysed program into the IR and inserts reference
+// counting
+func (s *sourceFile) lower() (*ir.Module, error) {
+	module, err := lowering.New(s.info).Lower(s.program.Files...)
+	if err != nil {
+		if lowerErr, ok := err.(*lowering.Error); ok {
+			return nil, diagnostics{s.diagnostic(s.file(lowerErr.Filename), lowerErr.Position, lowerErr.Position, lowerErr.Message)}
+		}
+		return nil, err
+	}
+	if err := arc.Insert(module); err != nil {
+		return nil, err
+	}
+	return module, nil
+}
+
+// exitOnError prints err and exits with a non zero status
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/orktes/orlang/parser"
+	"github.com/spf13/cobra"
+)
+
+// expandCmd represents the expand command
+var expandCmd = &cobra.Command{
+	Use:   "expand file.or",
+	Short: "Print source code after macro expansion",
+	Long: `Print source code after macro expansion.
+Macro declarations are left out and macro calls are replaced with the code
+they expand to. With --markers the code of each macro call is wrapped in comments`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		markers, _ := cmd.Flags().GetBool("markers")
+		exitOnError(expandFile(args[0], markers))
+	},
+}
+
+func expandFile(filePath string, markers bool) error {
+	ldr, err := newLoader()
+	if err != nil {
+		return err
+	}
+
+	ldr.Expand = true
+	program, err := ldr.Load(filePath)
+	if err != nil {
+		return loadError(err)
+	}
+
+	fmt.Print(parser.FormatExpanded(program.Expanded, markers))
+	return nil
+}
+func init() {
+	RootCmd.AddCommand(expandCmd)
+
+	expandCmd.Flags().Bool("markers", false, "Wrap the code of each macro call in comments")
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/builtin"
 	"github.com/orktes/orlang/linter"
 	"github.com/spf13/cobra"
 )
@@ -27,15 +28,16 @@ var lintCmd = &cobra.Command{
 
 		files := args
 
-		for index, filePath := range files {
-			file, err := os.Open(filePath)
-			if err != nil {
-				panic(err)
-			}
+		ldr, err := newLoader()
+		if err != nil {
+			panic(err)
+		}
 
-			lintError, err := linter.Lint(file, nil)
+		for index, filePath := range files {
+			lintError, err := linter.LintFile(filePath, ldr, builtin.Configure)
 			if err != nil {
-				panic(err)
+				fmt.Fprintln(os.Stderr, loadError(err))
+				os.Exit(1)
 			}
 
 			switch format {
@@ -78,6 +80,10 @@ var lintCmd = &cobra.Command{
 }
 
 func formatParseError(filePath string, pos ast.Position, endPos ast.Position, line string, err string) string {
+	return formatPosition(filePath, pos, endPos, line, err) + formatExpansions(pos)
+}
+
+func formatPosition(filePath string, pos ast.Position, endPos ast.Position, line string, err string) string {
 	if line != "" {
 		pointerPos := pos.Column
 
@@ -96,6 +102,14 @@ func formatParseError(filePath string, pos ast.Position, endPos ast.Position, li
 	return fmt.Sprintf("%s:%d:%d %s", filePath, pos.Line+1, pos.Column+1, err)
 }
 
+// formatExpansions returns the backtrace of the macro expansions pos is in
+func formatExpansions(pos ast.Position) (backtrace string) {
+	for _, expansion := range pos.Expansions() {
+		backtrace += "\n" + expansion.String()
+	}
+	return
+}
+
 func pad(padding int, str string) (res string) {
 	if padding < 0 {
 		padding = 0
@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/orktes/orlang/project"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var cfgFile string
+var manifestFile string
 
 // RootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
@@ -25,7 +27,7 @@ var RootCmd = &cobra.Command{
 func Execute() {
 	if err := RootCmd.Execute(); err != nil {
 		fmt.Println(err)
-		os.Exit(-1)
+		os.Exit(1)
 	}
 }
 
@@ -37,6 +39,7 @@ func init() {
 	// will be global for your application.
 
 	RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.orlang.yaml)")
+	RootCmd.PersistentFlags().StringVar(&manifestFile, "manifest", "", "project manifest (default is orlang.yaml in the current directory or its parents)")
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	RootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
@@ -57,3 +60,18 @@ func initConfig() {
 		fmt.Println("Using config file:", viper.ConfigFileUsed())
 	}
 }
+
+// readManifest reads the project manifest. Nil is returned if the manifest
+// is not given and there is no orlang.yaml in the working directory or its
+// parent directories
+func readManifest() (*project.Manifest, error) {
+	path := manifestFile
+	if path == "" {
+		var err error
+		if path, err = project.FindManifest("."); err != nil || path == "" {
+			return nil, err
+		}
+	}
+
+	return project.ReadManifest(viper.New(), path)
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/orktes/orlang/builtin"
+	"github.com/orktes/orlang/ir/interpreter"
+	"github.com/spf13/cobra"
+)
+
+// runCmd represents the run command
+var runCmd = &cobra.Command{
+	Use:   "run file.or [args...]",
+	Short: "Run Orlang application",
+	Long: `Run Orlang application with the built-in interpreter.
+Arguments after the file name are passed to the program`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		debugLeaks, _ := cmd.Flags().GetBool("debug-leaks")
+		exitOnError(runFile(args[0], args[1:], debugLeaks))
+	},
+}
+
+func runFile(filePath string, args []string, debugLeaks bool) error {
+	source, err := loadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	module, err := source.lower()
+	if err != nil {
+		return err
+	}
+
+	in := interpreter.New(module)
+	in.LeakCheck = debugLeaks
+	builtin.Register(in, os.Stdout, args)
+
+	return in.Run()
+}
+
+func init() {
+	RootCmd.AddCommand(runCmd)
+
+	runCmd.Flags().Bool("debug-leaks", false, "Report objects that are never released when the program exits")
+}
@@ -0,0 +1,691 @@
+package c
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/orktes/orlang/ir"
+)
+
+var primitiveTypes = map[ir.PrimitiveType]string{
+	ir.Int64:   "int64_t",
+	ir.Int32:   "int32_t",
+	ir.Int16:   "int16_t",
+	ir.Int8:    "int8_t",
+	ir.UInt64:  "uint64_t",
+	ir.UInt32:  "uint32_t",
+	ir.UInt16:  "uint16_t",
+	ir.UInt8:   "uint8_t",
+	ir.Float64: "double",
+	ir.Float32: "float",
+	ir.Bool:    "bool",
+	ir.String:  "const char *",
+	ir.Void:    "void",
+}
+
+// wrapTypes are the unsigned types integer arithmetic is done in. Signed
+// overflow is undefined in C and smaller types would be promoted to int
+var wrapTypes = map[ir.PrimitiveType]string{
+	ir.Int64:  "uint64_t",
+	ir.Int32:  "uint32_t",
+	ir.Int16:  "uint32_t",
+	ir.Int8:   "uint32_t",
+	ir.UInt64: "uint64_t",
+	ir.UInt32: "uint32_t",
+	ir.UInt16: "uint32_t",
+	ir.UInt8:  "uint32_t",
+}
+
+func isSigned(typ ir.PrimitiveType) bool {
+	switch typ {
+	case ir.Int64, ir.Int32, ir.Int16, ir.Int8:
+		return true
+	}
+	return false
+}
+
+// prelude declares the runtime helpers generated code depends on
+const prelude = `#include <stdbool.h>
+#include <stddef.h>
+#include <stdint.h>
+
+void orl_init_args(int argc, char **argv);
+void orl_panic(const char *msg);
+void *orl_alloc(size_t size);
+void orl_free(void *ptr);
+void *orl_new(size_t size, void (*release)(void *), const char *name);
+void orl_delete(void *ptr);
+void orl_retain(const void *ptr);
+void orl_release(const void *ptr);
+int orl_exit(void);
+const char *orl_string(const char *chars);
+const char *orl_concat(const char *left, const char *right);
+int orl_strcmp(const char *left, const char *right);
+
+` + objectHeader
+
+// Error is returned when a module can not be represented in C
+type Error struct {
+	Function string
+	Message  string
+}
+
+func (e *Error) Error() string {
+	if e.Function == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Function, e.Message)
+}
+
+// CCodeGen generates C source code from IR modules. Module functions are
+// prefixed with orl_, globals with orlg_ and types with orlt_ so that they do
+// not collide with the C library. Externs keep their names. Objects are
+// allocated with orl_new together with a function releasing the references
+// they hold. String literals are static string objects
+type CCodeGen struct {
+	module       *ir.Module
+	buffer       bytes.Buffer
+	typeNames    map[string]string
+	typeDecls    bytes.Buffer
+	structs      []string
+	releases     map[string]string
+	releaseFns   bytes.Buffer
+	literals     map[string]string
+	literalDecls bytes.Buffer
+	fn           *ir.Function
+	registers    map[ir.Register]ir.Type
+}
+
+// New returns a code generator for module
+func New(module *ir.Module) *CCodeGen {
+	return &CCodeGen{
+		module:    module,
+		typeNames: map[string]string{},
+		releases:  map[string]string{},
+		literals:  map[string]string{},
+	}
+}
+
+// Generate returns the C source for module
+func Generate(module *ir.Module) ([]byte, error) {
+	return New(module).Generate()
+}
+
+// Generate returns the C source for the module
+func (cg *CCodeGen) Generate() (code []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			genErr, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			code, err = nil, genErr
+		}
+	}()
+
+	var body bytes.Buffer
+
+	for _, global := range cg.module.Globals {
+		fmt.Fprintf(&body, "static %s;\n", cg.declaration(global.Type, globalName(global.Name)))
+	}
+	if len(cg.module.Globals) > 0 {
+		body.WriteString("\n")
+	}
+
+	for _, extern := range cg.module.Externs {
+		fmt.Fprintf(&body, "%s;\n", cg.prototype(extern.Name, extern.Params, extern.ReturnType, extern.Variadic))
+	}
+	if len(cg.module.Externs) > 0 {
+		body.WriteString("\n")
+	}
+
+	for _, fn := range cg.module.Functions {
+		fmt.Fprintf(&body, "%s;\n", cg.prototype(functionName(fn.Name), fn.Params, fn.ReturnType, false))
+	}
+
+	for _, fn := range cg.module.Functions {
+		body.WriteString("\n")
+		cg.buffer.Reset()
+		cg.function(fn)
+		body.Write(cg.buffer.Bytes())
+	}
+
+	if main := cg.module.Function("main"); main != nil && len(main.Params) == 0 {
+		body.WriteString("\nint main(int argc, char **argv) {\n")
+		body.WriteString("  orl_init_args(argc, argv);\n")
+		fmt.Fprintf(&body, "  %s();\n", functionName(main.Name))
+		body.WriteString("  return orl_exit();\n}\n")
+	}
+
+	var out bytes.Buffer
+	out.WriteString(prelude)
+	if cg.typeDecls.Len() > 0 {
+		out.WriteString("\n")
+		out.Write(cg.typeDecls.Bytes())
+	}
+	for _, def := range cg.structs {
+		out.WriteString("\n")
+		out.WriteString(def)
+	}
+	if cg.releaseFns.Len() > 0 {
+		out.WriteString("\n")
+		out.Write(cg.releaseFns.Bytes())
+	}
+	if cg.literalDecls.Len() > 0 {
+		out.WriteString("\n")
+		out.Write(cg.literalDecls.Bytes())
+	}
+	out.WriteString("\n")
+	out.Write(body.Bytes())
+
+	return out.Bytes(), nil
+}
+
+func (cg *CCodeGen) error(msg string) {
+	name := ""
+	if cg.fn != nil {
+		name = cg.fn.Name
+	}
+	panic(&Error{Function: name, Message: msg})
+}
+
+func functionName(name string) string {
+	return "orl_" + name
+}
+
+func globalName(name string) string {
+	return "orlg_" + name
+}
+
+func registerName(reg ir.Register) string {
+	return "r_" + string(reg)
+}
+
+func labelName(label string) string {
+	return "L_" + label
+}
+
+// cType returns the C type name for typ. Struct and function types are
+// declared on first use
+func (cg *CCodeGen) cType(typ ir.Type) string {
+	switch t := typ.(type) {
+	case ir.PrimitiveType:
+		if name, ok := primitiveTypes[t]; ok {
+			return name
+		}
+	case *ir.PointerType:
+		elem := cg.cType(t.Elem)
+		if strings.HasSuffix(elem, "*") {
+			return elem + "*"
+		}
+		return elem + " *"
+	case ir.NamedType:
+		decl := cg.module.TypeDeclaration(string(t))
+		if decl == nil {
+			cg.error(fmt.Sprintf("undefined type %s", t))
+		}
+		if structType, ok := decl.Type.(*ir.StructType); ok {
+			return cg.structType(string(t), "orlt_"+string(t), structType)
+		}
+		return cg.cType(decl.Type)
+	case *ir.StructType:
+		key := t.String()
+		if name, ok := cg.typeNames[key]; ok {
+			return name
+		}
+		return cg.structType(key, fmt.Sprintf("orlanon_tuple%d", len(cg.typeNames)), t)
+	case *ir.FunctionType:
+		key := t.String()
+		if name, ok := cg.typeNames[key]; ok {
+			return name
+		}
+		params := make([]string, len(t.Params))
+		for i, param := range t.Params {
+			params[i] = cg.cType(param)
+		}
+		if t.Variadic {
+			params = append(params, "...")
+		}
+		if len(params) == 0 {
+			params = []string{"void"}
+		}
+		ret := cg.cType(t.Return)
+		name := fmt.Sprintf("orlanon_fn%d", len(cg.typeNames))
+		cg.typeNames[key] = name
+		fmt.Fprintf(&cg.typeDecls, "typedef %s (*%s)(%s);\n", ret, name, strings.Join(params, ", "))
+		return name
+	}
+
+	cg.error(fmt.Sprintf("type %s is not supported by the C backend", typ))
+	return ""
+}
+
+// structType forward declares a struct and queues its definition. Fields
+// are named f0, f1 and so on
+func (cg *CCodeGen) structType(key string, name string, typ *ir.StructType) string {
+	if existing, ok := cg.typeNames[key]; ok {
+		return existing
+	}
+	cg.typeNames[key] = name
+	fmt.Fprintf(&cg.typeDecls, "typedef struct %s %s;\n", name, name)
+
+	var def bytes.Buffer
+	fmt.Fprintf(&def, "struct %s {\n", name)
+	for i, field := range typ.Fields {
+		if _, inline := cg.module.Underlying(field).(*ir.StructType); inline {
+			cg.error(fmt.Sprintf("struct %s can not contain a struct value", key))
+		}
+		fmt.Fprintf(&def, "  %s;\n", cg.declaration(field, fmt.Sprintf("f%d", i)))
+	}
+	if len(typ.Fields) == 0 {
+		// Empty structs are not valid C
+		def.WriteString("  char unused;\n")
+	}
+	def.WriteString("};\n")
+	cg.structs = append(cg.structs, def.String())
+
+	return name
+}
+
+// release returns the function releasing the references held by objects of
+// typ. NULL is returned if they hold none
+func (cg *CCodeGen) release(typ ir.Type) string {
+	ctype := cg.cType(typ)
+	if name, ok := cg.releases[ctype]; ok {
+		return name
+	}
+
+	var fields []int
+	for i, field := range cg.module.Underlying(typ).(*ir.StructType).Fields {
+		if cg.module.IsReference(field) {
+			fields = append(fields, i)
+		}
+	}
+
+	name := "NULL"
+	if len(fields) > 0 {
+		name = ctype + "_release"
+		fmt.Fprintf(&cg.releaseFns, "static void %s(void *ptr) {\n", name)
+		for _, i := range fields {
+			fmt.Fprintf(&cg.releaseFns, "  orl_release(((%s *)ptr)->f%d);\n", ctype, i)
+		}
+		cg.releaseFns.WriteString("}\n")
+	}
+	cg.releases[ctype] = name
+	return name
+}
+
+func (cg *CCodeGen) declaration(typ ir.Type, name string) string {
+	ctype := cg.cType(typ)
+	if strings.HasSuffix(ctype, "*") {
+		return ctype + name
+	}
+	return ctype + " " + name
+}
+
+func (cg *CCodeGen) prototype(name string, params []*ir.Param, ret ir.Type, variadic bool) string {
+	args := make([]string, len(params))
+	for i, param := range params {
+		args[i] = cg.declaration(param.Type, registerName(ir.Register(param.Name)))
+	}
+	if variadic {
+		args = append(args, "...")
+	}
+	if len(args) == 0 {
+		args = []string{"void"}
+	}
+	return fmt.Sprintf("%s(%s)", cg.declaration(ret, name), strings.Join(args, ", "))
+}
+
+func (cg *CCodeGen) write(format string, args ...interface{}) {
+	fmt.Fprintf(&cg.buffer, format, args...)
+}
+
+func (cg *CCodeGen) function(fn *ir.Function) {
+	cg.fn = fn
+	defer func() { cg.fn = nil }()
+
+	cg.registers = map[ir.Register]ir.Type{}
+	for _, param := range fn.Params {
+		cg.registers[ir.Register(param.Name)] = param.Type
+	}
+
+	var locals []ir.Register
+	for _, block := range fn.Blocks {
+		for _, instr := range block.Instructions {
+			valueInstr, ok := instr.(ir.ValueInstruction)
+			if !ok || valueInstr.Destination() == "" {
+				continue
+			}
+			dest := valueInstr.Destination()
+			if existing, ok := cg.registers[dest]; ok {
+				if existing.String() != valueInstr.ResultType().String() {
+					cg.error(fmt.Sprintf("register %s is used with types %s and %s", ir.FormatOperand(dest), existing, valueInstr.ResultType()))
+				}
+				continue
+			}
+			cg.registers[dest] = valueInstr.ResultType()
+			locals = append(locals, dest)
+		}
+	}
+
+	cg.write("%s {\n", cg.prototype(functionName(fn.Name), fn.Params, fn.ReturnType, false))
+	for _, reg := range locals {
+		cg.write("  %s;\n", cg.declaration(cg.registers[reg], registerName(reg)))
+	}
+
+	for i, block := range fn.Blocks {
+		if i > 0 || len(locals) > 0 {
+			cg.write("\n")
+		}
+		if block.Label != "" {
+			cg.write("%s:;\n", labelName(block.Label))
+		}
+		for _, instr := range block.Instructions {
+			cg.instruction(instr)
+		}
+	}
+
+	cg.write("}\n")
+}
+
+// typeOf returns the type of an operand. Constants have no type and nil is returned
+func (cg *CCodeGen) typeOf(operand ir.Operand) ir.Type {
+	switch o := operand.(type) {
+	case ir.Register:
+		if typ, ok := cg.registers[o]; ok {
+			return typ
+		}
+		if global := cg.module.Global(string(o)); global != nil {
+			return &ir.PointerType{Elem: global.Type}
+		}
+		cg.error(fmt.Sprintf("undefined register %s", ir.FormatOperand(o)))
+	case ir.FunctionRef:
+		if fn := cg.module.Function(string(o)); fn != nil {
+			return fn.Signature()
+		}
+		if extern := cg.module.Extern(string(o)); extern != nil {
+			return extern.Signature()
+		}
+		cg.error(fmt.Sprintf("undefined function %s", o))
+	}
+	return nil
+}
+
+// operand returns a C expression for operand. Constants are cast to typ
+func (cg *CCodeGen) operand(operand ir.Operand, typ ir.Type) string {
+	switch o := operand.(type) {
+	case ir.Register:
+		if _, ok := cg.registers[o]; ok {
+			return registerName(o)
+		}
+		if cg.module.Global(string(o)) != nil {
+			return "(&" + globalName(string(o)) + ")"
+		}
+		cg.error(fmt.Sprintf("undefined register %s", ir.FormatOperand(o)))
+	case ir.FunctionRef:
+		if cg.module.Function(string(o)) != nil {
+			return functionName(string(o))
+		}
+		if cg.module.Extern(string(o)) != nil {
+			return string(o)
+		}
+		cg.error(fmt.Sprintf("undefined function %s", o))
+	case ir.Constant:
+		return cg.constant(o, typ)
+	}
+
+	cg.error(fmt.Sprintf("unknown operand %v", operand))
+	return ""
+}
+
+func (cg *CCodeGen) constant(c ir.Constant, typ ir.Type) string {
+	switch val := c.Value.(type) {
+	case nil:
+		if typ == nil {
+			return "0"
+		}
+		return fmt.Sprintf("((%s)0)", cg.cType(typ))
+	case bool:
+		return strconv.FormatBool(val)
+	case string:
+		return cg.stringLiteral(val)
+	case int64:
+		literal := strconv.FormatInt(val, 10)
+		if primitive, ok := typ.(ir.PrimitiveType); ok && primitive.IsFloat() {
+			literal += ".0"
+		}
+		if val == -1<<63 {
+			literal = "(-9223372036854775807 - 1)"
+		}
+		if typ == nil {
+			return fmt.Sprintf("((int64_t)%s)", literal)
+		}
+		return fmt.Sprintf("((%s)%s)", cg.cType(typ), literal)
+	case float64:
+		literal := strconv.FormatFloat(val, 'g', -1, 64)
+		if !strings.ContainsAny(literal, ".eEn") {
+			literal += ".0"
+		}
+		switch literal {
+		case "+Inf":
+			literal = "(1.0 / 0.0)"
+		case "-Inf":
+			literal = "(-1.0 / 0.0)"
+		case "NaN":
+			literal = "(0.0 / 0.0)"
+		}
+		if typ == nil {
+			return literal
+		}
+		return fmt.Sprintf("((%s)%s)", cg.cType(typ), literal)
+	}
+
+	cg.error(fmt.Sprintf("unknown constant %v", c.Value))
+	return ""
+}
+
+// stringLiteral returns a static string object holding str. Its negative
+// reference count keeps it from being released
+func (cg *CCodeGen) stringLiteral(str string) string {
+	name, ok := cg.literals[str]
+	if !ok {
+		name = fmt.Sprintf("orls_%d", len(cg.literals))
+		cg.literals[str] = name
+		fmt.Fprintf(&cg.literalDecls, "static struct { orl_header header; char chars[%d]; } %s = {{-1}, %s};\n", len(str)+1, name, quote(str))
+	}
+	return name + ".chars"
+}
+
+// quote returns a C string literal. Everything outside printable ASCII is
+// written as an octal escape
+func quote(str string) string {
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	for i := 0; i < len(str); i++ {
+		ch := str[i]
+		switch {
+		case ch == '"' || ch == '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(ch)
+		case ch == '?':
+			// Avoid trigraphs
+			buf.WriteString("\\?")
+		case ch >= 0x20 && ch < 0x7f:
+			buf.WriteByte(ch)
+		default:
+			fmt.Fprintf(&buf, "\\%03o", ch)
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+// operandType returns the type of the first typed operand
+func (cg *CCodeGen) operandType(operands ...ir.Operand) ir.Type {
+	for _, operand := range operands {
+		if typ := cg.typeOf(operand); typ != nil {
+			return typ
+		}
+	}
+	return nil
+}
+
+func (cg *CCodeGen) field(ptr ir.Operand, index int) string {
+	ptrType, ok := cg.typeOf(ptr).(*ir.PointerType)
+	if !ok {
+		cg.error(fmt.Sprintf("%s is not a pointer", ir.FormatOperand(ptr)))
+	}
+
+	expr := cg.operand(ptr, nil)
+	if structType, ok := cg.module.Underlying(ptrType.Elem).(*ir.StructType); ok {
+		if index < 0 || index >= len(structType.Fields) {
+			cg.error(fmt.Sprintf("index %d out of range for %s", index, ptrType))
+		}
+		return fmt.Sprintf("%s->f%d", expr, index)
+	}
+
+	if index != 0 {
+		cg.error(fmt.Sprintf("index %d out of range for %s", index, ptrType))
+	}
+	return fmt.Sprintf("(*%s)", expr)
+}
+
+func (cg *CCodeGen) fieldType(ptr ir.Operand, index int) ir.Type {
+	ptrType := cg.typeOf(ptr).(*ir.PointerType)
+	if structType, ok := cg.module.Underlying(ptrType.Elem).(*ir.StructType); ok {
+		return structType.Fields[index]
+	}
+	return ptrType.Elem
+}
+
+func (cg *CCodeGen) instruction(instr ir.Instruction) {
+	switch i := instr.(type) {
+	case *ir.Assign:
+		cg.write("  %s = %s;\n", registerName(i.Dest), cg.operand(i.Value, i.Type))
+	case *ir.BinaryOp:
+		cg.binaryOp(i)
+	case *ir.UnaryOp:
+		value := cg.operand(i.Value, i.Type)
+		switch i.Operator {
+		case "not":
+			cg.write("  %s = !%s;\n", registerName(i.Dest), value)
+		case "neg":
+			ctype := cg.cType(i.Type)
+			if wrap, ok := wrapTypes[i.Type.(ir.PrimitiveType)]; ok {
+				cg.write("  %s = (%s)(0 - (%s)%s);\n", registerName(i.Dest), ctype, wrap, value)
+			} else {
+				cg.write("  %s = (%s)(-%s);\n", registerName(i.Dest), ctype, value)
+			}
+		default:
+			cg.error(fmt.Sprintf("unknown operator %s", i.Operator))
+		}
+	case *ir.Cast:
+		cg.write("  %s = (%s)%s;\n", registerName(i.Dest), cg.cType(i.Type), cg.operand(i.Value, nil))
+	case *ir.Call:
+		sig, _ := cg.typeOf(i.Callee).(*ir.FunctionType)
+		if sig == nil {
+			cg.error(fmt.Sprintf("%s is not a function", ir.FormatOperand(i.Callee)))
+		}
+		args := make([]string, len(i.Arguments))
+		for x, arg := range i.Arguments {
+			var hint ir.Type
+			if x < len(sig.Params) {
+				hint = sig.Params[x]
+			}
+			args[x] = cg.operand(arg, hint)
+		}
+		call := fmt.Sprintf("%s(%s)", cg.operand(i.Callee, nil), strings.Join(args, ", "))
+		if i.Dest != "" {
+			cg.write("  %s = %s;\n", registerName(i.Dest), call)
+		} else {
+			cg.write("  %s;\n", call)
+		}
+	case *ir.Alloc:
+		if cg.module.IsObject(i.AllocType) {
+			cg.write("  %s = orl_new(sizeof(%s), %s, %s);\n", registerName(i.Dest), cg.cType(i.AllocType), cg.release(i.AllocType), quote(i.AllocType.String()))
+		} else {
+			cg.write("  %s = orl_alloc(sizeof(%s));\n", registerName(i.Dest), cg.cType(i.AllocType))
+		}
+	case *ir.Load:
+		cg.write("  %s = %s;\n", registerName(i.Dest), cg.field(i.Ptr, i.Index))
+	case *ir.Store:
+		cg.write("  %s = %s;\n", cg.field(i.Ptr, i.Index), cg.operand(i.Value, cg.fieldType(i.Ptr, i.Index)))
+	case *ir.Free:
+		if ptrType, ok := cg.typeOf(i.Ptr).(*ir.PointerType); ok && cg.module.IsObject(ptrType.Elem) {
+			cg.write("  orl_delete(%s);\n", cg.operand(i.Ptr, nil))
+		} else {
+			cg.write("  orl_free(%s);\n", cg.operand(i.Ptr, nil))
+		}
+	case *ir.Retain:
+		cg.write("  orl_retain(%s);\n", cg.operand(i.Ptr, nil))
+	case *ir.Release:
+		cg.write("  orl_release(%s);\n", cg.operand(i.Ptr, nil))
+	case *ir.Return:
+		if i.Value == nil {
+			cg.write("  return;\n")
+		} else {
+			cg.write("  return %s;\n", cg.operand(i.Value, i.Type))
+		}
+	case *ir.Br:
+		cg.write("  goto %s;\n", labelName(i.Label))
+	case *ir.BrCond:
+		cg.write("  if (%s) goto %s; else goto %s;\n", cg.operand(i.Condition, ir.Bool), labelName(i.True), labelName(i.False))
+	default:
+		cg.error(fmt.Sprintf("unknown instruction %T", instr))
+	}
+}
+
+func (cg *CCodeGen) binaryOp(i *ir.BinaryOp) {
+	dest := registerName(i.Dest)
+
+	typ := i.Type
+	switch i.Operator {
+	case "==", "!=", "<", ">", "<=", ">=":
+		typ = cg.operandType(i.Left, i.Right)
+	}
+
+	left, right := cg.operand(i.Left, typ), cg.operand(i.Right, typ)
+
+	if typ == ir.String {
+		switch i.Operator {
+		case "+":
+			cg.write("  %s = orl_concat(%s, %s);\n", dest, left, right)
+		case "==", "!=", "<", ">", "<=", ">=":
+			cg.write("  %s = orl_strcmp(%s, %s) %s 0;\n", dest, left, right, i.Operator)
+		default:
+			cg.error(fmt.Sprintf("operator %s is not defined on string", i.Operator))
+		}
+		return
+	}
+
+	switch i.Operator {
+	case "==", "!=", "<", ">", "<=", ">=":
+		cg.write("  %s = %s %s %s;\n", dest, left, i.Operator, right)
+		return
+	case "+", "-", "*", "/":
+	default:
+		cg.error(fmt.Sprintf("unknown operator %s", i.Operator))
+	}
+
+	primitive, _ := typ.(ir.PrimitiveType)
+	ctype := cg.cType(typ)
+
+	if i.Operator == "/" && primitive.IsInteger() {
+		cg.write("  if (%s == 0) orl_panic(\"integer division by zero\");\n", right)
+		if isSigned(primitive) {
+			// The most negative value divided by -1 overflows. It wraps around like in Go
+			cg.write("  %s = %s == -1 ? (%s)(0 - (%s)%s) : %s / %s;\n", dest, right, ctype, wrapTypes[primitive], left, left, right)
+			return
+		}
+	}
+
+	if wrap, ok := wrapTypes[primitive]; ok && i.Operator != "/" {
+		cg.write("  %s = (%s)((%s)%s %s (%s)%s);\n", dest, ctype, wrap, left, i.Operator, wrap, right)
+		return
+	}
+
+	cg.write("  %s = (%s)(%s %s %s);\n", dest, ctype, left, i.Operator, right)
+}
@@ -0,0 +1,228 @@
+package c
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/orktes/orlang/builtin"
+	"github.com/orktes/orlang/ir"
+	"github.com/orktes/orlang/ir/interpreter"
+	"github.com/orktes/orlang/scanner"
+)
+
+func parseModule(t *testing.T, src []byte) *ir.Module {
+	module, err := ir.Parse(ir.NewScanner(scanner.NewScanner(bytes.NewReader(src))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return module
+}
+
+func TestCompile(t *testing.T) {
+	if _, err := exec.LookPath(strings.Fields(CC())[0]); err != nil {
+		t.Skipf("C compiler %s not found", CC())
+	}
+
+	files, err := filepath.Glob("testdata/*.ir")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "orlang-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, file := range files {
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected, err := ioutil.ReadFile(strings.TrimSuffix(file, ".ir") + ".out")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		module := parseModule(t, src)
+		binary := filepath.Join(dir, strings.TrimSuffix(filepath.Base(file), ".ir"))
+		if err := CompileWithOptions(module, binary, Options{DebugLeaks: true}); err != nil {
+			t.Errorf("%s: %s", file, err)
+			continue
+		}
+
+		output, err := exec.Command(binary).Output()
+		if err != nil {
+			t.Errorf("%s: %s", file, err)
+			continue
+		}
+
+		if string(output) != string(expected) {
+			t.Errorf("%s: expected output %q got %q", file, expected, output)
+		}
+
+		// The interpreter is the reference implementation
+		var out bytes.Buffer
+		in := interpreter.New(parseModule(t, src))
+		in.LeakCheck = true
+		builtin.Register(in, &out, nil)
+		if err := in.Run(); err != nil {
+			t.Errorf("%s: %s", file, err)
+			continue
+		}
+
+		if out.String() != string(expected) {
+			t.Errorf("%s: interpreter output %q differs from %q", file, out.String(), expected)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	code, err := Generate(parseModule(t, []byte(`type Point {int32, int32}
+
+	global %origin : ptr<Point>
+
+	extern print(%str : string) : void
+
+	fn main() : void {
+		%p = alloc Point : ptr<Point>
+		store %p, 1, 1
+		store %origin, %p
+		call print("say \"hi\"\n") : void
+		return
+	}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"typedef struct orlt_Point orlt_Point;",
+		"struct orlt_Point {\n  int32_t f0;\n  int32_t f1;\n};",
+		"static orlt_Point *orlg_origin;",
+		"void print(const char *r_str);",
+		`r_p = orl_new(sizeof(orlt_Point), NULL, "Point");`,
+		"r_p->f1 = ((int32_t)1);",
+		"(*(&orlg_origin)) = r_p;",
+		`static struct { orl_header header; char chars[11]; } orls_0 = {{-1}, "say \"hi\"\\n"};`,
+		"print(orls_0.chars);",
+		"int main(int argc, char **argv) {",
+	} {
+		if !strings.Contains(string(code), expected) {
+			t.Errorf("Expected generated code to contain %q:\n%s", expected, code)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{`fn main() : void {
+			%a = load %missing : int32
+			return
+		}`, "main: undefined register %missing"},
+		{`fn main() : void {
+			%a = 1 : int32
+			%a = "a" : string
+			return
+		}`, "main: register %a is used with types int32 and string"},
+		{`type Outer {{int32}}
+
+		fn main() : void {
+			%a = alloc Outer : ptr<Outer>
+			return
+		}`, "main: struct Outer can not contain a struct value"},
+	}
+
+	for _, test := range tests {
+		_, err := Generate(parseModule(t, []byte(test.src)))
+		if err == nil {
+			t.Errorf("Expected error for %s", test.src)
+			continue
+		}
+		if err.Error() != test.err {
+			t.Errorf("Wrong error for %s: expected %q got %q", test.src, test.err, err.Error())
+		}
+	}
+}
+
+func TestDebugLeaks(t *testing.T) {
+	if _, err := exec.LookPath(strings.Fields(CC())[0]); err != nil {
+		t.Skipf("C compiler %s not found", CC())
+	}
+
+	dir, err := ioutil.TempDir("", "orlang-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	module := parseModule(t, []byte(`type Point {int32, int32}
+	type Line {ptr<Point>, ptr<Point>}
+
+	fn main() : void {
+		%a = alloc Point : ptr<Point>
+		%b = alloc Point : ptr<Point>
+		%l = alloc Line : ptr<Line>
+		store %l, %a
+		store %l, %b, 1
+		%c = alloc Point : ptr<Point>
+		release %c
+		%s = "a" + "b" : string
+		%t = "c" : string
+		release %t
+		return
+	}`))
+
+	binary := filepath.Join(dir, "leaks")
+	if err := CompileWithOptions(module, binary, Options{DebugLeaks: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(binary)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err == nil {
+		t.Error("Expected leaking program to fail")
+	}
+
+	expected := "4 objects leaked: Point, Point, Line, string\n"
+	if stderr.String() != expected {
+		t.Errorf("Expected %q got %q", expected, stderr.String())
+	}
+
+	// Without the debug mode leaks are not reported
+	if err := Compile(module, binary); err != nil {
+		t.Fatal(err)
+	}
+	if output, err := exec.Command(binary).CombinedOutput(); err != nil || len(output) > 0 {
+		t.Errorf("Expected no output got %q (%v)", output, err)
+	}
+}
+
+func TestRuntimeFor(t *testing.T) {
+	runtime := RuntimeFor(parseModule(t, []byte(`extern print(%str : string) : void
+	extern arg(%index : int64) : string
+
+	fn main() : void {
+		return
+	}`)))
+
+	if !strings.Contains(runtime, "void print(const char *str) {") {
+		t.Error("Expected runtime to define print")
+	}
+
+	// arg is declared with a signature different from the build-in one
+	for _, name := range []string{"int_to_str", "arg_count", "arg"} {
+		if strings.Contains(runtime, " "+name+"(") {
+			t.Errorf("Expected runtime not to define %s", name)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package c
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/orktes/orlang/ir"
+)
+
+// objectHeader is the C declaration of the header preceding objects. It is
+// shared by the runtime and generated code, which declares string literals
+// as static objects
+const objectHeader = `/* Objects are preceded by a header holding their reference count. Static
+   objects have a negative count and are never released. Building with
+   ORL_DEBUG_LEAKS keeps live objects in a list so that orl_exit can report
+   the objects that were never released */
+typedef struct orl_header {
+  int64_t refs;
+  void (*release)(void *);
+  const char *name;
+#ifdef ORL_DEBUG_LEAKS
+  struct orl_header *prev;
+  struct orl_header *next;
+#endif
+} orl_header;
+`
+
+// Runtime is the C source of the support library linked into every program.
+// It implements the helpers used by generated code. Strings are objects
+// holding their NUL terminated characters
+const Runtime = `#include <stdint.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <inttypes.h>
+
+static int orl_argc;
+static char **orl_argv;
+
+void orl_init_args(int argc, char **argv) {
+  orl_argc = argc;
+  orl_argv = argv;
+}
+
+void orl_panic(const char *msg) {
+  fprintf(stderr, "panic: %s\n", msg);
+  exit(2);
+}
+
+void *orl_alloc(size_t size) {
+  void *ptr = calloc(1, size);
+  if (ptr == NULL) {
+    orl_panic("out of memory");
+  }
+  return ptr;
+}
+
+void orl_free(void *ptr) {
+  free(ptr);
+}
+
+` + objectHeader + `
+#ifdef ORL_DEBUG_LEAKS
+static orl_header *orl_objects_head;
+static orl_header *orl_objects_tail;
+#endif
+
+static orl_header *orl_header_of(const void *ptr) {
+  return (orl_header *)ptr - 1;
+}
+
+void *orl_new(size_t size, void (*release)(void *), const char *name) {
+  orl_header *header = orl_alloc(sizeof(orl_header) + size);
+  header->refs = 1;
+  header->release = release;
+  header->name = name;
+#ifdef ORL_DEBUG_LEAKS
+  header->prev = orl_objects_tail;
+  if (orl_objects_tail != NULL) {
+    orl_objects_tail->next = header;
+  } else {
+    orl_objects_head = header;
+  }
+  orl_objects_tail = header;
+#endif
+  return header + 1;
+}
+
+void orl_delete(void *ptr) {
+  orl_header *header = orl_header_of(ptr);
+#ifdef ORL_DEBUG_LEAKS
+  if (header->prev != NULL) {
+    header->prev->next = header->next;
+  } else {
+    orl_objects_head = header->next;
+  }
+  if (header->next != NULL) {
+    header->next->prev = header->prev;
+  } else {
+    orl_objects_tail = header->prev;
+  }
+#endif
+  free(header);
+}
+
+void orl_retain(const void *ptr) {
+  if (ptr != NULL && orl_header_of(ptr)->refs > 0) {
+    orl_header_of(ptr)->refs++;
+  }
+}
+
+void orl_release(const void *ptr) {
+  if (ptr == NULL) {
+    return;
+  }
+  orl_header *header = orl_header_of(ptr);
+  if (header->refs < 0 || --header->refs > 0) {
+    return;
+  }
+  if (header->release != NULL) {
+    header->release((void *)ptr);
+  }
+  orl_delete((void *)ptr);
+}
+
+int orl_exit(void) {
+#ifdef ORL_DEBUG_LEAKS
+  int count = 0;
+  for (orl_header *header = orl_objects_head; header != NULL; header = header->next) {
+    count++;
+  }
+  if (count > 0) {
+    fprintf(stderr, "%d objects leaked: ", count);
+    for (orl_header *header = orl_objects_head; header != NULL; header = header->next) {
+      fprintf(stderr, "%s%s", header->name, header->next != NULL ? ", " : "\n");
+    }
+    return 1;
+  }
+#endif
+  return 0;
+}
+
+/* orl_string returns a new string object holding a copy of chars */
+const char *orl_string(const char *chars) {
+  size_t len = strlen(chars);
+  char *str = orl_new(len + 1, NULL, "string");
+  memcpy(str, chars, len + 1);
+  return str;
+}
+
+const char *orl_concat(const char *left, const char *right) {
+  size_t left_len = strlen(left);
+  size_t right_len = strlen(right);
+  char *str = orl_new(left_len + right_len + 1, NULL, "string");
+  memcpy(str, left, left_len);
+  memcpy(str + left_len, right, right_len);
+  return str;
+}
+
+int orl_strcmp(const char *left, const char *right) {
+  return strcmp(left, right);
+}
+`
+
+// builtinExterns are the C implementations of the build-in externs. Only the
+// externs a module declares with the build-in signature are linked so that
+// other externs with the same names don't clash with them
+var builtinExterns = []struct {
+	name      string
+	signature string
+	source    string
+}{
+	{"print", "fn(string) : void", `
+void print(const char *str) {
+  puts(str);
+}
+`},
+	{"int_to_str", "fn(int64) : string", `
+const char *int_to_str(int64_t i) {
+  char str[32];
+  snprintf(str, sizeof(str), "%" PRId64, i);
+  return orl_string(str);
+}
+`},
+	{"arg_count", "fn() : int32", `
+int32_t arg_count(void) {
+  return orl_argc - 1;
+}
+`},
+	{"arg", "fn(int32) : string", `
+const char *arg(int32_t index) {
+  if (index < 0 || index >= orl_argc - 1) {
+    orl_panic("argument index out of range");
+  }
+  return orl_string(orl_argv[index + 1]);
+}
+`},
+}
+
+// RuntimeFor returns the runtime together with the build-in externs module
+// declares
+func RuntimeFor(module *ir.Module) string {
+	runtime := Runtime
+	for _, builtin := range builtinExterns {
+		if extern := module.Extern(builtin.name); extern != nil && extern.Signature().String() == builtin.signature {
+			runtime += builtin.source
+		}
+	}
+	return runtime
+}
+
+// CC returns the C compiler used by Compile. It can be changed with the CC
+// environment variable, which can include flags separated by spaces
+func CC() string {
+	if cc := os.Getenv("CC"); cc != "" {
+		return cc
+	}
+	return "cc"
+}
+
+// Options configure CompileWithOptions
+type Options struct {
+	// DebugLeaks makes programs report the objects that are still alive when
+	// main returns on stderr and exit with status 1
+	DebugLeaks bool
+}
+
+// Compile generates C for module and compiles it together with the runtime
+// into an executable at output
+func Compile(module *ir.Module, output string) error {
+	return CompileWithOptions(module, output, Options{})
+}
+
+// CompileWithOptions is Compile with build options
+func CompileWithOptions(module *ir.Module, output string, options Options) error {
+	if module.Function("main") == nil {
+		return fmt.Errorf("no main function")
+	}
+
+	source, err := Generate(module)
+	if err != nil {
+		return err
+	}
+
+	dir, err := ioutil.TempDir("", "orlang")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	programFile := filepath.Join(dir, "program.c")
+	runtimeFile := filepath.Join(dir, "runtime.c")
+
+	if err := ioutil.WriteFile(programFile, source, 0644); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(runtimeFile, []byte(RuntimeFor(module)), 0644); err != nil {
+		return err
+	}
+
+	args := []string{"-std=c99", "-O2"}
+	if options.DebugLeaks {
+		args = append(args, "-DORL_DEBUG_LEAKS")
+	}
+	args = append(args, "-o", output, programFile, runtimeFile)
+
+	cc := strings.Fields(CC())
+	cmd := exec.Command(cc[0], append(cc[1:], args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %s\n%s", CC(), err, out)
+	}
+
+	return nil
+}
@@ -0,0 +1,300 @@
+package js
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/orktes/orlang/analyser"
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/scanner"
+	"github.com/orktes/orlang/types"
+)
+
+// Arrays are JS arrays. Indexes are bounds checked at runtime. Arrays are
+// values like structs: storing a stored array copies it and slicing copies
+// the sliced range into a new array so arrays never share elements. Maps are JS Maps which compare
+// keys by value for all comparable types
+
+// zeroValue returns the value used for the elements of a fixed length array
+// literal that are not given. Structs are new instances with every field set
+// to its zero value or its default value
+func (jscg *JSCodeGen) zeroValue(typ types.Type) string {
+	typ = types.LazyResolve(typ)
+	if bits, _, ok := integerType(typ); ok {
+		if bits == 64 {
+			return "0n"
+		}
+		return "0"
+	}
+
+	switch t := typ.(type) {
+	case types.PrimitiveType:
+		switch t {
+		case types.Float32Type, types.Float64Type:
+			return "0"
+		case types.BoolType:
+			return "false"
+		case types.StringType:
+			return `""`
+		}
+	case *types.ArrayType:
+		if t.Length > -1 {
+			values := make([]string, t.Length)
+			for i := range values {
+				values[i] = jscg.zeroValue(t.Type)
+			}
+			return "[" + strings.Join(values, ",") + "]"
+		}
+		return "[]"
+	case *types.MapType:
+		return "new Map()"
+	case *types.StructType:
+		if structDecl := jscg.structDecl(t); structDecl != nil {
+			fields := make([]string, len(structDecl.Variables))
+			for i, field := range structDecl.Variables {
+				fields[i] = "undefined"
+				if field.DefaultValue == nil {
+					fields[i] = jscg.zeroValue(t.Variables[i].Type)
+				}
+			}
+			name := jscg.getIdentifierForNode(structDecl, structDecl.Name.Text)
+			return fmt.Sprintf("new %s(%s)", name, strings.Join(fields, ","))
+		}
+	}
+
+	return "null"
+}
+
+// isPure returns true if evaluating expr has no side effects so that it can
+// be written more than once
+func isPure(expr ast.Expression) bool {
+	switch n := expr.(type) {
+	case *ast.Identifier:
+		return true
+	case *ast.MemberExpression:
+		return isPure(n.Target)
+	case *ast.ParenExpression:
+		return isPure(n.Expression)
+	case *ast.UnaryExpression:
+		return n.Operator.Type == scanner.TokenTypeASTERISK && isPure(n.Expression)
+	}
+	return false
+}
+
+func (jscg *JSCodeGen) writeArrayExpression(n *ast.ArrayExpression, typ types.Type) {
+	jscg.writeWithNodePosition(n, "[")
+
+	for i, expr := range n.Expressions {
+		if i > 0 {
+			jscg.write(",")
+		}
+		jscg.writeValue(expr, types.LazyResolve(typ).(*types.ArrayType).Type)
+	}
+
+	if arrType, ok := types.LazyResolve(typ).(*types.ArrayType); ok {
+		for i := int64(len(n.Expressions)); i < arrType.Length; i++ {
+			if i > 0 {
+				jscg.write(",")
+			}
+			jscg.write(jscg.zeroValue(arrType.Type))
+		}
+	}
+
+	jscg.writeWithPosition(n.EndPos(), n.EndPos(), "]")
+}
+
+// mapType returns the type of expr if it is a map
+func (jscg *JSCodeGen) mapType(expr ast.Expression) (*types.MapType, bool) {
+	mapType, ok := types.LazyResolve(jscg.getNodeInfo(expr).Type).(*types.MapType)
+	return mapType, ok
+}
+
+// writeIndexExpression writes a bounds checked index expression. Targets
+// without side effects are written as a member access so that the
+// expression can be assigned to. Missing map keys evaluate to the zero value
+func (jscg *JSCodeGen) writeIndexExpression(n *ast.IndexExpression) {
+	if mapType, ok := jscg.mapType(n.Target); ok {
+		jscg.writeWithNodePosition(n, jscg.useHelper("mapget")+"(")
+		ast.Walk(jscg, n.Target)
+		jscg.write(",")
+		ast.Walk(jscg, n.Index)
+		jscg.write("," + jscg.zeroValue(mapType.Value) + ")")
+		return
+	}
+
+	jscg.useHelper("idx")
+	if !isPure(n.Target) {
+		jscg.writeWithNodePosition(n, jscg.useHelper("get")+"(")
+		ast.Walk(jscg, n.Target)
+		jscg.write(",")
+		ast.Walk(jscg, n.Index)
+		jscg.write(")")
+		return
+	}
+
+	ast.Walk(jscg, n.Target)
+	jscg.writeWithPosition(ast.StartPositionFromToken(n.LeftBracket), n.EndPos(), "["+jscg.useHelper("idx")+"(")
+	ast.Walk(jscg, n.Target)
+	jscg.write(",")
+	ast.Walk(jscg, n.Index)
+	jscg.write(")]")
+}
+
+// writeIndexAssigment writes an assignment to a map entry or to an element
+// of an array whose target can not be evaluated twice
+func (jscg *JSCodeGen) writeIndexAssigment(n *ast.Assigment) bool {
+	left, ok := n.Left.(*ast.IndexExpression)
+	if !ok {
+		return false
+	}
+
+	helper := "mapset"
+	if _, isMap := jscg.mapType(left.Target); !isMap {
+		if isPure(left.Target) {
+			return false
+		}
+		jscg.useHelper("idx")
+		helper = "set"
+	}
+
+	jscg.writeWithNodePosition(n, jscg.useHelper(helper)+"(")
+	ast.Walk(jscg, left.Target)
+	jscg.write(",")
+	ast.Walk(jscg, left.Index)
+	jscg.write(",")
+	jscg.writeValue(n.Right, jscg.getNodeInfo(left).Type)
+	jscg.write(")")
+	return true
+}
+
+func (jscg *JSCodeGen) writeSliceExpression(n *ast.SliceExpression) {
+	jscg.writeWithNodePosition(n, jscg.useHelper("slice")+"(")
+	ast.Walk(jscg, n.Target)
+	for _, bound := range []ast.Expression{n.Low, n.High} {
+		jscg.write(",")
+		if bound == nil {
+			jscg.write("undefined")
+			continue
+		}
+		ast.Walk(jscg, bound)
+	}
+	if arrType, ok := types.LazyResolve(jscg.getNodeInfo(n.Target).Type).(*types.ArrayType); ok && isCopied(arrType.Type) {
+		jscg.write("," + jscg.elementCopier(arrType))
+	}
+	jscg.write(")")
+}
+
+// writeMapIncrement writes ++ and -- of a map entry evaluating the map and
+// the key only once
+func (jscg *JSCodeGen) writeMapIncrement(n *ast.UnaryExpression, typ types.Type) bool {
+	index, ok := n.Expression.(*ast.IndexExpression)
+	if !ok {
+		return false
+	}
+	mapType, ok := jscg.mapType(index.Target)
+	if !ok {
+		return false
+	}
+
+	op := "+"
+	if n.Operator.Type == scanner.TokenTypeDecrement {
+		op = "-"
+	}
+	prefix, suffix := wrap(typ)
+
+	jscg.writeWithPosition(ast.StartPositionFromToken(n.Operator), ast.EndPositionFromToken(n.Operator), jscg.useHelper("mapupdate")+"(")
+	ast.Walk(jscg, index.Target)
+	jscg.write(",")
+	ast.Walk(jscg, index.Index)
+	jscg.write(fmt.Sprintf(
+		",%s,function (x) { return %sx%s%s%s; },%t)",
+		jscg.zeroValue(mapType.Value),
+		prefix,
+		op,
+		one(typ),
+		suffix,
+		n.Postfix,
+	))
+	return true
+}
+
+func (jscg *JSCodeGen) writeForInLoop(n *ast.ForInLoop) {
+	_, isMap := jscg.mapType(n.Collection)
+
+	jscg.writeWithNodePosition(n, "for (var ")
+	if n.Value != nil {
+		jscg.write("[")
+	}
+	jscg.writeWithNodePosition(n.Key, jscg.getIdentifier(n.Key))
+	if n.Value != nil {
+		jscg.write(",")
+		jscg.writeWithNodePosition(n.Value, jscg.getIdentifier(n.Value))
+		jscg.write("]")
+	}
+	jscg.write(" of ")
+	ast.Walk(jscg, n.Collection)
+
+	// Maps iterate over their entries and arrays over their elements
+	if n.Value == nil {
+		jscg.write(".keys()")
+	} else if !isMap {
+		jscg.write(".entries()")
+	}
+
+	jscg.write(")")
+
+	// Loop variables whose address is taken get a new cell on every iteration
+	var boxes []string
+	for _, ident := range []*ast.Identifier{n.Key, n.Value} {
+		if ident != nil && jscg.isBoxed(ident) {
+			name := jscg.getIdentifier(ident)
+			boxes = append(boxes, fmt.Sprintf("%s={v:%s};", name, name))
+		}
+	}
+
+	if len(boxes) == 0 {
+		ast.Walk(jscg, n.Block)
+		return
+	}
+
+	jscg.write("{" + strings.Join(boxes, ""))
+	ast.Walk(jscg, n.Block)
+	jscg.write("}")
+}
+
+func (jscg *JSCodeGen) writeMapExpression(n *ast.MapExpression) {
+	jscg.writeWithNodePosition(n, "new Map([")
+
+	for i, entry := range n.Entries {
+		if i > 0 {
+			jscg.write(",")
+		}
+		jscg.write("[")
+		ast.Walk(jscg, entry.Key)
+		jscg.write(",")
+		jscg.writeValue(entry.Value, jscg.getNodeInfo(n).Type.(*types.MapType).Value)
+		jscg.write("]")
+	}
+
+	jscg.writeWithPosition(n.EndPos(), n.EndPos(), "])")
+}
+
+// writeBuiltinCall writes a call to a built-in function
+func (jscg *JSCodeGen) writeBuiltinCall(n *ast.FunctionCall, name string) {
+	switch name {
+	case analyser.LenBuiltin:
+		jscg.writeWithNodePosition(n, "(")
+		ast.Walk(jscg, n.Arguments[0])
+		if _, isMap := jscg.mapType(n.Arguments[0].Expression); isMap {
+			jscg.write(").size")
+		} else {
+			jscg.write(").length")
+		}
+	case analyser.DeleteBuiltin:
+		jscg.writeWithNodePosition(n, "(")
+		ast.Walk(jscg, n.Arguments[0])
+		jscg.write(").delete(")
+		ast.Walk(jscg, n.Arguments[1])
+		jscg.write(")")
+	}
+}
@@ -0,0 +1,144 @@
+package js
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/orktes/orlang/ast"
+)
+
+// Enum values are objects tagged with the name of their variant and holding
+// the values of the variant in an array. Enums are objects with a constructor
+// function for each variant carrying values and a shared value for the others
+
+func (jscg *JSCodeGen) writeEnum(n *ast.Enum) {
+	name := jscg.getIdentifierForNode(n, n.Name.Text)
+	jscg.writeWithNodePosition(n.Name, fmt.Sprintf("var %s = {", name))
+
+	for i, variant := range n.Variants {
+		if i > 0 {
+			jscg.write(",")
+		}
+
+		if len(variant.Types) == 0 {
+			jscg.writeWithNodePosition(variant, fmt.Sprintf(
+				"%s: {tag: %q, values: []}",
+				variant.Name.Text,
+				variant.Name.Text,
+			))
+			continue
+		}
+
+		args := make([]string, len(variant.Types))
+		for i := range args {
+			args[i] = fmt.Sprintf("v%d", i)
+		}
+
+		jscg.writeWithNodePosition(variant, fmt.Sprintf(
+			"%s: function (%s) {return {tag: %q, values: [%s]};}",
+			variant.Name.Text,
+			strings.Join(args, ", "),
+			variant.Name.Text,
+			strings.Join(args, ", "),
+		))
+	}
+
+	jscg.write("}")
+}
+
+// writeVariant writes a reference to an enum variant
+func (jscg *JSCodeGen) writeVariant(n *ast.MemberExpression) {
+	enumName := n.Target.(*ast.Identifier).Text
+	name := jscg.getIdentifierForNode(jscg.typeNode(enumName), enumName)
+	jscg.writeWithNodePosition(n, fmt.Sprintf("%s.%s", name, n.Property.Text))
+}
+
+// writeMatch writes a match as a switch over the tag of the matched value.
+// Matches with code blocks as arms are statements and the others are
+// evaluated in a function returning the value of the matching arm
+func (jscg *JSCodeGen) writeMatch(n *ast.MatchExpression) {
+	subject := jscg.getIdentifierForNode(n, "match")
+
+	statement := false
+	for _, arm := range n.Arms {
+		if _, isBlock := arm.Body.(*ast.Block); isBlock {
+			statement = true
+		}
+	}
+
+	if statement {
+		jscg.writeWithNodePosition(n, fmt.Sprintf("{var %s = ", subject))
+		ast.Walk(jscg, n.Subject)
+		jscg.write(";")
+	} else {
+		jscg.writeWithNodePosition(n, fmt.Sprintf("((%s) => {", subject))
+	}
+
+	jscg.write(fmt.Sprintf("switch (%s.tag) {", subject))
+
+	for _, arm := range n.Arms {
+		var payload *ast.TuplePattern
+
+		switch pattern := arm.Pattern.(type) {
+		case *ast.VariantPattern:
+			payload = pattern.Payload
+			jscg.writeWithNodePosition(pattern, fmt.Sprintf("case %q: {", pattern.Name.Text))
+		case *ast.Identifier:
+			if pattern.Text == "_" {
+				jscg.writeWithNodePosition(pattern, "default: {")
+			} else {
+				jscg.writeWithNodePosition(pattern, fmt.Sprintf("case %q: {", pattern.Text))
+			}
+		}
+
+		if payload != nil {
+			jscg.writeBindings(payload, subject+".values")
+		}
+
+		if statement {
+			ast.Walk(jscg, arm.Body)
+			jscg.write(";} break;")
+		} else {
+			jscg.write("return ")
+			ast.Walk(jscg, arm.Body)
+			jscg.write(";}")
+		}
+	}
+
+	jscg.write("}")
+
+	if statement {
+		jscg.write("}")
+		return
+	}
+
+	jscg.write("})(")
+	ast.Walk(jscg, n.Subject)
+	jscg.write(")")
+}
+
+// writeBindings declares the variables bound by the payload pattern of a match
+// arm. Structs are copied so that the matched value is not changed through
+// them
+func (jscg *JSCodeGen) writeBindings(pattern *ast.TuplePattern, prefix string) {
+	for i, pat := range pattern.Patterns {
+		value := fmt.Sprintf("%s[%d]", prefix, i)
+
+		switch p := pat.(type) {
+		case *ast.TuplePattern:
+			jscg.writeBindings(p, value)
+		case *ast.Identifier:
+			if p.Text == "_" {
+				continue
+			}
+
+			value = jscg.copyValue(jscg.getNodeInfo(p).Type, value)
+
+			jscg.writeWithNodePosition(p, fmt.Sprintf(
+				"var %s = %s;",
+				jscg.getIdentifier(p),
+				jscg.box(p, value),
+			))
+		}
+	}
+}
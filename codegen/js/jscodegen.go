@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"fmt"
 	"strings"
+	"unicode"
 
 	"github.com/orktes/orlang/analyser"
 	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/scanner"
 	"github.com/orktes/orlang/types"
 )
 
@@ -15,11 +17,19 @@ type JSCodeGen struct {
 	buffer       bytes.Buffer
 	currentFile  *ast.File
 	identNumbers map[ast.Node]int
-	types        map[string]ast.Node
+	// source map state
+	files    []*ast.File
+	sources  map[*ast.File]int
+	mappings []mapping
+	line     int
+	column   int
+	// runtime helpers used by the generated code
+	helpers     map[string]bool
+	helperOrder []string
 }
 
 func New(info *analyser.Info) *JSCodeGen {
-	return &JSCodeGen{analyserInfo: info, identNumbers: map[ast.Node]int{}, types: map[string]ast.Node{}}
+	return &JSCodeGen{analyserInfo: info, identNumbers: map[ast.Node]int{}, helpers: map[string]bool{}}
 }
 
 func (jscg *JSCodeGen) getIdentifierForNode(node ast.Node, name string) string {
@@ -31,12 +41,34 @@ func (jscg *JSCodeGen) getIdentifierForNode(node ast.Node, name string) string {
 		jscg.identNumbers[node] = identNumber
 	}
 
-	return fmt.Sprintf("$%d_%s", identNumber, name)
+	return fmt.Sprintf("$%d_%s", identNumber, identifierName(name))
+}
+
+// isIdentifier returns true if name can be used as a property name without
+// quoting
+func isIdentifier(name string) bool {
+	return name != "" && identifierName(name) == name
+}
+
+// identifierName replaces the characters not allowed in JavaScript
+// identifiers. Names of instances of generic declarations contain the type
+// arguments of the instance
+func identifierName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
 }
 
 func (jscg *JSCodeGen) getIdentifier(ident *ast.Identifier) string {
 	nodeInfo := jscg.analyserInfo.FileInfo[jscg.currentFile].NodeInfo[ident]
-	scopeItemDetals := nodeInfo.Scope.GetDetails(ident.Text, true)
+	if instance, ok := nodeInfo.Instance.(*ast.FunctionDeclaration); ok {
+		return jscg.getIdentifierForNode(instance.Signature.Identifier, instance.Signature.Identifier.Text)
+	}
+
+	scopeItemDetals := nodeInfo.Scope.LookupDetails(ident, true)
 	return jscg.getIdentifierForNode(scopeItemDetals.DefineIdentifier, ident.Text)
 }
 
@@ -54,11 +86,20 @@ func (jscg *JSCodeGen) writeWithNodePosition(node ast.Node, str string) {
 }
 
 func (jscg *JSCodeGen) writeWithPosition(start ast.Position, end ast.Position, str string) {
+	if str != "" {
+		jscg.mapPosition(start)
+	}
 	jscg.write(str)
 }
 
+// typeNode returns the declaration of a type visible in the current file
+func (jscg *JSCodeGen) typeNode(name string) ast.Node {
+	return jscg.analyserInfo.FileInfo[jscg.currentFile].Types[name]
+}
+
 func (jscg *JSCodeGen) write(str string) {
 	jscg.buffer.WriteString(str)
+	jscg.advance(str)
 }
 
 func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
@@ -66,7 +107,7 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 	switch n := node.(type) {
 	case *ast.Macro:
 	case *ast.CallArgument:
-		ast.Walk(jscg, n.Expression)
+		jscg.writeValue(n.Expression, jscg.argumentType(n))
 		return nil
 	case *ast.TupleDeclaration:
 
@@ -74,7 +115,7 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 
 		if n.DefaultValue != nil {
 			if ident, ok := n.DefaultValue.(*ast.Identifier); ok {
-				varName = jscg.getIdentifier(ident)
+				varName = jscg.variable(ident)
 			}
 		}
 
@@ -103,10 +144,9 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 				case *ast.Identifier:
 					jscg.writeWithNodePosition(pt,
 						fmt.Sprintf(
-							"var %s = %s[%d]",
+							"var %s = %s",
 							jscg.getIdentifier(pt),
-							prefix,
-							i,
+							jscg.box(pt, fmt.Sprintf("%s[%d]", prefix, i)),
 						))
 					if i != len(pattern.Patterns)-1 {
 						jscg.write(";")
@@ -124,16 +164,34 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 			`var %s`,
 			jscg.getIdentifier(n.Name),
 		))
-		if n.DefaultValue != nil {
-			jscg.write("=")
+
+		if jscg.isBoxed(n.Name) {
+			jscg.write("={v:")
+			if n.DefaultValue != nil {
+				jscg.writeValue(n.DefaultValue, nodeInfo.Type)
+			} else {
+				jscg.write(jscg.zeroValue(nodeInfo.Type))
+			}
+			jscg.write("}")
+			return nil
+		}
+
+		jscg.write("=")
+		if n.DefaultValue == nil {
+			jscg.write(jscg.zeroValue(nodeInfo.Type))
+			return nil
 		}
 
-		ast.Walk(jscg, n.DefaultValue)
+		jscg.writeValue(n.DefaultValue, nodeInfo.Type)
 		return nil
 	case *ast.Assigment:
+		if jscg.writeIndexAssigment(n) {
+			return nil
+		}
+
 		ast.Walk(jscg, n.Left)
 		jscg.write(" = ")
-		ast.Walk(jscg, n.Right)
+		jscg.writeValue(n.Right, jscg.getNodeInfo(n.Left).Type)
 		return nil
 	case *ast.IfStatement:
 		jscg.writeWithPosition(n.StartPos(), n.StartPos(), " if (")
@@ -152,7 +210,7 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 		jscg.writeWithPosition(n.StartPos(), n.EndPos(), `[`)
 
 		for i, expr := range n.Expressions {
-			ast.Walk(jscg, expr)
+			jscg.writeValue(expr, nil)
 			if i < len(n.Expressions)-1 {
 				jscg.write(`,`)
 			}
@@ -161,6 +219,23 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 		jscg.writeWithPosition(n.EndPos(), n.EndPos(), `]`)
 		return nil
 	case *ast.UnaryExpression:
+		switch n.Operator.Type {
+		case scanner.TokenTypeAMPERSAND:
+			jscg.writeAddressOf(n)
+			return nil
+		case scanner.TokenTypeASTERISK:
+			jscg.writeDereference(n)
+			return nil
+		case scanner.TokenTypeIncrement, scanner.TokenTypeDecrement:
+			if jscg.writeMapIncrement(n, nodeInfo.Type) || jscg.writeIncrement(n, nodeInfo.Type) {
+				return nil
+			}
+		case scanner.TokenTypeSUB:
+			if jscg.writeNegation(n, nodeInfo.Type) {
+				return nil
+			}
+		}
+
 		if n.Postfix {
 			ast.Walk(jscg, n.Expression)
 
@@ -178,9 +253,7 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 		return nil
 	case *ast.BinaryExpression:
 		if nodeInfo.OverloadedOperation == nil {
-			ast.Walk(jscg, n.Left)
-			jscg.writeWithPosition(ast.StartPositionFromToken(n.Operator), ast.EndPositionFromToken(n.Operator), n.Operator.Text)
-			ast.Walk(jscg, n.Right)
+			jscg.writeArithmetic(n, nodeInfo.Type)
 		} else {
 			// Operator has been overloaded
 			name := jscg.getIdentifierForNode(nodeInfo.OverloadedOperation, n.Operator.Type.String())
@@ -196,9 +269,9 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 			}
 
 			jscg.writeWithPosition(ast.StartPositionFromToken(n.Operator), ast.EndPositionFromToken(n.Operator), fmt.Sprintf("%s(", name))
-			ast.Walk(jscg, n.Left)
+			jscg.writeValue(n.Left, nil)
 			jscg.write(", ")
-			ast.Walk(jscg, n.Right)
+			jscg.writeValue(n.Right, nil)
 			jscg.writeWithPosition(n.EndPos(), n.EndPos(), ")")
 		}
 		return nil
@@ -219,22 +292,39 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 			break
 		}
 
-		jscg.writeWithNodePosition(n, jscg.getIdentifier(n))
+		jscg.writeWithNodePosition(n, jscg.variable(n))
 	case *ast.ValueExpression:
+		suffix := ""
+		if n.Token.Type == scanner.TokenTypeNumber {
+			suffix = literalSuffix(nodeInfo.Type)
+		}
+
 		jscg.writeWithNodePosition(n, fmt.Sprintf(
-			`%s`,
+			`%s%s`,
 			n.Text,
+			suffix,
 		))
 	case *ast.ReturnStatement:
 		jscg.writeWithPosition(n.Start, n.ReturnEnd, `return `)
+		jscg.writeValue(n.Expression, jscg.returnType(n))
+		return nil
 	case *ast.FunctionCall:
+		if nodeInfo.Builtin != "" {
+			jscg.writeBuiltinCall(n, nodeInfo.Builtin)
+			return nil
+		}
+
 		if nodeInfo.TypeCast {
-			if nodeInfo.Type == types.Int32Type || nodeInfo.Type == types.Int64Type {
-				jscg.writeWithNodePosition(n, `Math.floor`)
-			} else {
+			argType := jscg.getNodeInfo(n.Arguments[0].Expression).Type
+			if prefix, suffix, ok := convert(argType, nodeInfo.Type); ok {
+				jscg.writeWithNodePosition(n, prefix)
 				ast.Walk(jscg, n.Arguments[0])
+				jscg.write(suffix)
 				return nil
 			}
+
+			ast.Walk(jscg, n.Arguments[0])
+			return nil
 		} else {
 			ast.Walk(jscg, n.Callee)
 		}
@@ -260,7 +350,7 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 				found := false
 				for _, expr := range n.Arguments {
 					if expr.Name.Text == argName {
-						ast.Walk(jscg, expr.Expression)
+						jscg.writeValue(expr.Expression, jscg.argumentType(expr))
 						found = true
 						break
 					}
@@ -290,7 +380,13 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 		jscg.writeWithPosition(n.End, n.End, `}`)
 		return nil
 	case *ast.File:
-		jscg.write("(function () {")
+		// Instances of generic declarations are declared before the code
+		// using them
+		for _, instance := range jscg.analyserInfo.FileInfo[n].Instances {
+			ast.Walk(jscg, instance)
+			jscg.write(";")
+		}
+
 		for _, node := range n.Body {
 			ast.Walk(jscg, node)
 			jscg.write(";")
@@ -318,6 +414,11 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 		ast.Walk(jscg, n.Block)
 		return nil
 	case *ast.FunctionDeclaration:
+		if len(n.Signature.TypeParameters) > 0 {
+			// Only instances of generic functions are emitted
+			return nil
+		}
+
 		var name string
 		var args []string
 		var start ast.Position
@@ -360,6 +461,13 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 			}
 		}
 
+		for _, arg := range n.Signature.Arguments {
+			if jscg.isBoxed(arg.Name) {
+				name := jscg.getIdentifier(arg.Name)
+				jscg.write(fmt.Sprintf("%s={v:%s};", name, name))
+			}
+		}
+
 		for _, node := range n.Block.Body {
 			ast.Walk(jscg, node)
 			jscg.write(";")
@@ -374,8 +482,12 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 			break
 		}
 
+		if len(n.TypeParameters) > 0 {
+			// Only instances of generic structs are emitted
+			return nil
+		}
+
 		name := jscg.getIdentifierForNode(n, n.Name.Text)
-		jscg.types[n.Name.Text] = n
 
 		args := []string{}
 		for _, v := range n.Variables {
@@ -406,7 +518,7 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 
 		jscg.write("};")
 
-		for i, funDecl := range n.Functions {
+		for _, funDecl := range n.Functions {
 			var start ast.Position
 			var end ast.Position
 			var funcName string
@@ -428,11 +540,27 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 			))
 
 			ast.Walk(jscg, funDecl)
-			if i < len(n.Functions)-1 {
-				jscg.write(";")
-			}
+			jscg.write(";")
 		}
 
+		jscg.writeStructCopy(name, nodeInfo.Type.(*types.StructType))
+		jscg.write(";")
+		jscg.writeTypeTag(name, n)
+
+		return nil
+	case *ast.Enum:
+		if n.Name != nil {
+			jscg.writeEnum(n)
+		}
+		return nil
+	case *ast.MatchExpression:
+		jscg.writeMatch(n)
+		return nil
+	case *ast.TypeAssertion:
+		jscg.writeTypeAssertion(n)
+		return nil
+	case *ast.TypeSwitch:
+		jscg.writeTypeSwitch(n)
 		return nil
 	case *ast.Interface:
 		if n.Name == nil {
@@ -440,34 +568,40 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 		}
 
 		name := jscg.getIdentifierForNode(n, n.Name.Text)
-		jscg.types[n.Name.Text] = n
 
 		jscg.writeWithNodePosition(n.Name, fmt.Sprintf("function %s (val) {};", name))
 
-		for _, signature := range n.Functions {
-			var start ast.Position
-			var end ast.Position
-			var funcName string
-			if signature.Identifier != nil {
-				funcName = signature.Identifier.Text
-				start = signature.Identifier.StartPos()
-				end = signature.Identifier.EndPos()
-			} else if signature.Operator != nil {
+		// Methods of embedded interfaces are part of the interface
+		for _, fn := range nodeInfo.Type.(*types.InterfaceType).Functions {
+			if !isIdentifier(fn.Name) {
 				// Here be dragons
-				// TODO decide what to do here. Most likely analyzer should trough an error
+				// TODO decide what to do with operators. Most likely analyzer should trough an error
+				continue
+			}
+
+			start, end := n.Name.StartPos(), n.Name.EndPos()
+			for _, signature := range n.Functions {
+				if signature.Identifier != nil && signature.Identifier.Text == fn.Name {
+					start, end = signature.Identifier.StartPos(), signature.Identifier.EndPos()
+				}
 			}
 
 			jscg.writeWithPosition(start, end, fmt.Sprintf(
 				"%s.prototype.%s = function () { return this.%s.apply(this, arguments); };",
 				name,
-				funcName,
-				funcName,
+				fn.Name,
+				fn.Name,
 			))
 		}
 
 		return nil
 	case *ast.StructExpression:
-		if typeNode := jscg.types[n.Identifier.Text]; typeNode != nil {
+		typeNode := jscg.typeNode(n.Identifier.Text)
+		if nodeInfo.Instance != nil {
+			typeNode = nodeInfo.Instance
+		}
+
+		if typeNode != nil {
 			if structTypeNode, ok := typeNode.(*ast.Struct); ok {
 				name := jscg.getIdentifierForNode(structTypeNode, structTypeNode.Name.Text)
 				jscg.writeWithNodePosition(n, fmt.Sprintf("new %s(", name))
@@ -491,7 +625,7 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 						found := false
 						for _, expr := range n.Arguments {
 							if expr.Name.Text == argName {
-								ast.Walk(jscg, expr.Expression)
+								jscg.writeValue(expr.Expression, jscg.argumentType(expr))
 								found = true
 								break
 							}
@@ -511,10 +645,37 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 			}
 		}
 
+		return nil
+	case *ast.ArrayExpression:
+		jscg.writeArrayExpression(n, nodeInfo.Type)
+		return nil
+	case *ast.MapExpression:
+		jscg.writeMapExpression(n)
+		return nil
+	case *ast.ForInLoop:
+		jscg.writeForInLoop(n)
+		return nil
+	case *ast.IndexExpression:
+		jscg.writeIndexExpression(n)
+		return nil
+	case *ast.SliceExpression:
+		jscg.writeSliceExpression(n)
 		return nil
 	case *ast.MemberExpression:
+		if nodeInfo.Variant != nil {
+			jscg.writeVariant(n)
+			return nil
+		}
+
 		// TODO clean this up
 		targetType := jscg.getNodeInfo(n.Target).Type
+		deref := ""
+		if pointerType, ok := types.LazyResolve(targetType).(*types.PointerType); ok {
+			// Fields and methods are accessed through the pointer
+			targetType = types.LazyResolve(pointerType.Elem)
+			deref = ".v"
+		}
+
 		if structType, structTypeOk := targetType.(types.TypeWithMethods); structTypeOk {
 			// Is property a method
 			if ok, _ := structType.HasFunction(n.Property.Text); ok {
@@ -530,7 +691,7 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 						name = typ.Name
 					}
 
-					if typeNode := jscg.types[name]; typeNode != nil {
+					if typeNode := jscg.typeNode(name); typeNode != nil {
 						name := jscg.getIdentifierForNode(typeNode, name)
 						jscg.writeWithPosition(
 							node.StartPos(),
@@ -543,7 +704,7 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 						)
 
 						ast.Walk(jscg, n.Target)
-						jscg.write(")")
+						jscg.write(deref + ")")
 
 						return nil
 
@@ -553,7 +714,7 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 		}
 
 		ast.Walk(jscg, n.Target)
-		jscg.write(".")
+		jscg.write(deref + ".")
 		jscg.writeWithNodePosition(n.Property, n.Property.Text)
 
 		return nil
@@ -565,25 +726,41 @@ func (jscg *JSCodeGen) Visit(node ast.Node) ast.Visitor {
 
 func (jscg *JSCodeGen) Leave(node ast.Node) {
 	switch n := node.(type) {
-	case *ast.File:
-		for _, n := range n.Body {
-			if funDecl, ok := n.(*ast.FunctionDeclaration); ok {
-				if funDecl.Signature != nil && funDecl.Signature.Identifier != nil && funDecl.Signature.Identifier.Text == "main" {
-					name := jscg.getIdentifier(funDecl.Signature.Identifier)
-					jscg.write(fmt.Sprintf("%s();", name))
-					break
-				}
-			}
-		}
-
-		jscg.write("})();")
 	case *ast.ParenExpression:
 		jscg.writeWithPosition(n.EndPos(), n.EndPos(), ")")
 	}
 }
 
-func (jscg *JSCodeGen) Generate(file *ast.File) []byte {
-	jscg.currentFile = file
-	ast.Walk(jscg, file)
+// Generate generates a single JavaScript program from files. Files must be in
+// the order they were analysed and main is called from the last file
+func (jscg *JSCodeGen) Generate(files ...*ast.File) []byte {
+	jscg.files = files
+	jscg.sources = map[*ast.File]int{}
+	for i, file := range files {
+		jscg.sources[file] = i
+	}
+
+	jscg.write("(function () {")
+
+	for _, file := range files {
+		jscg.currentFile = file
+		ast.Walk(jscg, file)
+	}
+
+	for _, n := range jscg.currentFile.Body {
+		if funDecl, ok := n.(*ast.FunctionDeclaration); ok {
+			if funDecl.Signature != nil && funDecl.Signature.Identifier != nil && funDecl.Signature.Identifier.Text == "main" {
+				name := jscg.getIdentifier(funDecl.Signature.Identifier)
+				jscg.write(fmt.Sprintf("%s();", name))
+				break
+			}
+		}
+	}
+
+	for _, name := range jscg.helperOrder {
+		jscg.write(helpers[name])
+	}
+
+	jscg.write("})();")
 	return jscg.buffer.Bytes()
 }
@@ -1,21 +1,25 @@
 package js
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"os/exec"
+	"strconv"
 	"strings"
 	"testing"
+	"unicode/utf16"
 
 	"github.com/orktes/orlang/ast"
 	"github.com/orktes/orlang/types"
 
 	"github.com/orktes/orlang/analyser"
 	"github.com/orktes/orlang/parser"
-	"github.com/robertkrimen/otto"
+	"gopkg.in/sourcemap.v1"
 )
 
 func TestSimple(t *testing.T) {
-	res, err := testCodegen(`
+	res, err := testCodegen(t, `
 		macro stringCat {
 			() : (
 				fn +(left:string, right:float32) => string {
@@ -210,13 +214,66 @@ func TestSimple(t *testing.T) {
 	}
 }
 
-func generateCode(str string) (string, error) {
-	file, err := parser.Parse(strings.NewReader(str))
+// generateCode generates a program from sources. The last source is the main
+// file and import paths are indexes of the imported sources
+func TestMultipleFiles(t *testing.T) {
+	res, err := testCodegen(t, `
+		export struct Counter {
+			var n : int64
+
+			fn inc() {
+				this.n = this.n + int64(1)
+			}
+		}
+
+		var step = int64(10)
+
+		export fn counter() => Counter {
+			return Counter{n: step}
+		}
+	`, `
+		import "0"
+
+		var step = int64(1)
+
+		fn main() {
+			var c = counter()
+			c.inc()
+			printInt(c.n + step)
+		}
+	`)
+
 	if err != nil {
-		return "", err
+		t.Fatal(err)
+	}
+
+	if res != "12" {
+		t.Error("Wrong result received", res)
+	}
+}
+
+func generateCode(sources ...string) (string, error) {
+	files := []*ast.File{}
+	for _, src := range sources {
+		file, err := parser.Parse(strings.NewReader(src))
+		if err != nil {
+			return "", err
+		}
+		files = append(files, file)
+	}
+
+	imports := map[*ast.Import]*ast.File{}
+	for _, file := range files {
+		for _, importDecl := range file.Imports {
+			i, err := strconv.Atoi(importDecl.Path)
+			if err != nil || i >= len(files) {
+				return "", fmt.Errorf("invalid import %s", importDecl.Path)
+			}
+			imports[importDecl] = files[i]
+		}
 	}
 
-	analyser, err := analyser.New(file)
+	analyser, err := analyser.NewProgram(files, imports)
 	if err != nil {
 		return "", err
 	}
@@ -264,37 +321,963 @@ func generateCode(str string) (string, error) {
 		return "", analyErr
 	}
 
-	code := New(info).Generate(file)
+	code := New(info).Generate(files...)
 	return string(code), nil
 }
 
-func testCodegen(str string) (string, error) {
-	code, err := generateCode(str)
+// runtime defines the externs used by the tests. Each extern stores its
+// argument as the result of the program
+const runtime = `var result;
+function print(str) { result = String(str); }
+function num_to_str(num) { result = String(num); return result; }
+function printInt(num) { result = String(num); }
+`
+
+// testCodegen generates a program from sources and runs it with node. The
+// result is the last value passed to an extern
+func testCodegen(t *testing.T, sources ...string) (string, error) {
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Fatal("node is required to run the generated code")
+	}
+
+	code, err := generateCode(sources...)
 	if err != nil {
 		return "", err
 	}
 
-	println(string(code)) // debug
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("node", "-")
+	cmd.Stdin = strings.NewReader(runtime + code + "\nprocess.stdout.write(result === undefined ? '' : result);")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s: %s\n%s", err, stderr.String(), code)
+	}
+
+	return stdout.String(), nil
+}
 
-	var result string
-	vm := otto.New()
-	vm.Set("print", func(call otto.FunctionCall) otto.Value {
-		result = call.Argument(0).String()
-		return otto.Value{}
-	})
+func TestIntegerSemantics(t *testing.T) {
+	tests := []struct {
+		src    string
+		result string
+	}{
+		{`var a = int32(2147483647)
+		a = a + 1
+		printInt(int64(a))`, "-2147483648"},
+		{`printInt(int64(int32(65536) * int32(65537)))`, "65536"},
+		{`printInt(int64(int32(-7) / int32(2)))`, "-3"},
+		{`var b = uint8(250)
+		b = b + uint8(10)
+		printInt(int64(b))`, "4"},
+		{`printInt(int64(uint8(-1)))`, "255"},
+		{`printInt(int64(int8(127) + int8(1)))`, "-128"},
+		{`printInt(int64(int16(200) * int16(200)))`, "-25536"},
+		{`printInt(int64(uint32(0) - uint32(1)))`, "4294967295"},
+		{`printInt(int64(uint32(65536) * uint32(65537)))`, "65536"},
+		{`var c = int8(127)
+		c++
+		printInt(int64(c))`, "-128"},
+		{`var c = uint16(0)
+		var d = c--
+		printInt(int64(c) * int64(100000) + int64(d))`, "6553500000"},
+		{`printInt(int64(int32(5.7)) + int64(int32(-5.7)))`, "0"},
+		{`var max = 9223372036854775807
+		printInt(max + int64(1))`, "-9223372036854775808"},
+		{`printInt(int64(-7) / int64(2))`, "-3"},
+		{`printInt(int64(int32(int64(4294967297))))`, "1"},
+		{`printInt(int64(uint64(0) - uint64(1)))`, "-1"},
+		{`num_to_str(float64(uint64(0) - uint64(1)))`, "18446744073709552000"},
+		{`num_to_str(float64(float32(0.1) + float32(0.2)))`, "0.30000001192092896"},
+	}
 
-	vm.Set("num_to_str", func(call otto.FunctionCall) otto.Value {
-		result = call.Argument(0).String()
-		val, _ := vm.ToValue(result)
-		return val
-	})
+	for _, test := range tests {
+		res, err := testCodegen(t, fmt.Sprintf("fn main() {\n%s\n}", test.src))
+		if err != nil {
+			t.Errorf("%s: %s", test.src, err)
+			continue
+		}
 
-	vm.Set("printInt", func(call otto.FunctionCall) otto.Value {
-		resultInt, _ := call.Argument(0).ToInteger()
-		result = fmt.Sprintf("%d", resultInt)
-		return otto.Value{}
-	})
-	_, err = vm.Run(string(code))
+		if res != test.result {
+			t.Errorf("Expected %s to produce %s got %s", test.src, test.result, res)
+		}
+	}
 
-	return result, err
+	_, err := testCodegen(t, `fn main() {
+		var zero = int32(0)
+		printInt(int64(int32(1) / zero))
+	}`)
+	if err == nil || !strings.Contains(err.Error(), "integer divide by zero") {
+		t.Errorf("Expected integer divide by zero error got %v", err)
+	}
+}
+
+func TestArrays(t *testing.T) {
+	tests := []struct {
+		src    string
+		result string
+	}{
+		{`var a = []int32{1, 2, 3}
+		printInt(int64(a[0] + a[2]))`, "4"},
+		{`var a = [4]int32{1, 2}
+		printInt(int64(len(a) * 10 + a[3]))`, "40"},
+		{`var a = [3]int64{}
+		a[1] = int64(5)
+		a[2] = a[1] * int64(2)
+		printInt(a[2])`, "10"},
+		{`var a = []int32{1, 2, 3, 4}
+		var i = 1
+		a[i]++
+		printInt(int64(a[1]))`, "3"},
+		{`var a = []int32{1, 2, 3, 4}
+		var b = a[1:3]
+		printInt(int64(len(b) * 10 + b[0]))`, "22"},
+		{`var a = []int32{1, 2, 3, 4}
+		printInt(int64(len(a[:]) * 100 + len(a[2:]) * 10 + len(a[:1])))`, "421"},
+		{`var a = []int32{1, 2, 3}
+		var i = int64(2)
+		printInt(int64(a[i]))`, "3"},
+		{`var a = [2][2]int32{[2]int32{1, 2}}
+		a[1][1] = 4
+		printInt(int64(a[0][1] + a[1][1] + a[1][0]))`, "6"},
+		{`var a = []string{"foo", "bar"}
+		print(a[1])`, "bar"},
+	}
+
+	for _, test := range tests {
+		res, err := testCodegen(t, fmt.Sprintf("fn main() {\n%s\n}", test.src))
+		if err != nil {
+			t.Errorf("%s: %s", test.src, err)
+			continue
+		}
+
+		if res != test.result {
+			t.Errorf("Expected %s to produce %s got %s", test.src, test.result, res)
+		}
+	}
+
+	res, err := testCodegen(t, `
+		fn values() => []int32 {
+			return []int32{1, 2, 3}
+		}
+
+		fn main() {
+			values()[1] = 5
+			printInt(int64(values()[1]))
+		}`)
+	if err != nil || res != "2" {
+		t.Errorf("Expected 2 got %s (%v)", res, err)
+	}
+
+	// The operand of ++ and -- is evaluated once
+	res, err = testCodegen(t, `
+		fn main() {
+			var a = []int32{1, 2, 3}
+			var calls = 0
+			var next = fn () => int32 {
+				calls++
+				return 1
+			}
+
+			a[next()]++
+			var b = a[next()]--
+			printInt(int64(calls * 100 + a[1] * 10 + b))
+		}`)
+	if err != nil || res != "223" {
+		t.Errorf("Expected 223 got %s (%v)", res, err)
+	}
+
+	// Arrays are values: assigning, passing and slicing an array copies it
+	res, err = testCodegen(t, `
+		struct Point {
+			var x : int32
+		}
+
+		fn clear(a : []int32) {
+			a[0] = 0
+		}
+
+		fn main() {
+			var a = []int32{1, 2, 3}
+			var b = a
+			var c = a[0:2]
+			b[0] = 4
+			c[1] = 5
+			clear(a)
+			var points = []Point{Point{1}}
+			var moved = points[:]
+			moved[0].x = 6
+			var grid = [][]int32{[]int32{7}}
+			var copied = grid
+			copied[0][0] = 8
+			printInt(int64(a[0] * 100000 + a[1] * 10000 + b[0] * 1000 + c[1] * 100 + points[0].x * 10 + grid[0][0]))
+		}`)
+	if err != nil || res != "124517" {
+		t.Errorf("Expected 124517 got %s (%v)", res, err)
+	}
+
+	// Elements not given in a fixed length array literal are zero structs
+	res, err = testCodegen(t, `
+		struct Point {
+			var x : int32
+			var y = 3
+			var tags : []string
+		}
+
+		fn main() {
+			var a = [2]Point{Point{1, 2, []string{}}}
+			a[1].x++
+			printInt(int64(a[1].x * 100 + a[1].y * 10 + len(a[1].tags)))
+		}`)
+	if err != nil || res != "130" {
+		t.Errorf("Expected 130 got %s (%v)", res, err)
+	}
+
+	// Variables declared without a value hold the zero value of their type
+	res, err = testCodegen(t, `
+		struct Point {
+			var x : int32
+		}
+
+		fn main() {
+			var i : int64
+			var p : Point
+			var a : []int32
+			p.x++
+			printInt(i + int64(p.x + len(a)))
+		}`)
+	if err != nil || res != "1" {
+		t.Errorf("Expected 1 got %s (%v)", res, err)
+	}
+
+	errorTests := []struct {
+		src string
+		err string
+	}{
+		{`var a = []int32{1, 2}
+		var i = 2
+		printInt(int64(a[i]))`, "index out of range [2] with length 2"},
+		{`var a = []int32{1, 2}
+		var i = -1
+		a[i] = 1`, "index out of range [-1] with length 2"},
+		{`var a = []int32{1, 2}
+		var i = 3
+		printInt(int64(len(a[1:i])))`, "slice bounds out of range [1:3] with length 2"},
+	}
+
+	for _, test := range errorTests {
+		_, err := testCodegen(t, fmt.Sprintf("fn main() {\n%s\n}", test.src))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Expected %s to fail with %s got %v", test.src, test.err, err)
+		}
+	}
+}
+
+func TestMaps(t *testing.T) {
+	tests := []struct {
+		src    string
+		result string
+	}{
+		{`var m = map[string]int32{"a": 1, "b": 2}
+		printInt(int64(m["a"] + m["b"] + m["c"]))`, "3"},
+		{`var m = map[string]int32{}
+		m["a"] = 5
+		m["a"]++
+		var old = m["b"]--
+		printInt(int64(m["a"] * 10 + m["b"] + old))`, "59"},
+		{`var m = map[int64]string{int64(1): "one", int64(2): "two"}
+		delete(m, int64(1))
+		print(m[int64(2)] + m[int64(1)] + len(m).toString())`, "two1"},
+		{`var m = map[int32]int32{1: 10, 2: 20, 3: 30}
+		var sum = 0
+		for k, v in m {
+			sum = sum + k * v
+		}
+		printInt(int64(sum))`, "140"},
+		{`var m = map[string]bool{"a": true, "b": false}
+		var keys = ""
+		for k in m {
+			keys = keys + k
+		}
+		print(keys)`, "ab"},
+		{`var arr = []int32{5, 6, 7}
+		var sum = 0
+		for i, v in arr {
+			sum = sum + i * v
+		}
+		for i in arr {
+			sum = sum + i
+		}
+		printInt(int64(sum))`, "23"},
+		{`var m = map[string][]int32{"a": []int32{1, 2}}
+		m["a"][1] = 3
+		printInt(int64(m["a"][1] + len(m["b"])))`, "3"},
+		{`var m = map[uint8]int32{}
+		m[uint8(255)] = 1
+		printInt(int64(m[uint8(255)]))`, "1"},
+	}
+
+	for _, test := range tests {
+		res, err := testCodegen(t, fmt.Sprintf("fn main() {\n%s\n}", test.src))
+		if err != nil {
+			t.Errorf("%s: %s", test.src, err)
+			continue
+		}
+
+		if res != test.result {
+			t.Errorf("Expected %s to produce %s got %s", test.src, test.result, res)
+		}
+	}
+
+	// Missing keys of a map of structs read as zero structs
+	res, err := testCodegen(t, `
+		struct Point {
+			var x : int32
+			var y = 3
+		}
+
+		fn main() {
+			var m = map[string]Point{}
+			m["a"].x = 1
+			printInt(int64(m["a"].x * 10 + m["a"].y))
+		}`)
+	if err != nil || res != "3" {
+		t.Errorf("Expected 3 got %s (%v)", res, err)
+	}
+}
+
+func TestPointers(t *testing.T) {
+	tests := []struct {
+		src    string
+		result string
+	}{
+		{`fn main() {
+			var x = 1
+			var p = &x
+			*p = 5
+			*p++
+			printInt(int64(x))
+		}`, "6"},
+		{`fn inc(p : *int32) {
+			*p = *p + 1
+		}
+		fn main() {
+			var x = 1
+			inc(&x)
+			inc(&x)
+			printInt(int64(x))
+		}`, "3"},
+		{`struct Point {
+			var x : int32
+			var y : int32
+
+			fn sum() => int32 {
+				return this.x + this.y
+			}
+		}
+		fn move(p : *Point, dx : int32) {
+			p.x = p.x + dx
+		}
+		fn moveCopy(p : Point, dx : int32) {
+			p.x = p.x + dx
+		}
+		fn main() {
+			var p = Point{1, 2}
+			move(&p, 10)
+			moveCopy(p, 100)
+			var q = p
+			q.y = 100
+			printInt(int64(p.sum()))
+		}`, "13"},
+		{`struct Point {
+			var x : int32
+		}
+		fn main() {
+			var p = &Point{1}
+			var q = p
+			q.x = 7
+			var a = []int32{1, 2, 3}
+			var e = &a[1]
+			*e = 20
+			var f = &p.x
+			*f = *f + a[1]
+			printInt(int64(p.x))
+		}`, "27"},
+		{`fn main() {
+			var x = 1
+			var y = 2
+			var p = &x
+			var pp = &p
+			**pp = 10
+			*pp = &y
+			*p = *p + 1
+			var n = 0
+			if p == &y {
+				n = 1
+			}
+			printInt(int64(x * 100 + y * 10 + n))
+		}`, "1031"},
+		{`fn main() {
+			var sum = 0
+			var ptrs = []*int32{}
+			for i, v in []int32{1, 2, 3} {
+				ptrs = []*int32{&v}
+				sum = sum + *ptrs[0] + i
+			}
+			printInt(int64(sum))
+		}`, "9"},
+	}
+
+	for _, test := range tests {
+		res, err := testCodegen(t, test.src)
+		if err != nil {
+			t.Errorf("%s: %s", test.src, err)
+			continue
+		}
+
+		if res != test.result {
+			t.Errorf("Expected %s to produce %s got %s", test.src, test.result, res)
+		}
+	}
+}
+
+func TestSourceMap(t *testing.T) {
+	lib, err := parser.Parse(strings.NewReader(`export fn double(x : int32) => int32 {
+	return x * 2
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lib.Filename = "lib.or"
+
+	main, err := parser.Parse(strings.NewReader(`import "lib"
+
+fn main() {
+	var s = "ü😀"
+	var a = double(1)
+	a = a + 1
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	main.Filename = "main.or"
+
+	alrz, err := analyser.NewProgram([]*ast.File{lib, main}, map[*ast.Import]*ast.File{main.Imports[0]: lib})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := alrz.Analyse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codegen := New(info)
+	code := string(codegen.Generate(lib, main))
+
+	data, err := codegen.SourceMap("out.js").JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	smap, err := sourcemap.Parse("", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if smap.File() != "out.js" {
+		t.Errorf("Wrong file %s", smap.File())
+	}
+
+	// Generated column of a fragment in UTF-16 code units
+	column := func(fragment string) int {
+		i := strings.Index(code, fragment)
+		if i == -1 {
+			t.Fatalf("Could not find %s in %s", fragment, code)
+		}
+		return len(utf16.Encode([]rune(code[:i])))
+	}
+
+	tests := []struct {
+		fragment string
+		source   string
+		line     int
+		col      int
+	}{
+		{"return", "lib.or", 2, 1},
+		{"var $", "main.or", 4, 5},
+		// Columns after non ASCII strings
+		{"1)", "main.or", 5, 16},
+		{"+1", "main.or", 6, 7},
+	}
+
+	for _, test := range tests {
+		source, _, line, col, ok := smap.Source(1, column(test.fragment))
+		if !ok {
+			t.Errorf("No mapping for %s", test.fragment)
+			continue
+		}
+
+		if source != test.source || line != test.line || col != test.col {
+			t.Errorf("Expected %s to map to %s:%d:%d got %s:%d:%d", test.fragment, test.source, test.line, test.col, source, line, col)
+		}
+	}
+}
+
+func TestEnums(t *testing.T) {
+	tests := []struct {
+		src    string
+		result string
+	}{
+		{`enum Shape {
+			Circle(int32)
+			Rect(int32, int32)
+			Empty
+		}
+		fn area(s : Shape) => int32 {
+			return match s {
+				Circle(r) => 3 * r * r,
+				Rect(w, h) => w * h,
+				Empty => 0
+			}
+		}
+		fn main() {
+			var shapes = []Shape{Shape.Circle(2), Shape.Rect(3, 4), Shape.Empty}
+			var sum = 0
+			for _, s in shapes {
+				sum = sum + area(s)
+			}
+			printInt(int64(sum))
+		}`, "24"},
+		{`fn main() {
+			var state = State.Idle
+			var log = ""
+			for i in []int32{1, 2, 3} {
+				match state {
+					Idle => {
+						state = State.Running(i)
+					}
+					Running(n) => {
+						log = log + n.toString()
+						state = State.Done((n, "ok"))
+					}
+					_ => {
+						log = log + "!"
+					}
+				}
+			}
+			match state {
+				Done((n, msg)) => {
+					print(log + msg + n.toString())
+					return
+				}
+				_ => {}
+			}
+			print("unreachable")
+		}
+		enum State {
+			Idle
+			Running(int32)
+			Done((int32, string))
+		}`, "0!ok0"},
+		{`struct Point {
+			var x : int32
+		}
+		enum Wrapper {
+			Value(Point)
+		}
+		fn main() {
+			var w = Wrapper.Value(Point{1})
+			var get = fn (w : Wrapper) => int32 {
+				return match w {
+					Value(p) => p.x
+				}
+			}
+			match w {
+				Value(p) => p.x = 5
+			}
+			var x = 10
+			var f = match w {
+				Value(p) => fn () => int32 {
+					x = x + p.x
+					return x
+				}
+			}
+			f()
+			printInt(int64(get(w) * 100 + f()))
+		}`, "112"},
+	}
+
+	for _, test := range tests {
+		res, err := testCodegen(t, test.src)
+		if err != nil {
+			t.Errorf("%s: %s", test.src, err)
+			continue
+		}
+
+		if res != test.result {
+			t.Errorf("Expected %s to produce %s got %s", test.src, test.result, res)
+		}
+	}
+
+	res, err := testCodegen(t, `
+		export enum Option {
+			Some(int32)
+			None
+		}
+	`, `
+		import "0"
+
+		fn main() {
+			var o = Option.Some(41)
+			printInt(int64(match o {
+				Some(v) => v + 1,
+				None => 0
+			}))
+		}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res != "42" {
+		t.Error("Wrong result received", res)
+	}
+}
+
+func TestGenerics(t *testing.T) {
+	tests := []struct {
+		src    string
+		result string
+	}{
+		{`interface Sized {
+			fn size() => int32
+		}
+		struct Point {
+			var x : int32
+			fn size() => int32 {
+				return this.x
+			}
+		}
+		fn identity<T>(v : T) => T {
+			return v
+		}
+		fn first<T>(values : []T) => T {
+			return values[0]
+		}
+		fn total<T : Sized>(values : []T) => int32 {
+			var sum = 0
+			for _, v in values {
+				sum = sum + v.size()
+			}
+			return sum
+		}
+		fn main() {
+			var s = identity("x") + identity(1).toString()
+			print(s + first([]string{"y", "z"}) + total([]Point{Point{1}, Point{2}}).toString())
+		}`, "x1y3"},
+		{`struct Box<T> {
+			var value : T
+			fn get() => T {
+				return this.value
+			}
+		}
+		struct Pair<A, B> {
+			var first : A
+			var second : B
+		}
+		fn wrap<T>(v : T) => Box<T> {
+			return Box{value: v}
+		}
+		fn swap<A, B>(p : Pair<A, B>) => Pair<B, A> {
+			return Pair<B, A>{p.second, p.first}
+		}
+		fn main() {
+			var b = wrap(wrap(2))
+			var p = swap(Pair{"a", 1})
+			print(b.get().get().toString() + p.first.toString() + p.second)
+		}`, "21a"},
+		{`struct Node<T> {
+			var value : T
+			var children : []Node<T>
+		}
+		fn count<T>(n : *Node<T>) => int32 {
+			var sum = 1
+			for i in n.children {
+				sum = sum + count(&n.children[i])
+			}
+			return sum
+		}
+		fn main() {
+			var leaf = Node<string>{"c", []Node<string>{}}
+			var tree = Node{value: "a", children: []Node<string>{leaf, Node{"b", []Node<string>{leaf}}}}
+			print(count(&tree).toString() + tree.children[1].children[0].value)
+		}`, "4c"},
+	}
+
+	for _, test := range tests {
+		res, err := testCodegen(t, test.src)
+		if err != nil {
+			t.Errorf("%s: %s", test.src, err)
+			continue
+		}
+
+		if res != test.result {
+			t.Errorf("Expected %s to produce %s got %s", test.src, test.result, res)
+		}
+	}
+
+	res, err := testCodegen(t, `
+		export struct Stack<T> {
+			var items : map[int32]T
+			var size : int32
+			fn push(v : T) {
+				this.items[this.size] = v
+				this.size++
+			}
+			fn top() => T {
+				return this.items[this.size - 1]
+			}
+		}
+
+		export fn max<T>(a : T, b : T, less : (T, T) => bool) => T {
+			if less(a, b) {
+				return b
+			}
+			return a
+		}
+	`, `
+		import "0"
+
+		fn main() {
+			var s = Stack<int32>{map[int32]int32{}, 0}
+			s.push(1)
+			s.push(max(2, 3, fn (a : int32, b : int32) => bool { return a < b }))
+			printInt(int64(s.top()))
+		}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res != "3" {
+		t.Error("Wrong result received", res)
+	}
+}
+
+func TestTypeAssertions(t *testing.T) {
+	shapes := `
+		interface Shape {
+			fn area() => int32
+		}
+		interface Named {
+			fn name() => string
+		}
+		struct Square {
+			var side : int32
+			fn area() => int32 {
+				return this.side * this.side
+			}
+			fn name() => string {
+				return "square"
+			}
+		}
+		struct Rect {
+			var w : int32
+			var h : int32
+			fn area() => int32 {
+				return this.w * this.h
+			}
+		}
+	`
+
+	tests := []struct {
+		src    string
+		result string
+	}{
+		{`fn main() {
+			var s : Shape = Square{2}
+			var sq = s.(Square)
+			sq.side = 5
+			print(sq.area().toString() + s.area().toString() + s.(Named).name())
+		}`, "254square"},
+		{`fn main() {
+			var s : Shape = Rect{2, 3}
+			var (sq, isSquare) = s.(Square)
+			var (r, isRect) = s.(Rect)
+			var (_, isNamed) = s.(Named)
+			r.w = 1
+			print(isSquare.toString() + isRect.toString() + isNamed.toString() + r.area().toString() + s.area().toString())
+		}`, "falsetruefalse36"},
+		{`fn main() {
+			var s : Shape = Rect{2, 3}
+			var (sq, ok) = s.(Square)
+			print(ok.toString() + sq.side.toString() + sq.area().toString())
+		}`, "false00"},
+		{`fn describe(s : Shape) => string {
+			switch var v = s.(type) {
+			case Square {
+				v.side = 1
+				return "square " + v.side.toString()
+			}
+			case Named, Rect {
+				return "area " + v.area().toString()
+			}
+			}
+			return "unknown"
+		}
+		fn main() {
+			var s : Shape = Square{3}
+			print(describe(s) + ", " + describe(Rect{2, 2}) + ", " + s.area().toString())
+		}`, "square 1, area 4, 9"},
+		{`fn main() {
+			var values = []anything{Square{1}, Rect{1, 2}, "a"}
+			var log = ""
+			for _, value in values {
+				switch value.(type) {
+				case Shape {
+					log = log + "s"
+				}
+				default {
+					log = log + "-"
+				}
+				}
+			}
+			print(log)
+		}`, "ss-"},
+	}
+
+	for _, test := range tests {
+		res, err := testCodegen(t, shapes+test.src)
+		if err != nil {
+			t.Errorf("%s: %s", test.src, err)
+			continue
+		}
+
+		if res != test.result {
+			t.Errorf("Expected %s to produce %s got %s", test.src, test.result, res)
+		}
+	}
+
+	_, err := testCodegen(t, shapes+`fn main() {
+		var s : Shape = Square{2}
+		s.(Rect)
+	}`)
+	if err == nil || !strings.Contains(err.Error(), "interface conversion: interface is Square, not Rect") {
+		t.Errorf("Expected failed assertion to fail with interface conversion error got %v", err)
+	}
+}
+
+func TestInterfaceEmbedding(t *testing.T) {
+	res, err := testCodegen(t, `
+		interface Describer {
+			Shape
+			Named
+			fn describe() => string
+		}
+		interface Shape {
+			fn area() => int32
+		}
+		interface Named {
+			fn name() => string
+		}
+		struct Square {
+			var side : int32
+			fn area() => int32 {
+				return this.side * this.side
+			}
+			fn name() => string {
+				return "square"
+			}
+			fn describe() => string {
+				return this.name() + " " + this.area().toString()
+			}
+		}
+		fn main() {
+			var d : Describer = Square{3}
+			var s : Shape = d
+			var name = d.name
+			print(d.describe() + ", " + name() + ", " + s.area().toString() + ", " + d.(Named).name())
+		}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res != "square 9, square, 9, square" {
+		t.Errorf("Wrong result %s", res)
+	}
+}
+
+func TestMacroHygiene(t *testing.T) {
+	res, err := testCodegen(t, `
+		macro double {
+			($x:expr) : (
+				var tmp = $x
+				tmp = tmp + tmp
+			)
+		}
+		macro squareOf {
+			($x:expr) : (
+				(fn (tmp : int32) => int32 {
+					return tmp * $x
+				})($x)
+			)
+		}
+		fn main() {
+			var tmp = 3
+			double!(tmp)
+			double!(tmp + 1)
+			print(tmp.toString() + " " + squareOf!(tmp + 1).toString())
+		}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res != "3 16" {
+		t.Errorf("Wrong result %s", res)
+	}
+}
+
+func TestMacroFragments(t *testing.T) {
+	res, err := testCodegen(t, `
+		macro getter {
+			($name:ident, $t:type, $v:literal) : (
+				fn $name() => $t {
+					return $v
+				}
+			)
+		}
+		macro record {
+			($name:ident { $( $field:ident : $t:type ),* }) : (
+				struct $name {
+					$(
+						var $field : $t
+					)*
+				}
+			)
+		}
+		macro bind {
+			($p:pattern = $e:expr) : (var $p = $e)
+		}
+		macro tokens {
+			($( $x:tt )*) : ($( $x )*)
+		}
+		getter!(answer, int32, 40)
+		record!(Point { x: int32, y: int32 })
+		fn main() {
+			bind!((a, b) = (1, 2))
+			var p = Point{x: answer(), y: a}
+			tokens!(p.x = p.x + (b * b) / b)
+			print(p.x.toString())
+		}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res != "42" {
+		t.Errorf("Wrong result %s", res)
+	}
 }
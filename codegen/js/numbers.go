@@ -0,0 +1,240 @@
+package js
+
+import (
+	"fmt"
+
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/scanner"
+	"github.com/orktes/orlang/types"
+)
+
+// Integers up to 32 bits are represented as JS numbers and 64 bit integers as
+// BigInts. Results of arithmetic are wrapped to the width of the type so that
+// overflow behaves like in the native targets
+
+var integerTypes = map[string]struct {
+	bits   int
+	signed bool
+}{
+	"int8":   {8, true},
+	"int16":  {16, true},
+	"int32":  {32, true},
+	"int64":  {64, true},
+	"uint8":  {8, false},
+	"uint16": {16, false},
+	"uint32": {32, false},
+	"uint64": {64, false},
+}
+
+// helpers are runtime functions emitted at the end of the program when used
+var helpers = map[string]string{
+	"div":       `function $div(a, b) { if (b === 0) { throw new Error("integer divide by zero"); } return a / b; }`,
+	"idx":       `function $idx(a, i) { if (i < 0 || i >= a.length) { throw new Error("index out of range [" + i + "] with length " + a.length); } return Number(i); }`,
+	"get":       `function $get(a, i) { return a[$idx(a, i)]; }`,
+	"set":       `function $set(a, i, v) { return a[$idx(a, i)] = v; }`,
+	"slice":     `function $slice(a, l, h, c) { l = l === undefined ? 0 : Number(l); h = h === undefined ? a.length : Number(h); if (l < 0 || h > a.length || l > h) { throw new Error("slice bounds out of range [" + l + ":" + h + "] with length " + a.length); } var s = a.slice(l, h); return c ? s.map(c) : s; }`,
+	"mapget":    `function $mapget(m, k, z) { return m.has(k) ? m.get(k) : z; }`,
+	"mapset":    `function $mapset(m, k, v) { m.set(k, v); return v; }`,
+	"mapupdate": `function $mapupdate(m, k, z, f, post) { var old = m.has(k) ? m.get(k) : z; var v = f(old); m.set(k, v); return post ? old : v; }`,
+	"update":    `function $update(r, f, post) { var old = r.v; var v = f(old); r.v = v; return post ? old : v; }`,
+	"ref":       `function $ref(o, k) { var cells = $ref.cells || ($ref.cells = new WeakMap()); var refs = cells.get(o); if (!refs) { refs = new Map(); cells.set(o, refs); } var r = refs.get(k); if (!r) { r = { get v() { return o[k]; }, set v(x) { o[k] = x; } }; refs.set(k, r); } return r; }`,
+	"elemref":   `function $elemref(a, i) { return $ref(a, $idx(a, i)); }`,
+	"copy":      `function $copy(s) { return s === null || s === undefined ? s : s.$copy(); }`,
+	"copyarray": `function $copyarray(a, c) { return a === null || a === undefined ? a : c ? a.map(c) : a.slice(); }`,
+	"is":        `function $is(v, t) { return v !== null && v !== undefined && (t === null || t.some(function (c) { return v instanceof c; })); }`,
+	"assert":    `function $assert(v, t, name) { if (!$is(v, t)) { throw new Error("interface conversion: interface is " + (v === null || v === undefined ? "empty" : v.$type || typeof v) + ", not " + name); } return v; }`,
+	"assertok":  `function $assertok(v, t, z, c) { return $is(v, t) ? [c ? v.$copy() : v, true] : [z, false]; }`,
+}
+
+// integerType returns the width and signedness of an integer type
+func integerType(typ types.Type) (bits int, signed bool, ok bool) {
+	primitive, isPrimitive := types.LazyResolve(typ).(types.PrimitiveType)
+	if !isPrimitive {
+		return
+	}
+
+	info, ok := integerTypes[primitive.Type]
+	return info.bits, info.signed, ok
+}
+
+func isFloat32(typ types.Type) bool {
+	return types.LazyResolve(typ) == types.Float32Type
+}
+
+func isFloat(typ types.Type) bool {
+	typ = types.LazyResolve(typ)
+	return typ == types.Float32Type || typ == types.Float64Type
+}
+
+// wrap returns the code written around an expression to wrap its value to typ
+func wrap(typ types.Type) (prefix string, suffix string) {
+	bits, signed, ok := integerType(typ)
+	if !ok {
+		if isFloat32(typ) {
+			return "Math.fround(", ")"
+		}
+		return "", ""
+	}
+
+	switch {
+	case bits == 64 && signed:
+		return "BigInt.asIntN(64,", ")"
+	case bits == 64:
+		return "BigInt.asUintN(64,", ")"
+	case bits == 32 && signed:
+		return "((", ")|0)"
+	case bits == 32:
+		return "((", ")>>>0)"
+	case signed:
+		shift := 32 - bits
+		return "((", fmt.Sprintf(")<<%d>>%d)", shift, shift)
+	default:
+		return "((", fmt.Sprintf(")&%d)", (1<<uint(bits))-1)
+	}
+}
+
+// convert returns the code written around an expression to convert it from
+// type from to type to. Ok is false if either of the types is not numeric
+func convert(from types.Type, to types.Type) (prefix string, suffix string, ok bool) {
+	fromBits, _, fromInt := integerType(from)
+	toBits, toSigned, toInt := integerType(to)
+	if (!fromInt && !isFloat(from)) || (!toInt && !isFloat(to)) {
+		return "", "", false
+	}
+
+	switch {
+	case !toInt:
+		// To float
+		if fromBits == 64 {
+			prefix, suffix = "Number(", ")"
+		}
+		if isFloat32(to) {
+			prefix, suffix = "Math.fround("+prefix, suffix+")"
+		}
+	case toBits == 64:
+		wrapPrefix, wrapSuffix := wrap(to)
+		switch {
+		case fromBits == 64:
+			prefix, suffix = wrapPrefix, wrapSuffix
+		case fromInt:
+			prefix, suffix = wrapPrefix+"BigInt(", ")"+wrapSuffix
+		default:
+			prefix, suffix = wrapPrefix+"BigInt(Math.trunc(", "))"+wrapSuffix
+		}
+	case fromBits == 64:
+		fn := "asUintN"
+		if toSigned {
+			fn = "asIntN"
+		}
+		prefix, suffix = fmt.Sprintf("Number(BigInt.%s(%d,", fn, toBits), "))"
+	default:
+		// Wrapping also truncates floats towards zero
+		prefix, suffix = wrap(to)
+	}
+
+	return prefix, suffix, true
+}
+
+// literalSuffix returns the suffix of an integer literal of type typ
+func literalSuffix(typ types.Type) string {
+	if bits, _, ok := integerType(typ); ok && bits == 64 {
+		return "n"
+	}
+	return ""
+}
+
+// one returns the literal 1 of type typ
+func one(typ types.Type) string {
+	return "1" + literalSuffix(typ)
+}
+
+func (jscg *JSCodeGen) useHelper(name string) string {
+	if !jscg.helpers[name] {
+		jscg.helpers[name] = true
+		jscg.helperOrder = append(jscg.helperOrder, name)
+	}
+	return "$" + name
+}
+
+// writeArithmetic writes a binary expression of numeric or string type
+func (jscg *JSCodeGen) writeArithmetic(n *ast.BinaryExpression, typ types.Type) {
+	start, end := ast.StartPositionFromToken(n.Operator), ast.EndPositionFromToken(n.Operator)
+	prefix, suffix := wrap(typ)
+	bits, _, isInt := integerType(typ)
+	// 64 bit integers are BigInts which need no special handling
+	small := isInt && bits < 64
+
+	jscg.writeWithNodePosition(n, prefix)
+
+	switch {
+	case small && n.Operator.Type == scanner.TokenTypeASTERISK:
+		jscg.writeWithPosition(start, end, "Math.imul(")
+		ast.Walk(jscg, n.Left)
+		jscg.write(",")
+		ast.Walk(jscg, n.Right)
+		jscg.write(")")
+	case small && n.Operator.Type == scanner.TokenTypeSLASH:
+		jscg.writeWithPosition(start, end, jscg.useHelper("div")+"(")
+		ast.Walk(jscg, n.Left)
+		jscg.write(",")
+		ast.Walk(jscg, n.Right)
+		jscg.write(")")
+	default:
+		ast.Walk(jscg, n.Left)
+		jscg.writeWithPosition(start, end, n.Operator.Text)
+		ast.Walk(jscg, n.Right)
+	}
+
+	jscg.write(suffix)
+}
+
+// writeIncrement writes ++ and -- for wrapped numeric types as an assignment.
+// Postfix expressions evaluate to the value before the update. Operands with
+// side effects are updated through a reference so that they are evaluated
+// only once
+func (jscg *JSCodeGen) writeIncrement(n *ast.UnaryExpression, typ types.Type) bool {
+	prefix, suffix := wrap(typ)
+	if prefix == "" {
+		return false
+	}
+
+	op, inverse := "+", "-"
+	if n.Operator.Type == scanner.TokenTypeDecrement {
+		op, inverse = "-", "+"
+	}
+
+	if !isPure(n.Expression) {
+		jscg.writeWithPosition(ast.StartPositionFromToken(n.Operator), ast.EndPositionFromToken(n.Operator), jscg.useHelper("update")+"(")
+		jscg.writeAddressOf(n)
+		jscg.write(fmt.Sprintf(",function (x) { return %sx%s%s%s; },%t)", prefix, op, one(typ), suffix, n.Postfix))
+		return true
+	}
+
+	jscg.writeWithPosition(ast.StartPositionFromToken(n.Operator), ast.EndPositionFromToken(n.Operator), "(")
+	ast.Walk(jscg, n.Expression)
+	jscg.write("=" + prefix)
+	ast.Walk(jscg, n.Expression)
+	jscg.write(op + one(typ) + suffix)
+
+	if n.Postfix {
+		jscg.write("," + prefix)
+		ast.Walk(jscg, n.Expression)
+		jscg.write(inverse + one(typ) + suffix)
+	}
+
+	jscg.write(")")
+	return true
+}
+
+// writeNegation writes a wrapping integer negation
+func (jscg *JSCodeGen) writeNegation(n *ast.UnaryExpression, typ types.Type) bool {
+	if _, _, ok := integerType(typ); !ok {
+		return false
+	}
+
+	prefix, suffix := wrap(typ)
+	jscg.writeWithPosition(ast.StartPositionFromToken(n.Operator), ast.EndPositionFromToken(n.Operator), prefix+"-")
+	ast.Walk(jscg, n.Expression)
+	jscg.write(suffix)
+	return true
+}
@@ -0,0 +1,268 @@
+package js
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/scanner"
+	"github.com/orktes/orlang/types"
+)
+
+// Pointers are boxed cells: objects whose property v holds the value they
+// point to. Variables whose address is taken live in a cell for their whole
+// lifetime so that &x always evaluates to the same cell. Pointers to fields
+// and array elements are cached cells with an accessor property. Structs and
+// arrays are copied whenever a stored struct or array is stored again so that
+// values are only shared through pointers
+
+// isBoxed returns true if the variable ident refers to is stored in a cell
+func (jscg *JSCodeGen) isBoxed(ident *ast.Identifier) bool {
+	nodeInfo := jscg.getNodeInfo(ident)
+	if nodeInfo == nil || nodeInfo.Scope == nil {
+		return false
+	}
+
+	details := nodeInfo.Scope.LookupDetails(ident, true)
+	if details == nil {
+		return false
+	}
+
+	// Variables can be declared in another file than where their address is taken
+	for _, fileInfo := range jscg.analyserInfo.FileInfo {
+		if info, ok := fileInfo.NodeInfo[details.DefineIdentifier]; ok && info.AddressTaken {
+			return true
+		}
+	}
+
+	return false
+}
+
+// variable returns the expression used to read and write the variable ident
+// refers to
+func (jscg *JSCodeGen) variable(ident *ast.Identifier) string {
+	name := jscg.getIdentifier(ident)
+	if jscg.isBoxed(ident) {
+		return name + ".v"
+	}
+	return name
+}
+
+// box returns value wrapped in a cell if ident is stored in one
+func (jscg *JSCodeGen) box(ident *ast.Identifier, value string) string {
+	if jscg.isBoxed(ident) {
+		return "{v:" + value + "}"
+	}
+	return value
+}
+
+// isPointer returns true if expr evaluates to a pointer
+func (jscg *JSCodeGen) isPointer(expr ast.Expression) bool {
+	_, ok := types.LazyResolve(jscg.getNodeInfo(expr).Type).(*types.PointerType)
+	return ok
+}
+
+func (jscg *JSCodeGen) writeDereference(n *ast.UnaryExpression) {
+	jscg.writeWithPosition(ast.StartPositionFromToken(n.Operator), ast.EndPositionFromToken(n.Operator), "(")
+	ast.Walk(jscg, n.Expression)
+	jscg.write(").v")
+}
+
+func (jscg *JSCodeGen) writeAddressOf(n *ast.UnaryExpression) {
+	start, end := ast.StartPositionFromToken(n.Operator), ast.EndPositionFromToken(n.Operator)
+
+	expr := n.Expression
+	for {
+		paren, ok := expr.(*ast.ParenExpression)
+		if !ok {
+			break
+		}
+		expr = paren.Expression
+	}
+
+	switch operand := expr.(type) {
+	case *ast.Identifier:
+		jscg.writeWithNodePosition(operand, jscg.getIdentifier(operand))
+	case *ast.MemberExpression:
+		jscg.writeWithPosition(start, end, jscg.useHelper("ref")+"(")
+		ast.Walk(jscg, operand.Target)
+		if jscg.isPointer(operand.Target) {
+			jscg.write(".v")
+		}
+		jscg.write(fmt.Sprintf(",%q)", operand.Property.Text))
+	case *ast.IndexExpression:
+		jscg.useHelper("ref")
+		jscg.useHelper("idx")
+		jscg.writeWithPosition(start, end, jscg.useHelper("elemref")+"(")
+		ast.Walk(jscg, operand.Target)
+		jscg.write(",")
+		ast.Walk(jscg, operand.Index)
+		jscg.write(")")
+	case *ast.UnaryExpression:
+		// &*p is p
+		ast.Walk(jscg, operand.Expression)
+	default:
+		// &Foo{} stores a new struct in a cell of its own
+		jscg.writeWithPosition(start, end, "{v:")
+		ast.Walk(jscg, operand)
+		jscg.write("}")
+	}
+}
+
+// isCopied returns true if values of typ are copied when they are stored
+func isCopied(typ types.Type) bool {
+	switch types.LazyResolve(typ).(type) {
+	case *types.StructType, *types.ArrayType:
+		return true
+	}
+	return false
+}
+
+// copier returns the code written around a value of typ to copy it. Array
+// elements are copied as well
+func (jscg *JSCodeGen) copier(typ types.Type) (prefix string, suffix string) {
+	switch t := types.LazyResolve(typ).(type) {
+	case *types.StructType:
+		return jscg.useHelper("copy") + "(", ")"
+	case *types.ArrayType:
+		return jscg.useHelper("copyarray") + "(", "," + jscg.elementCopier(t) + ")"
+	}
+	return "", ""
+}
+
+// elementCopier returns a function copying the elements of an array or null
+// if the elements are not copied
+func (jscg *JSCodeGen) elementCopier(arrType *types.ArrayType) string {
+	if !isCopied(arrType.Type) {
+		return "null"
+	}
+	prefix, suffix := jscg.copier(arrType.Type)
+	return "function (e) { return " + prefix + "e" + suffix + "; }"
+}
+
+// copyValue returns value copied if values of typ are copied
+func (jscg *JSCodeGen) copyValue(typ types.Type, value string) string {
+	prefix, suffix := jscg.copier(typ)
+	return prefix + value + suffix
+}
+
+// isStoredValue returns true if expr evaluates to a struct or an array that
+// is stored in a variable, a field or an element
+func (jscg *JSCodeGen) isStoredValue(expr ast.Expression) bool {
+	if !isCopied(jscg.getNodeInfo(expr).Type) {
+		return false
+	}
+
+	switch n := expr.(type) {
+	case *ast.Identifier, *ast.MemberExpression, *ast.IndexExpression:
+		return true
+	case *ast.TypeAssertion:
+		// The struct is the one held by the interface value
+		return true
+	case *ast.UnaryExpression:
+		return n.Operator.Type == scanner.TokenTypeASTERISK
+	case *ast.ParenExpression:
+		return jscg.isStoredValue(n.Expression)
+	}
+	return false
+}
+
+// writeValue writes an expression whose value is stored in a location of
+// type to or passed to a function. Stored structs and arrays are copied
+// unless they are converted to an interface which refers to the original
+// value
+func (jscg *JSCodeGen) writeValue(expr ast.Expression, to types.Type) {
+	if expr == nil || !jscg.isStoredValue(expr) || (to != nil && !isCopied(to)) {
+		ast.Walk(jscg, expr)
+		return
+	}
+
+	prefix, suffix := jscg.copier(jscg.getNodeInfo(expr).Type)
+	jscg.write(prefix)
+	ast.Walk(jscg, expr)
+	jscg.write(suffix)
+}
+
+// argumentType returns the type of the parameter or the field a call
+// argument is assigned to
+func (jscg *JSCodeGen) argumentType(arg *ast.CallArgument) types.Type {
+	var (
+		names     []string
+		typs      []types.Type
+		arguments []*ast.CallArgument
+	)
+
+	switch parent := jscg.getParent(arg).(type) {
+	case *ast.FunctionCall:
+		sig, ok := jscg.getNodeInfo(parent.Callee).Type.(*types.SignatureType)
+		if !ok {
+			return nil
+		}
+		names, typs, arguments = sig.ArgumentNames, sig.ArgumentTypes, parent.Arguments
+	case *ast.StructExpression:
+		structType, ok := types.LazyResolve(jscg.getNodeInfo(parent).Type).(*types.StructType)
+		if !ok {
+			return nil
+		}
+		for _, field := range structType.Variables {
+			names = append(names, field.Name)
+			typs = append(typs, field.Type)
+		}
+		arguments = parent.Arguments
+	default:
+		return nil
+	}
+
+	for i, a := range arguments {
+		if a != arg {
+			continue
+		}
+		if arg.Name != nil {
+			i = indexOf(names, arg.Name.Text)
+		}
+		if i >= 0 && i < len(typs) {
+			return typs[i]
+		}
+	}
+
+	return nil
+}
+
+// returnType returns the return type of the function a return statement
+// belongs to
+func (jscg *JSCodeGen) returnType(n *ast.ReturnStatement) types.Type {
+	for info := jscg.getNodeInfo(n).Parent; info != nil; info = info.Parent {
+		if _, ok := info.Node.(*ast.FunctionDeclaration); ok {
+			if sig, ok := info.Type.(*types.SignatureType); ok {
+				return sig.ReturnType
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// writeStructCopy writes the method used by $copy to copy a struct. Nested
+// structs and arrays are copied as well
+func (jscg *JSCodeGen) writeStructCopy(name string, structType *types.StructType) {
+	fields := make([]string, len(structType.Variables))
+	for i, field := range structType.Variables {
+		fields[i] = jscg.copyValue(field.Type, "this."+field.Name)
+	}
+
+	jscg.write(fmt.Sprintf(
+		"%s.prototype.$copy = function () { return new %s(%s); }",
+		name,
+		name,
+		strings.Join(fields, ","),
+	))
+}
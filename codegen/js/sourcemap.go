@@ -0,0 +1,143 @@
+package js
+
+import (
+	"bytes"
+	"encoding/json"
+	"unicode/utf16"
+
+	"github.com/orktes/orlang/ast"
+)
+
+const base64Chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// SourceMap is a version 3 source map
+type SourceMap struct {
+	Version        int      `json:"version"`
+	File           string   `json:"file,omitempty"`
+	Sources        []string `json:"sources"`
+	SourcesContent []string `json:"sourcesContent,omitempty"`
+	Names          []string `json:"names"`
+	Mappings       string   `json:"mappings"`
+}
+
+// JSON returns the source map encoded as JSON
+func (sm *SourceMap) JSON() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(sm); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// mapping maps a position in the generated code to a position in a source file.
+// Lines and columns start from zero
+type mapping struct {
+	line       int
+	column     int
+	source     int
+	sourceLine int
+	sourceCol  int
+}
+
+// mapPosition records that the next write comes from pos in the current file
+func (jscg *JSCodeGen) mapPosition(pos ast.Position) {
+	source, ok := jscg.sources[jscg.currentFile]
+	if !ok {
+		return
+	}
+
+	m := mapping{
+		line:       jscg.line,
+		column:     jscg.column,
+		source:     source,
+		sourceLine: pos.Line,
+		sourceCol:  pos.Column,
+	}
+
+	// Only the first fragment written at a position is mapped
+	if n := len(jscg.mappings); n > 0 && jscg.mappings[n-1].line == m.line && jscg.mappings[n-1].column == m.column {
+		return
+	}
+
+	jscg.mappings = append(jscg.mappings, m)
+}
+
+// advance moves the generated position past str. Columns are counted in
+// UTF-16 code units as required by the source map format
+func (jscg *JSCodeGen) advance(str string) {
+	for _, r := range str {
+		if r == '\n' {
+			jscg.line++
+			jscg.column = 0
+			continue
+		}
+
+		if n := utf16.RuneLen(r); n > 0 {
+			jscg.column += n
+		} else {
+			jscg.column++
+		}
+	}
+}
+
+// SourceMap returns a source map for the code returned by Generate. File is
+// the name of the generated file. Sources are the file names of the generated
+// files in the order they were given to Generate
+func (jscg *JSCodeGen) SourceMap(file string) *SourceMap {
+	sm := &SourceMap{
+		Version: 3,
+		File:    file,
+		Sources: make([]string, len(jscg.files)),
+		Names:   []string{},
+	}
+
+	for i, f := range jscg.files {
+		sm.Sources[i] = f.Filename
+	}
+
+	var buf bytes.Buffer
+	var prev mapping
+	line := 0
+	for i, m := range jscg.mappings {
+		for ; line < m.line; line++ {
+			buf.WriteByte(';')
+			prev.column = 0
+		}
+
+		if i > 0 && buf.Len() > 0 && buf.Bytes()[buf.Len()-1] != ';' {
+			buf.WriteByte(',')
+		}
+
+		writeVLQ(&buf, m.column-prev.column)
+		writeVLQ(&buf, m.source-prev.source)
+		writeVLQ(&buf, m.sourceLine-prev.sourceLine)
+		writeVLQ(&buf, m.sourceCol-prev.sourceCol)
+
+		prev = m
+	}
+	sm.Mappings = buf.String()
+
+	return sm
+}
+
+// writeVLQ writes a base64 VLQ encoded value
+func writeVLQ(buf *bytes.Buffer, value int) {
+	vlq := value << 1
+	if value < 0 {
+		vlq = (-value << 1) | 1
+	}
+
+	for {
+		digit := vlq & 31
+		vlq >>= 5
+		if vlq > 0 {
+			digit |= 32
+		}
+		buf.WriteByte(base64Chars[digit])
+		if vlq == 0 {
+			break
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package js
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/types"
+)
+
+// Interface values are the structs they hold. Struct prototypes carry a
+// runtime type tag, the name of the struct in $type, used in the messages of
+// failed assertions. Values are matched against the constructors of the
+// structs an asserted type stands for: the struct itself or every struct of
+// the program implementing an asserted interface
+
+func (jscg *JSCodeGen) writeTypeTag(name string, n *ast.Struct) {
+	jscg.write(fmt.Sprintf("%s.prototype.$type = %q", name, n.Name.Text))
+}
+
+// constructors returns a JavaScript array of the constructors of the structs
+// matching typ or null if every value matches
+func (jscg *JSCodeGen) constructors(typ types.Type) string {
+	typ = types.LazyResolve(typ)
+	if interfaceType, ok := typ.(*types.InterfaceType); ok && len(interfaceType.Functions) == 0 {
+		return "null"
+	}
+
+	names := []string{}
+	jscg.eachStruct(func(structDecl *ast.Struct, structType types.Type) {
+		if structType == typ || (isInterface(typ) && typ.IsEqual(structType)) {
+			names = append(names, jscg.getIdentifierForNode(structDecl, structDecl.Name.Text))
+		}
+	})
+
+	return "[" + strings.Join(names, ",") + "]"
+}
+
+// eachStruct calls fn with every struct declaration of the program and
+// instance of a generic struct together with its type
+func (jscg *JSCodeGen) eachStruct(fn func(structDecl *ast.Struct, structType types.Type)) {
+	for _, file := range jscg.files {
+		fileInfo := jscg.analyserInfo.FileInfo[file]
+		for _, node := range append(append([]ast.Node{}, file.Body...), fileInfo.Instances...) {
+			structDecl, ok := node.(*ast.Struct)
+			if !ok || structDecl.Name == nil || len(structDecl.TypeParameters) > 0 {
+				continue
+			}
+
+			fn(structDecl, types.LazyResolve(fileInfo.NodeInfo[structDecl].Type))
+		}
+	}
+}
+
+// structDecl returns the declaration of a struct type
+func (jscg *JSCodeGen) structDecl(typ *types.StructType) (decl *ast.Struct) {
+	jscg.eachStruct(func(structDecl *ast.Struct, structType types.Type) {
+		if structType == typ {
+			decl = structDecl
+		}
+	})
+	return
+}
+
+func isInterface(typ types.Type) bool {
+	_, ok := types.LazyResolve(typ).(*types.InterfaceType)
+	return ok
+}
+
+func isStruct(typ types.Type) bool {
+	_, ok := types.LazyResolve(typ).(*types.StructType)
+	return ok
+}
+
+func (jscg *JSCodeGen) writeTypeAssertion(n *ast.TypeAssertion) {
+	assertedType := jscg.getNodeInfo(n.Type).Type
+	jscg.useHelper("is")
+
+	if n.Ok {
+		jscg.writeWithNodePosition(n, jscg.useHelper("assertok")+"(")
+		ast.Walk(jscg, n.Expression)
+		jscg.write(fmt.Sprintf(
+			",%s,%s,%t)",
+			jscg.constructors(assertedType),
+			jscg.zeroValue(assertedType),
+			isStruct(assertedType),
+		))
+		return
+	}
+
+	jscg.writeWithNodePosition(n, jscg.useHelper("assert")+"(")
+	ast.Walk(jscg, n.Expression)
+	jscg.write(fmt.Sprintf(",%s,%q)", jscg.constructors(assertedType), n.Type))
+}
+
+// writeTypeSwitch writes a type switch as a chain of if statements checking
+// the cases in order with the default case last
+func (jscg *JSCodeGen) writeTypeSwitch(n *ast.TypeSwitch) {
+	subject := jscg.getIdentifierForNode(n, "switch")
+	is := jscg.useHelper("is")
+
+	jscg.writeWithNodePosition(n, fmt.Sprintf("{var %s = ", subject))
+	ast.Walk(jscg, n.Subject)
+	jscg.write(";")
+
+	var defaultCase *ast.TypeSwitchCase
+	first := true
+	for _, c := range n.Cases {
+		if c.Types == nil {
+			defaultCase = c
+			continue
+		}
+
+		if !first {
+			jscg.write(" else ")
+		}
+		first = false
+
+		conditions := make([]string, len(c.Types))
+		for i, typ := range c.Types {
+			conditions[i] = fmt.Sprintf("%s(%s,%s)", is, subject, jscg.constructors(jscg.getNodeInfo(typ).Type))
+		}
+
+		jscg.writeWithNodePosition(c, fmt.Sprintf("if (%s) ", strings.Join(conditions, " || ")))
+		jscg.writeTypeSwitchCase(n, c, subject)
+	}
+
+	if defaultCase != nil {
+		if !first {
+			jscg.write(" else ")
+		}
+		jscg.writeTypeSwitchCase(n, defaultCase, subject)
+	}
+
+	jscg.write("}")
+}
+
+// writeTypeSwitchCase writes the block of a case declaring the binding of the
+// switch. Structs are copied so that the switched value is not changed
+// through the binding
+func (jscg *JSCodeGen) writeTypeSwitchCase(n *ast.TypeSwitch, c *ast.TypeSwitchCase, subject string) {
+	jscg.writeWithNodePosition(c, "{")
+
+	if n.Binding != nil {
+		value := subject
+		if len(c.Types) == 1 {
+			value = jscg.copyValue(jscg.getNodeInfo(c.Types[0]).Type, value)
+		}
+
+		jscg.writeWithNodePosition(n.Binding, fmt.Sprintf(
+			"var %s = %s;",
+			jscg.getIdentifierForNode(n.Binding, n.Binding.Text),
+			jscg.box(n.Binding, value),
+		))
+	}
+
+	for _, node := range c.Block.Body {
+		ast.Walk(jscg, node)
+		jscg.write(";")
+	}
+
+	jscg.write("}")
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/builtin"
 	"github.com/orktes/orlang/linter"
 	"github.com/orktes/orlang/scanner"
 
@@ -16,29 +17,34 @@ import (
 	"github.com/orktes/orlang/types"
 )
 
-func configureAnalyzer(analsr *analyser.Analyser) {
-	analsr.AddExternalFunc("print", &types.SignatureType{
-		ArgumentNames: []string{"str"},
-		ArgumentTypes: []types.Type{&types.InterfaceType{
-			Name: "buildin(stringer)",
-			Functions: []struct {
-				Name string
-				Type *types.SignatureType
-			}{
-				{
-					Name: "toString",
-					Type: &types.SignatureType{
-						ArgumentNames: []string{},
-						ArgumentTypes: []types.Type{},
-						ReturnType:    types.StringType,
-						Extern:        true,
-					},
+// stringerPrint is the print of the playground. It accepts any value
+// implementing toString and writes it without a line break
+var stringerPrint = &types.SignatureType{
+	ArgumentNames: []string{"str"},
+	ArgumentTypes: []types.Type{&types.InterfaceType{
+		Name: "buildin(stringer)",
+		Functions: []struct {
+			Name string
+			Type *types.SignatureType
+		}{
+			{
+				Name: "toString",
+				Type: &types.SignatureType{
+					ArgumentNames: []string{},
+					ArgumentTypes: []types.Type{},
+					ReturnType:    types.StringType,
+					Extern:        true,
 				},
 			},
-		}},
-		ReturnType: types.VoidType,
-		Extern:     true,
-	})
+		},
+	}},
+	ReturnType: types.VoidType,
+	Extern:     true,
+}
+
+func configureAnalyzer(analsr *analyser.Analyser) {
+	builtin.Configure(analsr)
+	analsr.AddExternalFunc("print", stringerPrint)
 }
 
 func main() {
@@ -117,11 +117,16 @@ class App extends React.Component {
 
   _compileCode = (code) => {
     Compile(code).then((res)=> {
-      var fn = new Function('print', res);
+      var fn = new Function('print', 'int_to_str', 'arg_count', 'arg', res);
       var output = [];
       fn(
         (str)=> {
           output.push(str && str.toString());
+        },
+        (i)=> i.toString(),
+        ()=> 0,
+        (index)=> {
+          throw new Error('argument index ' + index + ' out of range');
         }
       );
       this.setState({
@@ -5,9 +5,9 @@ go 1.23.4
 require (
 	github.com/glycerine/rbuf v0.0.0-20190314090850-75b78581bebe
 	github.com/gopherjs/gopherjs v1.17.2
-	github.com/robertkrimen/otto v0.5.1
 	github.com/spf13/cobra v1.9.1
 	github.com/spf13/viper v1.20.1
+	gopkg.in/sourcemap.v1 v1.0.5
 )
 
 require (
@@ -27,6 +27,5 @@ require (
 	go.uber.org/multierr v1.9.0 // indirect
 	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
-	gopkg.in/sourcemap.v1 v1.0.5 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,427 @@
+// Package arc inserts automatic reference counting into IR modules.
+//
+// Memory allocated for a struct is an object with a reference count of one.
+// Strings are objects too. References (pointers to objects and strings)
+// stored in memory own a reference: storing one retains it and the
+// reference it replaces is released. References returned by alloc, by calls
+// and by string concatenation are owned by the register holding them and
+// are released after their last use unless they are stored or returned.
+// Other references are borrowed. Functions return owned references. Slots
+// release the reference they hold before they are freed and main releases
+// the references held by globals before it returns
+package arc
+
+import (
+	"fmt"
+
+	"github.com/orktes/orlang/ir"
+)
+
+// Error is returned when a function can not be reference counted
+type Error struct {
+	Function string
+	Message  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Function, e.Message)
+}
+
+// Insert adds retain and release instructions to the functions of module.
+// Objects allocated into registers that the function already releases
+// itself are left alone
+func Insert(module *ir.Module) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			arcErr, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			err = arcErr
+		}
+	}()
+
+	for _, fn := range module.Functions {
+		newFunction(module, fn).insert()
+	}
+	return nil
+}
+
+type function struct {
+	module    *ir.Module
+	fn        *ir.Function
+	registers map[ir.Register]ir.Type
+	// owned are the registers holding a reference they own
+	owned map[ir.Register]bool
+	// aliases maps registers assigned or cast from other registers to the
+	// register they copy
+	aliases map[ir.Register]ir.Register
+	// loads maps registers loaded from memory to the pointer they were
+	// loaded through. Values loaded from an owned object are borrowed from it
+	loads map[ir.Register]ir.Register
+	count int
+}
+
+func newFunction(module *ir.Module, fn *ir.Function) *function {
+	f := &function{
+		module:    module,
+		fn:        fn,
+		registers: map[ir.Register]ir.Type{},
+		owned:     map[ir.Register]bool{},
+		aliases:   map[ir.Register]ir.Register{},
+		loads:     map[ir.Register]ir.Register{},
+	}
+
+	for _, param := range fn.Params {
+		f.registers[ir.Register(param.Name)] = param.Type
+	}
+
+	// Registers released by the function manage their reference themselves
+	released := map[ir.Register]bool{}
+	for _, block := range fn.Blocks {
+		for _, instr := range block.Instructions {
+			if release, ok := instr.(*ir.Release); ok {
+				if reg, ok := release.Ptr.(ir.Register); ok {
+					released[reg] = true
+				}
+			}
+		}
+	}
+
+	for _, block := range fn.Blocks {
+		for _, instr := range block.Instructions {
+			valueInstr, ok := instr.(ir.ValueInstruction)
+			if !ok || valueInstr.Destination() == "" {
+				continue
+			}
+			dest := valueInstr.Destination()
+			f.registers[dest] = valueInstr.ResultType()
+
+			switch i := instr.(type) {
+			case *ir.Alloc:
+				f.owned[dest] = module.IsObject(i.AllocType) && !released[dest]
+			case *ir.Call, *ir.BinaryOp:
+				f.owned[dest] = module.IsReference(valueInstr.ResultType())
+			case *ir.Assign:
+				if reg, ok := i.Value.(ir.Register); ok {
+					f.aliases[dest] = reg
+				}
+			case *ir.Cast:
+				if reg, ok := i.Value.(ir.Register); ok && module.IsReference(i.Type) {
+					f.aliases[dest] = reg
+				}
+			case *ir.Load:
+				if reg, ok := i.Ptr.(ir.Register); ok {
+					f.loads[dest] = reg
+				}
+			}
+		}
+	}
+
+	return f
+}
+
+func (f *function) error(msg string) {
+	panic(&Error{Function: f.fn.Name, Message: msg})
+}
+
+func (f *function) temp() ir.Register {
+	for {
+		reg := ir.Register(fmt.Sprintf("arc%d", f.count))
+		f.count++
+		if _, ok := f.registers[reg]; !ok && f.module.Global(string(reg)) == nil {
+			f.registers[reg] = nil
+			return reg
+		}
+	}
+}
+
+// root returns the register an alias copies
+func (f *function) root(reg ir.Register) ir.Register {
+	for i := 0; i <= len(f.aliases); i++ {
+		alias, ok := f.aliases[reg]
+		if !ok {
+			break
+		}
+		reg = alias
+	}
+	return reg
+}
+
+// owner returns the register keeping the value of reg alive. Values loaded
+// from an owned object need the object to stay alive until they are last used
+func (f *function) owner(reg ir.Register) ir.Register {
+	for i := 0; i <= len(f.aliases)+len(f.loads); i++ {
+		reg = f.root(reg)
+		ptr, ok := f.loads[reg]
+		if !ok || !f.owned[f.root(ptr)] {
+			break
+		}
+		reg = ptr
+	}
+	return reg
+}
+
+// typeOf returns the type of an operand. Globals are pointers to their
+// storage. Constants have no type and nil is returned
+func (f *function) typeOf(operand ir.Operand) ir.Type {
+	reg, ok := operand.(ir.Register)
+	if !ok {
+		return nil
+	}
+	if typ, ok := f.registers[reg]; ok {
+		return typ
+	}
+	if global := f.module.Global(string(reg)); global != nil {
+		return &ir.PointerType{Elem: global.Type}
+	}
+	return nil
+}
+
+func (f *function) isReference(operand ir.Operand) bool {
+	reg, ok := operand.(ir.Register)
+	return ok && f.module.IsReference(f.typeOf(reg))
+}
+
+// fieldType returns the type of the value stored at index of the memory ptr
+// points to
+func (f *function) fieldType(ptr ir.Operand, index int) ir.Type {
+	ptrType, ok := f.typeOf(ptr).(*ir.PointerType)
+	if !ok {
+		return nil
+	}
+	if structType, ok := f.module.Underlying(ptrType.Elem).(*ir.StructType); ok {
+		if index >= 0 && index < len(structType.Fields) {
+			return structType.Fields[index]
+		}
+		return nil
+	}
+	return ptrType.Elem
+}
+
+func (f *function) insert() {
+	// Owned registers must not be used outside the block defining them so
+	// that they can be released at the end of their last use
+	defined := map[ir.Register]*ir.Block{}
+	for _, block := range f.fn.Blocks {
+		for _, instr := range block.Instructions {
+			if valueInstr, ok := instr.(ir.ValueInstruction); ok && f.owned[valueInstr.Destination()] {
+				defined[valueInstr.Destination()] = block
+			}
+		}
+	}
+	for _, block := range f.fn.Blocks {
+		for _, instr := range block.Instructions {
+			for _, operand := range operands(instr) {
+				reg, ok := operand.(ir.Register)
+				if !ok {
+					continue
+				}
+				reg = f.owner(reg)
+				if owner, ok := defined[reg]; ok && owner != block {
+					f.error(fmt.Sprintf("%s owns a reference and is used outside of its block", ir.FormatOperand(reg)))
+				}
+			}
+		}
+	}
+
+	for _, block := range f.fn.Blocks {
+		f.insertBlock(block)
+	}
+}
+
+func (f *function) insertBlock(block *ir.Block) {
+	// last maps owned registers to the index of their last use
+	var (
+		last  = map[ir.Register]int{}
+		order []ir.Register
+	)
+	for i, instr := range block.Instructions {
+		if valueInstr, ok := instr.(ir.ValueInstruction); ok && f.owned[valueInstr.Destination()] {
+			last[valueInstr.Destination()] = i
+			order = append(order, valueInstr.Destination())
+		}
+		for _, operand := range operands(instr) {
+			if reg, ok := operand.(ir.Register); ok {
+				if _, ok := last[f.owner(reg)]; ok {
+					last[f.owner(reg)] = i
+				}
+			}
+		}
+	}
+
+	// isLastUse returns true if instruction i is the last use of an owned
+	// register and hands its reference over
+	isLastUse := func(operand ir.Operand, i int) bool {
+		reg, ok := operand.(ir.Register)
+		if !ok {
+			return false
+		}
+		index, owned := last[f.root(reg)]
+		return owned && index == i
+	}
+
+	epilogue := epilogueStart(block)
+	initialized := map[ir.Register]map[int]bool{}
+	moved := map[ir.Register]bool{}
+
+	var out []ir.Instruction
+	emit := func(instrs ...ir.Instruction) {
+		out = append(out, instrs...)
+	}
+
+	for i, instr := range block.Instructions {
+		if i == epilogue {
+			emit(f.epilogue(block)...)
+		}
+
+		switch in := instr.(type) {
+		case *ir.Store:
+			if !f.module.IsReference(f.fieldType(in.Ptr, in.Index)) {
+				emit(in)
+				break
+			}
+
+			if isLastUse(in.Value, i) {
+				moved[f.root(in.Value.(ir.Register))] = true
+			} else if f.isReference(in.Value) {
+				emit(&ir.Retain{Ptr: in.Value})
+			}
+
+			// Fields of new objects hold no reference before they are stored
+			ptr, fresh := in.Ptr.(ir.Register)
+			fresh = fresh && initialized[ptr] != nil && !initialized[ptr][in.Index]
+			if fresh {
+				initialized[ptr][in.Index] = true
+				emit(in)
+				break
+			}
+
+			old := f.temp()
+			emit(
+				&ir.Load{Dest: old, Ptr: in.Ptr, Index: in.Index, Type: f.fieldType(in.Ptr, in.Index)},
+				in,
+				&ir.Release{Ptr: old},
+			)
+		case *ir.Free:
+			if ptrType, ok := f.typeOf(in.Ptr).(*ir.PointerType); ok && f.module.IsReference(ptrType.Elem) {
+				// Slots release the reference they hold
+				value := f.temp()
+				emit(
+					&ir.Load{Dest: value, Ptr: in.Ptr, Type: ptrType.Elem},
+					&ir.Release{Ptr: value},
+				)
+			}
+			emit(in)
+		case *ir.Return:
+			if isLastUse(in.Value, i) {
+				moved[f.root(in.Value.(ir.Register))] = true
+			}
+			emit(in)
+		case *ir.Call:
+			// The callee can store into new objects
+			initialized = map[ir.Register]map[int]bool{}
+			emit(in)
+		default:
+			emit(instr)
+		}
+
+		if alloc, ok := instr.(*ir.Alloc); ok && f.owned[alloc.Dest] {
+			initialized[alloc.Dest] = map[int]bool{}
+		}
+
+		// Owned references are released after their last use
+		for _, reg := range order {
+			if last[reg] == i && !moved[reg] && !ir.IsTerminator(instr) {
+				emit(&ir.Release{Ptr: reg})
+			}
+		}
+	}
+
+	block.Instructions = out
+}
+
+// epilogueStart returns the index of the frees and releases that precede the
+// return at the end of block. The length of the block is returned for other
+// blocks
+func epilogueStart(block *ir.Block) int {
+	n := len(block.Instructions)
+	if n == 0 {
+		return n
+	}
+	if _, ok := block.Instructions[n-1].(*ir.Return); !ok {
+		return n
+	}
+
+	start := n - 1
+	for start > 0 {
+		switch block.Instructions[start-1].(type) {
+		case *ir.Free, *ir.Release:
+		default:
+			return start
+		}
+		start--
+	}
+	return start
+}
+
+// epilogue returns the instructions run before the frees and releases
+// preceding a return.
+// A borrowed reference that is returned is retained and main releases the
+// references held by globals
+func (f *function) epilogue(block *ir.Block) (instrs []ir.Instruction) {
+	ret := block.Instructions[len(block.Instructions)-1].(*ir.Return)
+	if reg, ok := ret.Value.(ir.Register); ok && f.isReference(reg) && !f.owned[f.root(reg)] {
+		instrs = append(instrs, &ir.Retain{Ptr: reg})
+	}
+
+	if f.fn.Name != "main" {
+		return
+	}
+
+	for _, global := range f.module.Globals {
+		if !f.module.IsReference(global.Type) {
+			continue
+		}
+		value := f.temp()
+		instrs = append(instrs,
+			&ir.Load{Dest: value, Ptr: ir.Register(global.Name), Type: global.Type},
+			&ir.Store{Ptr: ir.Register(global.Name), Value: ir.Constant{}},
+			&ir.Release{Ptr: value},
+		)
+	}
+	return
+}
+
+// operands returns the operands an instruction reads
+func operands(instr ir.Instruction) []ir.Operand {
+	switch i := instr.(type) {
+	case *ir.Assign:
+		return []ir.Operand{i.Value}
+	case *ir.BinaryOp:
+		return []ir.Operand{i.Left, i.Right}
+	case *ir.UnaryOp:
+		return []ir.Operand{i.Value}
+	case *ir.Cast:
+		return []ir.Operand{i.Value}
+	case *ir.Call:
+		return append([]ir.Operand{i.Callee}, i.Arguments...)
+	case *ir.Load:
+		return []ir.Operand{i.Ptr}
+	case *ir.Store:
+		return []ir.Operand{i.Ptr, i.Value}
+	case *ir.Free:
+		return []ir.Operand{i.Ptr}
+	case *ir.Retain:
+		return []ir.Operand{i.Ptr}
+	case *ir.Release:
+		return []ir.Operand{i.Ptr}
+	case *ir.Return:
+		if i.Value != nil {
+			return []ir.Operand{i.Value}
+		}
+	case *ir.BrCond:
+		return []ir.Operand{i.Condition}
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package arc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/orktes/orlang/ir"
+	"github.com/orktes/orlang/scanner"
+)
+
+func parseModule(t *testing.T, src string) *ir.Module {
+	module, err := ir.Parse(ir.NewScanner(scanner.NewScanner(strings.NewReader(src))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return module
+}
+
+func TestInsert(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{
+			// Unused objects are released after their last use
+			`type Point {int32, int32}
+
+			fn main() : void {
+				%p = alloc Point : ptr<Point>
+				store %p, 1
+				%x = load %p : int32
+				return
+			}`,
+			`type Point {int32, int32}
+
+			fn main() : void {
+				%p = alloc Point : ptr<Point>
+				store %p, 1
+				%x = load %p : int32
+				release %p
+				return
+			}`,
+		},
+		{
+			// The last use of an owned reference moves it into memory and
+			// the slot releases it before it is freed
+			`type Point {int32, int32}
+
+			fn main() : void {
+				%slot = alloc ptr<Point> : ptr<ptr<Point>>
+				%p = alloc Point : ptr<Point>
+				store %slot, %p
+				free %slot
+				return
+			}`,
+			`type Point {int32, int32}
+
+			fn main() : void {
+				%slot = alloc ptr<Point> : ptr<ptr<Point>>
+				%p = alloc Point : ptr<Point>
+				%arc0 = load %slot : ptr<Point>
+				store %slot, %p
+				release %arc0
+				%arc1 = load %slot : ptr<Point>
+				release %arc1
+				free %slot
+				return
+			}`,
+		},
+		{
+			// Borrowed references are retained when they are stored and
+			// fields of new objects hold nothing to release
+			`type Line {ptr<Point>, ptr<Point>}
+			type Point {int32, int32}
+
+			fn line(%p : ptr<Point>) : ptr<Line> {
+				%l = alloc Line : ptr<Line>
+				store %l, %p
+				store %l, %p, 1
+				return %l : ptr<Line>
+			}`,
+			`type Line {ptr<Point>, ptr<Point>}
+			type Point {int32, int32}
+
+			fn line(%p : ptr<Point>) : ptr<Line> {
+				%l = alloc Line : ptr<Line>
+				retain %p
+				store %l, %p
+				retain %p
+				store %l, %p, 1
+				return %l : ptr<Line>
+			}`,
+		},
+		{
+			// Returned borrowed references are retained and values loaded
+			// from an owned object keep it alive
+			`type Pair {ptr<Pair>, ptr<Pair>}
+
+			fn second(%p : ptr<Pair>) : ptr<Pair> {
+				%a = load %p : ptr<Pair>
+				return %a : ptr<Pair>
+			}
+
+			fn first() : ptr<Pair> {
+				%p = call make() : ptr<Pair>
+				%a = load %p : ptr<Pair>
+				%b = call second(%a) : ptr<Pair>
+				return %b : ptr<Pair>
+			}
+
+			fn make() : ptr<Pair> {
+				%p = alloc Pair : ptr<Pair>
+				return %p : ptr<Pair>
+			}`,
+			`type Pair {ptr<Pair>, ptr<Pair>}
+
+			fn second(%p : ptr<Pair>) : ptr<Pair> {
+				%a = load %p : ptr<Pair>
+				retain %a
+				return %a : ptr<Pair>
+			}
+
+			fn first() : ptr<Pair> {
+				%p = call make() : ptr<Pair>
+				%a = load %p : ptr<Pair>
+				%b = call second(%a) : ptr<Pair>
+				release %p
+				return %b : ptr<Pair>
+			}
+
+			fn make() : ptr<Pair> {
+				%p = alloc Pair : ptr<Pair>
+				return %p : ptr<Pair>
+			}`,
+		},
+		{
+			// Main releases the references held by globals and registers
+			// released by the function are left alone
+			`type Box {int32}
+
+			global %box : ptr<Box>
+
+			fn main() : void {
+				%b = alloc Box : ptr<Box>
+				br next
+
+			next:
+				store %box, %b
+				release %b
+				return
+			}`,
+			`type Box {int32}
+
+			global %box : ptr<Box>
+
+			fn main() : void {
+				%b = alloc Box : ptr<Box>
+				br next
+
+			next:
+				retain %b
+				%arc0 = load %box : ptr<Box>
+				store %box, %b
+				release %arc0
+				%arc1 = load %box : ptr<Box>
+				store %box, null
+				release %arc1
+				release %b
+				return
+			}`,
+		},
+		{
+			// Strings returned by calls and concatenation are owned like
+			// objects
+			`extern print(%str : string) : void
+			extern int_to_str(%i : int64) : string
+
+			fn main() : void {
+				%slot = alloc string : ptr<string>
+				%a = call int_to_str(1) : string
+				%b = %a + "!" : string
+				call print(%b) : void
+				store %slot, %b
+				free %slot
+				return
+			}`,
+			`extern print(%str : string) : void
+			extern int_to_str(%i : int64) : string
+
+			fn main() : void {
+				%slot = alloc string : ptr<string>
+				%a = call int_to_str(1) : string
+				%b = %a + "!" : string
+				release %a
+				call print(%b) : void
+				%arc0 = load %slot : string
+				store %slot, %b
+				release %arc0
+				%arc1 = load %slot : string
+				release %arc1
+				free %slot
+				return
+			}`,
+		},
+		{
+			// A cast reference is the reference it was cast from
+			`type Closure {fn(ptr<Closure>) : void}
+
+			fn make() : ptr<Closure> {
+				%env = alloc {fn(ptr<Closure>) : void, int32} : ptr<{fn(ptr<Closure>) : void, int32}>
+				%c = cast %env : ptr<Closure>
+				return %c : ptr<Closure>
+			}
+
+			fn main() : void {
+				%c = call make() : ptr<Closure>
+				return
+			}`,
+			`type Closure {fn(ptr<Closure>) : void}
+
+			fn make() : ptr<Closure> {
+				%env = alloc {fn(ptr<Closure>) : void, int32} : ptr<{fn(ptr<Closure>) : void, int32}>
+				%c = cast %env : ptr<Closure>
+				return %c : ptr<Closure>
+			}
+
+			fn main() : void {
+				%c = call make() : ptr<Closure>
+				release %c
+				return
+			}`,
+		},
+	}
+
+	for _, test := range tests {
+		module := parseModule(t, test.src)
+		if err := Insert(module); err != nil {
+			t.Errorf("%s: %s", test.src, err)
+			continue
+		}
+
+		expected := string(ir.Print(parseModule(t, test.expected)))
+		if result := string(ir.Print(module)); result != expected {
+			t.Errorf("Expected:\n%s\ngot:\n%s", expected, result)
+		}
+	}
+}
+
+func TestInsertErrors(t *testing.T) {
+	module := parseModule(t, `type Point {int32, int32}
+
+	fn main() : void {
+		%p = alloc Point : ptr<Point>
+		br next
+
+	next:
+		%x = load %p : int32
+		return
+	}`)
+
+	err := Insert(module)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	expected := "main: %p owns a reference and is used outside of its block"
+	if err.Error() != expected {
+		t.Errorf("Expected %q got %q", expected, err.Error())
+	}
+}
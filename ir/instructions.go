@@ -1 +1,203 @@
 package ir
+
+// Operand is a value used as an instruction argument
+type Operand interface {
+	operand()
+}
+
+// Register is a named virtual register (%name). The name is stored without the % prefix
+type Register string
+
+func (Register) operand() {}
+
+// Constant is a literal value. Value is int64, float64, bool, string or nil for null
+type Constant struct {
+	Value interface{}
+}
+
+func (Constant) operand() {}
+
+// FunctionRef refers to a function or an extern by name
+type FunctionRef string
+
+func (FunctionRef) operand() {}
+
+// Instruction is a single three-address instruction inside a basic block
+type Instruction interface {
+	instruction()
+}
+
+// ValueInstruction is an instruction that defines a register
+type ValueInstruction interface {
+	Instruction
+	Destination() Register
+	ResultType() Type
+}
+
+// Assign copies an operand to a register
+//
+//	%dest = value : type
+type Assign struct {
+	Dest  Register
+	Value Operand
+	Type  Type
+}
+
+// BinaryOp applies an arithmetic or comparison operator to two operands
+//
+//	%dest = left op right : type
+type BinaryOp struct {
+	Dest     Register
+	Operator string
+	Left     Operand
+	Right    Operand
+	Type     Type
+}
+
+// UnaryOp applies neg or not to an operand
+//
+//	%dest = op value : type
+type UnaryOp struct {
+	Dest     Register
+	Operator string
+	Value    Operand
+	Type     Type
+}
+
+// Cast converts an operand to another primitive type or a pointer to a
+// pointer of another type
+//
+//	%dest = cast value : type
+type Cast struct {
+	Dest  Register
+	Value Operand
+	Type  Type
+}
+
+// Call calls a function, an extern or a function reference stored in a register.
+// Dest is empty when the result is discarded
+//
+//	%dest = call callee(args) : type
+//	call callee(args) : type
+type Call struct {
+	Dest      Register
+	Callee    Operand
+	Arguments []Operand
+	Type      Type
+}
+
+// Alloc allocates memory for a value of AllocType
+//
+//	%dest = alloc type : ptr<type>
+type Alloc struct {
+	Dest      Register
+	AllocType Type
+	Type      Type
+}
+
+// Load reads a value (or a struct field by index) through a pointer
+//
+//	%dest = load ptr, index : type
+type Load struct {
+	Dest  Register
+	Ptr   Operand
+	Index int
+	Type  Type
+}
+
+// Store writes a value (or a struct field by index) through a pointer
+//
+//	store ptr, value, index
+type Store struct {
+	Ptr   Operand
+	Value Operand
+	Index int
+}
+
+// Free releases memory allocated with alloc
+//
+//	free ptr
+type Free struct {
+	Ptr Operand
+}
+
+// Retain increments the reference count of an object allocated with alloc.
+// Retaining null is a no-op
+//
+//	retain ptr
+type Retain struct {
+	Ptr Operand
+}
+
+// Release decrements the reference count of an object allocated with alloc.
+// When the count drops to zero the references stored in the object are
+// released and the object is freed. Releasing null is a no-op
+//
+//	release ptr
+type Release struct {
+	Ptr Operand
+}
+
+// Return returns from the current function. Value is nil for void functions
+//
+//	return value : type
+type Return struct {
+	Value Operand
+	Type  Type
+}
+
+// Br jumps unconditionally to a label
+//
+//	br label
+type Br struct {
+	Label string
+}
+
+// BrCond jumps to True if Condition holds and to False otherwise
+//
+//	br_cond cond, true, false
+type BrCond struct {
+	Condition Operand
+	True      string
+	False     string
+}
+
+func (*Assign) instruction()   {}
+func (*BinaryOp) instruction() {}
+func (*UnaryOp) instruction()  {}
+func (*Cast) instruction()     {}
+func (*Call) instruction()     {}
+func (*Alloc) instruction()    {}
+func (*Load) instruction()     {}
+func (*Store) instruction()    {}
+func (*Free) instruction()     {}
+func (*Retain) instruction()   {}
+func (*Release) instruction()  {}
+func (*Return) instruction()   {}
+func (*Br) instruction()       {}
+func (*BrCond) instruction()   {}
+
+func (i *Assign) Destination() Register   { return i.Dest }
+func (i *BinaryOp) Destination() Register { return i.Dest }
+func (i *UnaryOp) Destination() Register  { return i.Dest }
+func (i *Cast) Destination() Register     { return i.Dest }
+func (i *Call) Destination() Register     { return i.Dest }
+func (i *Alloc) Destination() Register    { return i.Dest }
+func (i *Load) Destination() Register     { return i.Dest }
+
+func (i *Assign) ResultType() Type   { return i.Type }
+func (i *BinaryOp) ResultType() Type { return i.Type }
+func (i *UnaryOp) ResultType() Type  { return i.Type }
+func (i *Cast) ResultType() Type     { return i.Type }
+func (i *Call) ResultType() Type     { return i.Type }
+func (i *Alloc) ResultType() Type    { return i.Type }
+func (i *Load) ResultType() Type     { return i.Type }
+
+// IsTerminator returns true for instructions that end a basic block
+func IsTerminator(instr Instruction) bool {
+	switch instr.(type) {
+	case *Return, *Br, *BrCond:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,503 @@
+package interpreter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/orktes/orlang/ir"
+)
+
+// DefaultMaxDepth is the default limit for nested calls
+const DefaultMaxDepth = 10000
+
+// ExternFunc implements an extern declared by a module. Arguments are
+// converted to the parameter types of the extern declaration
+type ExternFunc func(args []Value) (Value, error)
+
+// Error is returned when the execution of a module fails
+type Error struct {
+	Function    string
+	Label       string
+	Instruction ir.Instruction
+	Message     string
+}
+
+func (e *Error) Error() string {
+	location := e.Function
+	if e.Label != "" {
+		location += ":" + e.Label
+	}
+	if e.Instruction != nil {
+		return fmt.Sprintf("%s: %s: %s", location, ir.FormatInstruction(e.Instruction), e.Message)
+	}
+	return fmt.Sprintf("%s: %s", location, e.Message)
+}
+
+// Interpreter executes IR modules
+type Interpreter struct {
+	// MaxDepth limits the depth of nested calls
+	MaxDepth int
+	// LeakCheck makes Run return a *LeakError if reference counted objects
+	// are still alive when main returns
+	LeakCheck bool
+
+	module  *ir.Module
+	externs map[string]ExternFunc
+	globals map[string]*Pointer
+	labels  map[*ir.Function]map[string]*ir.Block
+	depth   int
+	objects map[*Pointer]bool
+	count   int
+}
+
+// LeakError is returned by Run in leak check mode. Leaks are in allocation
+// order
+type LeakError struct {
+	Leaks []*Pointer
+}
+
+func (e *LeakError) Error() string {
+	names := make([]string, len(e.Leaks))
+	for i, leak := range e.Leaks {
+		names[i] = leak.Type.String()
+	}
+	return fmt.Sprintf("%d objects leaked: %s", len(e.Leaks), strings.Join(names, ", "))
+}
+
+type frame struct {
+	fn        *ir.Function
+	registers map[ir.Register]Value
+}
+
+// New returns an interpreter for module with zero initialized globals
+func New(module *ir.Module) *Interpreter {
+	in := &Interpreter{
+		MaxDepth: DefaultMaxDepth,
+		module:   module,
+		externs:  map[string]ExternFunc{},
+		globals:  map[string]*Pointer{},
+		labels:   map[*ir.Function]map[string]*ir.Block{},
+		objects:  map[*Pointer]bool{},
+	}
+
+	for _, global := range module.Globals {
+		in.globals[global.Name] = newPointer(module, global.Type)
+	}
+
+	return in
+}
+
+// RegisterExtern provides the implementation for the extern called name
+func (in *Interpreter) RegisterExtern(name string, fn ExternFunc) {
+	in.externs[name] = fn
+}
+
+// Global returns the memory of a global variable
+func (in *Interpreter) Global(name string) *Pointer {
+	return in.globals[name]
+}
+
+// Run calls the main function of the module
+func (in *Interpreter) Run() error {
+	if _, err := in.Call("main"); err != nil {
+		return err
+	}
+
+	if leaks := in.Leaks(); in.LeakCheck && len(leaks) > 0 {
+		return &LeakError{Leaks: leaks}
+	}
+	return nil
+}
+
+// Leaks returns the reference counted objects that have not been released
+// in allocation order
+func (in *Interpreter) Leaks() []*Pointer {
+	leaks := make([]*Pointer, 0, len(in.objects))
+	for object := range in.objects {
+		leaks = append(leaks, object)
+	}
+	sort.Slice(leaks, func(i, j int) bool { return leaks[i].id < leaks[j].id })
+	return leaks
+}
+
+// Call calls a function or an extern by name
+func (in *Interpreter) Call(name string, args ...Value) (Value, error) {
+	return in.call(ir.FunctionRef(name), args)
+}
+
+func (in *Interpreter) call(callee Value, args []Value) (Value, error) {
+	ref, ok := callee.(ir.FunctionRef)
+	if !ok {
+		if callee == nil {
+			return nil, fmt.Errorf("call of null function")
+		}
+		return nil, fmt.Errorf("%v is not callable", callee)
+	}
+
+	if fn := in.module.Function(string(ref)); fn != nil {
+		return in.execute(fn, args)
+	}
+
+	if extern := in.module.Extern(string(ref)); extern != nil {
+		impl, ok := in.externs[extern.Name]
+		if !ok {
+			return nil, fmt.Errorf("extern %s is not registered", extern.Name)
+		}
+		if len(args) != len(extern.Params) && !(extern.Variadic && len(args) > len(extern.Params)) {
+			return nil, fmt.Errorf("extern %s expects %d arguments got %d", extern.Name, len(extern.Params), len(args))
+		}
+		return impl(args)
+	}
+
+	return nil, fmt.Errorf("undefined function %s", ref)
+}
+
+// params returns the parameter types of the function or extern callee refers to
+func (in *Interpreter) params(callee Value) []*ir.Param {
+	ref, _ := callee.(ir.FunctionRef)
+	if fn := in.module.Function(string(ref)); fn != nil {
+		return fn.Params
+	}
+	if extern := in.module.Extern(string(ref)); extern != nil {
+		return extern.Params
+	}
+	return nil
+}
+
+func (in *Interpreter) block(fn *ir.Function, label string) (*ir.Block, error) {
+	labels, ok := in.labels[fn]
+	if !ok {
+		labels = map[string]*ir.Block{}
+		for _, block := range fn.Blocks {
+			labels[block.Label] = block
+		}
+		in.labels[fn] = labels
+	}
+
+	block, ok := labels[label]
+	if !ok {
+		return nil, fmt.Errorf("undefined label %s", label)
+	}
+	return block, nil
+}
+
+func (in *Interpreter) execute(fn *ir.Function, args []Value) (Value, error) {
+	if len(args) != len(fn.Params) {
+		return nil, &Error{Function: fn.Name, Message: fmt.Sprintf("expects %d arguments got %d", len(fn.Params), len(args))}
+	}
+	if len(fn.Blocks) == 0 {
+		return nil, &Error{Function: fn.Name, Message: "function has no blocks"}
+	}
+
+	in.depth++
+	defer func() { in.depth-- }()
+	if in.depth > in.MaxDepth {
+		return nil, &Error{Function: fn.Name, Message: "stack overflow"}
+	}
+
+	f := &frame{fn: fn, registers: map[ir.Register]Value{}}
+	for i, param := range fn.Params {
+		f.registers[ir.Register(param.Name)] = args[i]
+	}
+
+	block := fn.Blocks[0]
+	for {
+		next, done, result, err := in.executeBlock(f, block)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			return result, nil
+		}
+		block = next
+	}
+}
+
+// executeBlock runs the instructions of block and returns either the next
+// block or the return value of the function
+func (in *Interpreter) executeBlock(f *frame, block *ir.Block) (next *ir.Block, done bool, result Value, err error) {
+	for _, instr := range block.Instructions {
+		next, done, result, err = in.executeInstruction(f, instr)
+		if err != nil {
+			if _, ok := err.(*Error); !ok {
+				err = &Error{Function: f.fn.Name, Label: block.Label, Instruction: instr, Message: err.Error()}
+			}
+			return
+		}
+		if done || next != nil {
+			return
+		}
+	}
+
+	err = &Error{Function: f.fn.Name, Label: block.Label, Message: "block is not terminated"}
+	return
+}
+
+func (in *Interpreter) executeInstruction(f *frame, instr ir.Instruction) (next *ir.Block, done bool, result Value, err error) {
+	var value Value
+
+	switch i := instr.(type) {
+	case *ir.Assign:
+		value, err = f.operand(in, i.Value, i.Type)
+	case *ir.BinaryOp:
+		var left, right Value
+		left, right, err = f.operands(in, i.Left, i.Right, i.Type)
+		if err == nil {
+			value, err = binaryOp(i.Operator, left, right)
+		}
+	case *ir.UnaryOp:
+		var operand Value
+		if operand, err = f.operand(in, i.Value, i.Type); err == nil {
+			value, err = unaryOp(i.Operator, operand)
+		}
+	case *ir.Cast:
+		var operand Value
+		if operand, err = f.operand(in, i.Value, nil); err == nil {
+			value = operand
+			if primitive, ok := i.Type.(ir.PrimitiveType); ok {
+				value, err = convert(operand, primitive)
+			}
+		}
+	case *ir.Call:
+		value, err = in.executeCall(f, i)
+	case *ir.Alloc:
+		value = in.alloc(i.AllocType)
+	case *ir.Load:
+		var ptr *Pointer
+		if ptr, err = f.pointer(in, i.Ptr); err == nil {
+			value, err = ptr.Load(i.Index)
+		}
+	case *ir.Store:
+		var ptr *Pointer
+		if ptr, err = f.pointer(in, i.Ptr); err != nil {
+			return
+		}
+		if err = ptr.check(i.Index); err != nil {
+			return
+		}
+		if value, err = f.operand(in, i.Value, ptr.types[i.Index]); err == nil {
+			err = ptr.Store(i.Index, value)
+		}
+		return
+	case *ir.Free:
+		var ptr *Pointer
+		if ptr, err = f.pointer(in, i.Ptr); err != nil {
+			// Freeing null is a no-op
+			if ptr == nil && err == errNullPointer {
+				err = nil
+			}
+			return
+		}
+		if ptr.freed {
+			err = fmt.Errorf("double free of %s", ptr)
+			return
+		}
+		ptr.freed = true
+		delete(in.objects, ptr)
+		return
+	case *ir.Retain:
+		var ptr *Pointer
+		if ptr, err = in.object(f, i.Ptr); err == nil && ptr != nil {
+			ptr.refs++
+		}
+		return
+	case *ir.Release:
+		var ptr *Pointer
+		if ptr, err = in.object(f, i.Ptr); err == nil && ptr != nil {
+			err = in.release(ptr)
+		}
+		return
+	case *ir.Return:
+		done = true
+		if i.Value != nil {
+			result, err = f.operand(in, i.Value, i.Type)
+		}
+		return
+	case *ir.Br:
+		next, err = in.block(f.fn, i.Label)
+		return
+	case *ir.BrCond:
+		var cond Value
+		if cond, err = f.operand(in, i.Condition, ir.Bool); err != nil {
+			return
+		}
+		b, ok := cond.(bool)
+		if !ok {
+			err = fmt.Errorf("condition %v is not a bool", cond)
+			return
+		}
+		label := i.False
+		if b {
+			label = i.True
+		}
+		next, err = in.block(f.fn, label)
+		return
+	default:
+		err = fmt.Errorf("unknown instruction %T", instr)
+		return
+	}
+
+	if err == nil {
+		if dest, ok := instr.(ir.ValueInstruction); ok && dest.Destination() != "" {
+			f.registers[dest.Destination()] = value
+		}
+	}
+	return
+}
+
+func (in *Interpreter) executeCall(f *frame, i *ir.Call) (Value, error) {
+	callee, err := f.operand(in, i.Callee, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	params := in.params(callee)
+	args := make([]Value, len(i.Arguments))
+	for x, arg := range i.Arguments {
+		var hint ir.Type
+		if x < len(params) {
+			hint = params[x].Type
+		}
+		if args[x], err = f.operand(in, arg, hint); err != nil {
+			return nil, err
+		}
+	}
+
+	return in.call(callee, args)
+}
+
+var errNullPointer = fmt.Errorf("nil pointer dereference")
+
+// alloc allocates memory for a value of typ. Objects start with a single
+// reference
+func (in *Interpreter) alloc(typ ir.Type) *Pointer {
+	ptr := newPointer(in.module, typ)
+	if in.module.IsObject(typ) {
+		in.count++
+		ptr.object, ptr.refs, ptr.id = true, 1, in.count
+		in.objects[ptr] = true
+	}
+	return ptr
+}
+
+// object returns the object operand refers to. Null and strings are
+// returned as nil without an error. Strings are Go values and need no
+// reference counting
+func (in *Interpreter) object(f *frame, operand ir.Operand) (*Pointer, error) {
+	if value, err := f.operand(in, operand, nil); err == nil {
+		if _, ok := value.(string); ok {
+			return nil, nil
+		}
+	}
+
+	ptr, err := f.pointer(in, operand)
+	switch {
+	case err == errNullPointer:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	case ptr.freed:
+		return nil, fmt.Errorf("use of freed memory %s", ptr)
+	case !ptr.object:
+		return nil, fmt.Errorf("%s is not reference counted", ptr)
+	}
+	return ptr, nil
+}
+
+// release drops a reference to an object. The last reference frees the
+// object and releases the references stored in it
+func (in *Interpreter) release(ptr *Pointer) error {
+	ptr.refs--
+	if ptr.refs > 0 {
+		return nil
+	}
+
+	ptr.freed = true
+	delete(in.objects, ptr)
+
+	for i, typ := range ptr.types {
+		field, ok := ptr.fields[i].(*Pointer)
+		if !ok || !in.module.IsReference(typ) {
+			continue
+		}
+		if field.freed {
+			return fmt.Errorf("use of freed memory %s", field)
+		}
+		if err := in.release(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *frame) pointer(in *Interpreter, operand ir.Operand) (*Pointer, error) {
+	value, err := f.operand(in, operand, nil)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, errNullPointer
+	}
+	ptr, ok := value.(*Pointer)
+	if !ok {
+		return nil, fmt.Errorf("%v is not a pointer", value)
+	}
+	return ptr, nil
+}
+
+// operand returns the value of operand. Untyped numeric constants are
+// converted to hint
+func (f *frame) operand(in *Interpreter, operand ir.Operand, hint ir.Type) (Value, error) {
+	switch o := operand.(type) {
+	case ir.Register:
+		if value, ok := f.registers[o]; ok {
+			return value, nil
+		}
+		if global, ok := in.globals[string(o)]; ok {
+			return global, nil
+		}
+		return nil, fmt.Errorf("undefined register %s", ir.FormatOperand(o))
+	case ir.FunctionRef:
+		return o, nil
+	case ir.Constant:
+		switch o.Value.(type) {
+		case int64, float64:
+			if primitive, ok := hint.(ir.PrimitiveType); ok && (primitive.IsInteger() || primitive.IsFloat()) {
+				return convert(o.Value, primitive)
+			}
+		}
+		return o.Value, nil
+	}
+
+	return nil, fmt.Errorf("unknown operand %v", operand)
+}
+
+// operands returns the values of a binary operation. Constants take the type
+// of the other operand so that comparisons work on typed values
+func (f *frame) operands(in *Interpreter, left, right ir.Operand, typ ir.Type) (Value, Value, error) {
+	_, leftConstant := left.(ir.Constant)
+	_, rightConstant := right.(ir.Constant)
+
+	if typ == ir.Bool && leftConstant != rightConstant {
+		if leftConstant {
+			r, l, err := f.operands(in, right, left, typ)
+			return l, r, err
+		}
+
+		l, err := f.operand(in, left, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		hint, _ := primitiveOf(l)
+		r, err := f.operand(in, right, hint)
+		return l, r, err
+	}
+
+	l, err := f.operand(in, left, typ)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := f.operand(in, right, typ)
+	return l, r, err
+}
@@ -0,0 +1,376 @@
+package interpreter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/orktes/orlang/analyser"
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/ir"
+	"github.com/orktes/orlang/ir/arc"
+	"github.com/orktes/orlang/ir/lowering"
+	"github.com/orktes/orlang/loader"
+	"github.com/orktes/orlang/scanner"
+	"github.com/orktes/orlang/types"
+)
+
+func parseModule(t *testing.T, src string) *ir.Module {
+	module, err := ir.Parse(ir.NewScanner(scanner.NewScanner(strings.NewReader(src))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return module
+}
+
+func lower(path string) (*ir.Module, error) {
+	program, err := loader.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	analyser, err := analyser.NewProgram(program.Files, program.Imports)
+	if err != nil {
+		return nil, err
+	}
+
+	analyser.AddExternalFunc("print", &types.SignatureType{
+		ArgumentNames: []string{"str"},
+		ArgumentTypes: []types.Type{types.StringType},
+		ReturnType:    types.VoidType,
+		Extern:        true,
+	})
+	analyser.AddExternalFunc("int_to_str", &types.SignatureType{
+		ArgumentNames: []string{"i"},
+		ArgumentTypes: []types.Type{types.Int64Type},
+		ReturnType:    types.StringType,
+		Extern:        true,
+	})
+
+	var analyErr error
+	analyser.Error = func(node ast.Node, msg string, fatal bool) {
+		if fatal {
+			analyErr = errors.New(fmt.Sprintf(
+				"%d:%d %s",
+				node.StartPos().Line+1,
+				node.StartPos().Column+1,
+				msg,
+			))
+		}
+	}
+
+	info, err := analyser.Analyse()
+	if err != nil {
+		return nil, err
+	}
+
+	if analyErr != nil {
+		return nil, analyErr
+	}
+
+	module, err := lowering.New(info).Lower(program.Files...)
+	if err != nil {
+		return nil, err
+	}
+
+	return module, arc.Insert(module)
+}
+
+func run(module *ir.Module) (string, error) {
+	var out bytes.Buffer
+
+	in := New(module)
+	in.LeakCheck = true
+	in.RegisterExtern("print", func(args []Value) (Value, error) {
+		fmt.Fprintln(&out, args[0])
+		return nil, nil
+	})
+	in.RegisterExtern("int_to_str", func(args []Value) (Value, error) {
+		return strconv.FormatInt(args[0].(int64), 10), nil
+	})
+
+	err := in.Run()
+	return out.String(), err
+}
+
+func TestRunPrograms(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.or")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		expected, err := ioutil.ReadFile(strings.TrimSuffix(file, ".or") + ".out")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		module, err := lower(file)
+		if err != nil {
+			t.Errorf("%s: %s", file, err)
+			continue
+		}
+
+		output, err := run(module)
+		if err != nil {
+			t.Errorf("%s: %s", file, err)
+			continue
+		}
+
+		if output != string(expected) {
+			t.Errorf("%s: expected output %q got %q", file, expected, output)
+		}
+	}
+}
+
+func TestCall(t *testing.T) {
+	tests := []struct {
+		src    string
+		args   []Value
+		result Value
+	}{
+		{`fn test(%a : int8, %b : int8) : int8 {
+			%c = %a + %b : int8
+			return %c : int8
+		}`, []Value{int8(100), int8(100)}, int8(-56)},
+		{`fn test(%a : uint32) : uint32 {
+			%b = %a - 1 : uint32
+			return %b : uint32
+		}`, []Value{uint32(0)}, uint32(4294967295)},
+		{`fn test(%a : int32) : int32 {
+			%b = %a / 2 : int32
+			return %b : int32
+		}`, []Value{int32(-7)}, int32(-3)},
+		{`fn test(%a : float64) : int32 {
+			%b = cast %a : int32
+			return %b : int32
+		}`, []Value{float64(3.9)}, int32(3)},
+		{`fn test(%a : int32) : bool {
+			%b = %a >= 10 : bool
+			%c = not %b : bool
+			return %c : bool
+		}`, []Value{int32(3)}, true},
+		{`fn test(%a : string) : string {
+			%b = %a + "!" : string
+			return %b : string
+		}`, []Value{"hi"}, "hi!"},
+		{`fn test(%a : int64) : int64 {
+			%b = neg %a : int64
+			return %b : int64
+		}`, []Value{int64(5)}, int64(-5)},
+		{`type Pair {int32, float32}
+
+		fn test() : float32 {
+			%p = alloc Pair : ptr<Pair>
+			store %p, 1.5, 1
+			%a = load %p, 1 : float32
+			%b = load %p : int32
+			%c = cast %b : float32
+			%d = %a + %c : float32
+			free %p
+			return %d : float32
+		}`, nil, float32(1.5)},
+		{`global %counter : int32
+
+		fn inc() : void {
+			%a = load %counter : int32
+			%b = %a + 1 : int32
+			store %counter, %b
+			return
+		}
+
+		fn test(%n : int32) : int32 {
+			%i = alloc int32 : ptr<int32>
+			br loop
+
+		loop:
+			%v = load %i : int32
+			%c = %v < %n : bool
+			br_cond %c, body, end
+
+		body:
+			call inc() : void
+			%next = %v + 1 : int32
+			store %i, %next
+			br loop
+
+		end:
+			free %i
+			%r = load %counter : int32
+			return %r : int32
+		}`, []Value{int32(5)}, int32(5)},
+		{`fn double(%x : int32) : int32 {
+			%y = %x * 2 : int32
+			return %y : int32
+		}
+
+		fn test(%x : int32) : int32 {
+			%f = double : fn(int32) : int32
+			%r = call %f(%x) : int32
+			return %r : int32
+		}`, []Value{int32(21)}, int32(42)},
+		{`fn test() : bool {
+			%p = null : ptr<int32>
+			%c = %p == null : bool
+			return %c : bool
+		}`, nil, true},
+	}
+
+	for _, test := range tests {
+		result, err := New(parseModule(t, test.src)).Call("test", test.args...)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %s", test.src, err)
+			continue
+		}
+		if result != test.result {
+			t.Errorf("Wrong result for %s: expected %#v got %#v", test.src, test.result, result)
+		}
+	}
+}
+
+func TestExterns(t *testing.T) {
+	module := parseModule(t, `extern add(%a : int64, %b : int64) : int64
+
+	fn main() : void {
+		%r = call add(1, 2) : int64
+		%s = call add(%r, 3) : int64
+		call check(%s) : void
+		return
+	}
+
+	extern check(%v : int64) : void`)
+
+	var result Value
+	in := New(module)
+	in.RegisterExtern("add", func(args []Value) (Value, error) {
+		return args[0].(int64) + args[1].(int64), nil
+	})
+	in.RegisterExtern("check", func(args []Value) (Value, error) {
+		result = args[0]
+		return nil, nil
+	})
+
+	if err := in.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if result != int64(6) {
+		t.Errorf("Expected 6 got %#v", result)
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{`fn main() : void {
+			%a = 1 : int32
+			%b = %a / 0 : int32
+			return
+		}`, "main: %b = %a / 0 : int32: integer division by zero"},
+		{`fn main() : void {
+			%p = alloc int32 : ptr<int32>
+			free %p
+			br next
+
+		next:
+			%v = load %p : int32
+			return
+		}`, "main:next: %v = load %p : int32: use of freed memory ptr<int32>"},
+		{`fn main() : void {
+			%p = alloc int32 : ptr<int32>
+			free %p
+			free %p
+			return
+		}`, "main: free %p: double free of ptr<int32>"},
+		{`fn main() : void {
+			%p = alloc {int32} : ptr<{int32}>
+			release %p
+			release %p
+			return
+		}`, "main: release %p: use of freed memory ptr<{int32}>"},
+		{`fn main() : void {
+			%p = alloc int32 : ptr<int32>
+			retain %p
+			return
+		}`, "main: retain %p: ptr<int32> is not reference counted"},
+		{`fn main() : void {
+			%p = null : ptr<int32>
+			store %p, 1
+			return
+		}`, "main: store %p, 1: nil pointer dereference"},
+		{`extern missing() : void
+
+		fn main() : void {
+			call missing() : void
+			return
+		}`, "main: call missing() : void: extern missing is not registered"},
+		{`fn loop() : void {
+			call loop() : void
+			return
+		}
+
+		fn main() : void {
+			call loop() : void
+			return
+		}`, "loop: stack overflow"},
+	}
+
+	for _, test := range tests {
+		in := New(parseModule(t, test.src))
+		in.MaxDepth = 100
+
+		err := in.Run()
+		if err == nil {
+			t.Errorf("Expected error for %s", test.src)
+			continue
+		}
+		if err.Error() != test.err {
+			t.Errorf("Wrong error for %s: expected %q got %q", test.src, test.err, err.Error())
+		}
+	}
+}
+
+func TestLeakCheck(t *testing.T) {
+	src := `type Point {int32, int32}
+	type Line {ptr<Point>, ptr<Point>}
+
+	fn main() : void {
+		%a = alloc Point : ptr<Point>
+		%b = alloc Point : ptr<Point>
+		%l = alloc Line : ptr<Line>
+		store %l, %a
+		store %l, %b, 1
+		retain %b
+		%c = alloc Point : ptr<Point>
+		release %c
+		release %l
+		return
+	}`
+
+	in := New(parseModule(t, src))
+	if err := in.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	in = New(parseModule(t, src))
+	in.LeakCheck = true
+	err := in.Run()
+	leakErr, ok := err.(*LeakError)
+	if !ok {
+		t.Fatalf("Expected a leak error got %v", err)
+	}
+
+	if err.Error() != "1 objects leaked: Point" {
+		t.Errorf("Wrong error %q", err.Error())
+	}
+	if leak := leakErr.Leaks[0]; leak.Refs() != 1 || leak.Freed() {
+		t.Errorf("Expected the leaked point to have one reference got %d", leak.Refs())
+	}
+}
@@ -0,0 +1,202 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"github.com/orktes/orlang/ir"
+)
+
+func binaryOp(operator string, left, right Value) (Value, error) {
+	switch operator {
+	case "==", "!=":
+		equal, err := equals(left, right)
+		if err != nil {
+			return nil, err
+		}
+		return equal == (operator == "=="), nil
+	case "<", ">", "<=", ">=":
+		cmp, err := compare(left, right)
+		if err != nil {
+			return nil, err
+		}
+		switch operator {
+		case "<":
+			return cmp < 0, nil
+		case ">":
+			return cmp > 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		}
+		return cmp >= 0, nil
+	}
+
+	typ, err := operandType(left, right)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ == ir.String {
+		if operator != "+" {
+			return nil, fmt.Errorf("operator %s is not defined on string", operator)
+		}
+		return left.(string) + right.(string), nil
+	}
+
+	switch {
+	case typ.IsFloat():
+		a, b := toFloat64(left), toFloat64(right)
+		var result float64
+		switch operator {
+		case "+":
+			result = a + b
+		case "-":
+			result = a - b
+		case "*":
+			result = a * b
+		case "/":
+			result = a / b
+		default:
+			return nil, fmt.Errorf("unknown operator %s", operator)
+		}
+		return convert(result, typ)
+	case isSigned(typ):
+		a, b := toInt64(left), toInt64(right)
+		var result int64
+		switch operator {
+		case "+":
+			result = a + b
+		case "-":
+			result = a - b
+		case "*":
+			result = a * b
+		case "/":
+			if b == 0 {
+				return nil, fmt.Errorf("integer division by zero")
+			}
+			result = a / b
+		default:
+			return nil, fmt.Errorf("unknown operator %s", operator)
+		}
+		return convert(result, typ)
+	case typ.IsInteger():
+		a, b := toUint64(left), toUint64(right)
+		var result uint64
+		switch operator {
+		case "+":
+			result = a + b
+		case "-":
+			result = a - b
+		case "*":
+			result = a * b
+		case "/":
+			if b == 0 {
+				return nil, fmt.Errorf("integer division by zero")
+			}
+			result = a / b
+		default:
+			return nil, fmt.Errorf("unknown operator %s", operator)
+		}
+		return convert(result, typ)
+	}
+
+	return nil, fmt.Errorf("operator %s is not defined on %s", operator, typ)
+}
+
+func operandType(left, right Value) (ir.PrimitiveType, error) {
+	leftType, leftOk := primitiveOf(left)
+	rightType, rightOk := primitiveOf(right)
+	if !leftOk || !rightOk || leftType != rightType {
+		return "", fmt.Errorf("mismatched operands %v and %v", left, right)
+	}
+	return leftType, nil
+}
+
+func equals(left, right Value) (bool, error) {
+	switch l := left.(type) {
+	case nil, *Pointer, ir.FunctionRef:
+		switch right.(type) {
+		case nil, *Pointer, ir.FunctionRef:
+			if left == nil || right == nil {
+				return isNull(left) && isNull(right), nil
+			}
+			return left == right, nil
+		}
+	case bool:
+		if r, ok := right.(bool); ok {
+			return l == r, nil
+		}
+	case float64, float32:
+		// Compared directly so that NaN is not equal to itself
+		if _, err := operandType(left, right); err != nil {
+			return false, err
+		}
+		return toFloat64(left) == toFloat64(right), nil
+	default:
+		cmp, err := compare(left, right)
+		return cmp == 0, err
+	}
+
+	return false, fmt.Errorf("mismatched operands %v and %v", left, right)
+}
+
+func isNull(value Value) bool {
+	ptr, ok := value.(*Pointer)
+	return value == nil || (ok && ptr == nil)
+}
+
+func compare(left, right Value) (int, error) {
+	typ, err := operandType(left, right)
+	if err != nil {
+		return 0, err
+	}
+
+	var less, greater bool
+	switch {
+	case typ == ir.String:
+		a, b := left.(string), right.(string)
+		less, greater = a < b, a > b
+	case typ.IsFloat():
+		a, b := toFloat64(left), toFloat64(right)
+		less, greater = a < b, a > b
+	case isSigned(typ):
+		a, b := toInt64(left), toInt64(right)
+		less, greater = a < b, a > b
+	case typ.IsInteger():
+		a, b := toUint64(left), toUint64(right)
+		less, greater = a < b, a > b
+	default:
+		return 0, fmt.Errorf("values of type %s can not be ordered", typ)
+	}
+
+	switch {
+	case less:
+		return -1, nil
+	case greater:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func unaryOp(operator string, value Value) (Value, error) {
+	switch operator {
+	case "not":
+		if b, ok := value.(bool); ok {
+			return !b, nil
+		}
+	case "neg":
+		typ, ok := primitiveOf(value)
+		switch {
+		case !ok:
+		case typ.IsFloat():
+			return convert(-toFloat64(value), typ)
+		case isSigned(typ):
+			return convert(-toInt64(value), typ)
+		case typ.IsInteger():
+			return convert(-toUint64(value), typ)
+		}
+	default:
+		return nil, fmt.Errorf("unknown operator %s", operator)
+	}
+
+	return nil, fmt.Errorf("operator %s is not defined on %v", operator, value)
+}
@@ -0,0 +1,239 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"github.com/orktes/orlang/ir"
+)
+
+// Value is a runtime value. Primitive values use the matching Go type
+// (int32 for int32, string for string etc.), pointers are *Pointer,
+// function references are ir.FunctionRef and null is nil
+type Value interface{}
+
+// Pointer refers to memory returned by alloc or to a global
+type Pointer struct {
+	Type   ir.Type
+	fields []Value
+	types  []ir.Type
+	freed  bool
+	// object is set for reference counted memory
+	object bool
+	refs   int
+	id     int
+}
+
+func (p *Pointer) String() string {
+	return fmt.Sprintf("ptr<%s>", p.Type)
+}
+
+// Freed returns true if the memory has been released with free or by
+// releasing the last reference to it
+func (p *Pointer) Freed() bool {
+	return p.freed
+}
+
+// Refs returns the reference count of an object
+func (p *Pointer) Refs() int {
+	return p.refs
+}
+
+func (p *Pointer) check(index int) error {
+	switch {
+	case p == nil:
+		return fmt.Errorf("nil pointer dereference")
+	case p.freed:
+		return fmt.Errorf("use of freed memory %s", p)
+	case index < 0 || index >= len(p.fields):
+		return fmt.Errorf("index %d out of range for %s", index, p)
+	}
+	return nil
+}
+
+// Load returns the value stored at index
+func (p *Pointer) Load(index int) (Value, error) {
+	if err := p.check(index); err != nil {
+		return nil, err
+	}
+	return p.fields[index], nil
+}
+
+// Store replaces the value stored at index
+func (p *Pointer) Store(index int, value Value) error {
+	if err := p.check(index); err != nil {
+		return err
+	}
+	p.fields[index] = value
+	return nil
+}
+
+func newPointer(module *ir.Module, typ ir.Type) *Pointer {
+	ptr := &Pointer{Type: typ}
+	if structType, ok := module.Underlying(typ).(*ir.StructType); ok {
+		ptr.types = structType.Fields
+	} else {
+		ptr.types = []ir.Type{typ}
+	}
+
+	ptr.fields = make([]Value, len(ptr.types))
+	for i, fieldType := range ptr.types {
+		ptr.fields[i] = zero(fieldType)
+	}
+	return ptr
+}
+
+// zero returns the zero value of typ. Pointers, function references and
+// structs stored inline are null
+func zero(typ ir.Type) Value {
+	if primitive, ok := typ.(ir.PrimitiveType); ok {
+		switch primitive {
+		case ir.Bool:
+			return false
+		case ir.String:
+			return ""
+		case ir.Void:
+			return nil
+		}
+		value, _ := convert(int64(0), primitive)
+		return value
+	}
+	return nil
+}
+
+// primitiveOf returns the IR type of a primitive Go value
+func primitiveOf(value Value) (ir.PrimitiveType, bool) {
+	switch value.(type) {
+	case int64:
+		return ir.Int64, true
+	case int32:
+		return ir.Int32, true
+	case int16:
+		return ir.Int16, true
+	case int8:
+		return ir.Int8, true
+	case uint64:
+		return ir.UInt64, true
+	case uint32:
+		return ir.UInt32, true
+	case uint16:
+		return ir.UInt16, true
+	case uint8:
+		return ir.UInt8, true
+	case float64:
+		return ir.Float64, true
+	case float32:
+		return ir.Float32, true
+	case bool:
+		return ir.Bool, true
+	case string:
+		return ir.String, true
+	}
+	return "", false
+}
+
+func isSigned(typ ir.PrimitiveType) bool {
+	switch typ {
+	case ir.Int64, ir.Int32, ir.Int16, ir.Int8:
+		return true
+	}
+	return false
+}
+
+func toInt64(value Value) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case int32:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case float64:
+		return int64(v)
+	case float32:
+		return int64(v)
+	}
+	return 0
+}
+
+func toUint64(value Value) uint64 {
+	switch v := value.(type) {
+	case uint64:
+		return v
+	case float64:
+		return uint64(v)
+	case float32:
+		return uint64(v)
+	}
+	return uint64(toInt64(value))
+}
+
+func toFloat64(value Value) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case uint32, uint16, uint8:
+		return float64(toUint64(v))
+	}
+	return float64(toInt64(value))
+}
+
+// convert converts a numeric value to typ. Integers wrap around like they
+// would in a fixed width register
+func convert(value Value, typ ir.PrimitiveType) (Value, error) {
+	from, ok := primitiveOf(value)
+	if !ok || !(from.IsInteger() || from.IsFloat()) {
+		if from == typ {
+			return value, nil
+		}
+		return nil, fmt.Errorf("cannot convert %v to %s", value, typ)
+	}
+
+	var (
+		i = toInt64(value)
+		u = toUint64(value)
+		f = toFloat64(value)
+	)
+
+	if from.IsInteger() && !isSigned(from) {
+		i = int64(u)
+	}
+
+	switch typ {
+	case ir.Int64:
+		return i, nil
+	case ir.Int32:
+		return int32(i), nil
+	case ir.Int16:
+		return int16(i), nil
+	case ir.Int8:
+		return int8(i), nil
+	case ir.UInt64:
+		return u, nil
+	case ir.UInt32:
+		return uint32(u), nil
+	case ir.UInt16:
+		return uint16(u), nil
+	case ir.UInt8:
+		return uint8(u), nil
+	case ir.Float64:
+		return f, nil
+	case ir.Float32:
+		return float32(f), nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %v to %s", value, typ)
+}
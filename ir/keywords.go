@@ -0,0 +1,41 @@
+package ir
+
+var keywords = []string{}
+
+var (
+	keywordType    = registerKeyword("type")
+	keywordGlobal  = registerKeyword("global")
+	keywordExtern  = registerKeyword("extern")
+	keywordFn      = registerKeyword("fn")
+	keywordPtr     = registerKeyword("ptr")
+	keywordAlloc   = registerKeyword("alloc")
+	keywordFree    = registerKeyword("free")
+	keywordRetain  = registerKeyword("retain")
+	keywordRelease = registerKeyword("release")
+	keywordLoad    = registerKeyword("load")
+	keywordStore   = registerKeyword("store")
+	keywordCall    = registerKeyword("call")
+	keywordCast    = registerKeyword("cast")
+	keywordNeg     = registerKeyword("neg")
+	keywordNot     = registerKeyword("not")
+	keywordReturn  = registerKeyword("return")
+	keywordBr      = registerKeyword("br")
+	keywordBrCond  = registerKeyword("br_cond")
+	keywordNull    = registerKeyword("null")
+)
+
+func registerKeyword(kw string) string {
+	keywords = append(keywords, kw)
+	return kw
+}
+
+// IsKeyword returns true if kw is reserved by the textual IR
+func IsKeyword(kw string) bool {
+	for _, keyword := range keywords {
+		if keyword == kw {
+			return true
+		}
+	}
+
+	return false
+}
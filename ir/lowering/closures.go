@@ -0,0 +1,244 @@
+package lowering
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/ir"
+	"github.com/orktes/orlang/types"
+)
+
+// Function values are closures: pointers to objects whose first field is the
+// function the closure calls. Every signature has a closure type holding a
+// function that takes the closure itself as its first argument. Nested and
+// anonymous functions are lifted and receive the closure as %env. Their
+// closures hold the boxes of the variables they capture after the function,
+// so captured variables live in boxes like variables whose address is taken.
+// Other functions are wrapped in a thunk ignoring %env. Calls through a
+// closure load the function from it and pass the closure along
+
+// collectClosures records the closures of the analysed files and the
+// variables they capture
+func (l *Lowering) collectClosures() {
+	for _, fileInfo := range l.analyserInfo.FileInfo {
+		for _, closure := range fileInfo.Closures {
+			l.closures[closure.FunctionDeclaration] = closure
+			for _, ident := range closure.Captures {
+				l.captured[ident] = true
+			}
+		}
+	}
+}
+
+// boxed returns true if the variable declared by ident lives in a box
+func (l *Lowering) boxed(ident *ast.Identifier) bool {
+	return l.addressTaken(ident) || l.captured[ident]
+}
+
+// closureType returns the type of closures of sig
+func (l *Lowering) closureType(sig *types.SignatureType, node ast.Node) ir.Type {
+	fn := &ir.FunctionType{Return: ir.Void}
+	for _, arg := range sig.ArgumentTypes {
+		fn.Params = append(fn.Params, l.irType(arg, node))
+	}
+	if sig.ReturnType != nil {
+		fn.Return = l.irType(sig.ReturnType, node)
+	}
+
+	key := fn.String()
+	name, ok := l.closureTypes[key]
+	if !ok {
+		base := irName(key)
+		name = base
+		for i := 1; l.module.TypeDeclaration(name) != nil; i++ {
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+		l.closureTypes[key] = name
+
+		fn.Params = append([]ir.Type{&ir.PointerType{Elem: ir.NamedType(name)}}, fn.Params...)
+		l.module.Types = append(l.module.Types, &ir.TypeDeclaration{Name: name, Type: &ir.StructType{Fields: []ir.Type{fn}}})
+	}
+
+	return &ir.PointerType{Elem: ir.NamedType(name)}
+}
+
+// closureFunction returns the type of the function called by closures of typ
+func (l *Lowering) closureFunction(typ ir.Type) *ir.FunctionType {
+	return l.structFields(typ)[0].(*ir.FunctionType)
+}
+
+// captures returns the identifiers declaring the variables captured by a
+// lifted function in the order they are stored in its closures. Closures
+// created by the function need the variables of the nested functions it
+// refers to as well
+func (l *Lowering) captures(decl *ast.FunctionDeclaration) []*ast.Identifier {
+	if idents, ok := l.captureLists[decl]; ok {
+		return idents
+	}
+
+	idents := []*ast.Identifier{}
+	seen := map[ast.Node]bool{}
+
+	var visit func(decl *ast.FunctionDeclaration)
+	visit = func(decl *ast.FunctionDeclaration) {
+		closure := l.closures[decl]
+		if seen[decl] || closure == nil {
+			return
+		}
+		seen[decl] = true
+
+		for i, item := range closure.Env {
+			if fn, ok := item.(*ast.FunctionDeclaration); ok {
+				if l.lifted[fn] {
+					visit(fn)
+				}
+				continue
+			}
+
+			ident := closure.Captures[i]
+			if !seen[ident] {
+				seen[ident] = true
+				idents = append(idents, ident)
+			}
+		}
+	}
+	visit(decl)
+
+	sort.Slice(idents, func(i, j int) bool {
+		a, b := idents[i].StartPos(), idents[j].StartPos()
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+
+	l.captureLists[decl] = idents
+	return idents
+}
+
+// envType returns the type of the closures of a lifted function
+func (l *Lowering) envType(decl *ast.FunctionDeclaration) ir.Type {
+	closureType := l.irType(l.signatureOf(decl), decl)
+
+	env := &ir.StructType{Fields: []ir.Type{l.closureFunction(closureType)}}
+	for _, ident := range l.captures(decl) {
+		typ, ok := l.variableTypes[ident]
+		if !ok {
+			l.error(decl, fmt.Sprintf("closure captures %s before it is declared", ident.Text))
+		}
+		env.Fields = append(env.Fields, &ir.PointerType{Elem: boxType(typ)})
+	}
+	return &ir.PointerType{Elem: env}
+}
+
+// declareEnv adds the closure parameter of a lifted function and loads the
+// boxes of the variables it captures in the prologue
+func (f *function) declareEnv(closureType ir.Type) {
+	l := f.lowering
+
+	env := f.register("env")
+	f.fn.Params = append(f.fn.Params, &ir.Param{Name: string(env), Type: closureType})
+
+	captures := l.captures(f.decl)
+	if len(captures) == 0 {
+		return
+	}
+
+	envType := l.envType(f.decl)
+	fields := l.structFields(envType)
+	concrete := f.temp()
+	f.prologue = append(f.prologue, &ir.Cast{Dest: concrete, Value: env, Type: envType})
+
+	for i, ident := range captures {
+		box := f.register(ident.Text)
+		f.prologue = append(f.prologue, &ir.Load{Dest: box, Ptr: concrete, Index: i + 1, Type: fields[i+1]})
+		f.variables[ident] = &variable{ptr: box, typ: l.variableTypes[ident]}
+	}
+}
+
+// closure returns a new closure of a lifted function holding the boxes of
+// the variables it captures
+func (f *function) closure(decl *ast.FunctionDeclaration) ir.Operand {
+	l := f.lowering
+	closureType := l.irType(l.signatureOf(decl), decl)
+
+	captures := l.captures(decl)
+	values := []ir.Operand{l.functionRef(decl)}
+	for _, ident := range captures {
+		values = append(values, f.box(ident, decl))
+	}
+
+	if len(captures) == 0 {
+		return f.alloc(closureType, values)
+	}
+
+	env := f.alloc(l.envType(decl), values)
+	dest := f.temp()
+	f.emit(&ir.Cast{Dest: dest, Value: env, Type: closureType})
+	return dest
+}
+
+// environment returns the closure passed to a direct call of a lifted
+// function. Functions capturing nothing are passed null
+func (f *function) environment(decl *ast.FunctionDeclaration) ir.Operand {
+	if len(f.lowering.captures(decl)) == 0 {
+		return ir.Constant{}
+	}
+	return f.closure(decl)
+}
+
+// box returns the box holding a captured variable
+func (f *function) box(ident *ast.Identifier, node ast.Node) ir.Operand {
+	v, ok := f.variables[ident]
+	if !ok {
+		f.lowering.error(node, fmt.Sprintf("closure captures %s before it is declared", ident.Text))
+	}
+	if v.cell != "" {
+		return f.load(location{ptr: v.cell, typ: v.cellType()})
+	}
+	return v.ptr
+}
+
+// functionValue returns a closure calling the function ref refers to. Decl
+// is nil for externs registered with the analyser
+func (f *function) functionValue(ref ir.FunctionRef, decl *ast.FunctionDeclaration, typ ir.Type) ir.Operand {
+	if decl != nil && f.lowering.lifted[decl] {
+		return f.closure(decl)
+	}
+	return f.alloc(typ, []ir.Operand{f.lowering.thunk(ref, typ)})
+}
+
+// callClosure calls the function of a closure
+func (f *function) callClosure(closure ir.Operand, closureType ir.Type, args []ir.Operand, typ ir.Type) ir.Operand {
+	fn := f.load(location{ptr: closure, typ: f.lowering.closureFunction(closureType)})
+	return f.call(fn, append([]ir.Operand{closure}, args...), typ)
+}
+
+// thunk returns a function with the signature of the function of closures
+// of closureType calling ref
+func (l *Lowering) thunk(ref ir.FunctionRef, closureType ir.Type) ir.FunctionRef {
+	if name, ok := l.thunks[ref]; ok {
+		return name
+	}
+
+	fnType := l.closureFunction(closureType)
+	f := l.newFunction(l.uniqueName(string(ref)+"_closure"), fnType.Return)
+	l.thunks[ref] = ir.FunctionRef(f.fn.Name)
+
+	var args []ir.Operand
+	for i, typ := range fnType.Params {
+		name := "env"
+		if i > 0 {
+			name = fmt.Sprintf("arg%d", i-1)
+		}
+		param := f.register(name)
+		f.fn.Params = append(f.fn.Params, &ir.Param{Name: string(param), Type: typ})
+		if i > 0 {
+			args = append(args, param)
+		}
+	}
+
+	value := f.call(ref, args, fnType.Return)
+	f.emitReturn(value)
+	l.module.Functions = append(l.module.Functions, f.fn)
+
+	return ir.FunctionRef(f.fn.Name)
+}
@@ -0,0 +1,423 @@
+package lowering
+
+import (
+	"fmt"
+
+	"github.com/orktes/orlang/analyser"
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/ir"
+	"github.com/orktes/orlang/scanner"
+	"github.com/orktes/orlang/types"
+)
+
+type location struct {
+	ptr   ir.Operand
+	index int
+	typ   ir.Type
+}
+
+func (f *function) lowerValue(expr ast.Expression) ir.Operand {
+	value := f.lowerExpression(expr)
+	if value == nil {
+		f.lowering.error(expr, fmt.Sprintf("%s (no value) used as value", expr))
+	}
+	return value
+}
+
+func (f *function) lowerValues(exprs []ast.Expression) []ir.Operand {
+	values := make([]ir.Operand, len(exprs))
+	for i, expr := range exprs {
+		if expr != nil {
+			values[i] = f.lowerValue(expr)
+		}
+	}
+	return values
+}
+
+// lowerExpression emits the instructions for expr and returns the operand
+// holding its value. Nil is returned for void function calls
+func (f *function) lowerExpression(expr ast.Expression) ir.Operand {
+	l := f.lowering
+
+	switch n := expr.(type) {
+	case *ast.ValueExpression:
+		dest := f.temp()
+		f.emit(&ir.Assign{Dest: dest, Value: ir.Constant{Value: n.Token.Value}, Type: l.typeOf(n)})
+		return dest
+	case *ast.Identifier:
+		return f.lowerIdentifier(n)
+	case *ast.ParenExpression:
+		return f.lowerExpression(n.Expression)
+	case *ast.BinaryExpression:
+		if overload := l.getNodeInfo(n).OverloadedOperation; overload != nil {
+			var args []ir.Operand
+			if l.lifted[overload] {
+				args = append(args, f.environment(overload))
+			}
+			args = append(args, f.lowerStoredValue(n.Left), f.lowerStoredValue(n.Right))
+			return f.call(l.functionRef(overload), args, l.typeOf(n))
+		}
+
+		left, right := f.lowerValue(n.Left), f.lowerValue(n.Right)
+		dest := f.temp()
+		f.emit(&ir.BinaryOp{Dest: dest, Operator: n.Operator.Text, Left: left, Right: right, Type: l.typeOf(n)})
+		return dest
+	case *ast.ComparisonExpression:
+		left, right := f.lowerValue(n.Left), f.lowerValue(n.Right)
+		dest := f.temp()
+		f.emit(&ir.BinaryOp{Dest: dest, Operator: n.Operator.Text, Left: left, Right: right, Type: ir.Bool})
+		return dest
+	case *ast.UnaryExpression:
+		return f.lowerUnaryExpression(n)
+	case *ast.Assigment:
+		loc := f.lowerLocation(n.Left)
+		value := f.lowerStoredValue(n.Right)
+		f.emit(&ir.Store{Ptr: loc.ptr, Value: value, Index: loc.index})
+		return value
+	case *ast.FunctionCall:
+		return f.lowerFunctionCall(n)
+	case *ast.MemberExpression:
+		loc, ok := f.lowerField(n)
+		if !ok {
+			l.error(n, fmt.Sprintf("method value %s is not supported by the IR", n.Property.Text))
+		}
+		return f.load(loc)
+	case *ast.TupleExpression:
+		return f.alloc(l.typeOf(n), f.lowerStoredValues(n.Expressions))
+	case *ast.StructExpression:
+		return f.lowerStructExpression(n)
+	case *ast.FunctionDeclaration:
+		return f.functionValue(l.functionRef(n), n, l.typeOf(n))
+	}
+
+	l.error(expr, fmt.Sprintf("expression %s is not supported by the IR", expr))
+	return nil
+}
+
+func (f *function) lowerIdentifier(n *ast.Identifier) ir.Operand {
+	l := f.lowering
+
+	if n.Text == "this" && f.this != "" {
+		return f.this
+	}
+
+	if ref, decl, ok := f.functionRef(n); ok {
+		return f.functionValue(ref, decl, l.typeOf(n))
+	}
+
+	return f.load(f.lowerLocation(n))
+}
+
+// functionRef returns a reference to the function or extern ident refers to
+// and its declaration. The declaration is nil for externs registered with
+// the analyser
+func (f *function) functionRef(n *ast.Identifier) (ref ir.FunctionRef, decl *ast.FunctionDeclaration, ok bool) {
+	l := f.lowering
+
+	if instance, ok := l.getNodeInfo(n).Instance.(*ast.FunctionDeclaration); ok {
+		return l.functionRef(instance), instance, true
+	}
+
+	details := l.getNodeInfo(n).Scope.LookupDetails(n, true)
+	if details == nil {
+		l.error(n, fmt.Sprintf("undefined: %s", n))
+	}
+
+	switch item := details.ScopeItem.(type) {
+	case *ast.FunctionDeclaration:
+		return l.functionRef(item), item, true
+	case *analyser.CustomTypeResolvingScopeItem:
+		// Items without a node are externals registered with Analyser.AddExternalFunc
+		if sig, isSig := item.ResolvedType.(*types.SignatureType); isSig && item.Node == nil {
+			return l.declareExtern(details.DefineIdentifier.Text, sig, n), nil, true
+		}
+	}
+
+	return "", nil, false
+}
+
+func (f *function) lowerLocation(expr ast.Expression) location {
+	switch n := expr.(type) {
+	case *ast.Identifier:
+		details := f.lowering.getNodeInfo(n).Scope.LookupDetails(n, true)
+		if details != nil {
+			return f.location(f.variable(details.DefineIdentifier, n))
+		}
+	case *ast.MemberExpression:
+		if loc, ok := f.lowerField(n); ok {
+			return loc
+		}
+	case *ast.ParenExpression:
+		return f.lowerLocation(n.Expression)
+	case *ast.UnaryExpression:
+		if n.Operator.Type == scanner.TokenTypeASTERISK {
+			return f.lowerDereference(n)
+		}
+	}
+
+	f.lowering.error(expr, fmt.Sprintf("cannot assign to %s", expr))
+	return location{}
+}
+
+// lowerField returns the location of a struct field. Ok is false for methods
+func (f *function) lowerField(n *ast.MemberExpression) (loc location, ok bool) {
+	structType, isStruct := f.memberTargetType(n.Target).(*types.StructType)
+	if !isStruct {
+		return
+	}
+
+	for i, field := range structType.Variables {
+		if field.Name == n.Property.Text {
+			return location{
+				ptr:   f.lowerMemberTarget(n.Target),
+				index: i,
+				typ:   f.lowering.irType(field.Type, n),
+			}, true
+		}
+	}
+
+	return
+}
+
+func (f *function) load(loc location) ir.Register {
+	dest := f.temp()
+	f.emit(&ir.Load{Dest: dest, Ptr: loc.ptr, Index: loc.index, Type: loc.typ})
+	return dest
+}
+
+func (f *function) store(loc location, value ir.Operand) {
+	f.emit(&ir.Store{Ptr: loc.ptr, Value: value, Index: loc.index})
+}
+
+func (f *function) alloc(typ ir.Type, values []ir.Operand) ir.Register {
+	dest := f.temp()
+	f.emit(&ir.Alloc{Dest: dest, AllocType: typ.(*ir.PointerType).Elem, Type: typ})
+	for i, value := range values {
+		// Missing values are left zero initialized
+		if value != nil {
+			f.emit(&ir.Store{Ptr: dest, Value: value, Index: i})
+		}
+	}
+	return dest
+}
+
+func (f *function) call(callee ir.Operand, args []ir.Operand, typ ir.Type) ir.Operand {
+	if typ == ir.Void {
+		f.emit(&ir.Call{Callee: callee, Arguments: args, Type: typ})
+		return nil
+	}
+
+	dest := f.temp()
+	f.emit(&ir.Call{Dest: dest, Callee: callee, Arguments: args, Type: typ})
+	return dest
+}
+
+func (f *function) lowerUnaryExpression(n *ast.UnaryExpression) ir.Operand {
+	l := f.lowering
+	typ := l.typeOf(n)
+
+	switch n.Operator.Type {
+	case scanner.TokenTypeAMPERSAND:
+		return f.lowerAddressOf(n)
+	case scanner.TokenTypeASTERISK:
+		return f.load(f.lowerDereference(n))
+	case scanner.TokenTypeADD:
+		return f.lowerValue(n.Expression)
+	case scanner.TokenTypeSUB, scanner.TokenTypeEXCL:
+		operator := "neg"
+		if n.Operator.Type == scanner.TokenTypeEXCL {
+			operator = "not"
+		}
+
+		value := f.lowerValue(n.Expression)
+		dest := f.temp()
+		f.emit(&ir.UnaryOp{Dest: dest, Operator: operator, Value: value, Type: typ})
+		return dest
+	case scanner.TokenTypeIncrement, scanner.TokenTypeDecrement:
+		var one interface{} = int64(1)
+		if primitive, ok := typ.(ir.PrimitiveType); ok && primitive.IsFloat() {
+			one = float64(1)
+		}
+
+		operator := "+"
+		if n.Operator.Type == scanner.TokenTypeDecrement {
+			operator = "-"
+		}
+
+		loc := f.lowerLocation(n.Expression)
+		old := f.load(loc)
+		dest := f.temp()
+		f.emit(&ir.BinaryOp{Dest: dest, Operator: operator, Left: old, Right: ir.Constant{Value: one}, Type: typ})
+		f.emit(&ir.Store{Ptr: loc.ptr, Value: dest, Index: loc.index})
+
+		if n.Postfix {
+			return old
+		}
+		return dest
+	}
+
+	l.error(n, fmt.Sprintf("unary operator %s is not supported by the IR", n.Operator.Text))
+	return nil
+}
+
+func (f *function) lowerFunctionCall(n *ast.FunctionCall) ir.Operand {
+	l := f.lowering
+
+	if l.getNodeInfo(n).TypeCast {
+		expr := n.Arguments[0].Expression
+		value := f.lowerValue(expr)
+		typ := l.typeOf(n)
+		if typ == l.typeOf(expr) {
+			return value
+		}
+
+		dest := f.temp()
+		f.emit(&ir.Cast{Dest: dest, Value: value, Type: typ})
+		return dest
+	}
+
+	var (
+		callee ir.Operand
+		decl   *ast.FunctionDeclaration
+		args   []ir.Operand
+	)
+
+	switch c := n.Callee.(type) {
+	case *ast.Identifier:
+		if c.Text == "this" {
+			break
+		}
+		if ref, fn, ok := f.functionRef(c); ok {
+			callee, decl = ref, fn
+			if decl != nil && l.lifted[decl] {
+				args = append(args, f.environment(decl))
+			}
+		}
+	case *ast.MemberExpression:
+		if method := f.method(c); method != nil {
+			callee = l.functionRef(method)
+			decl = method
+			args = append(args, f.lowerMemberTarget(c.Target))
+		}
+	}
+
+	sig := l.signatureOf(n.Callee)
+	var closure ir.Operand
+	if callee == nil {
+		closure = f.lowerValue(n.Callee)
+	}
+
+	exprs := make([]ast.Expression, len(sig.ArgumentTypes))
+	for i, arg := range n.Arguments {
+		if arg.Name != nil {
+			i = indexOf(sig.ArgumentNames, arg.Name.Text)
+		}
+		if i >= len(exprs) {
+			// Arguments passed to the variadic part of an extern
+			exprs = append(exprs, arg.Expression)
+			continue
+		}
+		exprs[i] = arg.Expression
+	}
+
+	for i, expr := range exprs {
+		if expr != nil {
+			continue
+		}
+		if decl == nil || decl.Signature.Arguments[i].DefaultValue == nil {
+			l.error(n, fmt.Sprintf("missing argument %s in call to %s", sig.ArgumentNames[i], n.Callee))
+		}
+		exprs[i] = decl.Signature.Arguments[i].DefaultValue
+	}
+
+	args = append(args, f.lowerStoredValues(exprs)...)
+
+	if closure != nil {
+		return f.callClosure(closure, l.typeOf(n.Callee), args, l.irType(sig.ReturnType, n))
+	}
+	return f.call(callee, args, l.irType(sig.ReturnType, n))
+}
+
+// memberTargetType returns the type whose members a member expression on
+// target accesses. Pointers to structs are dereferenced automatically
+func (f *function) memberTargetType(target ast.Expression) types.Type {
+	typ := f.lowering.getType(target)
+	if pointerType, ok := typ.(*types.PointerType); ok {
+		return types.LazyResolve(pointerType.Elem)
+	}
+	return typ
+}
+
+// lowerMemberTarget returns the struct a member expression on target accesses
+func (f *function) lowerMemberTarget(target ast.Expression) ir.Operand {
+	value := f.lowerValue(target)
+	if _, ok := f.lowering.getType(target).(*types.PointerType); ok {
+		return f.load(location{ptr: value, typ: f.lowering.irType(f.memberTargetType(target), target)})
+	}
+	return value
+}
+
+// method returns the declaration of the struct method n refers to
+func (f *function) method(n *ast.MemberExpression) *ast.FunctionDeclaration {
+	structType, ok := f.memberTargetType(n.Target).(*types.StructType)
+	if !ok {
+		return nil
+	}
+
+	structDecl := f.lowering.structDecl(structType)
+	if structDecl == nil {
+		return nil
+	}
+
+	for _, fn := range structDecl.Functions {
+		if fn.Signature.Identifier != nil && fn.Signature.Identifier.Text == n.Property.Text {
+			return fn
+		}
+	}
+
+	return nil
+}
+
+func (f *function) lowerStructExpression(n *ast.StructExpression) ir.Operand {
+	l := f.lowering
+
+	structType, ok := l.getType(n).(*types.StructType)
+	if !ok {
+		l.error(n, fmt.Sprintf("%s is not a struct", n.Identifier))
+	}
+
+	structDecl := l.structDecl(structType)
+	if structDecl == nil {
+		l.error(n, fmt.Sprintf("%s is not a struct", n.Identifier))
+	}
+
+	names := make([]string, len(structType.Variables))
+	for i, field := range structType.Variables {
+		names[i] = field.Name
+	}
+
+	exprs := make([]ast.Expression, len(names))
+	for i, arg := range n.Arguments {
+		if arg.Name != nil {
+			i = indexOf(names, arg.Name.Text)
+		}
+		exprs[i] = arg.Expression
+	}
+
+	for i, expr := range exprs {
+		if expr == nil {
+			exprs[i] = structDecl.Variables[i].DefaultValue
+		}
+	}
+
+	return f.alloc(l.typeOf(n), f.lowerStoredValues(exprs))
+}
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,422 @@
+package lowering
+
+import (
+	"fmt"
+
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/ir"
+)
+
+type function struct {
+	lowering  *Lowering
+	decl      *ast.FunctionDeclaration
+	fn        *ir.Function
+	block     *ir.Block
+	prologue  []ir.Instruction
+	variables map[*ast.Identifier]*variable
+	slots     []ir.Register
+	boxes     []ir.Register
+	registers map[string]bool
+	counters  map[string]int
+	this      ir.Register
+}
+
+func (l *Lowering) newFunction(name string, returnType ir.Type) *function {
+	f := &function{
+		lowering:  l,
+		fn:        &ir.Function{Name: name, ReturnType: returnType},
+		block:     &ir.Block{},
+		variables: map[*ast.Identifier]*variable{},
+		registers: map[string]bool{},
+		counters:  map[string]int{},
+	}
+	f.fn.Blocks = []*ir.Block{f.block}
+
+	for _, global := range l.module.Globals {
+		f.registers[global.Name] = true
+	}
+	return f
+}
+
+func (l *Lowering) lowerFunction(decl *ast.FunctionDeclaration) {
+	sig := l.signatureOf(decl)
+
+	f := l.newFunction(l.functionNames[decl], l.irType(sig.ReturnType, decl.Signature))
+	f.decl = decl
+
+	if l.lifted[decl] {
+		f.declareEnv(l.irType(sig, decl))
+	}
+
+	if structDecl, ok := l.methods[decl]; ok && decl.Signature.Identifier != nil {
+		f.this = f.register("this")
+		f.fn.Params = append(f.fn.Params, &ir.Param{Name: string(f.this), Type: l.typeOf(structDecl)})
+	}
+
+	for i, arg := range decl.Signature.Arguments {
+		typ := l.irType(sig.ArgumentTypes[i], arg)
+		param := f.register(arg.Name.Text)
+		f.fn.Params = append(f.fn.Params, &ir.Param{Name: string(param), Type: typ})
+
+		v := f.declareVariable(arg.Name, typ)
+		if v.cell != "" {
+			f.prologue = append(f.prologue, f.newBox(v)...)
+			value := f.temp()
+			f.prologue = append(f.prologue,
+				&ir.Load{Dest: value, Ptr: v.cell, Type: v.cellType()},
+				&ir.Store{Ptr: value, Value: param},
+			)
+			continue
+		}
+		f.prologue = append(f.prologue, &ir.Store{Ptr: v.ptr, Value: param})
+	}
+
+	if f.fn.Name == "main" && l.isTopLevel(decl) {
+		for _, init := range l.globalInits {
+			l.currentFile = l.files[init]
+			f.lowerNode(init)
+		}
+		l.currentFile = l.mainFile
+	}
+
+	f.lowerBlock(decl.Block)
+
+	if !f.terminated() && f.fn.ReturnType == ir.Void {
+		f.emitReturn(nil)
+	}
+
+	f.removeUnreachableBlocks()
+
+	for _, block := range f.fn.Blocks {
+		if !terminated(block) {
+			l.error(decl, fmt.Sprintf("missing return at the end of function %s", f.fn.Name))
+		}
+	}
+
+	f.freeVariables()
+	f.fn.Blocks[0].Instructions = append(f.prologue, f.fn.Blocks[0].Instructions...)
+	l.module.Functions = append(l.module.Functions, f.fn)
+}
+
+func (l *Lowering) isTopLevel(decl *ast.FunctionDeclaration) bool {
+	for _, node := range l.mainFile.Body {
+		if node == decl {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *function) register(base string) ir.Register {
+	name := base
+	for i := 1; f.registers[name]; i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	f.registers[name] = true
+	return ir.Register(name)
+}
+
+func (f *function) temp() ir.Register {
+	for {
+		name := fmt.Sprintf("temp%d", f.count("temp"))
+		if !f.registers[name] {
+			f.registers[name] = true
+			return ir.Register(name)
+		}
+	}
+}
+
+func (f *function) count(name string) int {
+	n := f.counters[name]
+	f.counters[name]++
+	return n
+}
+
+func (f *function) labels(base string, names ...string) []string {
+	n := f.count(base)
+	labels := make([]string, len(names))
+	for i, name := range names {
+		labels[i] = fmt.Sprintf("%s%d_%s", base, n, name)
+	}
+	return labels
+}
+
+// declareVariable allocates the storage of a variable in the prologue.
+// Variables whose address is taken or that are captured by a closure live
+// in a box. Boxes of variables that
+// do not escape are released on return. Variables that escape get a cell
+// that holds the box created by the latest execution of the declaration
+func (f *function) declareVariable(ident *ast.Identifier, typ ir.Type) *variable {
+	l := f.lowering
+	v := &variable{typ: typ}
+	l.variableTypes[ident] = typ
+
+	switch {
+	case l.boxed(ident) && l.escapes(ident):
+		v.cell = f.register(ident.Text)
+		f.prologue = append(f.prologue, &ir.Alloc{Dest: v.cell, AllocType: v.cellType(), Type: &ir.PointerType{Elem: v.cellType()}})
+		f.slots = append(f.slots, v.cell)
+	case l.boxed(ident):
+		v.ptr = f.register(ident.Text)
+		f.prologue = append(f.prologue, &ir.Alloc{Dest: v.ptr, AllocType: boxType(typ), Type: v.cellType()})
+		f.boxes = append(f.boxes, v.ptr)
+	default:
+		v.ptr = f.register(ident.Text)
+		f.prologue = append(f.prologue, &ir.Alloc{Dest: v.ptr, AllocType: typ, Type: &ir.PointerType{Elem: typ}})
+		f.slots = append(f.slots, v.ptr)
+	}
+
+	f.variables[ident] = v
+	return v
+}
+
+// variableFor returns the storage for a declaration. Globals are declared up
+// front. Every execution of the declaration of a variable with a cell
+// creates a new box
+func (f *function) variableFor(ident *ast.Identifier, typ ir.Type) *variable {
+	v, ok := f.lowering.globals[ident]
+	if !ok {
+		v = f.declareVariable(ident, typ)
+	}
+
+	if v.cell != "" {
+		for _, instr := range f.newBox(v) {
+			f.emit(instr)
+		}
+	}
+	return v
+}
+
+// newBox returns the instructions storing a new box in the cell of v
+func (f *function) newBox(v *variable) []ir.Instruction {
+	box := f.temp()
+	return []ir.Instruction{
+		&ir.Alloc{Dest: box, AllocType: boxType(v.typ), Type: v.cellType()},
+		&ir.Store{Ptr: v.cell, Value: box},
+	}
+}
+
+// location returns the location of the value of v
+func (f *function) location(v *variable) location {
+	if v.cell != "" {
+		return location{ptr: f.load(location{ptr: v.cell, typ: v.cellType()}), typ: v.typ}
+	}
+	return location{ptr: v.ptr, typ: v.typ}
+}
+
+func (f *function) variable(ident *ast.Identifier, ref ast.Node) *variable {
+	if v, ok := f.variables[ident]; ok {
+		return v
+	}
+	if global, ok := f.lowering.globals[ident]; ok {
+		return global
+	}
+
+	f.lowering.error(ref, fmt.Sprintf("undefined: %s", ident.Text))
+	return nil
+}
+
+func terminated(block *ir.Block) bool {
+	n := len(block.Instructions)
+	return n > 0 && ir.IsTerminator(block.Instructions[n-1])
+}
+
+func (f *function) terminated() bool {
+	return terminated(f.block)
+}
+
+func (f *function) emit(instr ir.Instruction) {
+	if f.terminated() {
+		// Code after a terminator is unreachable but still needs a block
+		f.startBlock(fmt.Sprintf("unreachable%d", f.count("unreachable")))
+	}
+	f.block.Instructions = append(f.block.Instructions, instr)
+}
+
+func (f *function) startBlock(label string) {
+	f.block = &ir.Block{Label: label}
+	f.fn.Blocks = append(f.fn.Blocks, f.block)
+}
+
+func (f *function) branch(label string) {
+	if !f.terminated() {
+		f.emit(&ir.Br{Label: label})
+	}
+}
+
+func (f *function) emitReturn(value ir.Operand) {
+	if value == nil {
+		f.emit(&ir.Return{})
+		return
+	}
+	f.emit(&ir.Return{Value: value, Type: f.fn.ReturnType})
+}
+
+// freeVariables frees slots and releases boxes before every return
+func (f *function) freeVariables() {
+	for _, block := range f.fn.Blocks {
+		last := len(block.Instructions) - 1
+		if _, ok := block.Instructions[last].(*ir.Return); !ok {
+			continue
+		}
+
+		instructions := append([]ir.Instruction{}, block.Instructions[:last]...)
+		for _, slot := range f.slots {
+			instructions = append(instructions, &ir.Free{Ptr: slot})
+		}
+		for _, box := range f.boxes {
+			instructions = append(instructions, &ir.Release{Ptr: box})
+		}
+		block.Instructions = append(instructions, block.Instructions[last])
+	}
+}
+
+func (f *function) removeUnreachableBlocks() {
+	reachable := map[string]bool{}
+
+	var visit func(block *ir.Block)
+	visit = func(block *ir.Block) {
+		if block == nil || reachable[block.Label] {
+			return
+		}
+		reachable[block.Label] = true
+
+		if len(block.Instructions) == 0 {
+			return
+		}
+		switch instr := block.Instructions[len(block.Instructions)-1].(type) {
+		case *ir.Br:
+			visit(f.fn.Block(instr.Label))
+		case *ir.BrCond:
+			visit(f.fn.Block(instr.True))
+			visit(f.fn.Block(instr.False))
+		}
+	}
+	visit(f.fn.Blocks[0])
+
+	blocks := f.fn.Blocks[:0]
+	for _, block := range f.fn.Blocks {
+		if reachable[block.Label] {
+			blocks = append(blocks, block)
+		}
+	}
+	f.fn.Blocks = blocks
+}
+
+func (f *function) lowerBlock(block *ast.Block) {
+	for _, node := range block.Body {
+		f.lowerNode(node)
+	}
+}
+
+func (f *function) lowerNode(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.Block:
+		f.lowerBlock(n)
+	case *ast.VariableDeclaration:
+		v := f.variableFor(n.Name, f.lowering.typeOf(n))
+		var value ir.Operand = zeroValue(v.typ)
+		if n.DefaultValue != nil {
+			value = f.lowerStoredValue(n.DefaultValue)
+		}
+		f.store(f.location(v), value)
+	case *ast.TupleDeclaration:
+		f.lowerPattern(n.Pattern, f.lowerValue(n.DefaultValue), f.lowering.typeOf(n))
+	case *ast.IfStatement:
+		f.lowerIfStatement(n)
+	case *ast.ForLoop:
+		f.lowerForLoop(n)
+	case *ast.ReturnStatement:
+		if n.Expression == nil {
+			f.emitReturn(nil)
+			break
+		}
+		f.emitReturn(f.lowerStoredValue(n.Expression))
+	case *ast.FunctionDeclaration, *ast.Macro:
+		// Functions are lowered separately
+	case ast.Expression:
+		f.lowerExpression(n)
+	default:
+		f.lowering.error(node, "statement is not supported by the IR")
+	}
+}
+
+func (f *function) lowerPattern(pattern *ast.TuplePattern, tuple ir.Operand, typ ir.Type) {
+	fields := f.lowering.structFields(typ)
+	for i, pat := range pattern.Patterns {
+		value := f.temp()
+		f.emit(&ir.Load{Dest: value, Ptr: tuple, Index: i, Type: fields[i]})
+
+		switch p := pat.(type) {
+		case *ast.Identifier:
+			v := f.variableFor(p, fields[i])
+			f.store(f.location(v), value)
+		case *ast.TuplePattern:
+			f.lowerPattern(p, value, fields[i])
+		}
+	}
+}
+
+func (f *function) lowerIfStatement(n *ast.IfStatement) {
+	labels := f.labels("if", "then", "else", "end")
+	then, els, end := labels[0], labels[1], labels[2]
+	if n.Else == nil {
+		els = end
+	}
+
+	f.emit(&ir.BrCond{Condition: f.lowerValue(n.Condition), True: then, False: els})
+
+	f.startBlock(then)
+	f.lowerBlock(n.Block)
+	f.branch(end)
+
+	if n.Else != nil {
+		f.startBlock(els)
+		f.lowerBlock(n.Else)
+		f.branch(end)
+	}
+
+	f.startBlock(end)
+}
+
+func (f *function) lowerForLoop(n *ast.ForLoop) {
+	labels := f.labels("for", "cond", "body", "end")
+	cond, body, end := labels[0], labels[1], labels[2]
+
+	if n.Init != nil {
+		f.lowerNode(n.Init)
+	}
+
+	f.branch(cond)
+	f.startBlock(cond)
+	if n.Condition != nil {
+		f.emit(&ir.BrCond{Condition: f.lowerValue(n.Condition), True: body, False: end})
+	} else {
+		f.emit(&ir.Br{Label: body})
+	}
+
+	f.startBlock(body)
+	f.lowerBlock(n.Block)
+	if n.After != nil {
+		f.lowerNode(n.After)
+	}
+	f.branch(cond)
+
+	f.startBlock(end)
+}
+
+func zeroValue(typ ir.Type) ir.Constant {
+	if primitive, ok := typ.(ir.PrimitiveType); ok {
+		switch {
+		case primitive.IsInteger():
+			return ir.Constant{Value: int64(0)}
+		case primitive.IsFloat():
+			return ir.Constant{Value: float64(0)}
+		case primitive == ir.Bool:
+			return ir.Constant{Value: false}
+		case primitive == ir.String:
+			return ir.Constant{Value: ""}
+		}
+	}
+	return ir.Constant{}
+}
@@ -0,0 +1,459 @@
+package lowering
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/orktes/orlang/analyser"
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/ir"
+	"github.com/orktes/orlang/types"
+)
+
+var operatorNames = map[string]string{
+	"+": "add",
+	"-": "sub",
+	"*": "mul",
+	"/": "div",
+}
+
+// Error is returned when an analysed file can not be lowered into the IR
+type Error struct {
+	ast.Position
+	Filename string
+	Message  string
+}
+
+func (e *Error) Error() string {
+	if e.Filename != "" {
+		return fmt.Sprintf("%s:%d:%d: %s", e.Filename, e.Position.Line+1, e.Position.Column+1, e.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s", e.Position.Line+1, e.Position.Column+1, e.Message)
+}
+
+// Lowering turns analysed orlang ASTs into IR modules
+type Lowering struct {
+	analyserInfo  *analyser.Info
+	mainFile      *ast.File
+	currentFile   *ast.File
+	module        *ir.Module
+	names         map[string]bool
+	globalNames   map[string]bool
+	typeNames     map[*types.StructType]string
+	files         map[ast.Node]*ast.File
+	functions     []*ast.FunctionDeclaration
+	functionNames map[*ast.FunctionDeclaration]string
+	methods       map[*ast.FunctionDeclaration]*ast.Struct
+	globals       map[*ast.Identifier]*variable
+	globalInits   []ast.Node
+	// lifted are the nested and anonymous functions taking a closure
+	lifted        map[*ast.FunctionDeclaration]bool
+	closures      map[*ast.FunctionDeclaration]*analyser.Closure
+	captured      map[*ast.Identifier]bool
+	captureLists  map[*ast.FunctionDeclaration][]*ast.Identifier
+	closureTypes  map[string]string
+	thunks        map[ir.FunctionRef]ir.FunctionRef
+	variableTypes map[*ast.Identifier]ir.Type
+}
+
+type variable struct {
+	ptr ir.Register
+	typ ir.Type
+	// cell holds the box of a variable that escapes. Ptr is unused
+	cell ir.Register
+}
+
+// cellType is the type of the box a variable lives in
+func (v *variable) cellType() ir.Type {
+	return &ir.PointerType{Elem: boxType(v.typ)}
+}
+
+// New returns a new Lowering for the analysed files in info
+func New(info *analyser.Info) *Lowering {
+	return &Lowering{analyserInfo: info}
+}
+
+// Lower lowers files into a single IR module. Files must be in the order they were
+// analysed and the last file is the main file. Global variables are initialized at
+// the start of main in file order
+func (l *Lowering) Lower(files ...*ast.File) (module *ir.Module, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			lowerErr, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			module, err = nil, lowerErr
+		}
+	}()
+
+	l.mainFile = files[len(files)-1]
+	l.module = &ir.Module{}
+	l.names = map[string]bool{}
+	l.globalNames = map[string]bool{}
+	l.typeNames = map[*types.StructType]string{}
+	l.files = map[ast.Node]*ast.File{}
+	l.functions = nil
+	l.functionNames = map[*ast.FunctionDeclaration]string{}
+	l.methods = map[*ast.FunctionDeclaration]*ast.Struct{}
+	l.globals = map[*ast.Identifier]*variable{}
+	l.globalInits = nil
+	l.lifted = map[*ast.FunctionDeclaration]bool{}
+	l.closures = map[*ast.FunctionDeclaration]*analyser.Closure{}
+	l.captured = map[*ast.Identifier]bool{}
+	l.captureLists = map[*ast.FunctionDeclaration][]*ast.Identifier{}
+	l.closureTypes = map[string]string{}
+	l.thunks = map[ir.FunctionRef]ir.FunctionRef{}
+	l.variableTypes = map[*ast.Identifier]ir.Type{}
+
+	l.collectClosures()
+	l.declareFunctions(files)
+	for _, file := range files {
+		l.currentFile = file
+		l.declareGlobals(file)
+	}
+
+	for _, decl := range l.functions {
+		l.currentFile = l.files[decl]
+		if decl.Signature.Extern {
+			l.functionRef(decl)
+			continue
+		}
+		l.lowerFunction(decl)
+	}
+
+	if len(l.globalInits) > 0 && l.module.Function("main") == nil {
+		l.currentFile = l.files[l.globalInits[0]]
+		l.error(l.globalInits[0], "global variables can only be initialized with a main function")
+	}
+
+	return l.module, nil
+}
+
+func (l *Lowering) error(node ast.Node, msg string) {
+	err := &Error{Position: node.StartPos(), Message: msg}
+	if l.currentFile != nil {
+		err.Filename = l.currentFile.Filename
+	}
+	panic(err)
+}
+
+func (l *Lowering) getNodeInfo(node ast.Node) *analyser.NodeInfo {
+	return l.analyserInfo.FileInfo[l.currentFile].NodeInfo[node]
+}
+
+func (l *Lowering) getType(node ast.Node) types.Type {
+	nodeInfo := l.getNodeInfo(node)
+	if nodeInfo == nil || nodeInfo.Type == nil {
+		l.error(node, "could not resolve type")
+	}
+	return types.LazyResolve(nodeInfo.Type)
+}
+
+func (l *Lowering) typeOf(node ast.Node) ir.Type {
+	return l.irType(l.getType(node), node)
+}
+
+func (l *Lowering) signatureOf(node ast.Node) *types.SignatureType {
+	sig, ok := l.getType(node).(*types.SignatureType)
+	if !ok {
+		l.error(node, fmt.Sprintf("%s is not a function", node))
+	}
+	return sig
+}
+
+func (l *Lowering) irType(typ types.Type, node ast.Node) ir.Type {
+	switch t := types.LazyResolve(typ).(type) {
+	case types.PrimitiveType:
+		return ir.PrimitiveType(t.Type)
+	case *types.TupleType:
+		tuple := &ir.StructType{}
+		for _, field := range t.Types {
+			tuple.Fields = append(tuple.Fields, l.irType(field, node))
+		}
+		return &ir.PointerType{Elem: tuple}
+	case *types.StructType:
+		return &ir.PointerType{Elem: ir.NamedType(l.declareStruct(t, node))}
+	case *types.PointerType:
+		return &ir.PointerType{Elem: boxType(l.irType(t.Elem, node))}
+	case *types.SignatureType:
+		return l.closureType(t, node)
+	}
+
+	l.error(node, fmt.Sprintf("type %s is not supported by the IR", typ.GetName()))
+	return nil
+}
+
+// declareStruct declares typ in the module and returns its IR name. Structs with
+// the same name in different files get unique names
+func (l *Lowering) declareStruct(typ *types.StructType, node ast.Node) string {
+	if typ.Name == "" {
+		l.error(node, "anonymous structs are not supported by the IR")
+	}
+
+	if name, ok := l.typeNames[typ]; ok {
+		return name
+	}
+
+	base := irName(typ.Name)
+	name := base
+	for i := 1; l.module.TypeDeclaration(name) != nil; i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	l.typeNames[typ] = name
+
+	// Declare before resolving fields so that self references terminate
+	structType := &ir.StructType{}
+	l.module.Types = append(l.module.Types, &ir.TypeDeclaration{Name: name, Type: structType})
+
+	for _, field := range typ.Variables {
+		structType.Fields = append(structType.Fields, l.irType(field.Type, node))
+	}
+
+	return name
+}
+
+// structDecl returns the declaration of typ from any of the lowered files
+func (l *Lowering) structDecl(typ *types.StructType) *ast.Struct {
+	for _, fileInfo := range l.analyserInfo.FileInfo {
+		structDecl, ok := fileInfo.Types[typ.Name].(*ast.Struct)
+		if !ok {
+			continue
+		}
+		if nodeInfo := fileInfo.NodeInfo[structDecl]; nodeInfo != nil && types.LazyResolve(nodeInfo.Type) == typ {
+			return structDecl
+		}
+	}
+	return nil
+}
+
+// irName turns the name of an instance of a generic declaration into an IR
+// identifier by replacing the characters of its type arguments: Pair<int32,
+// string> becomes Pair_int32_string
+func irName(name string) string {
+	var b strings.Builder
+	separate := false
+	for _, r := range name {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if separate {
+				b.WriteRune('_')
+				separate = false
+			}
+			b.WriteRune(r)
+		} else if b.Len() > 0 {
+			separate = true
+		}
+	}
+	return b.String()
+}
+
+func (l *Lowering) uniqueName(base string) string {
+	base = irName(base)
+	name := base
+	for i := 1; l.names[name] || ir.IsKeyword(name); i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	l.names[name] = true
+	return name
+}
+
+func (l *Lowering) declareFunctions(files []*ast.File) {
+	// Top level functions keep their names. Names in the main file take precedence
+	// and only the main file can declare main
+	for i := len(files) - 1; i >= 0; i-- {
+		for _, node := range files[i].Body {
+			if decl, ok := node.(*ast.FunctionDeclaration); ok && decl.Signature.Identifier != nil && !isGeneric(decl) {
+				name := decl.Signature.Identifier.Text
+				if files[i] != l.mainFile && name == "main" {
+					continue
+				}
+				if !ir.IsKeyword(name) && !l.names[name] {
+					l.names[name] = true
+					l.functionNames[decl] = name
+				}
+			}
+		}
+	}
+
+	for _, file := range files {
+		collector := &functionCollector{lowering: l, file: file}
+		ast.Walk(collector, file)
+
+		// Generic functions and structs are lowered through their instances
+		for _, instance := range l.analyserInfo.FileInfo[file].Instances {
+			ast.Walk(collector, instance)
+		}
+	}
+}
+
+// isGeneric returns true for generic function and struct declarations
+func isGeneric(node ast.Node) bool {
+	switch n := node.(type) {
+	case *ast.FunctionDeclaration:
+		return len(n.Signature.TypeParameters) > 0
+	case *ast.Struct:
+		return len(n.TypeParameters) > 0
+	}
+	return false
+}
+
+func (l *Lowering) functionRef(decl *ast.FunctionDeclaration) ir.FunctionRef {
+	if !decl.Signature.Extern {
+		return ir.FunctionRef(l.functionNames[decl])
+	}
+
+	return l.declareExtern(decl.Signature.Identifier.Text, l.signatureOf(decl), decl)
+}
+
+func (l *Lowering) declareExtern(name string, sig *types.SignatureType, node ast.Node) ir.FunctionRef {
+	if l.module.Extern(name) != nil {
+		return ir.FunctionRef(name)
+	}
+
+	if ir.IsKeyword(name) {
+		l.error(node, fmt.Sprintf("extern %s uses a name reserved by the IR", name))
+	}
+
+	extern := &ir.Extern{Name: name, ReturnType: ir.Void, Variadic: sig.Variadic}
+	for i, arg := range sig.ArgumentTypes {
+		argName := fmt.Sprintf("arg%d", i)
+		if i < len(sig.ArgumentNames) && sig.ArgumentNames[i] != "" {
+			argName = sig.ArgumentNames[i]
+		}
+		extern.Params = append(extern.Params, &ir.Param{Name: argName, Type: l.irType(arg, node)})
+	}
+	if sig.ReturnType != nil {
+		extern.ReturnType = l.irType(sig.ReturnType, node)
+	}
+
+	l.module.Externs = append(l.module.Externs, extern)
+	return ir.FunctionRef(name)
+}
+
+func (l *Lowering) declareGlobals(file *ast.File) {
+	for _, node := range file.Body {
+		switch n := node.(type) {
+		case *ast.VariableDeclaration:
+			l.declareGlobal(n.Name, l.typeOf(n))
+			l.globalInits = append(l.globalInits, n)
+			l.files[n] = file
+		case *ast.TupleDeclaration:
+			l.declareGlobalPattern(n.Pattern, l.typeOf(n))
+			l.globalInits = append(l.globalInits, n)
+			l.files[n] = file
+		}
+	}
+}
+
+func (l *Lowering) declareGlobalPattern(pattern *ast.TuplePattern, typ ir.Type) {
+	fields := l.structFields(typ)
+	for i, pat := range pattern.Patterns {
+		switch p := pat.(type) {
+		case *ast.Identifier:
+			l.declareGlobal(p, fields[i])
+		case *ast.TuplePattern:
+			l.declareGlobalPattern(p, fields[i])
+		}
+	}
+}
+
+func (l *Lowering) declareGlobal(ident *ast.Identifier, typ ir.Type) {
+	name := ident.Text
+	for i := 1; l.globalNames[name]; i++ {
+		name = fmt.Sprintf("%s_%d", ident.Text, i)
+	}
+	l.globalNames[name] = true
+
+	v := &variable{ptr: ir.Register(name), typ: typ}
+	if l.addressTaken(ident) {
+		v = &variable{cell: ir.Register(name), typ: typ}
+		typ = v.cellType()
+	}
+
+	l.module.Globals = append(l.module.Globals, &ir.Global{Name: name, Type: typ})
+	l.globals[ident] = v
+}
+
+func (l *Lowering) structFields(typ ir.Type) []ir.Type {
+	if ptr, ok := typ.(*ir.PointerType); ok {
+		if structType, ok := l.module.Underlying(ptr.Elem).(*ir.StructType); ok {
+			return structType.Fields
+		}
+	}
+	return nil
+}
+
+type functionCollector struct {
+	lowering *Lowering
+	file     *ast.File
+	parents  []ast.Node
+	names    []string
+}
+
+func (c *functionCollector) Visit(node ast.Node) ast.Visitor {
+	if isGeneric(node) {
+		return nil
+	}
+
+	switch n := node.(type) {
+	case *ast.Macro:
+		return nil
+	case *ast.Struct:
+		c.parents = append(c.parents, n)
+		c.names = append(c.names, n.Name.Text)
+	case *ast.FunctionDeclaration:
+		if len(c.parents) > 0 {
+			switch parent := c.parents[len(c.parents)-1].(type) {
+			case *ast.Struct:
+				c.lowering.methods[n] = parent
+			case *ast.FunctionDeclaration:
+				c.lowering.lifted[n] = !n.Signature.Extern
+			}
+		}
+
+		name, ok := c.lowering.functionNames[n]
+		if !ok {
+			name = c.name(n)
+			if !n.Signature.Extern {
+				name = c.lowering.uniqueName(name)
+			}
+			c.lowering.functionNames[n] = name
+		}
+
+		c.lowering.functions = append(c.lowering.functions, n)
+		c.lowering.files[n] = c.file
+		c.parents = append(c.parents, n)
+		c.names = append(c.names, name)
+	}
+	return c
+}
+
+func (c *functionCollector) Leave(node ast.Node) {
+	if isGeneric(node) {
+		return
+	}
+
+	switch node.(type) {
+	case *ast.Struct, *ast.FunctionDeclaration:
+		c.parents = c.parents[:len(c.parents)-1]
+		c.names = c.names[:len(c.names)-1]
+	}
+}
+
+func (c *functionCollector) name(decl *ast.FunctionDeclaration) string {
+	name := "fn"
+	if decl.Signature.Identifier != nil {
+		name = decl.Signature.Identifier.Text
+		if decl.Signature.Extern {
+			return name
+		}
+	} else if decl.Signature.Operator != nil {
+		name = "op_" + operatorNames[decl.Signature.Operator.Text]
+	}
+
+	if len(c.names) > 0 {
+		name = c.names[len(c.names)-1] + "_" + name
+	}
+	return name
+}
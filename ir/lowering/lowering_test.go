@@ -0,0 +1,160 @@
+package lowering
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/orktes/orlang/analyser"
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/ir"
+	"github.com/orktes/orlang/parser"
+	"github.com/orktes/orlang/scanner"
+	"github.com/orktes/orlang/types"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func lower(src string) (*ir.Module, error) {
+	file, err := parser.Parse(strings.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	analyser, err := analyser.New(file)
+	if err != nil {
+		return nil, err
+	}
+
+	analyser.AddExternalFunc("print", &types.SignatureType{
+		ArgumentNames: []string{"str"},
+		ArgumentTypes: []types.Type{types.StringType},
+		ReturnType:    types.VoidType,
+		Extern:        true,
+	})
+
+	var analyErr error
+	analyser.Error = func(node ast.Node, msg string, fatal bool) {
+		if fatal {
+			analyErr = errors.New(fmt.Sprintf(
+				"%d:%d %s",
+				node.StartPos().Line+1,
+				node.StartPos().Column+1,
+				msg,
+			))
+		}
+	}
+
+	info, err := analyser.Analyse()
+	if err != nil {
+		return nil, err
+	}
+
+	if analyErr != nil {
+		return nil, analyErr
+	}
+
+	return New(info).Lower(file)
+}
+
+func TestLowerGolden(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.or")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		module, err := lower(string(src))
+		if err != nil {
+			t.Errorf("%s: %s", file, err)
+			continue
+		}
+
+		output := ir.Print(module)
+		golden := strings.TrimSuffix(file, ".or") + ".ir"
+
+		if *update {
+			if err := ioutil.WriteFile(golden, output, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(output, expected) {
+			t.Errorf("%s: lowered IR did not match %s:\n%s", file, golden, output)
+			continue
+		}
+
+		// Golden files must be valid IR
+		reparsed, err := ir.Parse(ir.NewScanner(scanner.NewScanner(bytes.NewReader(expected))))
+		if err != nil {
+			t.Errorf("%s: %s", golden, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(module, reparsed) {
+			t.Errorf("%s: parsed module is not identical to the lowered module", golden)
+		}
+	}
+}
+
+func TestLowerErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{`struct Foo {
+			fn bar() {}
+		}
+		fn main() {
+			var f = Foo{}.bar
+			f()
+		}`, "5:12: method value bar is not supported by the IR"},
+		{`fn foo() => int32 {
+			if true {
+				return 1
+			}
+		}
+		fn main() { foo() }`, "1:1: missing return at the end of function foo"},
+		{`var x = 1
+		fn foo() { x++ }`, "1:5: global variables can only be initialized with a main function"},
+		{`fn main() {
+			var arr = [2]int32{1, 2}
+		}`, "2:8: type [2]int32 is not supported by the IR"},
+		{`struct Foo {
+			var x : int32
+		}
+		fn main() {
+			var foo = Foo{}
+			var x = &foo.x
+			*x = 1
+		}`, "6:12: taking the address of foo.x is not supported by the IR"},
+	}
+
+	for _, test := range tests {
+		_, err := lower(test.src)
+		if err == nil {
+			t.Errorf("Expected error for %s", test.src)
+			continue
+		}
+		if err.Error() != test.err {
+			t.Errorf("Wrong error for %s: expected %q got %q", test.src, test.err, err.Error())
+		}
+	}
+}
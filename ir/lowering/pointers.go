@@ -0,0 +1,129 @@
+package lowering
+
+import (
+	"fmt"
+
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/ir"
+	"github.com/orktes/orlang/scanner"
+	"github.com/orktes/orlang/types"
+)
+
+// Pointers point to boxes: objects holding a single value. Variables whose
+// address is taken live in a box and &x evaluates to the box of x. Boxes of
+// variables that do not escape the function declaring them are allocated in
+// the prologue and released on return. Variables that escape get a new box
+// every time their declaration is executed so that closures and pointers
+// keep the box they saw alive. Stored structs are copied when they are
+// stored again so that only pointers share a struct
+
+// addressTaken returns true if the address of the variable declared by
+// ident is taken in any of the lowered files
+func (l *Lowering) addressTaken(ident *ast.Identifier) bool {
+	for _, fileInfo := range l.analyserInfo.FileInfo {
+		if nodeInfo, ok := fileInfo.NodeInfo[ident]; ok && nodeInfo.AddressTaken {
+			return true
+		}
+	}
+	return false
+}
+
+// escapes returns true if the variable declared by ident outlives the call
+// of the function declaring it
+func (l *Lowering) escapes(ident *ast.Identifier) bool {
+	for _, fileInfo := range l.analyserInfo.FileInfo {
+		if nodeInfo, ok := fileInfo.NodeInfo[ident]; ok && nodeInfo.Escapes {
+			return true
+		}
+	}
+	return false
+}
+
+// boxType is the type of the object a pointer to typ points to
+func boxType(typ ir.Type) ir.Type {
+	return &ir.StructType{Fields: []ir.Type{typ}}
+}
+
+func (f *function) lowerAddressOf(n *ast.UnaryExpression) ir.Operand {
+	l := f.lowering
+
+	expr := n.Expression
+	for {
+		paren, ok := expr.(*ast.ParenExpression)
+		if !ok {
+			break
+		}
+		expr = paren.Expression
+	}
+
+	switch operand := expr.(type) {
+	case *ast.Identifier:
+		return f.lowerLocation(operand).ptr
+	case *ast.UnaryExpression:
+		// &*p is p
+		return f.lowerValue(operand.Expression)
+	case *ast.StructExpression:
+		return f.alloc(l.typeOf(n), []ir.Operand{f.lowerValue(operand)})
+	}
+
+	l.error(n, fmt.Sprintf("taking the address of %s is not supported by the IR", n.Expression))
+	return nil
+}
+
+// lowerDereference returns the location a pointer points to
+func (f *function) lowerDereference(n *ast.UnaryExpression) location {
+	return location{ptr: f.lowerValue(n.Expression), typ: f.lowering.typeOf(n)}
+}
+
+// isStoredStruct returns true if expr evaluates to a struct that is stored
+// in a variable, a field or behind a pointer
+func (f *function) isStoredStruct(expr ast.Expression) bool {
+	if _, ok := f.lowering.getType(expr).(*types.StructType); !ok {
+		return false
+	}
+
+	switch n := expr.(type) {
+	case *ast.Identifier, *ast.MemberExpression:
+		return true
+	case *ast.UnaryExpression:
+		return n.Operator.Type == scanner.TokenTypeASTERISK
+	case *ast.ParenExpression:
+		return f.isStoredStruct(n.Expression)
+	}
+	return false
+}
+
+// lowerStoredValue lowers an expression whose value is stored or passed to a
+// function. Stored structs are copied
+func (f *function) lowerStoredValue(expr ast.Expression) ir.Operand {
+	value := f.lowerValue(expr)
+	if !f.isStoredStruct(expr) {
+		return value
+	}
+	return f.copyStruct(value, f.lowering.getType(expr).(*types.StructType), expr)
+}
+
+func (f *function) lowerStoredValues(exprs []ast.Expression) []ir.Operand {
+	values := make([]ir.Operand, len(exprs))
+	for i, expr := range exprs {
+		if expr != nil {
+			values[i] = f.lowerStoredValue(expr)
+		}
+	}
+	return values
+}
+
+// copyStruct allocates a copy of the struct value points to. Nested structs
+// are copied as well
+func (f *function) copyStruct(value ir.Operand, typ *types.StructType, node ast.Node) ir.Operand {
+	l := f.lowering
+	fields := make([]ir.Operand, len(typ.Variables))
+	for i, field := range typ.Variables {
+		fieldValue := f.load(location{ptr: value, index: i, typ: l.irType(field.Type, node)})
+		fields[i] = fieldValue
+		if fieldType, ok := types.LazyResolve(field.Type).(*types.StructType); ok {
+			fields[i] = f.copyStruct(fieldValue, fieldType, node)
+		}
+	}
+	return f.alloc(l.irType(typ, node), fields)
+}
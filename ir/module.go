@@ -0,0 +1,162 @@
+package ir
+
+// Module is a single IR compilation unit
+type Module struct {
+	Types     []*TypeDeclaration
+	Globals   []*Global
+	Externs   []*Extern
+	Functions []*Function
+}
+
+// TypeDeclaration names a type
+//
+//	type Foo {int32, int32}
+type TypeDeclaration struct {
+	Name string
+	Type Type
+}
+
+// Global is module level storage. Inside functions the register %name
+// holds a pointer (ptr<type>) to the storage
+//
+//	global %name : type
+type Global struct {
+	Name string
+	Type Type
+}
+
+// Extern declares a function implemented outside of the module. Variadic
+// externs accept any number of arguments after the declared parameters
+//
+//	extern print(%str : string) : void
+//	extern printf(%format : string, ...) : int32
+type Extern struct {
+	Name       string
+	Params     []*Param
+	ReturnType Type
+	Variadic   bool
+}
+
+// Param is a named function parameter
+type Param struct {
+	Name string
+	Type Type
+}
+
+// Function is a function made of basic blocks. The first block is the entry block
+type Function struct {
+	Name       string
+	Params     []*Param
+	ReturnType Type
+	Blocks     []*Block
+}
+
+// Block is a basic block. Label is empty for an unlabelled entry block
+type Block struct {
+	Label        string
+	Instructions []Instruction
+}
+
+// TypeDeclaration returns the declaration for a named type
+func (m *Module) TypeDeclaration(name string) *TypeDeclaration {
+	for _, decl := range m.Types {
+		if decl.Name == name {
+			return decl
+		}
+	}
+	return nil
+}
+
+// Function returns a function by name
+func (m *Module) Function(name string) *Function {
+	for _, fn := range m.Functions {
+		if fn.Name == name {
+			return fn
+		}
+	}
+	return nil
+}
+
+// Extern returns an extern by name
+func (m *Module) Extern(name string) *Extern {
+	for _, ext := range m.Externs {
+		if ext.Name == name {
+			return ext
+		}
+	}
+	return nil
+}
+
+// Global returns a global by name
+func (m *Module) Global(name string) *Global {
+	for _, global := range m.Globals {
+		if global.Name == name {
+			return global
+		}
+	}
+	return nil
+}
+
+// Underlying resolves named types to the type they refer to
+func (m *Module) Underlying(typ Type) Type {
+	for i := 0; i <= len(m.Types); i++ {
+		named, ok := typ.(NamedType)
+		if !ok {
+			return typ
+		}
+		decl := m.TypeDeclaration(string(named))
+		if decl == nil {
+			return typ
+		}
+		typ = decl.Type
+	}
+	// Type declarations form a cycle
+	return typ
+}
+
+// IsObject returns true if memory allocated for typ is reference counted.
+// Structs are objects
+func (m *Module) IsObject(typ Type) bool {
+	_, ok := m.Underlying(typ).(*StructType)
+	return ok
+}
+
+// IsReference returns true if values of typ are references to objects.
+// Strings are references to immutable string objects
+func (m *Module) IsReference(typ Type) bool {
+	if typ == String {
+		return true
+	}
+	ptr, ok := typ.(*PointerType)
+	return ok && m.IsObject(ptr.Elem)
+}
+
+// Block returns a basic block by label
+func (f *Function) Block(label string) *Block {
+	for _, blk := range f.Blocks {
+		if blk.Label == label {
+			return blk
+		}
+	}
+	return nil
+}
+
+// Signature returns the function type of the function
+func (f *Function) Signature() *FunctionType {
+	return signature(f.Params, f.ReturnType)
+}
+
+// Signature returns the function type of the extern
+func (e *Extern) Signature() *FunctionType {
+	typ := signature(e.Params, e.ReturnType)
+	typ.Variadic = e.Variadic
+	return typ
+}
+
+func signature(params []*Param, returnType Type) *FunctionType {
+	typ := &FunctionType{Return: returnType}
+	for _, param := range params {
+		typ.Params = append(typ.Params, param.Type)
+	}
+	return typ
+}
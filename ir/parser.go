@@ -1,6 +1,9 @@
 package ir
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/orktes/orlang/ast"
 	"github.com/orktes/orlang/scanner"
 )
@@ -15,6 +18,8 @@ type Parser struct {
 	ContinueOnErrors bool
 	snapshots        [][]scanner.Token
 	readTokens       int
+	// named type references checked after all declarations have been read
+	typeReferences []scanner.Token
 }
 
 func NewParser(scanner scanner.ScannerInterface) (parser *Parser) {
@@ -26,12 +31,797 @@ func NewParser(scanner scanner.ScannerInterface) (parser *Parser) {
 	return
 }
 
-func Parse(scanner scanner.ScannerInterface) error {
+// Parse IR module from scanner
+func Parse(scanner scanner.ScannerInterface) (*Module, error) {
 	return NewParser(scanner).Parse()
 }
 
-func (p *Parser) Parse() error {
-	return nil
+// Parse IR module
+func (p *Parser) Parse() (module *Module, err error) {
+	module = &Module{}
+
+loop:
+	for {
+		switch {
+		case p.parseTypeDeclaration(module):
+		case p.parseGlobal(module):
+		case p.parseExtern(module):
+		case p.parseFunction(module):
+		case p.eof():
+			p.checkTypeReferences(module)
+			p.checkError(&err)
+			break loop
+		default:
+			token := p.read()
+			p.error(unexpectedToken(token))
+		}
+
+		if !p.checkError(&err) {
+			break loop
+		}
+	}
+
+	return
+}
+
+// checkError converts pending parser error into a PosError. Returns false if parsing should stop
+func (p *Parser) checkError(err *error) bool {
+	if p.parserError != "" {
+		token := p.errorToken
+		posError := &PosError{Position: ast.StartPositionFromToken(token), Message: p.parserError}
+		p.parserError = ""
+		if *err == nil {
+			*err = posError
+		}
+		if !p.ContinueOnErrors {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Parser) checkTypeReferences(module *Module) {
+	for _, token := range p.typeReferences {
+		if module.TypeDeclaration(token.Text) == nil {
+			p.lastTokens = []scanner.Token{token}
+			p.error(fmt.Sprintf("undefined type %s", token.Text))
+		}
+	}
+}
+
+func (p *Parser) expectKeyword(keyword string) (token scanner.Token, ok bool) {
+	token = p.read()
+	if token.Type == scanner.TokenTypeIdent && token.Text == keyword {
+		ok = true
+		return
+	}
+	p.unread()
+	return
+}
+
+func (p *Parser) parseName(what string) (name string, ok bool) {
+	token, ok := p.expectToken(scanner.TokenTypeIdent)
+	if !ok || isRegister(token) {
+		ok = false
+		p.error(unexpected(token.StringValue(), what))
+		return
+	}
+
+	if IsKeyword(token.Text) {
+		ok = false
+		p.error(reservedKeywordError(token))
+		return
+	}
+
+	return token.Text, true
+}
+
+func (p *Parser) parseTypeDeclaration(module *Module) (ok bool) {
+	if _, ok = p.expectKeyword(keywordType); !ok {
+		return
+	}
+
+	name, nameOk := p.parseName("type name")
+	if !nameOk {
+		return
+	}
+
+	if module.TypeDeclaration(name) != nil {
+		p.error(fmt.Sprintf("type %s already declared", name))
+		return
+	}
+
+	typ, typOk := p.parseType()
+	if !typOk {
+		p.error(unexpected(p.read().StringValue(), "type"))
+		return
+	}
+
+	module.Types = append(module.Types, &TypeDeclaration{Name: name, Type: typ})
+	return
+}
+
+func (p *Parser) parseGlobal(module *Module) (ok bool) {
+	if _, ok = p.expectKeyword(keywordGlobal); !ok {
+		return
+	}
+
+	register, registerOk := p.parseRegister()
+	if !registerOk {
+		p.error(unexpected(p.read().StringValue(), "register"))
+		return
+	}
+
+	if module.Global(string(register)) != nil {
+		p.error(fmt.Sprintf("global %%%s already declared", register))
+		return
+	}
+
+	typ, typOk := p.parseTypeAnnotation()
+	if !typOk {
+		return
+	}
+
+	module.Globals = append(module.Globals, &Global{Name: string(register), Type: typ})
+	return
+}
+
+func (p *Parser) parseExtern(module *Module) (ok bool) {
+	if _, ok = p.expectKeyword(keywordExtern); !ok {
+		return
+	}
+
+	name, nameOk := p.parseName("extern name")
+	if !nameOk {
+		return
+	}
+
+	if module.Extern(name) != nil || module.Function(name) != nil {
+		p.error(fmt.Sprintf("%s already declared", name))
+		return
+	}
+
+	params, variadic, paramsOk := p.parseParams()
+	if !paramsOk {
+		return
+	}
+
+	returnType, returnTypeOk := p.parseTypeAnnotation()
+	if !returnTypeOk {
+		return
+	}
+
+	module.Externs = append(module.Externs, &Extern{
+		Name:       name,
+		Params:     params,
+		ReturnType: returnType,
+		Variadic:   variadic,
+	})
+	return
+}
+
+func (p *Parser) parseFunction(module *Module) (ok bool) {
+	if _, ok = p.expectKeyword(keywordFn); !ok {
+		return
+	}
+
+	name, nameOk := p.parseName("function name")
+	if !nameOk {
+		return
+	}
+
+	if module.Extern(name) != nil || module.Function(name) != nil {
+		p.error(fmt.Sprintf("%s already declared", name))
+		return
+	}
+
+	params, variadic, paramsOk := p.parseParams()
+	if !paramsOk {
+		return
+	}
+	if variadic {
+		p.error("only externs can be variadic")
+		return
+	}
+
+	returnType, returnTypeOk := p.parseTypeAnnotation()
+	if !returnTypeOk {
+		return
+	}
+
+	fn := &Function{
+		Name:       name,
+		Params:     params,
+		ReturnType: returnType,
+	}
+
+	if !p.parseFunctionBody(fn) {
+		return
+	}
+
+	module.Functions = append(module.Functions, fn)
+	return
+}
+
+// parseParams parses a parameter list. Variadic parameter lists end with ...
+func (p *Parser) parseParams() (params []*Param, variadic bool, ok bool) {
+	if token, lparenOk := p.expectToken(scanner.TokenTypeLPAREN); !lparenOk {
+		p.error(unexpectedToken(token, scanner.TokenTypeLPAREN))
+		return
+	}
+
+	for {
+		if _, variadic = p.expectToken(scanner.TokenTypeEllipsis); variadic {
+			break
+		}
+		p.unread()
+
+		register, registerOk := p.parseRegister()
+		if !registerOk {
+			if len(params) > 0 {
+				p.error(unexpected(p.read().StringValue(), "parameter"))
+				return
+			}
+			break
+		}
+
+		typ, typOk := p.parseTypeAnnotation()
+		if !typOk {
+			return
+		}
+
+		params = append(params, &Param{Name: string(register), Type: typ})
+
+		if _, commaOk := p.expectToken(scanner.TokenTypeCOMMA); !commaOk {
+			p.unread()
+			break
+		}
+	}
+
+	if token, rparenOk := p.expectToken(scanner.TokenTypeRPAREN); !rparenOk {
+		p.error(unexpectedToken(token, scanner.TokenTypeRPAREN))
+		return
+	}
+
+	ok = true
+	return
+}
+
+func (p *Parser) parseFunctionBody(fn *Function) (ok bool) {
+	if token, lbraceOk := p.expectToken(scanner.TokenTypeLBRACE); !lbraceOk {
+		p.error(unexpectedToken(token, scanner.TokenTypeLBRACE))
+		return
+	}
+
+	labelReferences := []scanner.Token{}
+	var block *Block
+
+	for {
+		tokens := p.peekMultiple(2)
+		token := tokens[0]
+		switch {
+		case token.Type == scanner.TokenTypeRBRACE:
+			p.skip()
+			for _, ref := range labelReferences {
+				if fn.Block(ref.Text) == nil {
+					p.lastTokens = []scanner.Token{ref}
+					p.error(fmt.Sprintf("undefined label %s", ref.Text))
+					return
+				}
+			}
+			ok = true
+			return
+		case token.Type == scanner.TokenTypeEOF:
+			p.error(unexpectedToken(p.read(), scanner.TokenTypeRBRACE))
+			return
+		case token.Type == scanner.TokenTypeIdent && !isRegister(token) && !IsKeyword(token.Text) && tokens[1].Type == scanner.TokenTypeCOLON:
+			// Label
+			p.read()
+			if fn.Block(token.Text) != nil {
+				p.error(fmt.Sprintf("label %s already defined", token.Text))
+				return
+			}
+			p.skip()
+			block = &Block{Label: token.Text}
+			fn.Blocks = append(fn.Blocks, block)
+		default:
+			instr, refs, instrOk := p.parseInstruction()
+			if !instrOk {
+				return
+			}
+			if block == nil {
+				// Unlabelled entry block
+				block = &Block{}
+				fn.Blocks = append(fn.Blocks, block)
+			}
+			block.Instructions = append(block.Instructions, instr)
+			labelReferences = append(labelReferences, refs...)
+		}
+	}
+}
+
+func (p *Parser) parseInstruction() (instr Instruction, labels []scanner.Token, ok bool) {
+	token := p.read()
+	if token.Type != scanner.TokenTypeIdent {
+		p.error(unexpected(token.StringValue(), "instruction or label"))
+		return
+	}
+
+	if isRegister(token) {
+		instr, ok = p.parseValueInstruction(Register(token.Text[1:]))
+		return
+	}
+
+	switch token.Text {
+	case keywordStore:
+		instr, ok = p.parseStore()
+	case keywordFree, keywordRetain, keywordRelease:
+		var ptr Operand
+		if ptr, ok = p.parseOperand(false); !ok {
+			p.error(unexpected(p.read().StringValue(), "pointer"))
+			return
+		}
+		switch token.Text {
+		case keywordFree:
+			instr = &Free{Ptr: ptr}
+		case keywordRetain:
+			instr = &Retain{Ptr: ptr}
+		default:
+			instr = &Release{Ptr: ptr}
+		}
+	case keywordBr:
+		var label scanner.Token
+		if label, ok = p.parseLabelReference(); ok {
+			instr = &Br{Label: label.Text}
+			labels = append(labels, label)
+		}
+	case keywordBrCond:
+		instr, labels, ok = p.parseBrCond()
+	case keywordReturn:
+		instr, ok = p.parseReturn()
+	case keywordCall:
+		instr, ok = p.parseCall("")
+	default:
+		p.error(unexpected(token.StringValue(), "instruction or label"))
+	}
+
+	return
+}
+
+func (p *Parser) parseValueInstruction(dest Register) (instr ValueInstruction, ok bool) {
+	if token, assignOk := p.expectToken(scanner.TokenTypeASSIGN); !assignOk {
+		p.error(unexpectedToken(token, scanner.TokenTypeASSIGN))
+		return
+	}
+
+	switch {
+	case p.skipKeyword(keywordAlloc):
+		allocType, typOk := p.parseType()
+		if !typOk {
+			p.error(unexpected(p.read().StringValue(), "type"))
+			return
+		}
+		instr = &Alloc{Dest: dest, AllocType: allocType}
+	case p.skipKeyword(keywordLoad):
+		ptr, ptrOk := p.parseOperand(false)
+		if !ptrOk {
+			p.error(unexpected(p.read().StringValue(), "pointer"))
+			return
+		}
+		index, indexOk := p.parseOptionalIndex()
+		if !indexOk {
+			return
+		}
+		instr = &Load{Dest: dest, Ptr: ptr, Index: index}
+	case p.skipKeyword(keywordCall):
+		return p.parseCall(dest)
+	case p.skipKeyword(keywordCast):
+		value, valueOk := p.parseOperand(false)
+		if !valueOk {
+			p.error(unexpected(p.read().StringValue(), "operand"))
+			return
+		}
+		instr = &Cast{Dest: dest, Value: value}
+	case p.skipKeyword(keywordNeg), p.skipKeyword(keywordNot):
+		operator := p.lastToken().Text
+		value, valueOk := p.parseOperand(false)
+		if !valueOk {
+			p.error(unexpected(p.read().StringValue(), "operand"))
+			return
+		}
+		instr = &UnaryOp{Dest: dest, Operator: operator, Value: value}
+	default:
+		left, leftOk := p.parseOperand(true)
+		if !leftOk {
+			p.error(unexpected(p.read().StringValue(), "operand or instruction"))
+			return
+		}
+
+		operator, operatorOk := p.expectToken(binaryOperators...)
+		if !operatorOk {
+			p.unread()
+			instr = &Assign{Dest: dest, Value: left}
+			break
+		}
+
+		right, rightOk := p.parseOperand(false)
+		if !rightOk {
+			p.error(unexpected(p.read().StringValue(), "operand"))
+			return
+		}
+
+		instr = &BinaryOp{Dest: dest, Operator: operator.Text, Left: left, Right: right}
+	}
+
+	typ, typOk := p.parseTypeAnnotation()
+	if !typOk {
+		return
+	}
+
+	switch i := instr.(type) {
+	case *Alloc:
+		i.Type = typ
+	case *Load:
+		i.Type = typ
+	case *Cast:
+		i.Type = typ
+	case *UnaryOp:
+		i.Type = typ
+	case *Assign:
+		i.Type = typ
+	case *BinaryOp:
+		i.Type = typ
+	}
+
+	ok = true
+	return
+}
+
+func (p *Parser) skipKeyword(keyword string) bool {
+	token, ok := p.expectKeyword(keyword)
+	if ok {
+		// Keep the keyword available through lastToken
+		p.lastTokens = []scanner.Token{token}
+	}
+	return ok
+}
+
+func (p *Parser) parseCall(dest Register) (instr *Call, ok bool) {
+	callee, calleeOk := p.parseCallee()
+	if !calleeOk {
+		return
+	}
+
+	if token, lparenOk := p.expectToken(scanner.TokenTypeLPAREN); !lparenOk {
+		p.error(unexpectedToken(token, scanner.TokenTypeLPAREN))
+		return
+	}
+
+	var args []Operand
+	for {
+		arg, argOk := p.parseOperand(true)
+		if !argOk {
+			if len(args) > 0 {
+				p.error(unexpected(p.read().StringValue(), "operand"))
+				return
+			}
+			break
+		}
+		args = append(args, arg)
+
+		if _, commaOk := p.expectToken(scanner.TokenTypeCOMMA); !commaOk {
+			p.unread()
+			break
+		}
+	}
+
+	if token, rparenOk := p.expectToken(scanner.TokenTypeRPAREN); !rparenOk {
+		p.error(unexpectedToken(token, scanner.TokenTypeRPAREN))
+		return
+	}
+
+	typ, typOk := p.parseTypeAnnotation()
+	if !typOk {
+		return
+	}
+
+	instr = &Call{Dest: dest, Callee: callee, Arguments: args, Type: typ}
+	ok = true
+	return
+}
+
+func (p *Parser) parseCallee() (callee Operand, ok bool) {
+	token, ok := p.expectToken(scanner.TokenTypeIdent)
+	if !ok {
+		p.error(unexpected(token.StringValue(), "function name or register"))
+		return
+	}
+
+	if isRegister(token) {
+		return Register(token.Text[1:]), true
+	}
+
+	if IsKeyword(token.Text) {
+		ok = false
+		p.error(reservedKeywordError(token))
+		return
+	}
+
+	return FunctionRef(token.Text), true
+}
+
+func (p *Parser) parseStore() (instr *Store, ok bool) {
+	ptr, ptrOk := p.parseOperand(false)
+	if !ptrOk {
+		p.error(unexpected(p.read().StringValue(), "pointer"))
+		return
+	}
+
+	if token, commaOk := p.expectToken(scanner.TokenTypeCOMMA); !commaOk {
+		p.error(unexpectedToken(token, scanner.TokenTypeCOMMA))
+		return
+	}
+
+	value, valueOk := p.parseOperand(true)
+	if !valueOk {
+		p.error(unexpected(p.read().StringValue(), "operand"))
+		return
+	}
+
+	index, indexOk := p.parseOptionalIndex()
+	if !indexOk {
+		return
+	}
+
+	return &Store{Ptr: ptr, Value: value, Index: index}, true
+}
+
+func (p *Parser) parseOptionalIndex() (index int, ok bool) {
+	if _, commaOk := p.expectToken(scanner.TokenTypeCOMMA); !commaOk {
+		p.unread()
+		return 0, true
+	}
+
+	token, numberOk := p.expectToken(scanner.TokenTypeNumber)
+	if !numberOk {
+		p.error(unexpected(token.StringValue(), "index"))
+		return
+	}
+
+	return int(token.Value.(int64)), true
+}
+
+func (p *Parser) parseBrCond() (instr *BrCond, labels []scanner.Token, ok bool) {
+	condition, conditionOk := p.parseOperand(false)
+	if !conditionOk {
+		p.error(unexpected(p.read().StringValue(), "condition"))
+		return
+	}
+
+	instr = &BrCond{Condition: condition}
+
+	for _, target := range []*string{&instr.True, &instr.False} {
+		if token, commaOk := p.expectToken(scanner.TokenTypeCOMMA); !commaOk {
+			p.error(unexpectedToken(token, scanner.TokenTypeCOMMA))
+			return
+		}
+
+		label, labelOk := p.parseLabelReference()
+		if !labelOk {
+			return
+		}
+		*target = label.Text
+		labels = append(labels, label)
+	}
+
+	ok = true
+	return
+}
+
+func (p *Parser) parseLabelReference() (token scanner.Token, ok bool) {
+	token, ok = p.expectToken(scanner.TokenTypeIdent)
+	if !ok || isRegister(token) || IsKeyword(token.Text) {
+		ok = false
+		p.error(unexpected(token.StringValue(), "label"))
+	}
+	return
+}
+
+func (p *Parser) parseReturn() (instr *Return, ok bool) {
+	instr = &Return{}
+
+	tokens := p.peekMultiple(2)
+	if !isOperandStart(tokens[0]) || tokens[1].Type == scanner.TokenTypeASSIGN {
+		// Return without a value
+		return instr, true
+	}
+
+	value, valueOk := p.parseOperand(false)
+	if !valueOk {
+		p.error(unexpected(p.read().StringValue(), "operand"))
+		return
+	}
+
+	typ, typOk := p.parseTypeAnnotation()
+	if !typOk {
+		return
+	}
+
+	instr.Value = value
+	instr.Type = typ
+	ok = true
+	return
+}
+
+func (p *Parser) parseRegister() (register Register, ok bool) {
+	token := p.read()
+	if token.Type != scanner.TokenTypeIdent || !isRegister(token) {
+		p.unread()
+		return
+	}
+
+	return Register(token.Text[1:]), true
+}
+
+// parseOperand parses a register or a constant. Bare identifiers are accepted
+// as function references if functionRefs is set
+func (p *Parser) parseOperand(functionRefs bool) (operand Operand, ok bool) {
+	token := p.read()
+	switch token.Type {
+	case scanner.TokenTypeIdent:
+		if isRegister(token) {
+			return Register(token.Text[1:]), true
+		}
+		if token.Text == keywordNull {
+			return Constant{}, true
+		}
+		if functionRefs && !IsKeyword(token.Text) {
+			return FunctionRef(token.Text), true
+		}
+	case scanner.TokenTypeNumber, scanner.TokenTypeFloat, scanner.TokenTypeBoolean, scanner.TokenTypeString:
+		return Constant{Value: token.Value}, true
+	case scanner.TokenTypeSUB:
+		number, numberOk := p.expectToken(scanner.TokenTypeNumber, scanner.TokenTypeFloat)
+		if !numberOk {
+			p.error(unexpected(number.StringValue(), "number"))
+			return
+		}
+		switch val := number.Value.(type) {
+		case int64:
+			return Constant{Value: -val}, true
+		case float64:
+			return Constant{Value: -val}, true
+		}
+	}
+
+	p.unread()
+	return
+}
+
+func (p *Parser) parseTypeAnnotation() (typ Type, ok bool) {
+	if token, colonOk := p.expectToken(scanner.TokenTypeCOLON); !colonOk {
+		p.error(unexpectedToken(token, scanner.TokenTypeCOLON))
+		return
+	}
+
+	if typ, ok = p.parseType(); !ok {
+		p.error(unexpected(p.read().StringValue(), "type"))
+	}
+	return
+}
+
+func (p *Parser) parseType() (typ Type, ok bool) {
+	token := p.read()
+	switch token.Type {
+	case scanner.TokenTypeLBRACE:
+		types, typesOk := p.parseTypeList(scanner.TokenTypeRBRACE)
+		if !typesOk {
+			return
+		}
+		return &StructType{Fields: types}, true
+	case scanner.TokenTypeIdent:
+		if isRegister(token) {
+			break
+		}
+
+		if primitive, isPrimitive := primitiveTypes[token.Text]; isPrimitive {
+			return primitive, true
+		}
+
+		switch token.Text {
+		case keywordPtr:
+			if lchev, lchevOk := p.expectToken(scanner.TokenTypeLess); !lchevOk {
+				p.error(unexpectedToken(lchev, scanner.TokenTypeLess))
+				return
+			}
+			elem, elemOk := p.parseType()
+			if !elemOk {
+				p.error(unexpected(p.read().StringValue(), "type"))
+				return
+			}
+			if rchev, rchevOk := p.expectToken(scanner.TokenTypeGreater); !rchevOk {
+				p.error(unexpectedToken(rchev, scanner.TokenTypeGreater))
+				return
+			}
+			return &PointerType{Elem: elem}, true
+		case keywordFn:
+			if lparen, lparenOk := p.expectToken(scanner.TokenTypeLPAREN); !lparenOk {
+				p.error(unexpectedToken(lparen, scanner.TokenTypeLPAREN))
+				return
+			}
+			params, paramsOk := p.parseTypeList(scanner.TokenTypeRPAREN)
+			if !paramsOk {
+				return
+			}
+			returnType, returnTypeOk := p.parseTypeAnnotation()
+			if !returnTypeOk {
+				return
+			}
+			return &FunctionType{Params: params, Return: returnType}, true
+		}
+
+		if IsKeyword(token.Text) {
+			break
+		}
+
+		p.typeReferences = append(p.typeReferences, token)
+		return NamedType(token.Text), true
+	}
+
+	p.unread()
+	return
+}
+
+func (p *Parser) parseTypeList(closing scanner.TokenType) (types []Type, ok bool) {
+	for {
+		typ, typOk := p.parseType()
+		if !typOk {
+			if len(types) > 0 {
+				p.error(unexpected(p.read().StringValue(), "type"))
+				return
+			}
+			break
+		}
+		types = append(types, typ)
+
+		if _, commaOk := p.expectToken(scanner.TokenTypeCOMMA); !commaOk {
+			p.unread()
+			break
+		}
+	}
+
+	if token, closingOk := p.expectToken(closing); !closingOk {
+		p.error(unexpectedToken(token, closing))
+		return
+	}
+
+	ok = true
+	return
+}
+
+var binaryOperators = []scanner.TokenType{
+	scanner.TokenTypeADD,
+	scanner.TokenTypeSUB,
+	scanner.TokenTypeASTERISK,
+	scanner.TokenTypeSLASH,
+	scanner.TokenTypeEqual,
+	scanner.TokenTypeNotEqual,
+	scanner.TokenTypeLess,
+	scanner.TokenTypeGreater,
+	scanner.TokenTypeLessOrEqual,
+	scanner.TokenTypeGreaterOrEqual,
+}
+
+func isRegister(token scanner.Token) bool {
+	return token.Type == scanner.TokenTypeIdent && strings.HasPrefix(token.Text, "%")
+}
+
+func isOperandStart(token scanner.Token) bool {
+	switch token.Type {
+	case scanner.TokenTypeNumber, scanner.TokenTypeFloat, scanner.TokenTypeBoolean, scanner.TokenTypeString, scanner.TokenTypeSUB:
+		return true
+	}
+	return isRegister(token) || token.Text == keywordNull
 }
 
 func (p *Parser) eof() (ok bool) {
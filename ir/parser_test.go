@@ -1,15 +1,19 @@
 package ir
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/orktes/orlang/scanner"
 )
 
+func testParse(src string) (*Module, error) {
+	return Parse(NewScanner(scanner.NewScanner(strings.NewReader(src))))
+}
+
 func TestParser(t *testing.T) {
-	err := Parse(NewScanner(scanner.NewScanner(strings.NewReader(
-		`
+	module, err := testParse(`
 type Foo {int32, int32}
 
 fn foobar(%x : int32, %y : int32) : ptr<Foo> {
@@ -35,8 +39,176 @@ label1:
 
   return %temp4 : ptr<Foo>
 }
-  `))))
+  `)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(module.Types, []*TypeDeclaration{
+		{Name: "Foo", Type: &StructType{Fields: []Type{Int32, Int32}}},
+	}) {
+		t.Error("Wrong types parsed", module.Types)
+	}
+
+	fn := module.Function("foobar")
+	if fn == nil {
+		t.Fatal("Function foobar not parsed")
+	}
+
+	if !reflect.DeepEqual(fn.Params, []*Param{{Name: "x", Type: Int32}, {Name: "y", Type: Int32}}) {
+		t.Error("Wrong params", fn.Params)
+	}
+
+	if !reflect.DeepEqual(fn.ReturnType, &PointerType{Elem: NamedType("Foo")}) {
+		t.Error("Wrong return type", fn.ReturnType)
+	}
+
+	if !reflect.DeepEqual(fn.Blocks, []*Block{
+		{
+			Instructions: []Instruction{
+				&Assign{Dest: "temp0", Value: Constant{Value: int64(10)}, Type: Int32},
+				&BinaryOp{Dest: "temp1", Operator: ">", Left: Register("x"), Right: Register("temp0"), Type: Bool},
+				&BrCond{Condition: Register("temp1"), True: "label0", False: "label1"},
+			},
+		},
+		{
+			Label: "label0",
+			Instructions: []Instruction{
+				&Assign{Dest: "temp2", Value: Constant{Value: int64(10)}, Type: Int32},
+				&Alloc{Dest: "temp3", AllocType: NamedType("Foo"), Type: &PointerType{Elem: NamedType("Foo")}},
+				&Store{Ptr: Register("temp3"), Value: Register("temp2")},
+				&Store{Ptr: Register("temp3"), Value: Register("y"), Index: 1},
+				&Return{Value: Register("temp3"), Type: &PointerType{Elem: NamedType("Foo")}},
+			},
+		},
+		{
+			Label: "label1",
+			Instructions: []Instruction{
+				&Alloc{Dest: "temp4", AllocType: NamedType("Foo"), Type: &PointerType{Elem: NamedType("Foo")}},
+				&Store{Ptr: Register("temp3"), Value: Register("x")},
+				&Store{Ptr: Register("temp3"), Value: Register("y"), Index: 1},
+				&Return{Value: Register("temp4"), Type: &PointerType{Elem: NamedType("Foo")}},
+			},
+		},
+	}) {
+		t.Error("Wrong blocks parsed")
+	}
+}
+
+func TestParseInstructions(t *testing.T) {
+	module, err := testParse(`
+extern print(%str : string) : void
+global %counter : int64
+
+fn apply(%f : fn(int32) : int32, %v : int32) : int32 {
+  %res = call %f(%v) : int32
+  return %res : int32
+}
+
+fn main() : void {
+  %t0 = -1 : int32
+  %t1 = cast %t0 : int64
+  %t2 = neg %t1 : int64
+  %t3 = not true : bool
+  %t4 = "hello" : string
+  call print(%t4) : void
+  %t5 = apply : fn(fn(int32) : int32, int32) : int32
+  %t6 = load %counter : int64
+  %t7 = %t6 - -2.5 : float64
+  %t8 = null : fn() : void
+  free %t4
+  retain %t8
+  release %t8
+  br exit
+exit:
+  return
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(module.Externs, []*Extern{
+		{Name: "print", Params: []*Param{{Name: "str", Type: String}}, ReturnType: Void},
+	}) {
+		t.Error("Wrong externs", module.Externs)
+	}
+
+	if !reflect.DeepEqual(module.Globals, []*Global{{Name: "counter", Type: Int64}}) {
+		t.Error("Wrong globals", module.Globals)
+	}
+
+	fnType := &FunctionType{Params: []Type{Int32}, Return: Int32}
+	if typ := module.Function("apply").Params[0].Type; !reflect.DeepEqual(typ, fnType) {
+		t.Error("Wrong function type", typ)
+	}
+
+	if !reflect.DeepEqual(module.Function("apply").Blocks[0].Instructions[0], &Call{
+		Dest:      "res",
+		Callee:    Register("f"),
+		Arguments: []Operand{Register("v")},
+		Type:      Int32,
+	}) {
+		t.Error("Wrong indirect call")
+	}
+
+	main := module.Function("main")
+	if !reflect.DeepEqual(main.Blocks[0].Instructions, []Instruction{
+		&Assign{Dest: "t0", Value: Constant{Value: int64(-1)}, Type: Int32},
+		&Cast{Dest: "t1", Value: Register("t0"), Type: Int64},
+		&UnaryOp{Dest: "t2", Operator: "neg", Value: Register("t1"), Type: Int64},
+		&UnaryOp{Dest: "t3", Operator: "not", Value: Constant{Value: true}, Type: Bool},
+		&Assign{Dest: "t4", Value: Constant{Value: "hello"}, Type: String},
+		&Call{Callee: FunctionRef("print"), Arguments: []Operand{Register("t4")}, Type: Void},
+		&Assign{Dest: "t5", Value: FunctionRef("apply"), Type: &FunctionType{Params: []Type{fnType, Int32}, Return: Int32}},
+		&Load{Dest: "t6", Ptr: Register("counter"), Type: Int64},
+		&BinaryOp{Dest: "t7", Operator: "-", Left: Register("t6"), Right: Constant{Value: float64(-2.5)}, Type: Float64},
+		&Assign{Dest: "t8", Value: Constant{}, Type: &FunctionType{Return: Void}},
+		&Free{Ptr: Register("t4")},
+		&Retain{Ptr: Register("t8")},
+		&Release{Ptr: Register("t8")},
+		&Br{Label: "exit"},
+	}) {
+		t.Error("Wrong instructions parsed")
+	}
+
+	if !reflect.DeepEqual(main.Block("exit").Instructions, []Instruction{&Return{}}) {
+		t.Error("Wrong return parsed")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{"foo", "1:1: Unexpected token IDENT(foo)"},
+		{"type Foo", "1:9: Expected type got EOF"},
+		{"type Foo {int32,}", "1:17: Expected type got RBRACE(})"},
+		{"type Foo int32 type Foo int32", "1:21: type Foo already declared"},
+		{"fn foo() : Bar {}", "1:12: undefined type Bar"},
+		{"fn foo() : ptr<int32 {}", "1:22: Expected [RCHEV] got LBRACE"},
+		{"fn foo(%x) : void {}", "1:10: Expected [COLON] got RPAREN"},
+		{"fn foo() : void { br bar }", "1:22: undefined label bar"},
+		{"fn foo() : void { a: a: }", "1:22: label a already defined"},
+		{"fn foo() : void { %x = 1 }", "1:26: Expected [COLON] got RBRACE"},
+		{"fn foo() : void { %x 1 }", "1:22: Expected [ASSIGN] got NUMBER"},
+		{"fn foo() : void { jump }", "1:19: Expected instruction or label got IDENT(jump)"},
+		{"fn foo() : void { store %x 1 }", "1:28: Expected [COMMA] got NUMBER"},
+		{"fn foo() : void { %x = load %y, %z : int32 }", "1:33: Expected index got IDENT(%z)"},
+		{"fn foo() : void { return", "1:25: Expected [RBRACE] got EOF"},
+		{"fn store() : void {}", "1:4: store is a reserved keyword"},
+		{"extern foo() : void fn foo() : void {}", "1:24: foo already declared"},
+	}
+
+	for _, test := range tests {
+		_, err := testParse(test.src)
+		if err == nil {
+			t.Errorf("Expected error for %s", test.src)
+			continue
+		}
+		if err.Error() != test.err {
+			t.Errorf("Wrong error for %s: expected %q got %q", test.src, test.err, err.Error())
+		}
 	}
 }
@@ -1 +1,202 @@
 package ir
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Printer renders modules in the textual IR format accepted by Parse
+type Printer struct {
+	buffer bytes.Buffer
+}
+
+// Print returns the textual representation of module
+func Print(module *Module) []byte {
+	printer := &Printer{}
+	printer.PrintModule(module)
+	return printer.Bytes()
+}
+
+// Bytes returns everything printed so far
+func (p *Printer) Bytes() []byte {
+	return p.buffer.Bytes()
+}
+
+func (p *Printer) write(format string, args ...interface{}) {
+	fmt.Fprintf(&p.buffer, format, args...)
+}
+
+// PrintModule prints type declarations, globals, externs and functions in that order
+func (p *Printer) PrintModule(module *Module) {
+	sections := 0
+	section := func() {
+		if sections > 0 {
+			p.write("\n")
+		}
+		sections++
+	}
+
+	if len(module.Types) > 0 {
+		section()
+		for _, decl := range module.Types {
+			p.write("type %s %s\n", decl.Name, decl.Type)
+		}
+	}
+
+	if len(module.Globals) > 0 {
+		section()
+		for _, global := range module.Globals {
+			p.write("global %%%s : %s\n", global.Name, global.Type)
+		}
+	}
+
+	if len(module.Externs) > 0 {
+		section()
+		for _, extern := range module.Externs {
+			externParams := params(extern.Params)
+			if extern.Variadic {
+				externParams = variadicList(externParams)
+			}
+			p.write("extern %s(%s) : %s\n", extern.Name, externParams, extern.ReturnType)
+		}
+	}
+
+	for _, fn := range module.Functions {
+		section()
+		p.PrintFunction(fn)
+	}
+}
+
+// PrintFunction prints a function with its blocks
+func (p *Printer) PrintFunction(fn *Function) {
+	p.write("fn %s(%s) : %s {\n", fn.Name, params(fn.Params), fn.ReturnType)
+	for i, block := range fn.Blocks {
+		if block.Label != "" {
+			if i > 0 {
+				p.write("\n")
+			}
+			p.write("%s:\n", block.Label)
+		}
+		for _, instr := range block.Instructions {
+			p.write("  %s\n", FormatInstruction(instr))
+		}
+	}
+	p.write("}\n")
+}
+
+func params(params []*Param) string {
+	strs := make([]string, len(params))
+	for i, param := range params {
+		strs[i] = fmt.Sprintf("%%%s : %s", param.Name, param.Type)
+	}
+	return strings.Join(strs, ", ")
+}
+
+// FormatInstruction returns the textual form of a single instruction
+func FormatInstruction(instr Instruction) string {
+	switch i := instr.(type) {
+	case *Assign:
+		return fmt.Sprintf("%s = %s : %s", FormatOperand(i.Dest), FormatOperand(i.Value), i.Type)
+	case *BinaryOp:
+		return fmt.Sprintf("%s = %s %s %s : %s", FormatOperand(i.Dest), FormatOperand(i.Left), i.Operator, FormatOperand(i.Right), i.Type)
+	case *UnaryOp:
+		return fmt.Sprintf("%s = %s %s : %s", FormatOperand(i.Dest), i.Operator, FormatOperand(i.Value), i.Type)
+	case *Cast:
+		return fmt.Sprintf("%s = cast %s : %s", FormatOperand(i.Dest), FormatOperand(i.Value), i.Type)
+	case *Call:
+		args := make([]string, len(i.Arguments))
+		for x, arg := range i.Arguments {
+			args[x] = FormatOperand(arg)
+		}
+		call := fmt.Sprintf("call %s(%s) : %s", FormatOperand(i.Callee), strings.Join(args, ", "), i.Type)
+		if i.Dest != "" {
+			return fmt.Sprintf("%s = %s", FormatOperand(i.Dest), call)
+		}
+		return call
+	case *Alloc:
+		return fmt.Sprintf("%s = alloc %s : %s", FormatOperand(i.Dest), i.AllocType, i.Type)
+	case *Load:
+		return fmt.Sprintf("%s = load %s%s : %s", FormatOperand(i.Dest), FormatOperand(i.Ptr), formatIndex(i.Index), i.Type)
+	case *Store:
+		return fmt.Sprintf("store %s, %s%s", FormatOperand(i.Ptr), FormatOperand(i.Value), formatIndex(i.Index))
+	case *Free:
+		return fmt.Sprintf("free %s", FormatOperand(i.Ptr))
+	case *Retain:
+		return fmt.Sprintf("retain %s", FormatOperand(i.Ptr))
+	case *Release:
+		return fmt.Sprintf("release %s", FormatOperand(i.Ptr))
+	case *Return:
+		if i.Value == nil {
+			return "return"
+		}
+		return fmt.Sprintf("return %s : %s", FormatOperand(i.Value), i.Type)
+	case *Br:
+		return fmt.Sprintf("br %s", i.Label)
+	case *BrCond:
+		return fmt.Sprintf("br_cond %s, %s, %s", FormatOperand(i.Condition), i.True, i.False)
+	}
+
+	panic(fmt.Errorf("Unknown instruction %T", instr))
+}
+
+func formatIndex(index int) string {
+	if index == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", %d", index)
+}
+
+// FormatOperand returns the textual form of an operand
+func FormatOperand(operand Operand) string {
+	switch o := operand.(type) {
+	case Register:
+		return "%" + string(o)
+	case FunctionRef:
+		return string(o)
+	case Constant:
+		switch val := o.Value.(type) {
+		case nil:
+			return keywordNull
+		case int64:
+			return strconv.FormatInt(val, 10)
+		case float64:
+			str := strconv.FormatFloat(val, 'f', -1, 64)
+			if !strings.Contains(str, ".") {
+				str += ".0"
+			}
+			return str
+		case bool:
+			return strconv.FormatBool(val)
+		case string:
+			return quote(val)
+		}
+	}
+
+	panic(fmt.Errorf("Unknown operand %#v", operand))
+}
+
+// quote quotes a string so that the orlang scanner reads back the same value
+func quote(str string) string {
+	var buf bytes.Buffer
+	buf.WriteRune('"')
+	for _, r := range str {
+		switch {
+		case r == '"' || r == '\\':
+			buf.WriteRune('\\')
+			buf.WriteRune(r)
+		case unicode.IsPrint(r):
+			buf.WriteRune(r)
+		case r < 0x100:
+			fmt.Fprintf(&buf, "\\x%02x", r)
+		case r < 0x10000:
+			fmt.Fprintf(&buf, "\\u%04x", r)
+		default:
+			fmt.Fprintf(&buf, "\\U%08x", r)
+		}
+	}
+	buf.WriteRune('"')
+	return buf.String()
+}
@@ -0,0 +1,91 @@
+package ir
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPrinterRoundTrip(t *testing.T) {
+	src := `type Foo {int32, ptr<Foo>}
+type Bar Foo
+
+global %counter : int64
+global %callback : fn(int32) : int32
+
+extern print(%str : string) : void
+
+fn apply(%f : fn(int32) : int32, %v : int32) : int32 {
+  %res = call %f(%v) : int32
+  return %res : int32
+}
+
+fn main() : void {
+  %t0 = -1 : int32
+  %t1 = cast %t0 : int64
+  %t2 = neg %t1 : int64
+  %t3 = not true : bool
+  %t4 = "hello \"world\"\x0a\\n" : string
+  call print(%t4) : void
+  %t5 = apply : fn(fn(int32) : int32, int32) : int32
+  %t6 = load %counter : int64
+  %t7 = %t6 - -2.5 : float64
+  %t8 = 1.0 : float32
+  %t9 = alloc Foo : ptr<Foo>
+  store %t9, null, 1
+  %t10 = load %t9, 1 : ptr<Foo>
+  %t11 = %t6 >= %t1 : bool
+  br_cond %t11, exit, loop
+
+loop:
+  retain %t10
+  release %t10
+  free %t9
+  br exit
+
+exit:
+  return
+}
+`
+
+	module, err := testParse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	printed := string(Print(module))
+	if printed != src {
+		t.Errorf("Printed module did not match source:\n%s", printed)
+	}
+
+	reparsed, err := testParse(printed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(module, reparsed) {
+		t.Error("Reparsed module is not identical")
+	}
+}
+
+func TestFormatOperand(t *testing.T) {
+	tests := []struct {
+		operand Operand
+		str     string
+	}{
+		{Register("temp0"), "%temp0"},
+		{FunctionRef("main"), "main"},
+		{Constant{}, "null"},
+		{Constant{Value: int64(-10)}, "-10"},
+		{Constant{Value: float64(100)}, "100.0"},
+		{Constant{Value: float64(0.25)}, "0.25"},
+		{Constant{Value: false}, "false"},
+		{Constant{Value: "tab\tquote\""}, `"tab\x09quote\""`},
+		{Constant{Value: "ä€\u2028"}, `"ä€\u2028"`},
+	}
+
+	for _, test := range tests {
+		if str := FormatOperand(test.operand); str != test.str {
+			t.Errorf("Expected %s got %s", test.str, str)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package ir
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type is a type of an IR value
+type Type interface {
+	String() string
+	irType()
+}
+
+// PrimitiveType is one of the built-in scalar types
+type PrimitiveType string
+
+var primitiveTypes = map[string]PrimitiveType{}
+
+var (
+	Int64   = registerPrimitive("int64")
+	Int32   = registerPrimitive("int32")
+	Int16   = registerPrimitive("int16")
+	Int8    = registerPrimitive("int8")
+	UInt64  = registerPrimitive("uint64")
+	UInt32  = registerPrimitive("uint32")
+	UInt16  = registerPrimitive("uint16")
+	UInt8   = registerPrimitive("uint8")
+	Float64 = registerPrimitive("float64")
+	Float32 = registerPrimitive("float32")
+	Bool    = registerPrimitive("bool")
+	String  = registerPrimitive("string")
+	Void    = registerPrimitive("void")
+)
+
+func registerPrimitive(name string) PrimitiveType {
+	typ := PrimitiveType(name)
+	primitiveTypes[name] = typ
+	return typ
+}
+
+func (PrimitiveType) irType() {}
+
+func (pt PrimitiveType) String() string {
+	return string(pt)
+}
+
+// IsInteger returns true for signed and unsigned integer types
+func (pt PrimitiveType) IsInteger() bool {
+	switch pt {
+	case Int64, Int32, Int16, Int8, UInt64, UInt32, UInt16, UInt8:
+		return true
+	}
+	return false
+}
+
+// IsFloat returns true for floating point types
+func (pt PrimitiveType) IsFloat() bool {
+	return pt == Float32 || pt == Float64
+}
+
+// StructType is an anonymous struct. Fields are referred by index
+type StructType struct {
+	Fields []Type
+}
+
+func (*StructType) irType() {}
+
+func (st *StructType) String() string {
+	return fmt.Sprintf("{%s}", typeList(st.Fields))
+}
+
+// PointerType is a pointer to a value of type Elem
+type PointerType struct {
+	Elem Type
+}
+
+func (*PointerType) irType() {}
+
+func (pt *PointerType) String() string {
+	return fmt.Sprintf("ptr<%s>", pt.Elem)
+}
+
+// NamedType refers to a type declared in the module with a type declaration
+type NamedType string
+
+func (NamedType) irType() {}
+
+func (nt NamedType) String() string {
+	return string(nt)
+}
+
+// FunctionType is the type of a function reference
+type FunctionType struct {
+	Params   []Type
+	Return   Type
+	Variadic bool
+}
+
+func (*FunctionType) irType() {}
+
+func (ft *FunctionType) String() string {
+	params := typeList(ft.Params)
+	if ft.Variadic {
+		params = variadicList(params)
+	}
+	return fmt.Sprintf("fn(%s) : %s", params, ft.Return)
+}
+
+// variadicList appends the ellipsis of variadic functions to a parameter list
+func variadicList(params string) string {
+	if params == "" {
+		return "..."
+	}
+	return params + ", ..."
+}
+
+func typeList(types []Type) string {
+	names := make([]string, len(types))
+	for i, typ := range types {
+		names[i] = typ.String()
+	}
+	return strings.Join(names, ", ")
+}
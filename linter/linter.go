@@ -2,9 +2,11 @@ package linter
 
 import (
 	"io"
+	"path/filepath"
 
 	"github.com/orktes/orlang/analyser"
 	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/loader"
 	"github.com/orktes/orlang/parser"
 	"github.com/orktes/orlang/scanner"
 )
@@ -17,14 +19,76 @@ type LintIssue struct {
 	Warning     bool
 }
 
+type linter struct {
+	issues []LintIssue
+}
+
 func Lint(r io.Reader, configureAnalyzer func(analyzer *analyser.Analyser)) (issues []LintIssue, err error) {
+	l := &linter{}
 	p := parser.NewParser(scanner.NewScanner(r))
+	l.configureParser(p)
+
+	file, err := p.Parse()
+	if err != nil {
+		return
+	}
+
+	analyser, err := analyser.New(file)
+	if err != nil {
+		return
+	}
+
+	l.analyse(analyser, file, configureAnalyzer)
+
+	return l.issues, nil
+}
+
+// LintFile lints the file in path. The files it imports are loaded with ldr
+// so that their declarations and macros can be used. Only the issues of the
+// file in path are reported. Errors in the imported files are returned as err
+func LintFile(path string, ldr *loader.Loader, configureAnalyzer func(analyzer *analyser.Analyser)) (issues []LintIssue, err error) {
+	l := &linter{}
+	mainPath := filepath.Clean(path)
+
+	fileLoader := *ldr
+	fileLoader.ConfigureParser = func(path string, p *parser.Parser) {
+		if path == mainPath {
+			l.configureParser(p)
+		}
+	}
+
+	program, err := fileLoader.Load(mainPath)
+	if err != nil {
+		if loadErr, ok := err.(*loader.Error); ok && loadErr.Filename == mainPath {
+			// Imports that can not be loaded are issues of the file
+			l.issues = append(l.issues, LintIssue{
+				Position:    loadErr.Position,
+				EndPosition: loadErr.Position,
+				Message:     loadErr.Message,
+			})
+			return l.issues, nil
+		}
+		return
+	}
+
+	analyser, err := analyser.NewProgram(program.Files, program.Imports)
+	if err != nil {
+		return
+	}
+
+	l.analyse(analyser, program.Main, configureAnalyzer)
+
+	return l.issues, nil
+}
+
+// configureParser makes p continue after errors and report them as issues
+func (l *linter) configureParser(p *parser.Parser) {
 	p.ContinueOnErrors = true
 	lastTokenErrorIndex := -2
 	p.Error = func(tokenIndx int, pos ast.Position, endPosition ast.Position, message string) {
 		if tokenIndx != lastTokenErrorIndex+1 {
 			line := ""
-			issues = append(issues, LintIssue{
+			l.issues = append(l.issues, LintIssue{
 				Position:    pos,
 				EndPosition: endPosition,
 				Message:     message,
@@ -34,23 +98,19 @@ func Lint(r io.Reader, configureAnalyzer func(analyzer *analyser.Analyser)) (iss
 		}
 		lastTokenErrorIndex = tokenIndx
 	}
+}
 
-	file, err := p.Parse()
-	if err != nil {
-		return
-	}
-
-	analyser, err := analyser.New(file)
-	if err != nil {
-		return
-	}
-
+// analyse reports the analyser errors in file as issues
+func (l *linter) analyse(an *analyser.Analyser, file *ast.File, configureAnalyzer func(analyzer *analyser.Analyser)) {
 	if configureAnalyzer != nil {
-		configureAnalyzer(analyser)
+		configureAnalyzer(an)
 	}
 
-	analyser.Error = func(node ast.Node, message string, fatal bool) {
-		issues = append(issues, LintIssue{
+	an.Error = func(node ast.Node, message string, fatal bool) {
+		if an.CurrentFile() != file {
+			return
+		}
+		l.issues = append(l.issues, LintIssue{
 			Position:    node.StartPos(),
 			EndPosition: node.EndPos(),
 			Message:     message,
@@ -59,7 +119,5 @@ func Lint(r io.Reader, configureAnalyzer func(analyzer *analyser.Analyser)) (iss
 		})
 	}
 
-	analyser.Analyse()
-
-	return
+	an.Analyse()
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/loader"
 )
 
 func TestLinter(t *testing.T) {
@@ -72,3 +73,25 @@ func TestLinterAnalyzeErrors(t *testing.T) {
 		t.Errorf("Output didnt match expected output %+v", lintErrors)
 	}
 }
+
+func TestLintFile(t *testing.T) {
+	lintErrors, err := LintFile("testdata/main.or", &loader.Loader{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lintErrors) != 1 || lintErrors[0].Message != "unused declared but not used" || !lintErrors[0].Warning {
+		t.Errorf("Output didnt match expected output %+v", lintErrors)
+	}
+}
+
+func TestLintFileImportError(t *testing.T) {
+	lintErrors, err := LintFile("testdata/missing.or", &loader.Loader{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lintErrors) != 1 || !strings.HasPrefix(lintErrors[0].Message, `could not import "nothere"`) {
+		t.Errorf("Output didnt match expected output %+v", lintErrors)
+	}
+}
@@ -0,0 +1,245 @@
+// Package loader parses an orlang file together with all the files it
+// imports
+package loader
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/parser"
+	"github.com/orktes/orlang/scanner"
+	"github.com/orktes/orlang/std"
+)
+
+// Extension is appended to import paths without an extension
+const Extension = ".or"
+
+// Error is an error in a loaded file
+type Error struct {
+	ast.Position
+	Filename string
+	Message  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.Filename, e.Position.Line+1, e.Position.Column+1, e.Message)
+}
+
+// Program is a main file and the files it imports
+type Program struct {
+	Main *ast.File
+	// Files are ordered so that every file comes after the files it imports.
+	// The main file is always last
+	Files   []*ast.File
+	Imports map[*ast.Import]*ast.File
+	// Sources contains the source code of each file
+	Sources map[*ast.File][]byte
+	// Expanded contains the tokens of the main file after macro expansion if
+	// the program was loaded with Expand
+	Expanded []parser.ExpandedToken
+}
+
+// Loader loads programs
+type Loader struct {
+	// Resolve returns the path of the file imported with path from the file
+	// importer. ImportPath is used if Resolve is nil
+	Resolve func(importer string, path string) (string, error)
+	// Expand records the main file after macro expansion in Program.Expanded
+	Expand bool
+	// ConfigureParser is called with the parser of each file before the
+	// file is parsed
+	ConfigureParser func(path string, p *parser.Parser)
+}
+
+type loader struct {
+	*Loader
+	program *Program
+	// files by absolute path
+	files map[string]*ast.File
+	// stack of files being loaded, used for cycle detection
+	stack []string
+	keys  []string
+}
+
+// Load parses the file in path and recursively all the files it imports.
+// Import paths are relative to the directory of the importing file
+func Load(path string) (*Program, error) {
+	return (&Loader{}).Load(path)
+}
+
+// Load parses the file in path and recursively all the files it imports.
+// Files importing each other directly or indirectly are reported as an error
+func (ldr *Loader) Load(path string) (*Program, error) {
+	l := &loader{
+		Loader: ldr,
+		program: &Program{
+			Imports: map[*ast.Import]*ast.File{},
+			Sources: map[*ast.File][]byte{},
+		},
+		files: map[string]*ast.File{},
+	}
+
+	main, err := l.load(filepath.Clean(path), false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	l.program.Main = main
+	return l.program, nil
+}
+
+// ImportPath returns the path of the file imported with path from the file
+// importer relative to the directory of the importer
+func ImportPath(importer string, path string) string {
+	if filepath.Ext(path) == "" {
+		path += Extension
+	}
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(filepath.Dir(importer), path)
+}
+
+// load loads the file in path. Files of the standard library are read from
+// the std package
+func (l *loader) load(path string, stdlib bool, importedBy *ast.Import) (*ast.File, error) {
+	key, err := l.key(path, stdlib)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, loading := range l.keys {
+		if loading == key {
+			cycle := append(append([]string{}, l.stack[i:]...), path)
+			return nil, &Error{
+				Filename: l.stack[len(l.stack)-1],
+				Position: importedBy.StartPos(),
+				Message:  fmt.Sprintf("import cycle: %s", strings.Join(cycle, " -> ")),
+			}
+		}
+	}
+
+	if file, ok := l.files[key]; ok {
+		return file, nil
+	}
+
+	src, err := l.read(path, stdlib)
+	if err != nil {
+		if importedBy == nil {
+			return nil, err
+		}
+		return nil, &Error{
+			Filename: l.stack[len(l.stack)-1],
+			Position: importedBy.StartPos(),
+			Message:  fmt.Sprintf("could not import %q: %s", importedBy.Path, err),
+		}
+	}
+
+	l.stack = append(l.stack, path)
+	l.keys = append(l.keys, key)
+
+	// Imported files are loaded while parsing so that their macros can be
+	// called in the rest of the file
+	p := parser.NewParser(scanner.NewScanner(bytes.NewReader(src)))
+	p.Filename = path
+	if importedBy == nil && l.Expand {
+		p.RecordExpansion()
+	}
+	p.Import = func(importDecl *ast.Import) ([]*ast.Macro, error) {
+		depPath, depStdlib, err := l.resolve(path, stdlib, importDecl.Path)
+		if err != nil {
+			return nil, &Error{Filename: path, Position: importDecl.StartPos(), Message: err.Error()}
+		}
+
+		dep, err := l.load(depPath, depStdlib, importDecl)
+		if err != nil {
+			return nil, err
+		}
+		l.program.Imports[importDecl] = dep
+
+		return ExportedMacros(dep), nil
+	}
+	if l.ConfigureParser != nil {
+		l.ConfigureParser(path, p)
+	}
+
+	file, err := p.Parse()
+	if err != nil {
+		if posErr, ok := err.(*parser.PosError); ok {
+			return nil, &Error{Filename: path, Position: posErr.Position, Message: posErr.Message}
+		}
+		return nil, err
+	}
+	file.Filename = path
+	if importedBy == nil && l.Expand {
+		l.program.Expanded = p.Expanded()
+	}
+
+	l.stack = l.stack[:len(l.stack)-1]
+	l.keys = l.keys[:len(l.keys)-1]
+
+	l.files[key] = file
+	l.program.Files = append(l.program.Files, file)
+	l.program.Sources[file] = src
+
+	return file, nil
+}
+
+// key returns the absolute path of a file. Files of the standard library keep
+// their import path
+func (l *loader) key(path string, stdlib bool) (string, error) {
+	if stdlib {
+		return filepath.ToSlash(path), nil
+	}
+	return filepath.Abs(path)
+}
+
+// ExportedMacros returns the macros exported by a file
+func ExportedMacros(file *ast.File) (macros []*ast.Macro) {
+	for _, node := range file.Exports {
+		if macro, ok := node.(*ast.Macro); ok {
+			macros = append(macros, macro)
+		}
+	}
+	return
+}
+
+// read returns the source of a file. Files of the standard library are read
+// from the std package
+func (l *loader) read(path string, stdlib bool) ([]byte, error) {
+	if stdlib {
+		return std.ReadFile(strings.TrimPrefix(filepath.ToSlash(path), std.Prefix))
+	}
+	return ioutil.ReadFile(path)
+}
+
+// resolve returns the path of the file imported with path from the file
+// importer and whether it is a file of the standard library. Import paths
+// starting with std/ and relative imports made by the standard library refer
+// to the standard library
+func (l *loader) resolve(importer string, importerStdlib bool, path string) (string, bool, error) {
+	if strings.HasPrefix(path, std.Prefix) {
+		if filepath.Ext(path) == "" {
+			path += Extension
+		}
+		return path, true, nil
+	}
+
+	if importerStdlib {
+		return filepath.ToSlash(ImportPath(importer, path)), true, nil
+	}
+
+	if l.Resolve == nil {
+		return ImportPath(importer, path), false, nil
+	}
+
+	resolved, err := l.Resolve(importer, path)
+	if err != nil {
+		return "", false, err
+	}
+	return filepath.Clean(resolved), false, nil
+}
@@ -0,0 +1,134 @@
+package loader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/orktes/orlang/parser"
+)
+
+func TestLoad(t *testing.T) {
+	program, err := Load("testdata/program/main.or")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		filepath.Join("testdata", "program", "lib", "math.or"),
+		filepath.Join("testdata", "program", "lib", "point.or"),
+		filepath.Join("testdata", "program", "main.or"),
+	}
+
+	if len(program.Files) != len(expected) {
+		t.Fatalf("Expected %d files got %d", len(expected), len(program.Files))
+	}
+
+	for i, file := range program.Files {
+		if file.Filename != expected[i] {
+			t.Errorf("Expected file %d to be %s got %s", i, expected[i], file.Filename)
+		}
+		if len(program.Sources[file]) == 0 {
+			t.Errorf("Missing source for %s", file.Filename)
+		}
+	}
+
+	if program.Main != program.Files[2] {
+		t.Error("Main file should be the last file")
+	}
+
+	// Both imports of math.or resolve to the same file
+	pointFile := program.Imports[program.Main.Imports[1]]
+	if pointFile != program.Files[1] || program.Imports[pointFile.Imports[0]] != program.Files[0] {
+		t.Error("Imports resolved to wrong files")
+	}
+}
+
+func TestLoadLocalStd(t *testing.T) {
+	program, err := Load("testdata/localstd/main.or")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		filepath.Join("testdata", "localstd", "std", "util.or"),
+		"std/macros.or",
+		filepath.Join("testdata", "localstd", "main.or"),
+	}
+	for i, file := range program.Files {
+		if file.Filename != expected[i] {
+			t.Errorf("Expected file %d to be %s got %s", i, expected[i], file.Filename)
+		}
+	}
+
+	// Local files in a directory named std are not part of the standard
+	// library
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(filepath.Join("testdata", "localstd")); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	program, err = Load("std/main.or")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(program.Files) != 2 || program.Files[0].Filename != filepath.Join("std", "util.or") {
+		t.Errorf("Expected std/main.or to import the local std/util.or")
+	}
+}
+
+func TestLoadMacros(t *testing.T) {
+	program, err := (&Loader{Expand: true}).Load("testdata/macros/main.or")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		filepath.Join("testdata", "macros", "lib", "macros.or"),
+		"std/macros.or",
+		filepath.Join("testdata", "macros", "main.or"),
+	}
+
+	if len(program.Files) != len(expected) {
+		t.Fatalf("Expected %d files got %d", len(expected), len(program.Files))
+	}
+
+	for i, file := range program.Files {
+		if file.Filename != expected[i] {
+			t.Errorf("Expected file %d to be %s got %s", i, expected[i], file.Filename)
+		}
+	}
+
+	expanded := parser.FormatExpanded(program.Expanded, false)
+	if !strings.Contains(expanded, "var (a, b) = ((1 * 2), 2)") || !strings.Contains(expanded, "a = b * 2") {
+		t.Errorf("Macros not expanded:\n%s", expanded)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		path string
+		err  string
+	}{
+		{"testdata/cycle/a.or", "testdata/cycle/c.or:1:1: import cycle: testdata/cycle/b.or -> testdata/cycle/c.or -> testdata/cycle/b.or"},
+		{"testdata/errors/missing.or", `testdata/errors/missing.or:1:1: could not import "nothere": open testdata/errors/nothere.or: no such file or directory`},
+		{"testdata/errors/macro.or", "testdata/errors/macro.or:4:19: Macro call doens't match available patterns"},
+		{"testdata/errors/parse.or", "testdata/errors/syntax.or:1:9: Expected [IDENT RPAREN] got LBRACE"},
+	}
+
+	for _, test := range tests {
+		_, err := Load(test.path)
+		if err == nil {
+			t.Errorf("Expected error for %s", test.path)
+			continue
+		}
+		if err.Error() != test.err {
+			t.Errorf("Wrong error for %s: expected %q got %q", test.path, test.err, err.Error())
+		}
+	}
+}
@@ -19,6 +19,7 @@ func (p *Parser) parseBlock() (node *ast.Block, ok bool) {
 
 loop:
 	for {
+		statementStart := len(p.expanded)
 		var blockNode ast.Node
 		var check = func(n ast.Node, ok bool) bool {
 			if ok {
@@ -39,6 +40,7 @@ loop:
 		if blockNode != nil {
 			node.AppendNode(blockNode)
 		}
+		p.markStatement(statementStart)
 	}
 
 	ok = true
@@ -0,0 +1,183 @@
+package parser
+
+import (
+	"github.com/orktes/orlang/ast"
+
+	"github.com/orktes/orlang/scanner"
+)
+
+func (p *Parser) parseEnum() (node *ast.Enum, ok bool) {
+	token := p.read()
+	if token.Type != scanner.TokenTypeIdent || token.Text != keywordEnum {
+		p.unread()
+		return
+	}
+
+	ok = true
+	node = &ast.Enum{Start: ast.StartPositionFromToken(token)}
+
+	identifier, _ := p.parseIdentfier()
+	node.Name = identifier
+
+	if leftBrace, leftBraceOk := p.expectToken(scanner.TokenTypeLBRACE); !leftBraceOk {
+		p.error(unexpectedToken(leftBrace, scanner.TokenTypeLBRACE))
+		return
+	}
+
+	for {
+		variantStart := len(p.expanded)
+		variant, variantOk := p.parseEnumVariant()
+		if !variantOk {
+			break
+		}
+		node.Variants = append(node.Variants, variant)
+		p.markStatement(variantStart)
+
+		if _, commaOk := p.expectToken(scanner.TokenTypeCOMMA); !commaOk {
+			p.unread()
+		}
+	}
+
+	if rightBrace, rightBraceOk := p.expectToken(scanner.TokenTypeRBRACE); rightBraceOk {
+		node.End = ast.EndPositionFromToken(rightBrace)
+	} else {
+		p.error(unexpectedToken(rightBrace, scanner.TokenTypeRBRACE))
+	}
+
+	return
+}
+
+func (p *Parser) parseEnumVariant() (node *ast.EnumVariant, ok bool) {
+	identifier, ok := p.parseIdentfier()
+	if !ok {
+		return
+	}
+
+	node = &ast.EnumVariant{Name: identifier}
+
+	if _, lParenOk := p.expectToken(scanner.TokenTypeLPAREN); !lParenOk {
+		p.unread()
+		return
+	}
+
+	for {
+		typ, typOk := p.parseType()
+		if !typOk {
+			p.error(unexpected(p.read().StringValue(), "type"))
+			return
+		}
+		node.Types = append(node.Types, typ)
+
+		if _, commaOk := p.expectToken(scanner.TokenTypeCOMMA); !commaOk {
+			p.unread()
+			break
+		}
+	}
+
+	rParen, rParenOk := p.expectToken(scanner.TokenTypeRPAREN)
+	if !rParenOk {
+		p.error(unexpectedToken(rParen, scanner.TokenTypeRPAREN))
+		return
+	}
+	node.End = ast.EndPositionFromToken(rParen)
+
+	return
+}
+
+func (p *Parser) parseMatchExpression() (node *ast.MatchExpression, ok bool) {
+	token := p.read()
+	if token.Type != scanner.TokenTypeIdent || token.Text != keywordMatch {
+		p.unread()
+		return
+	}
+
+	ok = true
+	node = &ast.MatchExpression{Start: ast.StartPositionFromToken(token)}
+
+	noStructExpression := p.noStructExpression
+	defer func() { p.noStructExpression = noStructExpression }()
+
+	p.noStructExpression = true
+	subject, subjectOk := p.parseExpression()
+	p.noStructExpression = false
+	if !subjectOk {
+		p.error(unexpected(p.read().StringValue(), "expression"))
+		return
+	}
+	node.Subject = subject
+
+	if leftBrace, leftBraceOk := p.expectToken(scanner.TokenTypeLBRACE); !leftBraceOk {
+		p.error(unexpectedToken(leftBrace, scanner.TokenTypeLBRACE))
+		return
+	}
+
+	for {
+		armStart := len(p.expanded)
+		arm, armOk := p.parseMatchArm()
+		if !armOk {
+			break
+		}
+		node.Arms = append(node.Arms, arm)
+		p.markStatement(armStart)
+
+		if _, commaOk := p.expectToken(scanner.TokenTypeCOMMA); !commaOk {
+			p.unread()
+			// Arms with a code block do not need to be separated with a comma
+			if _, isBlock := arm.Body.(*ast.Block); !isBlock {
+				break
+			}
+		}
+	}
+
+	if rightBrace, rightBraceOk := p.expectToken(scanner.TokenTypeRBRACE); rightBraceOk {
+		node.End = ast.EndPositionFromToken(rightBrace)
+	} else {
+		p.error(unexpectedToken(rightBrace, scanner.TokenTypeRBRACE))
+	}
+
+	return
+}
+
+func (p *Parser) parseMatchArm() (node *ast.MatchArm, ok bool) {
+	pattern, ok := p.parseVariantPattern()
+	if !ok {
+		return
+	}
+
+	node = &ast.MatchArm{Pattern: pattern}
+
+	if arrow, arrowOk := p.expectToken(scanner.TokenTypeArrow); !arrowOk {
+		p.error(unexpectedToken(arrow, scanner.TokenTypeArrow))
+		return
+	}
+
+	if block, blockOk := p.parseBlock(); blockOk {
+		node.Body = block
+	} else if expr, exprOk := p.parseExpression(); exprOk {
+		node.Body = expr
+	} else {
+		p.error(unexpected(p.read().StringValue(), "expression or code block"))
+	}
+
+	return
+}
+
+func (p *Parser) parseVariantPattern() (pattern ast.Pattern, ok bool) {
+	identifier, ok := p.parseIdentfier()
+	if !ok {
+		return
+	}
+
+	_, lParenOk := p.expectToken(scanner.TokenTypeLPAREN)
+	p.unread()
+	if !lParenOk {
+		return identifier, true
+	}
+
+	payload, payloadOk := p.parseTuplePattern()
+	if !payloadOk {
+		return identifier, true
+	}
+
+	return &ast.VariantPattern{Name: identifier, Payload: payload}, true
+}
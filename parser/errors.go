@@ -13,7 +13,11 @@ type PosError struct {
 }
 
 func (p PosError) Error() string {
-	return fmt.Sprintf("%d:%d: %s", p.Position.Line+1, p.Position.Column+1, p.Message)
+	msg := fmt.Sprintf("%d:%d: %s", p.Position.Line+1, p.Position.Column+1, p.Message)
+	for _, expansion := range p.Position.Expansions() {
+		msg += "\n\t" + expansion.String()
+	}
+	return msg
 }
 
 func unexpected(got string, expected string) string {
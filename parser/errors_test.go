@@ -25,6 +25,9 @@ func TestParseFailures(t *testing.T) {
 		{"fn test(foo : int) {]", "1:21: Expected code block got RBRACK(])"},
 		{"fn", "1:3: Expected function name or argument list got EOF"},
 		{"fn ( {}", "1:6: Expected [IDENT RPAREN] got LBRACE"},
+		// Imports and exports
+		{"import foo", "1:8: Expected [STRING] got foo"},
+		{"export 1", "1:8: Expected declaration got NUMBER(1)"},
 		// Variable declarations
 		{"var [", "1:5: Expected variable or tuple declaration got LBRACK([)"},
 		{"var foo = (1", "1:13: Expected [RPAREN] got EOF"},
@@ -61,6 +64,20 @@ func TestParseFailures(t *testing.T) {
 		{"fn foobar() {  fn foobar(i:int;) {} }", "1:31: Expected [RPAREN COMMA] got SEMICOLON"},
 		// Member expressions
 		{"fn foobar() {  foobar.false }", "1:23: Expected property name got BOOL(false)"},
+		// Maps
+		{"var foo : map", "1:14: Expected [LBRACK] got EOF"},
+		{"var foo : map[string", "1:21: Expected [RBRACK] got EOF"},
+		{"var foo : map[string]", "1:22: Expected map value type got EOF"},
+		{"var foo = map[string]int32{\"a\" 1}", "1:32: Expected [COLON] got NUMBER"},
+		{"var foo = map[string]int32{\"a\": }", "1:33: Expected map value got RBRACE(})"},
+		{"fn foobar() { for a, b in {} }", "1:27: Expected expression got LBRACE({)"},
+		// Pointers
+		{"var foo : *", "1:12: Expected pointer element type got EOF"},
+		{"var foo = &", "1:12: Expected expression got EOF"},
+		// Index expressions
+		{"fn foobar() {  foo[] }", "1:20: Expected index expression got RBRACK(])"},
+		{"fn foobar() {  foo[1 }", "1:22: Expected [RBRACK] got RBRACE"},
+		{"fn foobar() {  foo[1: }", "1:23: Expected [RBRACK] got RBRACE"},
 		// Reservedkeyword
 		{"fn return() {  }", "1:4: return is a reserved keyword"},
 		{"fn foobar() { var fn = 1 }", "1:19: fn is a reserved keyword"},
@@ -98,10 +115,21 @@ func TestParseFailures(t *testing.T) {
 		{"fn main() { M!(foo) }", "1:13: No macro with name M"},
 		{"macro M { (", "1:12: Expected token but got eof"},
 		{"macro M { ($()", "1:15: Expected macro repetition delimeter or operand (+, * or ?) got EOF"},
+		{"macro M { () : (var = 1) } fn main() { M!() }", "1:40: Expected variable or tuple declaration got ASSIGN(=)\n\tin expansion of macro M defined at 1:21"},
+		{"macro M { ($x:literal) : ($x) } fn main() { M!(foo) }", "1:48: No rules expected token IDENT(foo)"},
+		{"macro M { ($x:tt) : ($x) } fn main() { M!((1]) }", "1:45: Expected [RPAREN] got RBRACK"},
+		{"macro M { ($x:tt) : ($x) } fn main() { M!((1 }", "1:46: Expected [RPAREN] got RBRACE"},
+		{"macro M { ($x:ident) : (var $x = 1) } fn main() { M!(1) }", "1:54: No rules expected token NUMBER(1)"},
+		{"macro M { () : (var = 1) } macro N { () : (M!()) } fn main() { N!() }", "1:64: Expected variable or tuple declaration got ASSIGN(=)\n\tin expansion of macro M defined at 1:21\n\tin expansion of macro N defined at 1:44"},
 		// structs
 		{"struct {", "1:9: Expected [RBRACE] got EOF"},
 		{"struct", "1:7: Expected [LBRACE] got EOF"},
 		{"struct { var (foo, bar) = (1,2) }", "1:33: Expected variable declaration or member function got tuple declration"},
+		// type assertions
+		{"fn main() { x.(type) }", "1:16: use of .(type) outside type switch"},
+		{"fn main() { x.() }", "1:16: Expected type got RPAREN())"},
+		{"fn main() { switch x { } }", "1:22: Expected .(type) got LBRACE({)"},
+		{"fn main() { switch x.(type) { case { } } }", "1:36: Expected type got LBRACE({)"},
 	}
 	for _, test := range tests {
 		_, err := Parse(strings.NewReader(test.src))
@@ -0,0 +1,411 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/scanner"
+)
+
+// MacroCall is a macro call in the expanded source
+type MacroCall struct {
+	Name string
+	// Line and Column are the position of the call. Calls made by other
+	// macros are positioned in the definition of the calling macro
+	Line   int
+	Column int
+}
+
+// ExpandedToken is a token of the source after macro expansion
+type ExpandedToken struct {
+	scanner.Token
+	// Calls are the macro calls the token was expanded from starting from the
+	// outermost one
+	Calls []*MacroCall
+	// Statement is true if the token starts a statement, a declaration, a
+	// member of a struct or an interface, an enum variant, a match arm or a
+	// type switch case
+	Statement bool
+	// Binding is the name of an identifier introduced by a macro expansion
+	// that refers to a binding made by the same expansion. The name is
+	// suffixed with the mark of the expansion like tmp#1
+	Binding string
+}
+
+// recordedToken is a token consumed by the parser
+type recordedToken struct {
+	scanner.Token
+	statement bool
+}
+
+// binding is a name declared by a macro expansion with the mark of the
+// expansion
+type binding struct {
+	name string
+	mark int
+}
+
+// Expand parses source code and returns the tokens the parser consumed with
+// macro calls replaced by their expansions. Macro declarations are left out
+func Expand(reader io.Reader) (tokens []ExpandedToken, err error) {
+	p := NewParser(scanner.NewScanner(reader))
+	p.RecordExpansion()
+
+	if _, err = p.Parse(); err != nil {
+		return
+	}
+
+	return p.Expanded(), nil
+}
+
+// RecordExpansion makes the parser record the tokens it consumes so that the
+// source after macro expansion can be returned by Expanded
+func (p *Parser) RecordExpansion() {
+	p.recordExpansion = true
+	p.macroArguments = map[interface{}][]recordedToken{}
+}
+
+// Expanded returns the tokens consumed by Parse with macro calls replaced by
+// their expansions. RecordExpansion has to be called before parsing
+func (p *Parser) Expanded() []ExpandedToken {
+	e := &expander{parser: p, calls: map[macroCallKey]*MacroCall{}}
+	for _, token := range p.expanded {
+		if token.Type != scanner.TokenTypeEOF {
+			e.add(token, nil)
+		}
+	}
+
+	return e.tokens
+}
+
+func (p *Parser) recordToken(token scanner.Token) {
+	if p.recordExpansion {
+		p.expanded = append(p.expanded, recordedToken{Token: token})
+	}
+}
+
+// markStatement marks the token recorded at start as the start of a
+// statement. FormatExpanded starts a new line at each statement
+func (p *Parser) markStatement(start int) {
+	if p.recordExpansion && start < len(p.expanded) {
+		p.expanded[start].statement = true
+	}
+}
+
+// expansionBindings returns the names declared by macro expansions in file
+// that the analyser renames and the identifiers introduced by expansions that
+// keep their names. Struct fields, methods, interface methods and argument
+// names of calls are never renamed
+func expansionBindings(file *ast.File) (bindings map[binding]bool, unrenamed map[*scanner.Expansion]bool) {
+	bindings = map[binding]bool{}
+	unrenamed = map[*scanner.Expansion]bool{}
+	declare := func(ident *ast.Identifier) {
+		if ident != nil && ident.Mark != 0 {
+			bindings[binding{ident.Text, ident.Mark}] = true
+		}
+	}
+	keep := func(ident *ast.Identifier) {
+		if ident != nil && ident.Expansion != nil {
+			unrenamed[ident.Expansion] = true
+		}
+	}
+
+	var visit ast.VisitorFunc
+	visit = func(node ast.Node) ast.Visitor {
+		switch n := node.(type) {
+		case *ast.VariableDeclaration:
+			declare(n.Name)
+		case *ast.Argument:
+			declare(n.Name)
+		case *ast.FunctionSignature:
+			declare(n.Identifier)
+		case *ast.TuplePattern:
+			for _, pattern := range n.Patterns {
+				if ident, ok := pattern.(*ast.Identifier); ok {
+					declare(ident)
+				}
+			}
+		case *ast.ForInLoop:
+			declare(n.Key)
+			declare(n.Value)
+		case *ast.TypeSwitch:
+			declare(n.Binding)
+		case *ast.CallArgument:
+			keep(n.Name)
+		case *ast.Struct:
+			for _, field := range n.Variables {
+				keep(field.Name)
+				ast.Walk(visit, field.Type)
+				ast.Walk(visit, field.DefaultValue)
+			}
+			for _, fn := range n.Functions {
+				keep(fn.Signature.Identifier)
+				for _, arg := range fn.Signature.Arguments {
+					ast.Walk(visit, arg)
+				}
+				ast.Walk(visit, fn.Block)
+			}
+			return nil
+		case *ast.Interface:
+			for _, fn := range n.Functions {
+				keep(fn.Identifier)
+			}
+			return nil
+		}
+		return visit
+	}
+	ast.Walk(visit, file)
+
+	return
+}
+
+// truncateExpanded removes the recorded tokens after length. Tokens returned
+// to the buffer are recorded again once they are read
+func (p *Parser) truncateExpanded(length int) {
+	if length >= 0 && length < len(p.expanded) {
+		p.expanded = p.expanded[:length]
+	}
+}
+
+// recordMacroArgument records the tokens a macro argument was parsed from.
+// Arguments passed on to other macros keep the tokens of the original call
+func (p *Parser) recordMacroArgument(value interface{}, start int) {
+	if !p.recordExpansion || start > len(p.expanded) {
+		return
+	}
+
+	switch value.(type) {
+	case scanner.Token, *macroTokenTree:
+		return
+	}
+
+	if _, recorded := p.macroArguments[value]; !recorded {
+		p.macroArguments[value] = append([]recordedToken{}, p.expanded[start:]...)
+	}
+}
+
+type macroCallKey struct {
+	parent *scanner.Expansion
+	line   int
+	column int
+}
+
+type expander struct {
+	parser *Parser
+	calls  map[macroCallKey]*MacroCall
+	tokens []ExpandedToken
+	// statement is true if the next token starts a statement. It is set
+	// when a macro argument is substituted at the start of a statement
+	statement bool
+}
+
+func (e *expander) add(token recordedToken, substitution []*MacroCall) {
+	calls := e.nestCalls(substitution, e.tokenCalls(token.Token))
+	e.statement = e.statement || token.statement
+
+	if token.Type == scanner.TokenTypeMacroIdent {
+		if argument, ok := e.parser.macroArguments[token.Value]; ok {
+			_, isExpression := token.Value.(ast.Expression)
+			_, isBlock := token.Value.(*ast.Block)
+			parenthesize := isExpression && !isBlock && len(argument) > 1
+			if parenthesize {
+				e.add(argumentParen(argument[0], scanner.TokenTypeLPAREN, "("), calls)
+			}
+			for _, argumentToken := range argument {
+				e.add(argumentToken, calls)
+			}
+			if parenthesize {
+				e.add(argumentParen(argument[len(argument)-1], scanner.TokenTypeRPAREN, ")"), calls)
+			}
+			return
+		}
+
+		if argumentToken, ok := token.Value.(scanner.Token); ok {
+			e.add(recordedToken{Token: argumentToken}, calls)
+			return
+		}
+	}
+
+	expanded := ExpandedToken{Token: token.Token, Calls: calls, Statement: e.statement}
+	// Properties are never renamed
+	property := len(e.tokens) > 0 && e.tokens[len(e.tokens)-1].Type == scanner.TokenTypePERIOD
+	renamed := e.parser.bindings[binding{token.Text, token.Mark}] && !e.parser.unrenamed[token.Expansion]
+	if token.Type == scanner.TokenTypeIdent && token.Mark != 0 && !property && renamed {
+		expanded.Binding = fmt.Sprintf("%s#%d", token.Text, token.Mark)
+	}
+	e.tokens = append(e.tokens, expanded)
+	e.statement = false
+}
+
+// argumentParen returns a parenthesis placed around an expression substituted
+// for a macro argument to keep the precedence of the expression
+func argumentParen(token recordedToken, typ scanner.TokenType, text string) recordedToken {
+	token.Type = typ
+	token.Text = text
+	token.Value = nil
+	token.statement = false
+	return token
+}
+
+// tokenCalls returns the macro calls a token was expanded from
+func (e *expander) tokenCalls(token scanner.Token) (calls []*MacroCall) {
+	for expansion := token.Expansion; expansion != nil; expansion = expansion.Call.Expansion {
+		calls = append([]*MacroCall{e.call(expansion)}, calls...)
+	}
+	return
+}
+
+func (e *expander) call(expansion *scanner.Expansion) *MacroCall {
+	key := macroCallKey{
+		parent: expansion.Call.Expansion,
+		line:   expansion.Call.StartLine,
+		column: expansion.Call.StartColumn,
+	}
+
+	if call, ok := e.calls[key]; ok {
+		return call
+	}
+
+	call := &MacroCall{
+		Name:   expansion.Macro.Text,
+		Line:   expansion.Call.StartLine,
+		Column: expansion.Call.StartColumn,
+	}
+	if parent := expansion.Call.Expansion; parent != nil {
+		call.Line = parent.Line
+		call.Column = parent.Column
+	}
+
+	e.calls[key] = call
+	return call
+}
+
+// nestCalls places the calls of a token substituted for a macro argument
+// inside the calls of the substitution
+func (e *expander) nestCalls(substitution []*MacroCall, calls []*MacroCall) []*MacroCall {
+	common := 0
+	for common < len(substitution) && common < len(calls) && substitution[common] == calls[common] {
+		common++
+	}
+
+	if common == len(calls) && len(substitution) > 0 {
+		return substitution
+	}
+
+	return append(append([]*MacroCall{}, substitution...), calls[common:]...)
+}
+
+// FormatExpanded formats expanded tokens as source code. Statements start on
+// a line of their own. Bindings introduced by macro expansions are written
+// with the mark of the expansion. Markers place comments around the code
+// produced by each macro call
+func FormatExpanded(tokens []ExpandedToken, markers bool) string {
+	var buf bytes.Buffer
+
+	depth := 0
+	// blocks records for each open brace if the statements inside it were
+	// written on lines of their own
+	blocks := []bool{false}
+	var prev *ExpandedToken
+	var open []*MacroCall
+
+	write := func(text string, space bool) {
+		if buf.Len() > 0 && space {
+			last := buf.Bytes()[buf.Len()-1]
+			if last != '\n' && last != '\t' {
+				buf.WriteString(" ")
+			}
+		}
+		buf.WriteString(text)
+	}
+
+	newLine := func() {
+		buf.WriteString("\n")
+		buf.WriteString(strings.Repeat("\t", depth))
+	}
+
+	for i := range tokens {
+		token := &tokens[i]
+
+		common := 0
+		for common < len(open) && common < len(token.Calls) && open[common] == token.Calls[common] {
+			common++
+		}
+
+		if markers {
+			for j := len(open) - 1; j >= common; j-- {
+				write(fmt.Sprintf("/* end %s! */", open[j].Name), true)
+			}
+		}
+
+		if token.Type == scanner.TokenTypeRBRACE && len(blocks) > 1 {
+			depth--
+			if blocks[len(blocks)-1] {
+				newLine()
+			}
+			blocks = blocks[:len(blocks)-1]
+		}
+
+		if token.Statement && prev != nil {
+			newLine()
+			blocks[len(blocks)-1] = true
+		}
+
+		if markers {
+			for _, call := range token.Calls[common:] {
+				write(fmt.Sprintf("/* %s! %d:%d */", call.Name, call.Line+1, call.Column+1), true)
+			}
+		}
+		open = token.Calls
+
+		text := token.Text
+		if token.Binding != "" {
+			text = token.Binding
+		}
+		write(text, prev != nil && spaceBetween(prev.Token, token.Token))
+
+		if token.Type == scanner.TokenTypeLBRACE {
+			depth++
+			blocks = append(blocks, false)
+		}
+
+		prev = token
+	}
+
+	if markers {
+		for j := len(open) - 1; j >= 0; j-- {
+			write(fmt.Sprintf("/* end %s! */", open[j].Name), true)
+		}
+	}
+
+	if buf.Len() > 0 {
+		buf.WriteString("\n")
+	}
+
+	return buf.String()
+}
+
+func spaceBetween(prev scanner.Token, token scanner.Token) bool {
+	switch prev.Type {
+	case scanner.TokenTypeLPAREN, scanner.TokenTypeLBRACK, scanner.TokenTypePERIOD:
+		return false
+	}
+
+	switch token.Type {
+	case scanner.TokenTypeRPAREN, scanner.TokenTypeRBRACK, scanner.TokenTypeCOMMA,
+		scanner.TokenTypePERIOD, scanner.TokenTypeSEMICOLON:
+		return false
+	case scanner.TokenTypeLPAREN, scanner.TokenTypeLBRACK, scanner.TokenTypeIncrement, scanner.TokenTypeDecrement:
+		switch prev.Type {
+		case scanner.TokenTypeIdent:
+			return isKeyword(prev.Text)
+		case scanner.TokenTypeRPAREN, scanner.TokenTypeRBRACK:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,161 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	src := `
+		macro double {
+			($x:expr) : (
+				var tmp = $x
+				tmp = tmp * 2
+			)
+		}
+		macro twice {
+			($x:expr) : (
+				double!($x)
+				double!($x)
+			)
+		}
+		fn main() {
+			twice!(1 + 2)
+			var foo = CustomStruct{1}
+		}
+	`
+
+	tests := []struct {
+		markers bool
+		result  string
+	}{
+		{false, `fn main() {
+	var tmp#3 = (1 + 2)
+	tmp#3 = tmp#3 * 2
+	var tmp#2 = (1 + 2)
+	tmp#2 = tmp#2 * 2
+	var foo = CustomStruct { 1 }
+}
+`},
+		{true, `fn main() {
+	/* twice! 15:4 */ /* double! 10:5 */ var tmp#3 = (1 + 2)
+	tmp#3 = tmp#3 * 2 /* end double! */
+	/* double! 11:5 */ var tmp#2 = (1 + 2)
+	tmp#2 = tmp#2 * 2 /* end double! */ /* end twice! */
+	var foo = CustomStruct { 1 }
+}
+`},
+	}
+
+	for _, test := range tests {
+		tokens, err := Expand(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res := FormatExpanded(tokens, test.markers); res != test.result {
+			t.Errorf("Expected\n%s\ngot\n%s", test.result, res)
+		}
+	}
+}
+
+func TestExpandTokenTrees(t *testing.T) {
+	tokens, err := Expand(strings.NewReader(`
+		macro tokens {
+			($( $x:tt )*) : ($( $x )*)
+		}
+		fn main() {
+			var foo = 1
+			tokens!(foo = (foo + 1) * 2)
+		}
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `fn main() {
+	var foo = 1
+	/* tokens! 7:4 */ foo = (foo + 1) * 2 /* end tokens! */
+}
+`
+	if res := FormatExpanded(tokens, true); res != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, res)
+	}
+}
+
+func TestExpandHygiene(t *testing.T) {
+	tokens, err := Expand(strings.NewReader(`
+		macro swap {
+			($a:expr, $b:expr) : (
+				var tmp = $a
+				$a = $b
+				$b = tmp
+			)
+		}
+		fn main() {
+			var tmp = 1
+			var other = 2
+			swap!(tmp, other)
+		}
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `fn main() {
+	var tmp = 1
+	var other = 2
+	var tmp#1 = tmp
+	tmp = other
+	other = tmp#1
+}
+`
+	if res := FormatExpanded(tokens, false); res != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, res)
+	}
+}
+
+func TestExpandStructFields(t *testing.T) {
+	tokens, err := Expand(strings.NewReader(`
+		macro boxed {
+			($name:ident) : (
+				struct $name {
+					var value : int32
+					fn get(value : int32) => int32 {
+						return this.value + value
+					}
+				}
+				fn value() => $name {
+					var value = 1
+					return $name{value: value}
+				}
+			)
+		}
+		boxed!(Box)
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `struct Box {
+	var value : int32
+	fn get(value#1 : int32) => int32 {
+		return this.value + value#1
+	}
+}
+fn value#1() => Box {
+	var value#1 = 1
+	return Box { value : value#1 }
+}
+`
+	if res := FormatExpanded(tokens, false); res != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, res)
+	}
+}
+
+func TestExpandError(t *testing.T) {
+	_, err := Expand(strings.NewReader("macro M { () : (var = 1) } fn main() { M!() }"))
+	if err == nil || !strings.Contains(err.Error(), "in expansion of macro M") {
+		t.Errorf("Expected expansion error got %v", err)
+	}
+}
@@ -18,6 +18,8 @@ var unaryPrefix = []scanner.TokenType{
 	scanner.TokenTypeIncrement,
 	scanner.TokenTypeDecrement,
 	scanner.TokenTypeEXCL,
+	scanner.TokenTypeASTERISK,
+	scanner.TokenTypeAMPERSAND,
 }
 
 var unarySuffix = []scanner.TokenType{
@@ -51,13 +53,74 @@ func (p *Parser) parseMemberExpression(target ast.Expression) (node *ast.MemberE
 	return
 }
 
+func (p *Parser) parseIndexExpression(target ast.Expression) (node ast.Expression, ok bool) {
+	lBrack, ok := p.expectToken(scanner.TokenTypeLBRACK)
+	if !ok {
+		p.unread()
+		return
+	}
+
+	index, indexOk := p.parseExpression()
+
+	_, colonOk := p.expectToken(scanner.TokenTypeCOLON)
+	if !colonOk {
+		p.unread()
+		if !indexOk {
+			p.error(unexpected(p.read().StringValue(), "index expression"))
+			return
+		}
+
+		rBrack, rBrackOk := p.expectToken(scanner.TokenTypeRBRACK)
+		if !rBrackOk {
+			p.error(unexpectedToken(rBrack, scanner.TokenTypeRBRACK))
+			return
+		}
+
+		node = &ast.IndexExpression{
+			Target:       target,
+			Index:        index,
+			LeftBracket:  lBrack,
+			RightBracket: rBrack,
+		}
+		return
+	}
+
+	high, _ := p.parseExpression()
+
+	rBrack, rBrackOk := p.expectToken(scanner.TokenTypeRBRACK)
+	if !rBrackOk {
+		p.error(unexpectedToken(rBrack, scanner.TokenTypeRBRACK))
+		return
+	}
+
+	node = &ast.SliceExpression{
+		Target:       target,
+		Low:          index,
+		High:         high,
+		LeftBracket:  lBrack,
+		RightBracket: rBrack,
+	}
+	return
+}
+
 func (p *Parser) parseCallExpression(target ast.Expression) (node *ast.FunctionCall, ok bool) {
+	var typeArgs []ast.Type
+	if _, isIdent := target.(*ast.Identifier); isIdent && p.startsTypeArguments(scanner.TokenTypeLPAREN) {
+		if typeArgs, _, ok = p.parseTypeArguments(); !ok {
+			return
+		}
+	}
+
 	_, ok = p.expectToken(scanner.TokenTypeLPAREN)
 	if !ok {
 		p.unread()
 		return
 	}
 
+	noStructExpression := p.noStructExpression
+	p.noStructExpression = false
+	defer func() { p.noStructExpression = noStructExpression }()
+
 	args := make([]*ast.CallArgument, 0)
 	for {
 		arg, ok := p.parseCallArgument()
@@ -80,9 +143,10 @@ func (p *Parser) parseCallExpression(target ast.Expression) (node *ast.FunctionC
 	}
 
 	node = &ast.FunctionCall{
-		Callee:    target,
-		Arguments: args,
-		End:       ast.EndPositionFromToken(token),
+		Callee:        target,
+		TypeArguments: typeArgs,
+		Arguments:     args,
+		End:           ast.EndPositionFromToken(token),
 	}
 
 	return
@@ -147,6 +211,10 @@ func (p *Parser) parseUnaryExpression() (expression ast.Expression, ok bool) {
 			p.error(unexpected(p.read().StringValue(), "expression"))
 			return
 		}
+		if token.Type == scanner.TokenTypeASTERISK || token.Type == scanner.TokenTypeAMPERSAND {
+			expression = bindPointerOperator(token, rExpr)
+			return
+		}
 		expression = &ast.UnaryExpression{
 			Operator:   token,
 			Expression: rExpr,
@@ -169,6 +237,8 @@ func (p *Parser) parseUnaryExpression() (expression ast.Expression, ok bool) {
 	case check(p.parseParenExpressionOrTuple()):
 	case check(p.parseFuncDecl()):
 	case check(p.parseArrayExpression()):
+	case check(p.parseMapExpression()):
+	case check(p.parseMatchExpression()):
 	case check(p.parseIdentfier()):
 	case check(p.parseValueExpression()):
 	// case check(p.parseBlock()): this messes up for loops
@@ -184,7 +254,9 @@ rightLoop:
 		case check(p.parseAssigment(expression)):
 		case check(p.parseCallExpression(expression)):
 		case check(p.parseStructExpression(expression)):
+		case check(p.parseTypeAssertion(expression)):
 		case check(p.parseMemberExpression(expression)):
+		case check(p.parseIndexExpression(expression)):
 		case check(p.parseComparisonExpression(expression)):
 		default:
 			break rightLoop
@@ -208,6 +280,36 @@ rightLoop:
 	return
 }
 
+// startsStatement returns true if an operator on a new line after expr is a
+// dereference starting the next statement instead of a multiplication
+func startsStatement(operator scanner.Token, expr ast.Expression) bool {
+	return operator.Type == scanner.TokenTypeASTERISK && operator.StartLine > expr.EndPos().Line
+}
+
+// bindPointerOperator applies a dereference or address-of operator to the
+// leftmost operand of expr so that *p = x, *p == x and *p++ operate on *p
+// instead of on the whole expression
+func bindPointerOperator(operator scanner.Token, expr ast.Expression) ast.Expression {
+	switch n := expr.(type) {
+	case *ast.Assigment:
+		n.Left = bindPointerOperator(operator, n.Left)
+		return n
+	case *ast.ComparisonExpression:
+		n.Left = bindPointerOperator(operator, n.Left)
+		return n
+	case *ast.UnaryExpression:
+		if n.Postfix {
+			n.Expression = bindPointerOperator(operator, n.Expression)
+			return n
+		}
+	}
+
+	return &ast.UnaryExpression{
+		Operator:   operator,
+		Expression: expr,
+	}
+}
+
 func (p *Parser) parseBinaryExpression(left ast.Expression) (node *ast.BinaryExpression, ok bool) {
 	token, ok := p.expectToken(
 		scanner.TokenTypeADD,
@@ -216,9 +318,9 @@ func (p *Parser) parseBinaryExpression(left ast.Expression) (node *ast.BinaryExp
 		scanner.TokenTypeSLASH,
 	)
 
-	if !ok {
+	if !ok || startsStatement(token, left) {
 		p.unread()
-		return
+		return nil, false
 	}
 
 	var right ast.Expression
@@ -234,7 +336,7 @@ func (p *Parser) parseBinaryExpression(left ast.Expression) (node *ast.BinaryExp
 		scanner.TokenTypeSUB,
 		scanner.TokenTypeASTERISK,
 		scanner.TokenTypeSLASH,
-	); nextTokenOk {
+	); nextTokenOk && !startsStatement(nextToken, right) {
 		p.unread()
 		// TODO use proper weights
 		if !(token.Type == scanner.TokenTypeASTERISK || token.Type == scanner.TokenTypeSLASH) && (nextToken.Type == scanner.TokenTypeASTERISK || nextToken.Type == scanner.TokenTypeSLASH) {
@@ -334,7 +436,10 @@ func (p *Parser) parseParenExpressionOrTuple() (node ast.Expression, ok bool) {
 		return
 	}
 
+	noStructExpression := p.noStructExpression
+	p.noStructExpression = false
 	exprList, exprListOk := p.parseExpressionList()
+	p.noStructExpression = noStructExpression
 	if !exprListOk {
 		p.error(unexpected(p.read().StringValue(), "expression"))
 		return
@@ -399,3 +504,59 @@ func (p *Parser) parseArrayExpression() (node ast.Expression, ok bool) {
 
 	return
 }
+
+func (p *Parser) parseMapExpression() (node ast.Expression, ok bool) {
+	typ, typOk := p.parseMapType()
+	if !typOk {
+		return
+	}
+
+	lBrace, lBraceOk := p.expectToken(scanner.TokenTypeLBRACE)
+	if !lBraceOk {
+		p.error(unexpectedToken(lBrace, scanner.TokenTypeLBRACE))
+		return
+	}
+
+	entries := []*ast.MapEntry{}
+	for {
+		key, keyOk := p.parseExpression()
+		if !keyOk {
+			break
+		}
+
+		colon, colonOk := p.expectToken(scanner.TokenTypeCOLON)
+		if !colonOk {
+			p.error(unexpectedToken(colon, scanner.TokenTypeCOLON))
+			return
+		}
+
+		value, valueOk := p.parseExpression()
+		if !valueOk {
+			p.error(unexpected(p.read().StringValue(), "map value"))
+			return
+		}
+
+		entries = append(entries, &ast.MapEntry{Key: key, Value: value})
+
+		if _, commaOk := p.expectToken(scanner.TokenTypeCOMMA); !commaOk {
+			p.unread()
+			break
+		}
+	}
+
+	rBrace, rBraceOk := p.expectToken(scanner.TokenTypeRBRACE)
+	if !rBraceOk {
+		p.error(unexpectedToken(rBrace, scanner.TokenTypeRBRACE))
+		return
+	}
+
+	node = &ast.MapExpression{
+		Type:       typ,
+		Entries:    entries,
+		LeftBrace:  lBrace,
+		RightBrace: rBrace,
+	}
+	ok = true
+
+	return
+}
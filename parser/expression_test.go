@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
@@ -228,3 +229,207 @@ func TestParseFunctionCall(t *testing.T) {
 	}
 
 }
+
+func TestParseIndexExpression(t *testing.T) {
+	file, err := Parse(strings.NewReader(`
+		fn main() {
+			arr[1] = arr[i + 1]
+			var slice = arr[1:len(arr)]
+			var all = arr[:]
+			matrix[0][1]++
+		}
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := file.Body[0].(*ast.FunctionDeclaration).Block.Body
+
+	assigment := body[0].(*ast.Assigment)
+	if assigment.Left.(*ast.IndexExpression).Index.(*ast.ValueExpression).Value != int64(1) {
+		t.Error("Wrong index on the left")
+	}
+	if _, ok := assigment.Right.(*ast.IndexExpression).Index.(*ast.BinaryExpression); !ok {
+		t.Error("Wrong index on the right")
+	}
+
+	slice := body[1].(*ast.VariableDeclaration).DefaultValue.(*ast.SliceExpression)
+	if fmt.Sprintf("%s", slice) != "arr[1:len(arr)]" {
+		t.Errorf("Wrong slice expression %s", slice)
+	}
+
+	all := body[2].(*ast.VariableDeclaration).DefaultValue.(*ast.SliceExpression)
+	if all.Low != nil || all.High != nil {
+		t.Error("Slice bounds should be omitted")
+	}
+
+	increment := body[3].(*ast.UnaryExpression)
+	if fmt.Sprintf("%s", increment.Expression) != "matrix[0][1]" {
+		t.Errorf("Wrong index expression %s", increment.Expression)
+	}
+}
+
+func TestParsePointerExpression(t *testing.T) {
+	file, err := Parse(strings.NewReader(`
+		fn main() {
+			var p : *int32 = &x
+			*p = *p * 2
+			*p++
+			**pp == &point.x
+			var q = &arr[1]
+		}
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := file.Body[0].(*ast.FunctionDeclaration).Block.Body
+	if len(body) != 5 {
+		t.Fatalf("Expected 5 statements got %d", len(body))
+	}
+
+	decl := body[0].(*ast.VariableDeclaration)
+	if fmt.Sprintf("%s", decl.Type) != "*int32" {
+		t.Errorf("Wrong pointer type %s", decl.Type)
+	}
+
+	assigment := body[1].(*ast.Assigment)
+	if fmt.Sprintf("%s", assigment.Left) != "*p" {
+		t.Errorf("Wrong assigment target %s", assigment.Left)
+	}
+	if fmt.Sprintf("%s", assigment.Right) != "*p * 2" {
+		t.Errorf("Wrong assigment value %s", assigment.Right)
+	}
+
+	increment := body[2].(*ast.UnaryExpression)
+	if !increment.Postfix || fmt.Sprintf("%s", increment.Expression) != "*p" {
+		t.Errorf("Wrong increment %s", increment)
+	}
+
+	comparison := body[3].(*ast.ComparisonExpression)
+	if fmt.Sprintf("%s", comparison.Left) != "**pp" || fmt.Sprintf("%s", comparison.Right) != "&point.x" {
+		t.Errorf("Wrong comparison %s", comparison)
+	}
+
+	if fmt.Sprintf("%s", body[4].(*ast.VariableDeclaration).DefaultValue) != "&arr[1]" {
+		t.Errorf("Wrong address of element %s", body[4].(*ast.VariableDeclaration).DefaultValue)
+	}
+}
+
+func TestParseMapExpression(t *testing.T) {
+	file, err := Parse(strings.NewReader(`
+		fn main() {
+			var empty : map[string]int32 = map[string]int32{}
+			var nested = map[string][]int32{
+				"a": []int32{1},
+				"b": []int32{2, 3},
+			}
+		}
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := file.Body[0].(*ast.FunctionDeclaration).Block.Body
+
+	empty := body[0].(*ast.VariableDeclaration)
+	if fmt.Sprintf("%s", empty.Type) != "map[string]int32" {
+		t.Errorf("Wrong map type %s", empty.Type)
+	}
+	if len(empty.DefaultValue.(*ast.MapExpression).Entries) != 0 {
+		t.Error("Map should be empty")
+	}
+
+	nested := body[1].(*ast.VariableDeclaration).DefaultValue.(*ast.MapExpression)
+	if len(nested.Entries) != 2 {
+		t.Fatalf("Expected 2 entries got %d", len(nested.Entries))
+	}
+	if fmt.Sprintf("%s", nested.Entries[1]) != `"b": []int32{2, 3}` {
+		t.Errorf("Wrong map entry %s", nested.Entries[1])
+	}
+}
+
+func TestParseMatchExpression(t *testing.T) {
+	file, err := Parse(strings.NewReader(`
+		fn main() {
+			var area = match shape {
+				Circle(r) => r * r,
+				Rect(w, (h, _)) => w * h,
+				Empty => 0
+			}
+			match shape {
+				Circle(r) => { print(r) }
+				_ => {}
+			}
+		}
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := file.Body[0].(*ast.FunctionDeclaration).Block.Body
+
+	match := body[0].(*ast.VariableDeclaration).DefaultValue.(*ast.MatchExpression)
+	if len(match.Arms) != 3 {
+		t.Fatalf("Expected 3 arms got %d", len(match.Arms))
+	}
+	if fmt.Sprintf("%s", match.Arms[1].Pattern) != "Rect(w, (h, _))" {
+		t.Errorf("Wrong pattern %s", match.Arms[1].Pattern)
+	}
+	if _, ok := match.Arms[2].Pattern.(*ast.Identifier); !ok {
+		t.Errorf("Variant without payload should be an identifier got %T", match.Arms[2].Pattern)
+	}
+
+	match = body[1].(*ast.MatchExpression)
+	if len(match.Arms) != 2 {
+		t.Fatalf("Expected 2 arms got %d", len(match.Arms))
+	}
+	if _, ok := match.Arms[0].Body.(*ast.Block); !ok {
+		t.Errorf("Arm body should be a block got %T", match.Arms[0].Body)
+	}
+}
+
+func TestParseTypeAssertion(t *testing.T) {
+	file, err := Parse(strings.NewReader(`
+		fn main() {
+			var foo = shape.(Foo).bar
+			var (bar, ok) = shape.(*Bar)
+			switch var v = shape.(type) {
+			case Foo, Bar {
+				print(v)
+			}
+			default {
+			}
+			}
+			switch shape.(type) {
+			}
+		}
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := file.Body[0].(*ast.FunctionDeclaration).Block.Body
+
+	member := body[0].(*ast.VariableDeclaration).DefaultValue.(*ast.MemberExpression)
+	if assertion, ok := member.Target.(*ast.TypeAssertion); !ok || assertion.Ok {
+		t.Errorf("Wrong target %s", member.Target)
+	}
+
+	assertion := body[1].(*ast.TupleDeclaration).DefaultValue.(*ast.TypeAssertion)
+	if !assertion.Ok || fmt.Sprintf("%s", assertion) != "shape.(*Bar)" {
+		t.Errorf("Wrong assertion %s", assertion)
+	}
+
+	typeSwitch := body[2].(*ast.TypeSwitch)
+	if typeSwitch.Binding.Text != "v" || fmt.Sprintf("%s", typeSwitch.Subject) != "shape" {
+		t.Errorf("Wrong binding %s or subject %s", typeSwitch.Binding, typeSwitch.Subject)
+	}
+	if len(typeSwitch.Cases) != 2 || len(typeSwitch.Cases[0].Types) != 2 || typeSwitch.Cases[1].Types != nil {
+		t.Errorf("Wrong cases %v", typeSwitch.Cases)
+	}
+
+	if typeSwitch := body[3].(*ast.TypeSwitch); typeSwitch.Binding != nil || len(typeSwitch.Cases) != 0 {
+		t.Error("Switch without binding or cases parsed incorrectly")
+	}
+}
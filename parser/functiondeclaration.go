@@ -16,6 +16,9 @@ func (p *Parser) parseFuncSignature() (signature *ast.FunctionSignature, ok bool
 		signature.Start = ast.StartPositionFromToken(token)
 		if identifier, parseIdent := p.parseIdentfier(); parseIdent {
 			signature.Identifier = identifier
+			if params, paramsOk := p.parseTypeParameters(); paramsOk {
+				signature.TypeParameters = params
+			}
 		} else {
 			operatorToken, operatorOk := p.expectToken(
 				scanner.TokenTypeADD,
@@ -44,7 +47,11 @@ func (p *Parser) parseFuncSignature() (signature *ast.FunctionSignature, ok bool
 
 		signature.Arguments = arguments
 
-		_, returnTypeColonOk := p.expectToken(scanner.TokenTypeArrow)
+		// Externs can declare the return type C style after a colon
+		returnToken, returnTypeColonOk := p.expectToken(scanner.TokenTypeArrow, scanner.TokenTypeCOLON)
+		if returnTypeColonOk && returnToken.Type == scanner.TokenTypeCOLON && !signature.Extern {
+			returnTypeColonOk = false
+		}
 		if returnTypeColonOk {
 			if returnType, returnTypeOk := p.parseType(); returnTypeOk {
 				signature.ReturnType = returnType
@@ -155,10 +162,10 @@ func (p *Parser) parseArgument() (arg *ast.Argument, ok bool) {
 				p.error(unexpectedToken(p.read(), scanner.TokenTypeIdent))
 				return
 			}
+		} else {
+			arg.Type = typ
 		}
 
-		arg.Type = typ
-
 		if _, defaultAssOk := p.expectToken(scanner.TokenTypeASSIGN); !defaultAssOk {
 			p.unread()
 			return
@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/scanner"
+)
+
+// parseTypeParameters parses the type parameters of a generic function or
+// struct: <T, U : Constraint>
+func (p *Parser) parseTypeParameters() (params []*ast.TypeParameter, ok bool) {
+	if _, lessOk := p.expectToken(scanner.TokenTypeLess); !lessOk {
+		p.unread()
+		return
+	}
+
+	for {
+		name, nameOk := p.parseIdentfier()
+		if !nameOk {
+			p.error(unexpected(p.read().StringValue(), "type parameter"))
+			return
+		}
+
+		param := &ast.TypeParameter{Name: name}
+
+		if _, colonOk := p.expectToken(scanner.TokenTypeCOLON); colonOk {
+			constraint, constraintOk := p.parseType()
+			if !constraintOk {
+				p.error(unexpected(p.read().StringValue(), "type parameter constraint"))
+				return
+			}
+			param.Constraint = constraint
+		} else {
+			p.unread()
+		}
+
+		params = append(params, param)
+
+		token, tokenOk := p.expectToken(scanner.TokenTypeCOMMA, scanner.TokenTypeGreater)
+		if !tokenOk {
+			p.error(unexpectedToken(token, scanner.TokenTypeCOMMA, scanner.TokenTypeGreater))
+			return
+		}
+
+		if token.Type == scanner.TokenTypeGreater {
+			break
+		}
+	}
+
+	ok = true
+	return
+}
+
+// parseTypeArguments parses a list of type arguments: <int32, string>
+func (p *Parser) parseTypeArguments() (args []ast.Type, end scanner.Token, ok bool) {
+	if _, lessOk := p.expectToken(scanner.TokenTypeLess); !lessOk {
+		p.unread()
+		return
+	}
+
+	args, argsOk := p.parseTypeList()
+	if !argsOk {
+		p.error(unexpected(p.read().StringValue(), "type argument"))
+		return
+	}
+
+	end, ok = p.expectToken(scanner.TokenTypeGreater)
+	if !ok {
+		p.error(unexpectedToken(end, scanner.TokenTypeGreater))
+	}
+
+	return
+}
+
+// startsTypeArguments returns true if the next tokens are a list of type
+// arguments followed by a token of type follow as in Box<int32>{value: 1} or
+// zero<int32>(). Other uses of < are comparisons so the tokens are only
+// peeked at
+func (p *Parser) startsTypeArguments(follow scanner.TokenType) bool {
+	token := p.read()
+	p.unread()
+	if token.Type != scanner.TokenTypeLess {
+		return false
+	}
+
+	p.snapshot()
+	defer p.restore()
+
+	p.read()
+
+	depth := 1
+	for depth > 0 {
+		switch p.read().Type {
+		case scanner.TokenTypeLess:
+			depth++
+		case scanner.TokenTypeGreater:
+			depth--
+		case scanner.TokenTypeIdent,
+			scanner.TokenTypeNumber,
+			scanner.TokenTypeLBRACK,
+			scanner.TokenTypeRBRACK,
+			scanner.TokenTypeLPAREN,
+			scanner.TokenTypeRPAREN,
+			scanner.TokenTypeASTERISK,
+			scanner.TokenTypeCOMMA,
+			scanner.TokenTypeArrow:
+		default:
+			return false
+		}
+	}
+
+	return p.read().Type == follow
+}
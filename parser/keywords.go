@@ -14,6 +14,15 @@ var (
 	keywordMacro     = registerKeyword("macro")
 	keywordStruct    = registerKeyword("struct")
 	keywordInterface = registerKeyword("interface")
+	keywordImport    = registerKeyword("import")
+	keywordExport    = registerKeyword("export")
+	keywordMap       = registerKeyword("map")
+	keywordIn        = registerKeyword("in")
+	keywordEnum      = registerKeyword("enum")
+	keywordMatch     = registerKeyword("match")
+	keywordSwitch    = registerKeyword("switch")
+	keywordCase      = registerKeyword("case")
+	keywordDefault   = registerKeyword("default")
 )
 
 func registerKeyword(kw string) string {
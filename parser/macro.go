@@ -48,6 +48,7 @@ func (p *Parser) parseMacro() (node *ast.Macro, ok bool) {
 		End:      ast.EndPositionFromToken(rbrace),
 		Name:     macroNameToken,
 		Patterns: patterns,
+		Filename: p.Filename,
 	}
 
 	p.checkCommentForNode(node, false)
@@ -55,6 +56,28 @@ func (p *Parser) parseMacro() (node *ast.Macro, ok bool) {
 	return
 }
 
+// DefineMacro makes a macro declared elsewhere callable in the parsed file
+func (p *Parser) DefineMacro(macro *ast.Macro) {
+	p.macros[macro.Name.Text] = macro
+}
+
+// importMacros defines the macros exported by an imported file
+func (p *Parser) importMacros(importDecl *ast.Import) error {
+	if p.Import == nil {
+		return nil
+	}
+
+	macros, err := p.Import(importDecl)
+	if err != nil {
+		return err
+	}
+
+	for _, macro := range macros {
+		p.DefineMacro(macro)
+	}
+	return nil
+}
+
 func (p *Parser) parseMacroMatchRepetition() (match *ast.MacroMatchRepetition, ok bool) {
 	_, ok = p.expectToken(scanner.TokenTypeDOLLAR)
 	if !ok {
@@ -272,9 +295,11 @@ func (p *Parser) parseMacroCall(nameToken scanner.Token) (ok bool) {
 
 	{
 		closingTokenType := getClosingTokenType(lparen)
+		var argumentStart int
 		check := func(value interface{}, vOk bool) bool {
 			if vOk {
 				vOk = macroMatcher.feed(value)
+				p.recordMacroArgument(value, argumentStart)
 			}
 			return vOk
 		}
@@ -282,10 +307,16 @@ func (p *Parser) parseMacroCall(nameToken scanner.Token) (ok bool) {
 		parenCount := 1
 	loop:
 		for {
+			argumentStart = len(p.expanded)
 			switch {
 			case macroMatcher.acceptsType("block") && check(p.parseBlock()):
 			case macroMatcher.acceptsType("expr") && check(p.parseExpression()):
 			case macroMatcher.acceptsType("stmt") && check(p.parseStatement(false)):
+			case macroMatcher.acceptsType("ident") && check(p.parseIdentfier()):
+			case macroMatcher.acceptsType("type") && check(p.parseType()):
+			case macroMatcher.acceptsType("pattern") && check(p.parsePattern()):
+			case macroMatcher.acceptsType("literal") && check(p.parseMacroLiteral()):
+			case macroMatcher.acceptsType("tt") && check(p.parseMacroTokenTree()):
 			default:
 				token := p.read()
 				switch token.Type {
@@ -315,15 +346,26 @@ patternCheckLoop:
 		return
 	}
 
-	buf, err := matchingProcessor.processor.expand(matchingProcessor.pattern.TokensSets)
+	// Each expansion gets its own syntax context so that bindings made by
+	// different expansions don't clash with each other or with the caller
+	p.macroExpansions++
+	buf, err := matchingProcessor.processor.expand(matchingProcessor.pattern.TokensSets, p.macroExpansions)
 	if err != nil {
 		p.error(err.Error())
 		return
 	}
 
-	// This is a dirty hack to get error traces to point to the macro call instead of the macro definition
-	// TODO figure out the proper way to maintain both info
+	// Expanded tokens point to the macro call and keep their position in the
+	// macro definition for backtraces
 	for i, t := range buf {
+		t.Expansion = &scanner.Expansion{
+			Macro:    macro.Name,
+			Call:     nameToken,
+			Filename: macro.Filename,
+			Line:     t.StartLine,
+			Column:   t.StartColumn,
+		}
+
 		t.StartLine = nameToken.StartLine
 		t.StartColumn = nameToken.StartColumn
 
@@ -339,6 +381,54 @@ patternCheckLoop:
 	return
 }
 
+func (p *Parser) parseMacroLiteral() (token scanner.Token, ok bool) {
+	if token, ok = p.expectToken(valueTypes...); !ok {
+		p.unread()
+	}
+	return
+}
+
+// macroTokenTree is a single token or tokens between balanced parentheses,
+// braces or brackets
+type macroTokenTree struct {
+	tokens []scanner.Token
+}
+
+func (p *Parser) parseMacroTokenTree() (tree *macroTokenTree, ok bool) {
+	token := p.readToken(false)
+	switch token.Type {
+	case scanner.TokenTypeRPAREN, scanner.TokenTypeRBRACE, scanner.TokenTypeRBRACK, scanner.TokenTypeEOF:
+		p.unread()
+		return
+	}
+
+	tokens := []scanner.Token{token}
+	closing := []scanner.TokenType{}
+	if closingType := getClosingTokenType(token); closingType != scanner.TokenTypeUnknown {
+		closing = append(closing, closingType)
+	}
+
+	for len(closing) > 0 {
+		t := p.readToken(false)
+		switch t.Type {
+		case scanner.TokenTypeLPAREN, scanner.TokenTypeLBRACE, scanner.TokenTypeLBRACK:
+			closing = append(closing, getClosingTokenType(t))
+		case scanner.TokenTypeRPAREN, scanner.TokenTypeRBRACE, scanner.TokenTypeRBRACK:
+			if t.Type != closing[len(closing)-1] {
+				p.error(unexpectedToken(t, closing[len(closing)-1]))
+				return
+			}
+			closing = closing[:len(closing)-1]
+		case scanner.TokenTypeEOF:
+			p.error("Expected token but got eof")
+			return
+		}
+		tokens = append(tokens, t)
+	}
+
+	return &macroTokenTree{tokens: tokens}, true
+}
+
 func (p *Parser) parseMacroSubstitutionBlock() (block *ast.Block, ok bool) {
 	node, ok := p.parseMacroSubstitution()
 	if ok {
@@ -369,6 +459,36 @@ func (p *Parser) parseMacroSubstitutionStatement() (stmt ast.Statement, ok bool)
 	return
 }
 
+func (p *Parser) parseMacroSubstitutionType() (typ ast.Type, ok bool) {
+	node, ok := p.parseMacroSubstitution()
+	if ok {
+		if typ, ok = node.(ast.Type); !ok {
+			p.unread()
+		}
+	}
+	return
+}
+
+func (p *Parser) parseMacroSubstitutionPattern() (pattern ast.Pattern, ok bool) {
+	node, ok := p.parseMacroSubstitution()
+	if ok {
+		if pattern, ok = node.(ast.Pattern); !ok {
+			p.unread()
+		}
+	}
+	return
+}
+
+func (p *Parser) parseMacroSubstitutionTuplePattern() (pattern *ast.TuplePattern, ok bool) {
+	node, ok := p.parseMacroSubstitution()
+	if ok {
+		if pattern, ok = node.(*ast.TuplePattern); !ok {
+			p.unread()
+		}
+	}
+	return
+}
+
 func (p *Parser) parseMacroSubstitution() (substitution interface{}, ok bool) {
 	token, ok := p.expectToken(scanner.TokenTypeMacroIdent)
 	if !ok {
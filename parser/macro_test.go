@@ -316,3 +316,88 @@ func TestMacro(t *testing.T) {
 	}
 
 }
+
+func TestMacroHygieneMarks(t *testing.T) {
+	file, err := Parse(strings.NewReader(`
+		macro addOne {
+		  ($x:expr) : (var tmp = $x + 1)
+		}
+		fn main() {
+		  var tmp = 1
+		  addOne!(tmp)
+		  addOne!(tmp)
+		}
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := file.Body[1].(*ast.FunctionDeclaration).Block.Body
+	user := body[0].(*ast.VariableDeclaration)
+	first := body[1].(*ast.VariableDeclaration)
+	second := body[2].(*ast.VariableDeclaration)
+
+	if user.Name.Mark != 0 {
+		t.Errorf("User identifier should not be marked got %d", user.Name.Mark)
+	}
+
+	if first.Name.Mark == 0 || second.Name.Mark == 0 || first.Name.Mark == second.Name.Mark {
+		t.Errorf("Each expansion should have its own mark got %d and %d", first.Name.Mark, second.Name.Mark)
+	}
+
+	if arg := first.DefaultValue.(*ast.BinaryExpression).Left.(*ast.Identifier); arg.Mark != 0 {
+		t.Errorf("Macro argument should keep the mark of the call site got %d", arg.Mark)
+	}
+}
+
+func TestImportMacros(t *testing.T) {
+	lib, err := Parse(strings.NewReader(`
+		export macro double {
+		  ($a:expr) : ($a * 2)
+		}
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lib.Exports) != 1 {
+		t.Fatalf("Expected one export got %d", len(lib.Exports))
+	}
+	macro, ok := lib.Exports[0].(*ast.Macro)
+	if !ok {
+		t.Fatalf("Expected macro export got %T", lib.Exports[0])
+	}
+
+	p := NewParser(scanner.NewScanner(strings.NewReader(`
+		import "lib"
+		var x = double!(3)
+	`)))
+	p.Import = func(importDecl *ast.Import) ([]*ast.Macro, error) {
+		if importDecl.Path != "lib" {
+			t.Errorf("Wrong import path %s", importDecl.Path)
+		}
+		return []*ast.Macro{macro}, nil
+	}
+
+	file, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	varDecl := file.Body[0].(*ast.VariableDeclaration)
+	if _, ok := varDecl.DefaultValue.(*ast.BinaryExpression); !ok {
+		t.Errorf("Expected binary expression got %T", varDecl.DefaultValue)
+	}
+
+	p = NewParser(scanner.NewScanner(strings.NewReader(`
+		import "missing"
+		var x = double!(3)
+	`)))
+	p.Import = func(importDecl *ast.Import) ([]*ast.Macro, error) {
+		return nil, fmt.Errorf("could not import %q", importDecl.Path)
+	}
+
+	if _, err := p.Parse(); err == nil || err.Error() != `could not import "missing"` {
+		t.Errorf("Expected import error got %v", err)
+	}
+}
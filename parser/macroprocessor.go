@@ -180,6 +180,19 @@ feed:
 			_, accepts = val.(ast.Expression)
 		case "stmt":
 			_, accepts = val.(ast.Statement)
+		case "ident":
+			_, accepts = val.(*ast.Identifier)
+		case "type":
+			_, accepts = val.(ast.Type)
+		case "pattern":
+			_, accepts = val.(ast.Pattern)
+		case "literal":
+			var t scanner.Token
+			if t, accepts = val.(scanner.Token); accepts {
+				accepts = isValueType(t.Type)
+			}
+		case "tt":
+			_, accepts = val.(*macroTokenTree)
 		}
 
 		if accepts {
@@ -255,7 +268,10 @@ func (mp *macroProcessor) acceptsTypeWithIndex(indx int, t string) (accepts bool
 	return
 }
 
-func (mp *macroProcessor) expand(sets []ast.MacroTokenSet) (tokens []scanner.Token, err error) {
+// expand returns the tokens of a macro body with the metavariables replaced by
+// the matched values. Tokens of the body are marked with the syntax context
+// mark of the expansion
+func (mp *macroProcessor) expand(sets []ast.MacroTokenSet, mark int) (tokens []scanner.Token, err error) {
 	reps := 0
 	for _, set := range sets {
 		switch s := set.(type) {
@@ -266,7 +282,7 @@ func (mp *macroProcessor) expand(sets []ast.MacroTokenSet) (tokens []scanner.Tok
 			sp := mp.orderedSubProcessors[reps]
 			reps++
 			var newTokens []scanner.Token
-			newTokens, err = sp.expand(s.Sets)
+			newTokens, err = sp.expand(s.Sets, mark)
 			if err != nil {
 				return
 			}
@@ -286,6 +302,8 @@ func (mp *macroProcessor) expand(sets []ast.MacroTokenSet) (tokens []scanner.Tok
 							return
 						}
 						token.Value = val
+					} else {
+						token.Mark = mark
 					}
 					newTokens[index] = token
 				}
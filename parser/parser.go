@@ -16,14 +16,38 @@ type Parser struct {
 	errorToken       scanner.Token
 	Error            func(tokenIndx int, pos ast.Position, endPos ast.Position, msg string)
 	ContinueOnErrors bool
-	snapshots        [][]scanner.Token
-	readTokens       int
+	// Import is called for each import declaration. The returned macros can
+	// be called in the rest of the file
+	Import func(importDecl *ast.Import) (macros []*ast.Macro, err error)
+	// Filename is the name of the parsed file used in macro expansion
+	// backtraces
+	Filename   string
+	snapshots  [][]scanner.Token
+	readTokens int
 	// comments attaching
 	nodeComments          map[ast.Node][]ast.Comment
 	comments              []ast.Comment
 	commentAfterNodeCheck ast.Node
 	// macros
 	macros map[string]*ast.Macro
+	// macroExpansions is the number of expanded macro calls and the mark of
+	// the latest expansion
+	macroExpansions int
+	// expansion recording used by Expand
+	recordExpansion   bool
+	expanded          []recordedToken
+	expandedSnapshots []int
+	macroArguments    map[interface{}][]recordedToken
+	bindings          map[binding]bool
+	unrenamed         map[*scanner.Expansion]bool
+	// modules
+	exports []ast.Node
+	// noStructExpression is set while parsing expressions followed by a code
+	// block where a left brace starts the block instead of a struct expression
+	noStructExpression bool
+	// typeSwitchSubject is set while parsing the subject of a type switch
+	// where .(type) is allowed
+	typeSwitchSubject bool
 }
 
 // NewParser return new Parser for a given scanner
@@ -47,6 +71,8 @@ func (p *Parser) Parse() (file *ast.File, err error) {
 
 loop:
 	for {
+		expandedLen := len(p.expanded)
+		statementStart := len(p.expanded)
 		var node ast.Node
 		var check = func(n ast.Node, ok bool) bool {
 			if ok {
@@ -59,17 +85,33 @@ loop:
 		case check(p.parseVarDecl()):
 		case check(p.parseStruct()):
 		case check(p.parseInterface()):
+		case check(p.parseEnum()):
 		case check(p.parseImportDecl()):
+			if importDecl, isImport := node.(*ast.Import); isImport {
+				file.Imports = append(file.Imports, importDecl)
+				if err = p.importMacros(importDecl); err != nil {
+					break loop
+				}
+			}
+			// Imports are not part of the body
+			node = nil
 		case check(p.parseExportDecl()):
+			if macro, isMacro := node.(*ast.Macro); isMacro && macro != nil {
+				p.DefineMacro(macro)
+				// Macro declarations are not part of the expanded source
+				p.truncateExpanded(expandedLen)
+			}
 		case p.eof():
 			break loop
 		case check(p.parseMacro()):
 			if node != nil {
 				macro, isMacro := node.(*ast.Macro)
 				if isMacro && macro != nil {
-					p.macros[macro.Name.Text] = macro
+					p.DefineMacro(macro)
 				}
 			}
+			// Macro declarations are not part of the expanded source
+			p.truncateExpanded(expandedLen)
 		default:
 			token := p.read()
 			p.error(unexpectedToken(token))
@@ -78,6 +120,7 @@ loop:
 		if node != nil {
 			file.AppendNode(node)
 		}
+		p.markStatement(statementStart)
 
 		if p.parserError != "" {
 			token := p.errorToken
@@ -93,6 +136,11 @@ loop:
 	file.Comments = p.comments
 	file.NodeComments = p.nodeComments
 	file.Macros = p.macros
+	file.Exports = p.exports
+
+	if p.recordExpansion && err == nil {
+		p.bindings, p.unrenamed = expansionBindings(file)
+	}
 
 	return
 }
@@ -105,10 +153,59 @@ func (p *Parser) parseStatementOrExpression(block bool) (node ast.Node, ok bool)
 }
 
 func (p *Parser) parseImportDecl() (node ast.Node, ok bool) {
+	token := p.read()
+	if token.Type != scanner.TokenTypeIdent || token.Text != keywordImport {
+		p.unread()
+		return
+	}
+
+	ok = true
+
+	path, pathOk := p.expectToken(scanner.TokenTypeString)
+	if !pathOk {
+		p.error(unexpectedToken(path, scanner.TokenTypeString))
+		return
+	}
+
+	node = &ast.Import{
+		Start: ast.StartPositionFromToken(token),
+		End:   ast.EndPositionFromToken(path),
+		Path:  path.Value.(string),
+	}
 	return
 }
 
 func (p *Parser) parseExportDecl() (node ast.Node, ok bool) {
+	token := p.read()
+	if token.Type != scanner.TokenTypeIdent || token.Text != keywordExport {
+		p.unread()
+		return
+	}
+
+	ok = true
+
+	var check = func(n ast.Node, ok bool) bool {
+		if ok {
+			node = n
+		}
+		return ok
+	}
+
+	switch {
+	case check(p.parseFuncDecl()):
+	case check(p.parseVarDecl()):
+	case check(p.parseStruct()):
+	case check(p.parseInterface()):
+	case check(p.parseEnum()):
+	case check(p.parseMacro()):
+	default:
+		p.error(unexpected(p.read().StringValue(), "declaration"))
+		return
+	}
+
+	if node != nil {
+		p.exports = append(p.exports, node)
+	}
 	return
 }
 
@@ -163,14 +260,40 @@ readToken:
 		}
 	}
 
+	if tree, isTree := token.Value.(*macroTokenTree); isTree && token.Type == scanner.TokenTypeMacroIdent {
+		// Token trees are substituted token by token
+		buf := make([]scanner.Token, len(tree.tokens))
+		for i, t := range tree.tokens {
+			buf[i] = token
+			buf[i].Value = t
+		}
+		p.returnToBuffer(buf)
+		goto readToken
+	}
+
 	p.lastTokens = []scanner.Token{token}
+	p.recordToken(token)
 
 	if len(p.snapshots) > 0 {
 		p.snapshots[len(p.snapshots)-1] = append(p.snapshots[len(p.snapshots)-1], token)
 	}
 
+	if token.Type == scanner.TokenTypeMacroIdent {
+		// Macro arguments matched as single tokens are parsed like the
+		// tokens of the call
+		switch value := token.Value.(type) {
+		case scanner.Token:
+			token = value
+		case *ast.Identifier:
+			token = value.Token
+		}
+	}
+
 	if expandMacros && token.Type == scanner.TokenTypeMacroCallIdent {
+		expandedLen := len(p.expanded) - 1
 		if p.parseMacroCall(token) {
+			// The call is replaced by the tokens of the expansion
+			p.truncateExpanded(expandedLen)
 			goto readToken
 		} else {
 			// TODO throw error or something here
@@ -189,6 +312,7 @@ func (p *Parser) unread() {
 		snapshot := p.snapshots[len(p.snapshots)-1]
 		p.snapshots[len(p.snapshots)-1] = snapshot[:len(snapshot)-1]
 	}
+	p.truncateExpanded(len(p.expanded) - len(p.lastTokens))
 	p.returnToBuffer(p.lastTokens)
 }
 
@@ -226,22 +350,28 @@ func (p *Parser) peek() scanner.Token {
 }
 
 func (p *Parser) peekMultiple(amount int) (tokens []scanner.Token) {
+	expandedLen := len(p.expanded)
 	tokens = make([]scanner.Token, amount)
+	// Macro arguments are returned to the buffer as they were read
+	read := make([]scanner.Token, 0, amount)
 	for i := 0; i < amount; i++ {
 		tokens[i] = p.read()
+		read = append(read, p.lastTokens...)
 	}
 
-	p.tokenBuffer = append(p.tokenBuffer, tokens...)
-	p.lastTokens = []scanner.Token{}
+	p.truncateExpanded(expandedLen)
+	p.returnToBuffer(read)
 	return
 }
 
 func (p *Parser) snapshot() {
 	p.snapshots = append(p.snapshots, []scanner.Token{})
+	p.expandedSnapshots = append(p.expandedSnapshots, len(p.expanded))
 }
 
 func (p *Parser) restore() {
 	if len(p.snapshots) > 0 {
+		p.truncateExpanded(p.expandedSnapshots[len(p.expandedSnapshots)-1])
 		p.returnToBuffer(p.snapshots[len(p.snapshots)-1])
 		p.commit()
 	}
@@ -250,6 +380,7 @@ func (p *Parser) restore() {
 func (p *Parser) commit() {
 	if len(p.snapshots) > 0 {
 		p.snapshots = p.snapshots[:len(p.snapshots)-1]
+		p.expandedSnapshots = p.expandedSnapshots[:len(p.expandedSnapshots)-1]
 	}
 }
 
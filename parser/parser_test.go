@@ -232,6 +232,40 @@ func TestExternDefinition(t *testing.T) {
 	}
 }
 
+func TestImportExport(t *testing.T) {
+	file, err := Parse(strings.NewReader(`
+		import "foo/bar"
+		import "baz.or"
+
+		export fn foo() {}
+		export var bar = 1
+		export struct Baz {}
+		export interface Qux {}
+		fn hidden() {}
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(file.Imports) != 2 || file.Imports[0].Path != "foo/bar" || file.Imports[1].Path != "baz.or" {
+		t.Errorf("Wrong imports %v", file.Imports)
+	}
+
+	if len(file.Body) != 5 {
+		t.Errorf("Expected 5 nodes in body got %d", len(file.Body))
+	}
+
+	if len(file.Exports) != 4 {
+		t.Fatalf("Expected 4 exports got %d", len(file.Exports))
+	}
+
+	for i, export := range file.Exports {
+		if export != file.Body[i] {
+			t.Errorf("Export %d is not the declaration in body", i)
+		}
+	}
+}
+
 func BenchmarkParserSimple(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		_, err := Parse(strings.NewReader(`
@@ -6,6 +6,10 @@ import (
 )
 
 func (p *Parser) parseTuplePattern() (tuplePattern *ast.TuplePattern, ok bool) {
+	if tuplePattern, ok = p.parseMacroSubstitutionTuplePattern(); ok {
+		return
+	}
+
 	lParen, lparenOk := p.expectToken(scanner.TokenTypeLPAREN)
 	if !lparenOk {
 		p.unread()
@@ -51,6 +55,10 @@ func (p *Parser) parseTuplePattern() (tuplePattern *ast.TuplePattern, ok bool) {
 }
 
 func (p *Parser) parsePattern() (pattern ast.Pattern, ok bool) {
+	if pattern, ok = p.parseMacroSubstitutionPattern(); ok {
+		return
+	}
+
 	_, lparenOk := p.expectToken(scanner.TokenTypeLPAREN)
 	p.unread()
 	if !lparenOk {
@@ -18,6 +18,7 @@ func (p *Parser) parseStatement(block bool) (node ast.Statement, ok bool) {
 	case block && check(p.parseReturnStatement()):
 	case block && check(p.parseForLoop()):
 	case block && check(p.parseIfStatement()):
+	case block && check(p.parseTypeSwitch()):
 	case check(p.parseMacroSubstitutionStatement()):
 	case check(p.parseVarDecl()):
 	default:
@@ -27,11 +28,15 @@ func (p *Parser) parseStatement(block bool) (node ast.Statement, ok bool) {
 	return
 }
 
-func (p *Parser) parseForLoop() (node *ast.ForLoop, nodeOk bool) {
+func (p *Parser) parseForLoop() (statement ast.Statement, nodeOk bool) {
 	token := p.read()
 	if token.Type == scanner.TokenTypeIdent && token.Text == keywordFor {
+		if forIn, forInOk := p.parseForInLoop(token); forInOk {
+			return forIn, true
+		}
+
 		nodeOk = true
-		node = &ast.ForLoop{
+		node := &ast.ForLoop{
 			Start: ast.StartPositionFromToken(token),
 		}
 		var condition ast.Node
@@ -85,6 +90,7 @@ func (p *Parser) parseForLoop() (node *ast.ForLoop, nodeOk bool) {
 		node.Init = init
 		node.After = after
 		node.Block = block
+		statement = node
 
 		p.checkCommentForNode(node, false)
 
@@ -94,6 +100,58 @@ func (p *Parser) parseForLoop() (node *ast.ForLoop, nodeOk bool) {
 	return
 }
 
+// parseForInLoop parses the rest of a for loop of the form
+// for key, value in collection {} after the for keyword
+func (p *Parser) parseForInLoop(forToken scanner.Token) (node *ast.ForInLoop, ok bool) {
+	node = &ast.ForInLoop{Start: ast.StartPositionFromToken(forToken)}
+
+	p.snapshot()
+	key, keyOk := p.expectToken(scanner.TokenTypeIdent)
+	if !keyOk || isKeyword(key.Text) {
+		p.restore()
+		return nil, false
+	}
+	node.Key = &ast.Identifier{Token: key}
+
+	if _, commaOk := p.expectToken(scanner.TokenTypeCOMMA); commaOk {
+		value, valueOk := p.expectToken(scanner.TokenTypeIdent)
+		if !valueOk || isKeyword(value.Text) {
+			p.restore()
+			return nil, false
+		}
+		node.Value = &ast.Identifier{Token: value}
+	} else {
+		p.unread()
+	}
+
+	in, inOk := p.expectToken(scanner.TokenTypeIdent)
+	if !inOk || in.Text != keywordIn {
+		p.restore()
+		return nil, false
+	}
+	p.commit()
+
+	p.noStructExpression = true
+	collection, collectionOk := p.parseExpression()
+	p.noStructExpression = false
+	if !collectionOk {
+		p.error(unexpected(p.read().StringValue(), "expression"))
+		return node, true
+	}
+	node.Collection = collection
+
+	block, blockOk := p.parseBlock()
+	if !blockOk {
+		p.error(unexpected(p.read().StringValue(), "code block"))
+		return node, true
+	}
+	node.Block = block
+
+	p.checkCommentForNode(node, false)
+
+	return node, true
+}
+
 func (p *Parser) parseIfStatement() (node *ast.IfStatement, nodeOk bool) {
 	token := p.read()
 	if token.Type == scanner.TokenTypeIdent && token.Text == keywordIf {
@@ -104,7 +162,9 @@ func (p *Parser) parseIfStatement() (node *ast.IfStatement, nodeOk bool) {
 
 		p.checkCommentForNode(node, false)
 
+		p.noStructExpression = true
 		condition, statementok := p.parseExpression() // Condition
+		p.noStructExpression = false
 		if !statementok {
 			p.error(unexpected(p.read().StringValue(), "expression"))
 			return
@@ -271,6 +331,11 @@ func (p *Parser) parseTupleDeclaration(isConstant bool) (tupleDecl *ast.TupleDec
 		return
 	}
 
+	if assertion, isAssertion := expr.(*ast.TypeAssertion); isAssertion {
+		// var (v, ok) = x.(Foo) reports a failed assertion in ok
+		assertion.Ok = true
+	}
+
 	tupleDecl.DefaultValue = expr
 
 	return
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
@@ -129,6 +130,33 @@ func TestParseInterface(t *testing.T) {
 	}
 }
 
+func TestParseInterfaceEmbedding(t *testing.T) {
+	file, err := Parse(strings.NewReader(`
+		interface ReadWriter {
+			Reader
+			fn write(data : string)
+			Writer
+		}
+	`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val, ok := file.Body[0].(*ast.Interface)
+	if !ok {
+		t.Fatal("Wrong type")
+	}
+
+	if len(val.Functions) != 1 {
+		t.Error("Wrong number of functions")
+	}
+
+	if len(val.Embedded) != 2 || val.Embedded[0].Name.Text != "Reader" || val.Embedded[1].Name.Text != "Writer" {
+		t.Errorf("Wrong embedded interfaces %v", val.Embedded)
+	}
+}
+
 func TestParseIfElseIfCondition(t *testing.T) {
 	_, err := Parse(strings.NewReader(`
 		fn foobar() {
@@ -328,3 +356,138 @@ func TestParseFunctionReturnStatement(t *testing.T) {
 		t.Error("Wrong expression found")
 	}
 }
+
+func TestParseForInLoop(t *testing.T) {
+	file, err := Parse(strings.NewReader(`
+		fn foobar() {
+			for key, value in values {}
+			for key in keys(Foo{}) {}
+			for var i = 0; i < 10; i++ {}
+		}
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := file.Body[0].(*ast.FunctionDeclaration).Block.Body
+
+	loop, ok := body[0].(*ast.ForInLoop)
+	if !ok {
+		t.Fatalf("Wrong type %T", body[0])
+	}
+	if loop.Key.Text != "key" || loop.Value.Text != "value" || loop.Collection.(*ast.Identifier).Text != "values" {
+		t.Error("Loop could not be parsed")
+	}
+
+	loop, ok = body[1].(*ast.ForInLoop)
+	if !ok {
+		t.Fatalf("Wrong type %T", body[1])
+	}
+	if loop.Value != nil {
+		t.Error("Value should be omitted")
+	}
+	if _, ok := loop.Collection.(*ast.FunctionCall).Arguments[0].Expression.(*ast.StructExpression); !ok {
+		t.Error("Struct expressions should be allowed inside calls")
+	}
+
+	if _, ok := body[2].(*ast.ForLoop); !ok {
+		t.Errorf("Wrong type %T", body[2])
+	}
+}
+
+func TestParseEnum(t *testing.T) {
+	file, err := Parse(strings.NewReader(`
+		enum Shape {
+			Circle(float64)
+			Rect(float64, float64),
+			Empty
+		}
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enum, ok := file.Body[0].(*ast.Enum)
+	if !ok {
+		t.Fatal("Wrong type")
+	}
+
+	if enum.Name.Text != "Shape" {
+		t.Errorf("Wrong name %s", enum.Name.Text)
+	}
+
+	if len(enum.Variants) != 3 {
+		t.Fatalf("Expected 3 variants got %d", len(enum.Variants))
+	}
+
+	for i, count := range []int{1, 2, 0} {
+		if len(enum.Variants[i].Types) != count {
+			t.Errorf("Variant %s should have %d values", enum.Variants[i].Name, count)
+		}
+	}
+}
+
+func TestParseGenerics(t *testing.T) {
+	file, err := Parse(strings.NewReader(`
+		struct Pair<A, B : Stringer> {
+			var first : A
+			var second : B
+		}
+		fn first<A, B>(p : Pair<A, B>) => A {
+			return p.first
+		}
+		fn main() {
+			var p = Pair<int32, Foo>{1, Foo{}}
+			if first(p) < 2 {
+				first(Pair{1, Foo{}})
+			}
+			first<int32, Foo>(p)
+			if a < b > (c) {
+			}
+		}
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	structDecl := file.Body[0].(*ast.Struct)
+	if len(structDecl.TypeParameters) != 2 {
+		t.Fatalf("Expected 2 type parameters got %d", len(structDecl.TypeParameters))
+	}
+	if structDecl.TypeParameters[0].Constraint != nil {
+		t.Error("A should not have a constraint")
+	}
+	if structDecl.TypeParameters[1].String() != "B : Stringer" {
+		t.Errorf("Wrong type parameter %s", structDecl.TypeParameters[1])
+	}
+
+	fnDecl := file.Body[1].(*ast.FunctionDeclaration)
+	if len(fnDecl.Signature.TypeParameters) != 2 {
+		t.Fatalf("Expected 2 type parameters got %d", len(fnDecl.Signature.TypeParameters))
+	}
+	if fmt.Sprint(fnDecl.Signature.Arguments[0].Type) != "Pair<A, B>" {
+		t.Errorf("Wrong argument type %s", fnDecl.Signature.Arguments[0].Type)
+	}
+
+	body := file.Body[2].(*ast.FunctionDeclaration).Block.Body
+	structExpr := body[0].(*ast.VariableDeclaration).DefaultValue.(*ast.StructExpression)
+	if len(structExpr.TypeArguments) != 2 || fmt.Sprint(structExpr.TypeArguments[1]) != "Foo" {
+		t.Errorf("Wrong type arguments %s", structExpr.TypeArguments)
+	}
+
+	ifStmt := body[1].(*ast.IfStatement)
+	if _, ok := ifStmt.Condition.(*ast.ComparisonExpression); !ok {
+		t.Errorf("Comparison was parsed as %T", ifStmt.Condition)
+	}
+
+	call := body[2].(*ast.FunctionCall)
+	if call.String() != "first<int32, Foo>(p)" {
+		t.Errorf("Wrong call %s", call)
+	}
+
+	// a < b > (c) is a call with type arguments rather than two comparisons
+	ifStmt = body[3].(*ast.IfStatement)
+	if _, ok := ifStmt.Condition.(*ast.FunctionCall); !ok {
+		t.Errorf("Call with type arguments was parsed as %T", ifStmt.Condition)
+	}
+}
@@ -16,6 +16,10 @@ func (p *Parser) parseStruct() (node *ast.Struct, ok bool) {
 		identifier, _ := p.parseIdentfier()
 		node.Name = identifier
 
+		if params, paramsOk := p.parseTypeParameters(); paramsOk {
+			node.TypeParameters = params
+		}
+
 		if leftBrace, leftBraceOk := p.expectToken(scanner.TokenTypeLBRACE); leftBraceOk {
 			node.Start = ast.StartPositionFromToken(leftBrace)
 		} else {
@@ -24,6 +28,7 @@ func (p *Parser) parseStruct() (node *ast.Struct, ok bool) {
 		}
 
 		for {
+			memberStart := len(p.expanded)
 			if varDecl, varDeclOk := p.parseVarDecl(); varDeclOk {
 				if varDecl, varDeclOk := varDecl.(*ast.VariableDeclaration); varDeclOk {
 					node.Variables = append(node.Variables, varDecl)
@@ -35,6 +40,7 @@ func (p *Parser) parseStruct() (node *ast.Struct, ok bool) {
 			} else {
 				break
 			}
+			p.markStatement(memberStart)
 		}
 
 		if rightBrace, rightBraceOk := p.expectToken(scanner.TokenTypeRBRACE); rightBraceOk {
@@ -53,8 +59,15 @@ func (p *Parser) parseStruct() (node *ast.Struct, ok bool) {
 
 func (p *Parser) parseStructExpression(expr ast.Expression) (node *ast.StructExpression, ok bool) {
 	ident, ok := expr.(*ast.Identifier)
-	if !ok {
-		return
+	if !ok || p.noStructExpression {
+		return nil, false
+	}
+
+	var typeArgs []ast.Type
+	if p.startsTypeArguments(scanner.TokenTypeLBRACE) {
+		if typeArgs, _, ok = p.parseTypeArguments(); !ok {
+			return
+		}
 	}
 
 	_, ok = p.expectToken(scanner.TokenTypeLBRACE)
@@ -79,8 +92,9 @@ func (p *Parser) parseStructExpression(expr ast.Expression) (node *ast.StructExp
 	}
 
 	node = &ast.StructExpression{
-		Identifier: ident,
-		Arguments:  args,
+		Identifier:    ident,
+		TypeArguments: typeArgs,
+		Arguments:     args,
 	}
 
 	token, rBraceOk := p.expectToken(scanner.TokenTypeRBRACE)
@@ -88,6 +102,7 @@ func (p *Parser) parseStructExpression(expr ast.Expression) (node *ast.StructExp
 		p.error(unexpectedToken(token, scanner.TokenTypeRBRACE))
 		return
 	}
+	node.End = ast.EndPositionFromToken(token)
 	return
 }
 
@@ -109,11 +124,15 @@ func (p *Parser) parseInterface() (node *ast.Interface, ok bool) {
 		}
 
 		for {
+			memberStart := len(p.expanded)
 			if funcSig, funcSigOk := p.parseFuncSignature(); funcSigOk {
 				node.Functions = append(node.Functions, funcSig)
+			} else if embedded, embeddedOk := p.parseTypeReference(); embeddedOk {
+				node.Embedded = append(node.Embedded, embedded.(*ast.TypeReference))
 			} else {
 				break
 			}
+			p.markStatement(memberStart)
 		}
 
 		if rightBrace, rightBraceOk := p.expectToken(scanner.TokenTypeRBRACE); rightBraceOk {
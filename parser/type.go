@@ -6,7 +6,11 @@ import (
 )
 
 func (p *Parser) parseType() (typ ast.Type, ok bool) {
-	if typ, ok = p.parseTypeReference(); ok {
+	if typ, ok = p.parseMacroSubstitutionType(); ok {
+		return
+	} else if typ, ok = p.parseMapType(); ok {
+		return
+	} else if typ, ok = p.parseTypeReference(); ok {
 		return
 	} else if typ, ok = p.parseTupleOrSignatureType(); ok {
 		if tuple, tupleOk := typ.(*ast.TupleType); tupleOk {
@@ -18,6 +22,8 @@ func (p *Parser) parseType() (typ ast.Type, ok bool) {
 		return
 	} else if typ, ok = p.parseArrayType(); ok {
 		return
+	} else if typ, ok = p.parsePointerType(); ok {
+		return
 	}
 
 	return
@@ -35,7 +41,13 @@ func (p *Parser) parseTypeReference() (typ ast.Type, ok bool) {
 		p.error(reservedKeywordError(token))
 	}
 
-	typ = &ast.TypeReference{Name: &ast.Identifier{Token: token}}
+	ref := &ast.TypeReference{Name: &ast.Identifier{Token: token}}
+	if args, end, argsOk := p.parseTypeArguments(); argsOk {
+		ref.TypeArguments = args
+		ref.End = ast.EndPositionFromToken(end)
+	}
+
+	typ = ref
 
 	return
 }
@@ -165,3 +177,74 @@ parseType:
 
 	return
 }
+
+func (p *Parser) parseMapType() (node *ast.MapType, ok bool) {
+	mapToken, ok := p.expectToken(scanner.TokenTypeIdent)
+	if !ok || mapToken.Text != keywordMap {
+		p.unread()
+		return nil, false
+	}
+
+	leftToken, leftTokenOk := p.expectToken(scanner.TokenTypeLBRACK)
+	if !leftTokenOk {
+		p.error(unexpectedToken(leftToken, scanner.TokenTypeLBRACK))
+		return
+	}
+
+	key, keyOk := p.parseType()
+	if !keyOk {
+		p.error(unexpected(p.read().StringValue(), "map key type"))
+		return
+	}
+
+	rightToken, rightTokenOk := p.expectToken(scanner.TokenTypeRBRACK)
+	if !rightTokenOk {
+		p.error(unexpectedToken(rightToken, scanner.TokenTypeRBRACK))
+		return
+	}
+
+	value, valueOk := p.parseType()
+	if !valueOk {
+		p.error(unexpected(p.read().StringValue(), "map value type"))
+		return
+	}
+
+	node = &ast.MapType{
+		Map:          mapToken,
+		LeftBracket:  leftToken,
+		RightBracket: rightToken,
+		Key:          key,
+		Value:        value,
+	}
+
+	return
+}
+
+func (p *Parser) parsePointerType() (node *ast.PointerType, ok bool) {
+	starToken, ok := p.expectToken(scanner.TokenTypeASTERISK)
+	if !ok {
+		p.unread()
+		return nil, false
+	}
+
+	// *const T points to a value that is not modified through the pointer
+	constToken, constOk := p.expectToken(scanner.TokenTypeIdent)
+	constOk = constOk && constToken.Text == keywordConst
+	if !constOk {
+		p.unread()
+	}
+
+	elem, elemOk := p.parseType()
+	if !elemOk {
+		p.error(unexpected(p.read().StringValue(), "pointer element type"))
+		return nil, false
+	}
+
+	node = &ast.PointerType{
+		Star:  starToken,
+		Const: constOk,
+		Elem:  elem,
+	}
+
+	return
+}
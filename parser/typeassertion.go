@@ -0,0 +1,155 @@
+package parser
+
+import (
+	"github.com/orktes/orlang/ast"
+	"github.com/orktes/orlang/scanner"
+)
+
+// typeSwitchType is the type name used in the subject of a type switch
+const typeSwitchType = "type"
+
+func (p *Parser) parseTypeAssertion(target ast.Expression) (node *ast.TypeAssertion, ok bool) {
+	tokens := p.peekMultiple(2)
+	if tokens[0].Type != scanner.TokenTypePERIOD || tokens[1].Type != scanner.TokenTypeLPAREN {
+		return
+	}
+	p.skipMultiple(2)
+
+	ok = true
+	node = &ast.TypeAssertion{Expression: target}
+
+	if token := p.read(); token.Type == scanner.TokenTypeIdent && token.Text == typeSwitchType {
+		if !p.typeSwitchSubject {
+			p.error("use of .(type) outside type switch")
+			return
+		}
+		// The type of the value is checked by the cases of the type switch
+	} else {
+		p.unread()
+
+		typ, typOk := p.parseType()
+		if !typOk {
+			p.error(unexpected(p.read().StringValue(), "type"))
+			return
+		}
+		node.Type = typ
+	}
+
+	rParen, rParenOk := p.expectToken(scanner.TokenTypeRPAREN)
+	if !rParenOk {
+		p.error(unexpectedToken(rParen, scanner.TokenTypeRPAREN))
+		return
+	}
+	node.End = ast.EndPositionFromToken(rParen)
+
+	return
+}
+
+func (p *Parser) parseTypeSwitch() (node *ast.TypeSwitch, ok bool) {
+	token := p.read()
+	if token.Type != scanner.TokenTypeIdent || token.Text != keywordSwitch {
+		p.unread()
+		return
+	}
+
+	ok = true
+	node = &ast.TypeSwitch{Start: ast.StartPositionFromToken(token)}
+
+	if varToken := p.read(); varToken.Type == scanner.TokenTypeIdent && varToken.Text == keywordVar {
+		binding, bindingOk := p.parseIdentfier()
+		if !bindingOk {
+			p.error(unexpected(p.read().StringValue(), "identifier"))
+			return
+		}
+		node.Binding = binding
+
+		if assign, assignOk := p.expectToken(scanner.TokenTypeASSIGN); !assignOk {
+			p.error(unexpectedToken(assign, scanner.TokenTypeASSIGN))
+			return
+		}
+	} else {
+		p.unread()
+	}
+
+	noStructExpression := p.noStructExpression
+	defer func() {
+		p.noStructExpression = noStructExpression
+		p.typeSwitchSubject = false
+	}()
+
+	p.noStructExpression = true
+	p.typeSwitchSubject = true
+	subject, subjectOk := p.parseExpression()
+	p.noStructExpression = false
+	p.typeSwitchSubject = false
+	if !subjectOk {
+		p.error(unexpected(p.read().StringValue(), "expression"))
+		return
+	}
+
+	assertion, isAssertion := subject.(*ast.TypeAssertion)
+	if !isAssertion || assertion.Type != nil {
+		p.error(unexpected(p.lastToken().StringValue(), ".(type)"))
+		return
+	}
+	node.Subject = assertion.Expression
+
+	if leftBrace, leftBraceOk := p.expectToken(scanner.TokenTypeLBRACE); !leftBraceOk {
+		p.error(unexpectedToken(leftBrace, scanner.TokenTypeLBRACE))
+		return
+	}
+
+	for {
+		caseStart := len(p.expanded)
+		c, caseOk := p.parseTypeSwitchCase()
+		if !caseOk {
+			break
+		}
+		node.Cases = append(node.Cases, c)
+		p.markStatement(caseStart)
+	}
+
+	if rightBrace, rightBraceOk := p.expectToken(scanner.TokenTypeRBRACE); rightBraceOk {
+		node.End = ast.EndPositionFromToken(rightBrace)
+	} else {
+		p.error(unexpectedToken(rightBrace, scanner.TokenTypeRBRACE))
+	}
+
+	return
+}
+
+func (p *Parser) parseTypeSwitchCase() (node *ast.TypeSwitchCase, ok bool) {
+	token := p.read()
+	if token.Type != scanner.TokenTypeIdent || (token.Text != keywordCase && token.Text != keywordDefault) {
+		p.unread()
+		return
+	}
+
+	ok = true
+	node = &ast.TypeSwitchCase{Start: ast.StartPositionFromToken(token)}
+
+	if token.Text == keywordCase {
+		for {
+			typ, typOk := p.parseType()
+			if !typOk {
+				p.error(unexpected(p.read().StringValue(), "type"))
+				return
+			}
+			node.Types = append(node.Types, typ)
+
+			if _, commaOk := p.expectToken(scanner.TokenTypeCOMMA); !commaOk {
+				p.unread()
+				break
+			}
+		}
+	}
+
+	block, blockOk := p.parseBlock()
+	if !blockOk {
+		p.error(unexpected(p.read().StringValue(), "code block"))
+		return
+	}
+	node.Block = block
+
+	return
+}
@@ -40,3 +40,12 @@ func tupplePatternToTupleExpression(pattern *ast.TuplePattern) (*ast.TupleExpres
 
 	return nil, nil
 }
+
+func isValueType(typ scanner.TokenType) bool {
+	for _, valueType := range valueTypes {
+		if typ == valueType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+// Package project reads orlang.yaml project manifests and resolves imports
+// between the source roots and dependencies of a project
+package project
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+)
+
+// ManifestName is the file name of a project manifest
+const ManifestName = "orlang.yaml"
+
+// Manifest describes an orlang project
+type Manifest struct {
+	// Name of the module. Imports starting with the name are resolved from the
+	// source roots of the project
+	Name string `mapstructure:"name"`
+	// Main is the file built when no files are given
+	Main string `mapstructure:"main"`
+	// Roots are the directories imports are resolved from
+	Roots []string `mapstructure:"roots"`
+	// Dependencies maps import prefixes to local project directories
+	Dependencies map[string]string `mapstructure:"dependencies"`
+
+	// Dir is the directory of the manifest. Paths in the manifest are relative to it
+	Dir string `mapstructure:"-"`
+}
+
+// ReadManifest reads the manifest in path with v
+func ReadManifest(v *viper.Viper, path string) (*Manifest, error) {
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	if err := v.Unmarshal(manifest); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	if manifest.Name == "" {
+		return nil, fmt.Errorf("%s: module name is missing", path)
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	manifest.Dir = dir
+
+	if len(manifest.Roots) == 0 {
+		manifest.Roots = []string{"."}
+	}
+
+	return manifest, nil
+}
+
+// FindManifest returns the path of the manifest in dir or its closest parent
+// directory. An empty path is returned if there is no manifest
+func FindManifest(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		path := filepath.Join(dir, ManifestName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// MainFile returns the path of the main file or an empty string if the
+// manifest has no main file
+func (m *Manifest) MainFile() string {
+	if m.Main == "" {
+		return ""
+	}
+	return relativePath(m.path(m.Main))
+}
+
+// path returns the absolute path of a path in the manifest
+func (m *Manifest) path(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(m.Dir, path)
+}
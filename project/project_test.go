@@ -0,0 +1,126 @@
+package project
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func readManifest(t *testing.T, path string) *Manifest {
+	manifest, err := ReadManifest(viper.New(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return manifest
+}
+
+func TestReadManifest(t *testing.T) {
+	manifest := readManifest(t, "testdata/app/orlang.yaml")
+
+	if manifest.Name != "example/app" {
+		t.Errorf("Wrong module name %s", manifest.Name)
+	}
+
+	if len(manifest.Roots) != 1 || manifest.Roots[0] != "src" {
+		t.Errorf("Wrong source roots %v", manifest.Roots)
+	}
+
+	if manifest.Dependencies["math"] != "../mathlib" {
+		t.Errorf("Wrong dependencies %v", manifest.Dependencies)
+	}
+
+	if manifest.MainFile() != filepath.Join("testdata", "app", "src", "main.or") {
+		t.Errorf("Wrong main file %s", manifest.MainFile())
+	}
+
+	if _, err := ReadManifest(viper.New(), "testdata/noname/orlang.yaml"); err == nil || err.Error() != "testdata/noname/orlang.yaml: module name is missing" {
+		t.Errorf("Expected missing name error got %v", err)
+	}
+}
+
+func TestFindManifest(t *testing.T) {
+	path, err := FindManifest("testdata/app/src/util")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, _ := filepath.Abs("testdata/app/orlang.yaml")
+	if path != expected {
+		t.Errorf("Expected %s got %s", expected, path)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	resolver, err := NewResolver(readManifest(t, "testdata/app/orlang.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	program, err := resolver.Load(resolver.Main.MainFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		filepath.Join("testdata", "mathlib", "lib", "ops.or"),
+		filepath.Join("testdata", "app", "src", "util", "format.or"),
+		filepath.Join("testdata", "app", "src", "main.or"),
+	}
+
+	if len(program.Files) != len(expected) {
+		t.Fatalf("Expected %d files got %d", len(expected), len(program.Files))
+	}
+
+	for i, file := range program.Files {
+		if file.Filename != expected[i] {
+			t.Errorf("Expected file %d to be %s got %s", i, expected[i], file.Filename)
+		}
+	}
+
+	tests := []struct {
+		importer string
+		path     string
+		result   string
+		err      string
+	}{
+		{"testdata/app/src/main.or", "./util/format", "testdata/app/src/util/format.or", ""},
+		{"testdata/app/src/util/format.or", "example/app/main.or", "testdata/app/src/main.or", ""},
+		{"testdata/app/src/util/format.or", "format", "testdata/app/src/util/format.or", ""},
+		{"testdata/app/src/util/format.or", "util/format", "testdata/app/src/util/format.or", ""},
+		{"testdata/app/src/main.or", "math/missing", "", `could not resolve import "math/missing" from the source roots of mathlib`},
+		// Dependencies of the app are not visible to the dependency
+		{"testdata/mathlib/lib/ops.or", "math/ops", "", `could not resolve import "math/ops" from the source roots of mathlib`},
+	}
+
+	for _, test := range tests {
+		result, err := resolver.Resolve(test.importer, test.path)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("Expected error %q for %s got %v", test.err, test.path, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %s", test.path, err)
+			continue
+		}
+
+		if result != filepath.FromSlash(test.result) {
+			t.Errorf("Expected %s to resolve to %s got %s", test.path, test.result, result)
+		}
+	}
+}
+
+func TestResolveCycle(t *testing.T) {
+	resolver, err := NewResolver(readManifest(t, "testdata/cycle/orlang.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resolver.Load(resolver.Main.MainFile())
+	if err == nil || err.Error() != "testdata/cycle/b.or:1:1: import cycle: testdata/cycle/a.or -> testdata/cycle/b.or -> testdata/cycle/a.or" {
+		t.Errorf("Expected import cycle error got %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/orktes/orlang/loader"
+	"github.com/spf13/viper"
+)
+
+// Resolver resolves imports of a project and its local dependencies
+type Resolver struct {
+	Main *Manifest
+	// dependencies of each project by import prefix
+	dependencies map[*Manifest]map[string]*Manifest
+	// projects by directory
+	projects map[string]*Manifest
+}
+
+// NewResolver returns a resolver for the project described by manifest. The
+// manifests of dependencies are read recursively. Dependencies without a
+// manifest use their directory as the only source root
+func NewResolver(manifest *Manifest) (*Resolver, error) {
+	r := &Resolver{
+		Main:         manifest,
+		dependencies: map[*Manifest]map[string]*Manifest{},
+		projects:     map[string]*Manifest{manifest.Dir: manifest},
+	}
+
+	if err := r.readDependencies(manifest); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *Resolver) readDependencies(manifest *Manifest) error {
+	deps := map[string]*Manifest{}
+	r.dependencies[manifest] = deps
+
+	for name, path := range manifest.Dependencies {
+		dir, err := filepath.Abs(manifest.path(path))
+		if err != nil {
+			return err
+		}
+
+		if dep, ok := r.projects[dir]; ok {
+			deps[name] = dep
+			continue
+		}
+
+		info, err := os.Stat(dir)
+		if err != nil {
+			return fmt.Errorf("dependency %s: %s", name, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("dependency %s: %s is not a directory", name, path)
+		}
+
+		dep := &Manifest{Name: name, Dir: dir, Roots: []string{"."}}
+		manifestPath := filepath.Join(dir, ManifestName)
+		if _, err := os.Stat(manifestPath); err == nil {
+			dep, err = ReadManifest(viper.New(), manifestPath)
+			if err != nil {
+				return err
+			}
+		}
+
+		r.projects[dir] = dep
+		deps[name] = dep
+
+		if err := r.readDependencies(dep); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Resolve returns the path of the file imported with path from the file
+// importer. Paths starting with ./ or ../ are relative to the importer. Paths
+// starting with the name of a dependency are resolved from the source roots of
+// the dependency and paths starting with the module name from the source roots
+// of the project. Other paths are relative to the importer or any of the
+// source roots of the project the importer belongs to
+func (r *Resolver) Resolve(importer string, path string) (string, error) {
+	if strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") {
+		return loader.ImportPath(importer, path), nil
+	}
+
+	project, err := r.project(importer)
+	if err != nil {
+		return "", err
+	}
+
+	// The longest matching dependency prefix wins
+	var depPrefix string
+	var dep *Manifest
+	for prefix, d := range r.dependencies[project] {
+		if strings.HasPrefix(path, prefix+"/") && len(prefix) > len(depPrefix) {
+			depPrefix, dep = prefix, d
+		}
+	}
+	if dep != nil {
+		return r.find(dep, strings.TrimPrefix(path, depPrefix+"/"), path)
+	}
+
+	if strings.HasPrefix(path, project.Name+"/") {
+		return r.find(project, strings.TrimPrefix(path, project.Name+"/"), path)
+	}
+
+	if relative := loader.ImportPath(importer, path); exists(relative) {
+		return relative, nil
+	}
+
+	return r.find(project, path, path)
+}
+
+// Load loads the program in path resolving imports with the resolver. Files of
+// the program are ordered so that every file comes after its imports
+func (r *Resolver) Load(path string) (*loader.Program, error) {
+	return (&loader.Loader{Resolve: r.Resolve}).Load(path)
+}
+
+// project returns the project the file in path belongs to
+func (r *Resolver) project(path string) (*Manifest, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The project with the deepest directory containing the file
+	var found *Manifest
+	for dir, project := range r.projects {
+		if (path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))) &&
+			(found == nil || len(dir) > len(found.Dir)) {
+			found = project
+		}
+	}
+
+	if found == nil {
+		return r.Main, nil
+	}
+	return found, nil
+}
+
+// find searches path from the source roots of project
+func (r *Resolver) find(project *Manifest, path string, importPath string) (string, error) {
+	if filepath.Ext(path) == "" {
+		path += loader.Extension
+	}
+
+	for _, root := range project.Roots {
+		candidate := filepath.Join(project.path(root), path)
+		if exists(candidate) {
+			return relativePath(candidate), nil
+		}
+	}
+
+	return "", fmt.Errorf("could not resolve import %q from the source roots of %s", importPath, project.Name)
+}
+
+func exists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// relativePath returns path relative to the working directory if it is
+// inside of it
+func relativePath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+
+	rel, err := filepath.Rel(wd, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return rel
+}
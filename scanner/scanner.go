@@ -76,7 +76,7 @@ func (s *Scanner) Scan() (token Token) {
 		s.unread()
 		t, text = s.scanWhitespace()
 
-	case isLetter(ch):
+	case isLetter(ch) || ch == '_':
 		s.unread()
 		t, text, val = s.scanIdent()
 		if t == TokenTypeIdent {
@@ -166,6 +166,15 @@ var tests = []struct {
 			Token{Type: TokenTypeEOF, StartColumn: 16, StartLine: 3},
 		},
 	},
+	{
+		src: "_ _foo",
+		results: []Token{
+			Token{Type: TokenTypeIdent, StartColumn: 0, Text: `_`},
+			Token{Type: TokenTypeWhitespace, StartColumn: 1, Text: ` `},
+			Token{Type: TokenTypeIdent, StartColumn: 2, Text: `_foo`},
+			Token{Type: TokenTypeEOF, StartColumn: 6, Text: ``},
+		},
+	},
 	{
 		src: "/* eof ending block comments wont work",
 		results: []Token{
@@ -164,6 +164,34 @@ type Token struct {
 	StartColumn int
 	EndLine     int
 	EndColumn   int
+	// Mark is the syntax context of a token introduced by a macro expansion.
+	// Tokens written by the user have no mark
+	Mark int
+	// Expansion is the macro expansion the token was produced by. Positions
+	// of expanded tokens are the positions of the macro call
+	Expansion *Expansion
+}
+
+// Expansion is a macro call a token was expanded from
+type Expansion struct {
+	// Macro is the name token of the expanded macro
+	Macro Token
+	// Call is the name token of the macro call. Calls inside other macros
+	// have an expansion of their own
+	Call Token
+	// Filename is the file the macro is declared in. Empty for macros
+	// declared in the file being parsed without a name
+	Filename string
+	// Line and Column are the position of the token in the macro definition
+	Line   int
+	Column int
+}
+
+func (e *Expansion) String() string {
+	if e.Filename != "" {
+		return fmt.Sprintf("in expansion of macro %s defined at %s:%d:%d", e.Macro.Text, e.Filename, e.Line+1, e.Column+1)
+	}
+	return fmt.Sprintf("in expansion of macro %s defined at %d:%d", e.Macro.Text, e.Line+1, e.Column+1)
 }
 
 func (t Token) StringValue() string {
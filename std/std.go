@@ -0,0 +1,17 @@
+// Package std contains the standard library files programs can import with
+// the std/ prefix
+package std
+
+import "embed"
+
+// Prefix is the prefix of imports of the standard library
+const Prefix = "std/"
+
+//go:embed *.or
+var files embed.FS
+
+// ReadFile returns the source of a standard library file. Name is given
+// without the std/ prefix
+func ReadFile(name string) ([]byte, error) {
+	return files.ReadFile(name)
+}
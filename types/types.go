@@ -25,6 +25,26 @@ var (
 	AnyType     = registerType("anything", &InterfaceType{Name: "anything"})
 )
 
+// Short names of the primitive types and the C types used in extern
+// declarations
+var (
+	_ = registerType("i64", Int64Type)
+	_ = registerType("i32", Int32Type)
+	_ = registerType("i16", Int16Type)
+	_ = registerType("i8", Int8Type)
+	_ = registerType("u64", UInt64Type)
+	_ = registerType("u32", UInt32Type)
+	_ = registerType("u16", UInt16Type)
+	_ = registerType("u8", UInt8Type)
+	_ = registerType("f64", Float64Type)
+	_ = registerType("f32", Float32Type)
+	_ = registerType("c_int", Int32Type)
+)
+
+// CStringTypeName is the name of C characters. *const c_string is the C
+// representation of strings and has the type string
+const CStringTypeName = "c_string"
+
 var buildInMethods = []struct {
 	Name string
 	Type *SignatureType
@@ -160,6 +180,12 @@ type SignatureType struct {
 	ReturnType    Type
 	ArgumentNames []string
 	Extern        bool
+	// Variadic functions accept any number of arguments of any type after
+	// the ones in ArgumentTypes
+	Variadic bool
+	// TypeParameters are set for generic functions which have to be
+	// instantiated before they can be called
+	TypeParameters []*TypeParameterType
 }
 
 func (st *SignatureType) GetName() string {
@@ -168,6 +194,9 @@ func (st *SignatureType) GetName() string {
 	for _, arg := range st.ArgumentTypes {
 		names = append(names, arg.GetName())
 	}
+	if st.Variadic {
+		names = append(names, "...")
+	}
 
 	returnTypeStr := "void"
 
@@ -197,7 +226,7 @@ func (st *SignatureType) IsEqual(aType Type) bool {
 		thisTypes := st.ArgumentTypes
 		aTypes := signType.ArgumentTypes
 
-		if len(aTypes) != len(thisTypes) {
+		if len(aTypes) != len(thisTypes) || st.Variadic != signType.Variadic {
 			return false
 		}
 
@@ -247,6 +276,51 @@ func (at *ArrayType) IsEqual(aType Type) bool {
 	return false
 }
 
+type MapType struct {
+	Key   Type
+	Value Type
+}
+
+func (mt *MapType) GetName() string {
+	return fmt.Sprintf("map[%s]%s", mt.Key.GetName(), mt.Value.GetName())
+}
+
+func (mt *MapType) IsEqual(aType Type) bool {
+	aType = LazyResolve(aType)
+
+	if mt == aType {
+		return true
+	}
+
+	if mapType, ok := aType.(*MapType); ok {
+		return mt.Key.IsEqual(mapType.Key) && mt.Value.IsEqual(mapType.Value)
+	}
+
+	return false
+}
+
+type PointerType struct {
+	Elem Type
+}
+
+func (pt *PointerType) GetName() string {
+	return fmt.Sprintf("*%s", pt.Elem.GetName())
+}
+
+func (pt *PointerType) IsEqual(aType Type) bool {
+	aType = LazyResolve(aType)
+
+	if pt == aType {
+		return true
+	}
+
+	if pointerType, ok := aType.(*PointerType); ok {
+		return pt.Elem.IsEqual(pointerType.Elem)
+	}
+
+	return false
+}
+
 type StructType struct {
 	Name      string
 	Variables []struct {
@@ -423,6 +497,70 @@ func (st *InterfaceType) HasFunction(member string) (bool, Type) {
 	return false, nil
 }
 
+// EnumVariant is a variant of an enum and the types of the values it carries
+type EnumVariant struct {
+	Name  string
+	Types []Type
+}
+
+type EnumType struct {
+	Name     string
+	Variants []EnumVariant
+}
+
+func (et *EnumType) GetName() string {
+	return "enum " + et.Name
+}
+
+// IsEqual compares enums by identity. Each enum declaration has a single
+// type so enums with the same name and variants are still different types
+func (et *EnumType) IsEqual(aType Type) bool {
+	return et == LazyResolve(aType)
+}
+
+// Variant returns the variant with the given name or nil if there is none
+func (et *EnumType) Variant(name string) *EnumVariant {
+	for i := range et.Variants {
+		if et.Variants[i].Name == name {
+			return &et.Variants[i]
+		}
+	}
+	return nil
+}
+
+// TypeParameterType is a type parameter of a generic function or struct.
+// Values of it can only be used through the methods of its constraint
+type TypeParameterType struct {
+	Name       string
+	Constraint *InterfaceType
+}
+
+func (tp *TypeParameterType) GetName() string {
+	return tp.Name
+}
+
+func (tp *TypeParameterType) IsEqual(aType Type) bool {
+	return tp == LazyResolve(aType)
+}
+
+func (tp *TypeParameterType) HasMember(member string) (bool, Type) {
+	return tp.HasFunction(member)
+}
+
+func (tp *TypeParameterType) GetMembers() []Member {
+	if tp.Constraint == nil {
+		return nil
+	}
+	return tp.Constraint.GetMembers()
+}
+
+func (tp *TypeParameterType) HasFunction(member string) (bool, Type) {
+	if tp.Constraint == nil {
+		return false, nil
+	}
+	return tp.Constraint.HasFunction(member)
+}
+
 type LazyType struct {
 	Resolver func() Type
 }
@@ -458,6 +596,66 @@ func LazyResolve(t Type) Type {
 	return t
 }
 
+// IsNumeric returns true for integer and floating point types
+func IsNumeric(t Type) bool {
+	switch LazyResolve(t) {
+	case Int64Type, Int32Type, Int16Type, Int8Type,
+		UInt64Type, UInt32Type, UInt16Type, UInt8Type,
+		Float64Type, Float32Type:
+		return true
+	}
+	return false
+}
+
+// IsInteger returns true for signed and unsigned integer types
+func IsInteger(t Type) bool {
+	return IsNumeric(t) && !Float32Type.IsEqual(t) && !Float64Type.IsEqual(t)
+}
+
+// IsComparable returns true for types whose values can be compared by value
+// and used as map keys
+func IsComparable(t Type) bool {
+	switch LazyResolve(t).(type) {
+	case *PointerType:
+		// Pointers are compared by identity
+		return true
+	}
+
+	switch LazyResolve(t) {
+	case StringType, BoolType:
+		return true
+	}
+	return IsNumeric(t)
+}
+
+// IsAssignable returns true if a value of type from can be assigned to a
+// variable of type to. Unlike IsEqual it is not symmetric: arrays of unknown
+// length cannot be assigned to fixed length arrays
+func IsAssignable(to Type, from Type) bool {
+	if !to.IsEqual(from) {
+		return false
+	}
+
+	toPointer, toOk := LazyResolve(to).(*PointerType)
+	fromPointer, fromOk := LazyResolve(from).(*PointerType)
+	if toOk && fromOk {
+		// Values are written through pointers in both directions
+		return IsAssignable(toPointer.Elem, fromPointer.Elem) && IsAssignable(fromPointer.Elem, toPointer.Elem)
+	}
+
+	toArray, toOk := LazyResolve(to).(*ArrayType)
+	fromArray, fromOk := LazyResolve(from).(*ArrayType)
+	if !toOk || !fromOk {
+		return true
+	}
+
+	if toArray.Length > -1 && fromArray.Length == -1 {
+		return false
+	}
+
+	return IsAssignable(toArray.Type, fromArray.Type)
+}
+
 func registerType(name string, typ Type) Type {
 	Types[name] = typ
 	return typ